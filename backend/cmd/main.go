@@ -1,25 +1,124 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"chainwave/backend/internal/handlers"
 	"chainwave/backend/config"
+	"chainwave/backend/internal/geocoding"
+	"chainwave/backend/internal/jobs"
 	"chainwave/backend/internal/middleware"
+	"chainwave/backend/internal/money"
+	"chainwave/backend/internal/payments"
+	"chainwave/backend/internal/realtime"
+	"chainwave/backend/internal/repository"
 )
 
 func main() {
+	// Prices are in the sellers' currencies, and sellers who haven't picked one sell in the payments currency
+	paymentCurrency := getEnv("PAYMENTS_CURRENCY", "INR")
+	if !money.Valid(paymentCurrency) {
+		log.Fatalf("PAYMENTS_CURRENCY %q is not a supported currency", paymentCurrency)
+	}
+
 	// Initialize the database
 	log.Println("DATABASE_URL: ", os.Getenv("DATABASE_URL"))
-	db, err := config.InitDB(os.Getenv("DATABASE_URL"))
+	db, err := config.InitDB(os.Getenv("DATABASE_URL"), paymentCurrency)
 	if (err != nil) {
 		log.Fatal(err)
 	}
 	defer db.Close()
 
+	// Start the real-time hub, fed by Postgres notifications so events reach clients on every replica
+	hub := realtime.NewHub()
+	if err := hub.Listen(db, os.Getenv("DATABASE_URL")); err != nil {
+		log.Fatal(err)
+	}
+	jobs.RunEvery("prune realtime events", 10*time.Minute, func() error {
+		if _, err := realtime.PruneEvents(db, time.Hour); err != nil {
+			return err
+		}
+		_, err := repository.PruneEventTickets(db)
+		return err
+	})
+
+	// Recompute vehicle capacity from active shipments in case it drifted
+	jobs.RunEvery("reconcile vehicle capacity", 10*time.Minute, func() error {
+		corrected, err := repository.ReconcileVehicleCapacity(db)
+		if corrected > 0 {
+			log.Printf("Corrected current capacity of %d vehicles", corrected)
+		}
+		return err
+	})
+
+	// Drop guest carts nobody has touched in a month, and empty ones left for a day
+	jobs.RunEvery("prune guest carts", 6*time.Hour, func() error {
+		pruned, err := repository.PruneGuestCarts(db, 30*24*time.Hour, 24*time.Hour)
+		if pruned > 0 {
+			log.Printf("Pruned %d abandoned guest carts", pruned)
+		}
+		return err
+	})
+
+	// Responses to requests made with an Idempotency-Key are replayed to retries for a day
+	idempotencyTTL := 24 * time.Hour
+	jobs.RunEvery("prune idempotency keys", time.Hour, func() error {
+		_, err := repository.PruneIdempotencyKeys(db)
+		return err
+	})
+
+	// Geocode locations when a Nominatim-compatible server is configured, otherwise only validate coordinates
+	var geocoder geocoding.Geocoder
+	if geocoderURL := os.Getenv("GEOCODER_URL"); geocoderURL != "" {
+		geocoder = &geocoding.Cached{
+			Geocoder: geocoding.NewNominatim(geocoderURL, "ChainWave/1.0"),
+			Cache:    repository.GeocodeCache{DB: db},
+		}
+	}
+
+	// Take payments through Razorpay, or through the fake provider for local development when PAYMENTS_PROVIDER=fake.
+	// Every secret must be set, as signatures checked against an empty or well-known key could be forged.
+	var paymentProvider payments.Provider
+	switch provider := getEnv("PAYMENTS_PROVIDER", "razorpay"); provider {
+	case "razorpay":
+		keyID, keySecret, webhookSecret := os.Getenv("RAZORPAY_KEY_ID"), os.Getenv("RAZORPAY_KEY_SECRET"), os.Getenv("RAZORPAY_WEBHOOK_SECRET")
+		if keyID == "" || keySecret == "" || webhookSecret == "" {
+			log.Fatal("RAZORPAY_KEY_ID, RAZORPAY_KEY_SECRET and RAZORPAY_WEBHOOK_SECRET must be set, or PAYMENTS_PROVIDER=fake for local development")
+		}
+		paymentProvider = payments.NewRazorpay(keyID, keySecret, webhookSecret)
+	case "fake":
+		secret := os.Getenv("PAYMENTS_FAKE_SECRET")
+		if secret == "" {
+			log.Fatal("PAYMENTS_FAKE_SECRET must be set to use the fake payment provider")
+		}
+		log.Println("Using the fake payment provider, orders can be paid without taking any money")
+		paymentProvider = &payments.Fake{Secret: secret}
+	default:
+		log.Fatalf("PAYMENTS_PROVIDER %q is not razorpay or fake", provider)
+	}
+
+	// Send refunds the payment provider never answered again, say because the server stopped or the request
+	// timed out. The provider recognises a refund it already made by its idempotency key.
+	jobs.RunEvery("resubmit stalled refunds", 5*time.Minute, func() error {
+		resubmitted, err := handlers.ResubmitStalledRefunds(context.Background(), db, paymentProvider, 15*time.Minute)
+		if resubmitted > 0 {
+			log.Printf("Resubmitted %d stalled refunds", resubmitted)
+		}
+		return err
+	})
+
+	// Show prices in the currency customers choose, with rates from EXCHANGE_RATES such as "USD=0.012,EUR=0.011",
+	// each the amount of that currency one unit of the payments currency buys
+	exchangeRates, err := money.ParseStaticRates(paymentCurrency, os.Getenv("EXCHANGE_RATES"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// Create a Gin router
 	router := gin.Default()
 
@@ -43,24 +142,35 @@ func main() {
 	authRoutes.Use(middleware.RoleSwitchMiddleware(db))
 
 	// Routes that require JWT authentication
-	authRoutes.POST("/customer", func(c *gin.Context) { handlers.AddCustomerHandler(db, c) })
+	authRoutes.POST("/customer", func(c *gin.Context) { handlers.AddCustomerHandler(db, geocoder, c) })
 	authRoutes.PUT("/customer/:id", func(c *gin.Context) { handlers.EditCustomerHandler(db, c) })
-	authRoutes.POST("/business-admin", func(c *gin.Context) { handlers.AddBusinessAdminHandler(db, c) })
+	authRoutes.POST("/business-admin", func(c *gin.Context) { handlers.AddBusinessAdminHandler(db, geocoder, paymentCurrency, c) })
 	authRoutes.PUT("/business-admin/:id", func(c *gin.Context) { handlers.EditBusinessAdminHandler(db, c) })
-	authRoutes.POST("/transporter", func(c *gin.Context) { handlers.AddTransporterHandler(db, c) })
+	authRoutes.POST("/transporter", func(c *gin.Context) { handlers.AddTransporterHandler(db, geocoder, c) })
 	authRoutes.PUT("/transporter/:id", func(c *gin.Context) { handlers.EditTransporterHandler(db, c) })
-	authRoutes.POST("/supplier", func(c *gin.Context) { handlers.AddSupplierHandler(db, c) })
+	authRoutes.POST("/supplier", func(c *gin.Context) { handlers.AddSupplierHandler(db, geocoder, c) })
 	authRoutes.PUT("/supplier/:id", func(c *gin.Context) { handlers.EditSupplierHandler(db, c) })
 	authRoutes.GET("/role", func(c *gin.Context) { handlers.GetRolesHandler(db, c) })
 
+	// Address book routes
+	authRoutes.POST("/locations", func(c *gin.Context) { handlers.AddLocationHandler(db, geocoder, c) })
+	authRoutes.GET("/locations", func(c *gin.Context) { handlers.GetLocationsHandler(db, c) })
+	authRoutes.GET("/locations/:id", func(c *gin.Context) { handlers.GetLocationHandler(db, c) })
+	authRoutes.PUT("/locations/:id", func(c *gin.Context) { handlers.EditLocationHandler(db, geocoder, c) })
+	authRoutes.DELETE("/locations/:id", func(c *gin.Context) { handlers.DeleteLocationHandler(db, c) })
+	authRoutes.PUT("/locations/:id/default", func(c *gin.Context) { handlers.SetDefaultShippingLocationHandler(db, c) })
+
 	// Routes to update email,username and password for a user
 	authRoutes.PUT("/user/email", func(c *gin.Context) { handlers.UpdateEmailHandler(db, c) })
 	authRoutes.PUT("/user/username", func(c *gin.Context) { handlers.UpdateUsernameHandler(db, c) })
 	authRoutes.PUT("/user/password", func(c *gin.Context) { handlers.UpdatePasswordHandler(db, c) })
+	authRoutes.PUT("/user/units", func(c *gin.Context) { handlers.UpdateUnitSystemHandler(db, c) })
+	authRoutes.PUT("/user/currency", func(c *gin.Context) { handlers.UpdateCurrencyHandler(db, c) })
 
     // Authenticated routes for roles and puts role ids in the context
 	authRoleRoutes := router.Group("/api/roles")
 	authRoleRoutes.Use(middleware.AuthAdminMiddleware("your_secret_key", db)) // Replace with your actual secret key
+	authRoleRoutes.Use(middleware.IdempotencyMiddleware(db, idempotencyTTL))
 
 	// Item-related routes
 	itemRoutes := authRoleRoutes.Group("/items")
@@ -77,12 +187,173 @@ func main() {
 		category := c.Query("category")
 		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
 		offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
-		handlers.GetItemsByCategoryHandler(db, c, category, limit, offset)
+		handlers.GetItemsByCategoryHandler(db, exchangeRates, c, category, limit, offset)
 	})
 
 	// Route that gets an item by its ID
-	itemRoutes.GET("/:id", func(c *gin.Context) { handlers.GetItemHandler(db, c) })
+	itemRoutes.GET("/:id", func(c *gin.Context) { handlers.GetItemHandler(db, exchangeRates, c) })
+
+	// Item reviews by customers who've had them delivered, seller replies and moderation flags
+	reviewRoutes := authRoleRoutes.Group("/reviews")
+	reviewRoutes.POST("/", func(c *gin.Context) { handlers.CreateReviewHandler(db, c) })
+	reviewRoutes.GET("/", func(c *gin.Context) { handlers.GetItemReviewsHandler(db, c) })
+	reviewRoutes.GET("/received", func(c *gin.Context) { handlers.GetSellerReviewsHandler(db, c) })
+	reviewRoutes.PUT("/:id", func(c *gin.Context) { handlers.UpdateReviewHandler(db, c) })
+	reviewRoutes.DELETE("/:id", func(c *gin.Context) { handlers.DeleteReviewHandler(db, c) })
+	reviewRoutes.PUT("/:id/reply", func(c *gin.Context) { handlers.ReplyToReviewHandler(db, c) })
+	reviewRoutes.POST("/:id/flags", func(c *gin.Context) { handlers.FlagReviewHandler(db, c) })
+
+	// Moderation of flagged reviews by the admin user
+	moderationRoutes := router.Group("/api/moderation")
+	moderationRoutes.Use(middleware.AuthMiddleware("your_secret_key")) // Replace with your actual secret key
+	moderationRoutes.Use(middleware.ModeratorMiddleware(db))
+	moderationRoutes.GET("/reviews", func(c *gin.Context) { handlers.GetModerationQueueHandler(db, c) })
+	moderationRoutes.GET("/reviews/:id/flags", func(c *gin.Context) { handlers.GetReviewFlagsHandler(db, c) })
+	moderationRoutes.PUT("/reviews/:id", func(c *gin.Context) { handlers.ModerateReviewHandler(db, c) })
+
+	// Customers' wishlists and back-in-stock subscriptions
+	wishlistRoutes := authRoleRoutes.Group("/wishlist")
+	wishlistRoutes.GET("/", func(c *gin.Context) { handlers.GetWishlistHandler(db, exchangeRates, c) })
+	wishlistRoutes.PUT("/:itemId", func(c *gin.Context) { handlers.AddWishlistItemHandler(db, c) })
+	wishlistRoutes.DELETE("/:itemId", func(c *gin.Context) { handlers.RemoveWishlistItemHandler(db, c) })
+	stockSubscriptionRoutes := authRoleRoutes.Group("/stock-subscriptions")
+	stockSubscriptionRoutes.GET("/", func(c *gin.Context) { handlers.GetStockSubscriptionsHandler(db, c) })
+	stockSubscriptionRoutes.PUT("/:itemId", func(c *gin.Context) { handlers.SubscribeToStockHandler(db, c) })
+	stockSubscriptionRoutes.DELETE("/:itemId", func(c *gin.Context) { handlers.UnsubscribeFromStockHandler(db, c) })
+	stockNotificationRoutes := authRoleRoutes.Group("/stock-notifications")
+	stockNotificationRoutes.GET("/", func(c *gin.Context) { handlers.GetStockNotificationsHandler(db, c) })
+	stockNotificationRoutes.PUT("/:id/read", func(c *gin.Context) { handlers.MarkStockNotificationReadHandler(db, c) })
+
+	// Directory routes, searchable by distance with lat, lon and radius
+	authRoleRoutes.GET("/suppliers", func(c *gin.Context) { handlers.GetSuppliersHandler(db, c) })
+	authRoleRoutes.GET("/business-admins", func(c *gin.Context) { handlers.GetBusinessAdminsHandler(db, c) })
+
+	// Warehouse-related routes
+	warehouseRoutes := authRoleRoutes.Group("/warehouses")
+	warehouseRoutes.POST("/", func(c *gin.Context) { handlers.AddWarehouseHandler(db, geocoder, c) })
+	warehouseRoutes.GET("/", func(c *gin.Context) { handlers.GetWarehousesHandler(db, c) })
+	warehouseRoutes.POST("/transfers", func(c *gin.Context) { handlers.TransferStockHandler(db, c) })
+	warehouseRoutes.GET("/:id/stock", func(c *gin.Context) { handlers.GetWarehouseStockHandler(db, c) })
+	warehouseRoutes.PUT("/:id/stock", func(c *gin.Context) { handlers.SetWarehouseStockHandler(db, c) })
+	warehouseRoutes.GET("/:id/transfers", func(c *gin.Context) { handlers.GetStockTransfersHandler(db, c) })
+
+	// Order-related routes
+	orderRoutes := authRoleRoutes.Group("/orders")
+	orderRoutes.POST("/", func(c *gin.Context) { handlers.CreateOrderHandler(db, c) })
+	orderRoutes.GET("/", func(c *gin.Context) { handlers.GetOrdersHandler(db, c) })
+	orderRoutes.GET("/:id", func(c *gin.Context) { handlers.GetOrderHandler(db, c) })
+	orderRoutes.POST("/:id/cancel", func(c *gin.Context) { handlers.CancelOrderHandler(db, paymentProvider, c) })
+	orderRoutes.PUT("/:id/status", func(c *gin.Context) { handlers.UpdateOrderStatusHandler(db, paymentProvider, c) })
+	orderRoutes.GET("/:id/history", func(c *gin.Context) { handlers.GetOrderHistoryHandler(db, c) })
+	orderRoutes.POST("/:id/refunds", func(c *gin.Context) { handlers.RefundOrderHandler(db, paymentProvider, c) })
+	orderRoutes.GET("/:id/refunds", func(c *gin.Context) { handlers.GetOrderRefundsHandler(db, c) })
+	orderRoutes.POST("/:id/refunds/:refundId/retry", func(c *gin.Context) { handlers.RetryRefundHandler(db, paymentProvider, c) })
+	orderRoutes.GET("/:id/tax-summary", func(c *gin.Context) { handlers.GetOrderTaxSummaryHandler(db, c) })
+	orderRoutes.GET("/:id/documents", func(c *gin.Context) { handlers.GetOrderDocumentsHandler(db, c) })
+	orderRoutes.GET("/:id/documents/:sellerOrderId/invoice", func(c *gin.Context) { handlers.DownloadInvoiceHandler(db, c) })
+	orderRoutes.GET("/:id/documents/:sellerOrderId/packing-slip", func(c *gin.Context) { handlers.DownloadPackingSlipHandler(db, c) })
+
+	// Each seller's part of customer orders
+	sellerOrderRoutes := authRoleRoutes.Group("/seller-orders")
+	sellerOrderRoutes.GET("/", func(c *gin.Context) { handlers.GetSellerOrdersHandler(db, c) })
+	sellerOrderRoutes.GET("/:id", func(c *gin.Context) { handlers.GetSellerOrderHandler(db, c) })
+
+	// Sales and coupons business admins run on their items
+	promotionRoutes := authRoleRoutes.Group("/promotions")
+	promotionRoutes.POST("/sales", func(c *gin.Context) { handlers.CreateSaleHandler(db, c) })
+	promotionRoutes.GET("/sales", func(c *gin.Context) { handlers.GetSalesHandler(db, c) })
+	promotionRoutes.DELETE("/sales/:id", func(c *gin.Context) { handlers.DeleteSaleHandler(db, c) })
+	promotionRoutes.POST("/coupons", func(c *gin.Context) { handlers.CreateCouponHandler(db, c) })
+	promotionRoutes.GET("/coupons", func(c *gin.Context) { handlers.GetCouponsHandler(db, c) })
+	promotionRoutes.DELETE("/coupons/:id", func(c *gin.Context) { handlers.DeactivateCouponHandler(db, c) })
+
+	// Tax rules business admins charge on their sales
+	taxRoutes := authRoleRoutes.Group("/tax-rules")
+	taxRoutes.POST("/", func(c *gin.Context) { handlers.CreateTaxRuleHandler(db, c) })
+	taxRoutes.GET("/", func(c *gin.Context) { handlers.GetTaxRulesHandler(db, c) })
+	taxRoutes.DELETE("/:id", func(c *gin.Context) { handlers.DeleteTaxRuleHandler(db, c) })
+
+	// Shipment-related routes
+	shipmentRoutes := authRoleRoutes.Group("/shipments")
+	shipmentRoutes.POST("/", func(c *gin.Context) { handlers.CreateShipmentHandler(db, c) })
+	shipmentRoutes.GET("/", func(c *gin.Context) { handlers.GetShipmentsHandler(db, c) })
+	shipmentRoutes.GET("/available", func(c *gin.Context) { handlers.GetAvailableShipmentsHandler(db, c) })
+	shipmentRoutes.GET("/:id", func(c *gin.Context) { handlers.GetShipmentHandler(db, c) })
+	shipmentRoutes.GET("/:id/matches", func(c *gin.Context) { handlers.GetShipmentMatchesHandler(db, c) })
+	shipmentRoutes.POST("/:id/accept", func(c *gin.Context) { handlers.AcceptShipmentHandler(db, c) })
+	shipmentRoutes.POST("/:id/pickup", func(c *gin.Context) { handlers.PickUpShipmentHandler(db, c) })
+	shipmentRoutes.POST("/:id/deliver", func(c *gin.Context) { handlers.DeliverShipmentHandler(db, c) })
+	shipmentRoutes.POST("/:id/cancel", func(c *gin.Context) { handlers.CancelShipmentHandler(db, c) })
+
+	// Vehicle-related routes
+	vehicleRoutes := authRoleRoutes.Group("/vehicles")
+	vehicleRoutes.POST("/", func(c *gin.Context) { handlers.AddVehicleHandler(db, c) })
+	vehicleRoutes.GET("/", func(c *gin.Context) { handlers.GetVehiclesHandler(db, c) })
+	vehicleRoutes.PUT("/:id", func(c *gin.Context) { handlers.EditVehicleHandler(db, c) })
+	vehicleRoutes.DELETE("/:id", func(c *gin.Context) { handlers.RetireVehicleHandler(db, c) })
+	vehicleRoutes.PUT("/:id/status", func(c *gin.Context) { handlers.SetVehicleStatusHandler(db, c) })
+	vehicleRoutes.PUT("/:id/driver", func(c *gin.Context) { handlers.AssignVehicleDriverHandler(db, c) })
+	vehicleRoutes.GET("/:id/route", func(c *gin.Context) { handlers.GetVehicleRouteHandler(db, c) })
+	vehicleRoutes.POST("/:id/positions", func(c *gin.Context) { handlers.RecordVehiclePositionsHandler(db, c) })
+
+	// Customer-facing shipment tracking
+	trackingRoutes := router.Group("/api/shipments")
+	trackingRoutes.Use(middleware.AuthAdminMiddleware("your_secret_key", db)) // Replace with your actual secret key
+	trackingRoutes.GET("/:id/track", func(c *gin.Context) { handlers.TrackShipmentHandler(db, c) })
+
+	// Shipping quotes and transporter tariffs
+	shippingRoutes := router.Group("/api/shipping")
+	shippingRoutes.Use(middleware.AuthAdminMiddleware("your_secret_key", db)) // Replace with your actual secret key
+	shippingRoutes.POST("/quote", func(c *gin.Context) { handlers.QuoteShippingHandler(db, c) })
+	shippingRoutes.POST("/tariffs", func(c *gin.Context) { handlers.AddShippingTariffHandler(db, paymentCurrency, c) })
+	shippingRoutes.GET("/tariffs", func(c *gin.Context) { handlers.GetShippingTariffsHandler(db, c) })
+	shippingRoutes.DELETE("/tariffs/:id", func(c *gin.Context) { handlers.DeleteShippingTariffHandler(db, c) })
+
+	// Checkout and payments
+	checkoutRoutes := router.Group("/api/orders")
+	checkoutRoutes.Use(middleware.AuthAdminMiddleware("your_secret_key", db)) // Replace with your actual secret key
+	checkoutRoutes.Use(middleware.IdempotencyMiddleware(db, idempotencyTTL))
+	checkoutRoutes.POST("/create", func(c *gin.Context) { handlers.CheckoutHandler(db, paymentProvider, geocoder, paymentCurrency, c) })
+	checkoutRoutes.POST("/verify", func(c *gin.Context) { handlers.VerifyPaymentHandler(db, paymentProvider, c) })
+	checkoutRoutes.POST("/:id/pay", func(c *gin.Context) { handlers.PayOrderHandler(db, paymentProvider, paymentCurrency, c) })
+	router.POST("/api/payments/webhook", func(c *gin.Context) { handlers.PaymentWebhookHandler(db, paymentProvider, c) })
+
+	// Shopping cart, for customers and for guests by their X-Cart-Token
+	cartRoutes := authRoleRoutes.Group("/cart")
+	cartRoutes.GET("/", func(c *gin.Context) { handlers.GetCartHandler(db, c) })
+	cartRoutes.DELETE("/", func(c *gin.Context) { handlers.ClearCartHandler(db, c) })
+	cartRoutes.POST("/items", func(c *gin.Context) { handlers.AddCartItemHandler(db, c) })
+	cartRoutes.PUT("/items/:itemId", func(c *gin.Context) { handlers.UpdateCartItemHandler(db, c) })
+	cartRoutes.DELETE("/items/:itemId", func(c *gin.Context) { handlers.RemoveCartItemHandler(db, c) })
+	cartRoutes.PUT("/coupon", func(c *gin.Context) { handlers.ApplyCartCouponHandler(db, c) })
+	cartRoutes.DELETE("/coupon", func(c *gin.Context) { handlers.RemoveCartCouponHandler(db, c) })
+	cartRoutes.POST("/merge", func(c *gin.Context) { handlers.MergeCartHandler(db, c) })
+	cartRoutes.POST("/checkout", func(c *gin.Context) { handlers.CheckoutCartHandler(db, paymentProvider, paymentCurrency, c) })
+	guestCartRoutes := router.Group("/api/cart")
+	guestCartRoutes.GET("/", func(c *gin.Context) { handlers.GetCartHandler(db, c) })
+	guestCartRoutes.DELETE("/", func(c *gin.Context) { handlers.ClearCartHandler(db, c) })
+	guestCartRoutes.POST("/items", func(c *gin.Context) { handlers.AddCartItemHandler(db, c) })
+	guestCartRoutes.PUT("/items/:itemId", func(c *gin.Context) { handlers.UpdateCartItemHandler(db, c) })
+	guestCartRoutes.DELETE("/items/:itemId", func(c *gin.Context) { handlers.RemoveCartItemHandler(db, c) })
+	guestCartRoutes.PUT("/coupon", func(c *gin.Context) { handlers.ApplyCartCouponHandler(db, c) })
+	guestCartRoutes.DELETE("/coupon", func(c *gin.Context) { handlers.RemoveCartCouponHandler(db, c) })
+
+	// Real-time event stream, opened with a ticket from /api/events/tickets
+	eventRoutes := router.Group("/api/events")
+	eventRoutes.Use(middleware.EventTicketMiddleware(db))
+	eventRoutes.GET("", func(c *gin.Context) { handlers.StreamEventsHandler(hub, c) })
+	eventTicketRoutes := router.Group("/api/events/tickets")
+	eventTicketRoutes.Use(middleware.AuthAdminMiddleware("your_secret_key", db)) // Replace with your actual secret key
+	eventTicketRoutes.POST("", func(c *gin.Context) { handlers.CreateEventTicketHandler(db, c) })
 
 	// Start the server
 	log.Fatal(router.Run(":8000"))
 }
+
+// getEnv returns the value of an environment variable or fallback when it is unset
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
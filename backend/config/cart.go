@@ -0,0 +1,41 @@
+package config
+
+import "database/sql"
+
+// createCartTables creates the carts and cart_items tables. A cart belongs to a customer or, before
+// they log in, to a guest holding its token. Guest carts record the address they were created from so
+// creation can be rate limited.
+func createCartTables(db *sql.DB) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS carts (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			customer_id UUID UNIQUE,
+			guest_token TEXT UNIQUE,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			FOREIGN KEY (customer_id) REFERENCES customers(id) ON DELETE CASCADE,
+			CHECK (customer_id IS NOT NULL OR guest_token IS NOT NULL)
+		)`,
+		`CREATE TABLE IF NOT EXISTS cart_items (
+			cart_id UUID NOT NULL,
+			item_id UUID NOT NULL,
+			quantity INTEGER NOT NULL CHECK (quantity > 0),
+			added_price DOUBLE PRECISION NOT NULL,
+			added_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (cart_id, item_id),
+			FOREIGN KEY (cart_id) REFERENCES carts(id) ON DELETE CASCADE,
+			FOREIGN KEY (item_id) REFERENCES items(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_carts_guest_updated ON carts (updated_at) WHERE customer_id IS NULL`,
+		`ALTER TABLE carts ADD COLUMN IF NOT EXISTS created_ip TEXT`,
+		`CREATE INDEX IF NOT EXISTS idx_carts_guest_created_ip ON carts (created_ip, created_at) WHERE customer_id IS NULL`,
+	}
+
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -7,7 +7,8 @@ import (
 )
 
 // InitDB initializes the database connection and creates the users table if it doesn't exist.
-func InitDB(databaseURL string) (*sql.DB, error) {
+// Existing sellers and orders that predate currencies are given defaultCurrency.
+func InitDB(databaseURL string, defaultCurrency string) (*sql.DB, error) {
 	db, err := sql.Open("postgres", databaseURL)
 	if (err != nil) {
 		return nil, err
@@ -205,7 +206,8 @@ END $$;`)
 		business_admin_id UUID,
 		name TEXT NOT NULL,
 		description TEXT,
-		price DOUBLE PRECISION NOT NULL,
+		price_minor BIGINT NOT NULL CHECK (price_minor >= 0),
+		currency TEXT NOT NULL,
 		weight DOUBLE PRECISION NOT NULL,
 		dimensions TEXT,
 		category TEXT NOT NULL,
@@ -221,7 +223,12 @@ END $$;`)
 	_, err = db.Exec(`CREATE OR REPLACE FUNCTION notify_low_inventory() RETURNS trigger AS $$
 	BEGIN
 		IF NEW.quantity < 5 THEN
-			PERFORM pg_notify('inventory', 'Item ' || NEW.name || ' has low inventory: ' || NEW.quantity);
+			PERFORM pg_notify('inventory', json_build_object(
+				'item_id', NEW.id,
+				'business_admin_id', NEW.business_admin_id,
+				'name', NEW.name,
+				'quantity', NEW.quantity
+			)::text);
 		END IF;
 		RETURN NEW;
 	END;
@@ -230,6 +237,12 @@ END $$;`)
 		return nil, err
 	}
 	
+	// Recreate the trigger so restarting against an existing database doesn't fail
+	_, err = db.Exec(`DROP TRIGGER IF EXISTS check_inventory ON items`)
+	if err != nil {
+		return nil, err
+	}
+
 	_, err = db.Exec(`CREATE TRIGGER check_inventory
 	AFTER INSERT OR UPDATE ON items
 	FOR EACH ROW
@@ -287,7 +300,120 @@ END $$;`)
 		return nil, err
 	}
 
+	// Create the warehouse inventory tables
+	if err := createWarehouseTables(db); err != nil {
+		return nil, err
+	}
+
+	// Create the order tables
+	if err := createOrderTables(db); err != nil {
+		return nil, err
+	}
+
+	// Create the shipment tables
+	if err := createShipmentTables(db); err != nil {
+		return nil, err
+	}
+
+	// Create the vehicle tracking tables
+	if err := createTrackingTables(db); err != nil {
+		return nil, err
+	}
+
+	// Add the fleet management columns to vehicles
+	if err := createFleetColumns(db); err != nil {
+		return nil, err
+	}
+
+	// Create the geocoding cache
+	if err := createGeocodeTables(db); err != nil {
+		return nil, err
+	}
+
+	// Index locations for nearby searches
+	if err := createGeospatialIndexes(db); err != nil {
+		return nil, err
+	}
+
+	// Address book ownership of locations
+	if err := createAddressBookColumns(db); err != nil {
+		return nil, err
+	}
+
+	// Shipping tariffs and order shipping quotes
+	if err := createShippingTables(db); err != nil {
+		return nil, err
+	}
+
+	// Structured item dimensions and unit preferences
+	if err := createItemDimensionColumns(db); err != nil {
+		return nil, err
+	}
+
+	// Payments for orders
+	if err := createPaymentTables(db); err != nil {
+		return nil, err
+	}
 
+	// Order cancellations and refunds
+	if err := createRefundTables(db); err != nil {
+		return nil, err
+	}
+
+	// Shopping carts
+	if err := createCartTables(db); err != nil {
+		return nil, err
+	}
+
+	// Per-seller sub-orders
+	if err := createSellerOrderTables(db); err != nil {
+		return nil, err
+	}
+
+	// Order status history
+	if err := createOrderStatusTables(db); err != nil {
+		return nil, err
+	}
+
+	// Idempotency keys for retried requests
+	if err := createIdempotencyTables(db); err != nil {
+		return nil, err
+	}
+
+	// Sales, coupons and their redemptions
+	if err := createPromotionTables(db); err != nil {
+		return nil, err
+	}
+
+	// Prices in minor units and currencies
+	if err := createMoneyColumns(db, defaultCurrency); err != nil {
+		return nil, err
+	}
+
+	// Tax rules and the taxes charged on orders
+	if err := createTaxTables(db); err != nil {
+		return nil, err
+	}
+
+	// Invoices and packing slips
+	if err := createDocumentTables(db); err != nil {
+		return nil, err
+	}
+
+	// Item reviews, seller replies and moderation flags
+	if err := createReviewTables(db); err != nil {
+		return nil, err
+	}
+
+	// Wishlists and back-in-stock subscriptions
+	if err := createWishlistTables(db); err != nil {
+		return nil, err
+	}
+
+	// Events published to every replica
+	if err := createRealtimeTables(db); err != nil {
+		return nil, err
+	}
 
 	return db, nil
 }
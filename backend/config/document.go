@@ -0,0 +1,32 @@
+package config
+
+import "database/sql"
+
+// createDocumentTables creates the invoices and packing slips issued for seller orders and the counters
+// that number each seller's invoices in sequence. The PDFs themselves are kept on disk at path.
+func createDocumentTables(db *sql.DB) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS invoice_counters (
+			business_admin_id UUID PRIMARY KEY,
+			last_number BIGINT NOT NULL DEFAULT 0,
+			FOREIGN KEY (business_admin_id) REFERENCES business_admins(id) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE IF NOT EXISTS order_documents (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			seller_order_id UUID NOT NULL,
+			kind TEXT NOT NULL CHECK (kind IN ('invoice', 'packing_slip')),
+			number TEXT,
+			path TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			UNIQUE (seller_order_id, kind),
+			FOREIGN KEY (seller_order_id) REFERENCES seller_orders(id) ON DELETE CASCADE
+		)`,
+	}
+
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package config
+
+import "database/sql"
+
+// createFleetColumns adds availability, driver and retirement columns to vehicles and links
+// vehicles registered with a transporter through transporters.vehicle_id back to that transporter.
+func createFleetColumns(db *sql.DB) error {
+	statements := []string{
+		`ALTER TABLE vehicles ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'active'`,
+		`ALTER TABLE vehicles ADD COLUMN IF NOT EXISTS driver_name TEXT`,
+		`ALTER TABLE vehicles ADD COLUMN IF NOT EXISTS driver_contact TEXT`,
+		`ALTER TABLE vehicles ADD COLUMN IF NOT EXISTS retired_at TIMESTAMPTZ`,
+		`UPDATE vehicles v SET transporter_id = t.id FROM transporters t WHERE t.vehicle_id = v.id AND v.transporter_id IS NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_vehicles_transporter ON vehicles (transporter_id)`,
+	}
+
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package config
+
+import "database/sql"
+
+// createGeocodeTables creates the cache of resolved addresses.
+func createGeocodeTables(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS geocode_cache (
+		query TEXT PRIMARY KEY,
+		located BOOLEAN NOT NULL,
+		latitude FLOAT8 NOT NULL DEFAULT 0,
+		longitude FLOAT8 NOT NULL DEFAULT 0,
+		country_code TEXT NOT NULL DEFAULT '',
+		display_name TEXT NOT NULL DEFAULT '',
+		min_latitude FLOAT8 NOT NULL DEFAULT 0,
+		max_latitude FLOAT8 NOT NULL DEFAULT 0,
+		min_longitude FLOAT8 NOT NULL DEFAULT 0,
+		max_longitude FLOAT8 NOT NULL DEFAULT 0,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`)
+	return err
+}
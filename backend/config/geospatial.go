@@ -0,0 +1,9 @@
+package config
+
+import "database/sql"
+
+// createGeospatialIndexes indexes location coordinates for bounding box searches.
+func createGeospatialIndexes(db *sql.DB) error {
+	_, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_locations_lat_lon ON locations (latitude, longitude)`)
+	return err
+}
@@ -0,0 +1,30 @@
+package config
+
+import "database/sql"
+
+// createIdempotencyTables creates the idempotency_keys table holding the hash of each request made with
+// an Idempotency-Key header and the response it got, so retries are answered without running it again.
+// A key with no status code yet belongs to a request still being handled.
+func createIdempotencyTables(db *sql.DB) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS idempotency_keys (
+			scope TEXT NOT NULL,
+			key TEXT NOT NULL,
+			request_hash TEXT NOT NULL,
+			status_code INTEGER,
+			content_type TEXT NOT NULL DEFAULT '',
+			response_body BYTEA,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			expires_at TIMESTAMPTZ NOT NULL,
+			PRIMARY KEY (scope, key)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires ON idempotency_keys (expires_at)`,
+	}
+
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"chainwave/backend/internal/units"
+	"database/sql"
+	"log"
+
+	"github.com/google/uuid"
+)
+
+// createItemDimensionColumns adds structured dimensions to items and a display unit preference to
+// users, then parses the old free-text "LxWxH" dimensions into the new columns.
+func createItemDimensionColumns(db *sql.DB) error {
+	statements := []string{
+		`ALTER TABLE items ADD COLUMN IF NOT EXISTS length_cm DOUBLE PRECISION CHECK (length_cm > 0)`,
+		`ALTER TABLE items ADD COLUMN IF NOT EXISTS width_cm DOUBLE PRECISION CHECK (width_cm > 0)`,
+		`ALTER TABLE items ADD COLUMN IF NOT EXISTS height_cm DOUBLE PRECISION CHECK (height_cm > 0)`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS unit_system TEXT NOT NULL DEFAULT 'metric'`,
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return migrateItemDimensions(db)
+}
+
+// migrateItemDimensions fills the structured dimensions of items that only have the free-text ones.
+// Strings that can't be parsed are left for their seller to fix and are retried on every start.
+func migrateItemDimensions(db *sql.DB) error {
+	rows, err := db.Query(`SELECT id, dimensions FROM items WHERE length_cm IS NULL AND COALESCE(dimensions, '') <> ''`)
+	if err != nil {
+		return err
+	}
+
+	type legacyItem struct {
+		id         uuid.UUID
+		dimensions string
+	}
+	legacy := make([]legacyItem, 0)
+	for rows.Next() {
+		var item legacyItem
+		if err := rows.Scan(&item.id, &item.dimensions); err != nil {
+			rows.Close()
+			return err
+		}
+		legacy = append(legacy, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var skipped int
+	for _, item := range legacy {
+		parsed, err := units.ParseDimensions(item.dimensions)
+		if err == nil {
+			parsed, err = units.ConvertDimensions(parsed, units.Centimetre)
+		}
+		if err != nil {
+			skipped++
+			continue
+		}
+		_, err = db.Exec(`UPDATE items SET length_cm = $1, width_cm = $2, height_cm = $3 WHERE id = $4`,
+			parsed.Length, parsed.Width, parsed.Height, item.id)
+		if err != nil {
+			return err
+		}
+	}
+	if skipped > 0 {
+		log.Printf("Could not parse the dimensions of %d items", skipped)
+	}
+	return nil
+}
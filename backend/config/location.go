@@ -0,0 +1,26 @@
+package config
+
+import "database/sql"
+
+// createAddressBookColumns links locations to the user who owns them so they can be reused as saved
+// addresses, and backfills the owner of existing locations from the roles and warehouses using them.
+func createAddressBookColumns(db *sql.DB) error {
+	statements := []string{
+		`ALTER TABLE locations ADD COLUMN IF NOT EXISTS user_id UUID REFERENCES users(id)`,
+		`ALTER TABLE locations ADD COLUMN IF NOT EXISTS label TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE customers ADD COLUMN IF NOT EXISTS default_shipping_location_id UUID REFERENCES locations(id)`,
+		`UPDATE locations l SET user_id = r.user_id FROM customers r WHERE r.location_id = l.id AND l.user_id IS NULL`,
+		`UPDATE locations l SET user_id = r.user_id FROM business_admins r WHERE r.location_id = l.id AND l.user_id IS NULL`,
+		`UPDATE locations l SET user_id = r.user_id FROM transporters r WHERE r.location_id = l.id AND l.user_id IS NULL`,
+		`UPDATE locations l SET user_id = r.user_id FROM suppliers r WHERE r.location_id = l.id AND l.user_id IS NULL`,
+		`UPDATE locations l SET user_id = b.user_id FROM warehouses w JOIN business_admins b ON w.business_admin_id = b.id
+			WHERE w.location_id = l.id AND l.user_id IS NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_locations_user_id ON locations (user_id)`,
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
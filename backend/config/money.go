@@ -0,0 +1,200 @@
+package config
+
+import (
+	"chainwave/backend/internal/money"
+	"database/sql"
+	"math"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// createMoneyColumns gives business admins the currency they sell in and users the currency they view
+// prices in, and moves item prices from floating point to whole minor units in their seller's currency.
+// Orders record the currency they were charged in, with their amounts moved to minor units of it, and
+// shipping tariffs the currency they charge in. Existing sellers, orders and tariffs get defaultCurrency.
+func createMoneyColumns(db *sql.DB, defaultCurrency string) error {
+	statements := []string{
+		`ALTER TABLE business_admins ADD COLUMN IF NOT EXISTS currency TEXT`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS currency TEXT`,
+		`ALTER TABLE items ADD COLUMN IF NOT EXISTS price_minor BIGINT CHECK (price_minor >= 0)`,
+		`ALTER TABLE items ADD COLUMN IF NOT EXISTS currency TEXT`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS currency TEXT`,
+		`ALTER TABLE shipping_tariffs ADD COLUMN IF NOT EXISTS currency TEXT`,
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	defaults := []string{
+		`UPDATE business_admins SET currency = $1 WHERE currency IS NULL`,
+		`UPDATE items i SET currency = COALESCE((SELECT b.currency FROM business_admins b WHERE b.id = i.business_admin_id), $1)
+			WHERE currency IS NULL`,
+		`UPDATE orders SET currency = $1 WHERE currency IS NULL`,
+		`UPDATE shipping_tariffs SET currency = $1 WHERE currency IS NULL`,
+	}
+	for _, statement := range defaults {
+		if _, err := db.Exec(statement, defaultCurrency); err != nil {
+			return err
+		}
+	}
+
+	if err := migrateItemPrices(db); err != nil {
+		return err
+	}
+	if err := migrateOrderAmounts(db); err != nil {
+		return err
+	}
+
+	constraints := []string{
+		`ALTER TABLE business_admins ALTER COLUMN currency SET NOT NULL`,
+		`ALTER TABLE items ALTER COLUMN currency SET NOT NULL`,
+		`ALTER TABLE items ALTER COLUMN price_minor SET NOT NULL`,
+		`ALTER TABLE items DROP COLUMN IF EXISTS price`,
+		`ALTER TABLE orders ALTER COLUMN currency SET NOT NULL`,
+		`ALTER TABLE shipping_tariffs ALTER COLUMN currency SET NOT NULL`,
+	}
+	for _, statement := range constraints {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateItemPrices converts the floating point prices of items that don't have a minor unit price yet,
+// while the old price column is still there
+func migrateItemPrices(db *sql.DB) error {
+	var hasPrice bool
+	err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = 'items' AND column_name = 'price')`).Scan(&hasPrice)
+	if err != nil || !hasPrice {
+		return err
+	}
+
+	rows, err := db.Query(`SELECT id, price, currency FROM items WHERE price_minor IS NULL`)
+	if err != nil {
+		return err
+	}
+
+	type legacyItem struct {
+		id       uuid.UUID
+		price    float64
+		currency string
+	}
+	legacy := make([]legacyItem, 0)
+	for rows.Next() {
+		var item legacyItem
+		if err := rows.Scan(&item.id, &item.price, &item.currency); err != nil {
+			rows.Close()
+			return err
+		}
+		legacy = append(legacy, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, item := range legacy {
+		price := money.FromMajor(item.price, item.currency)
+		if _, err := db.Exec(`UPDATE items SET price_minor = $1 WHERE id = $2`, price.Amount, item.id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// orderAmounts are the amount columns of orders and the tables hanging off them, with the expression
+// finding the order each row belongs to
+var orderAmounts = []struct {
+	table   string
+	columns []string
+	orderID string
+}{
+	{"orders", []string{"total", "shipping_cost", "discount", "tax"}, "t.id"},
+	{"order_items", []string{"list_price", "price", "discount", "tax", "tax_exclusive"}, "t.order_id"},
+	{"order_item_taxes", []string{"taxable", "amount"}, "(SELECT oi.order_id FROM order_items oi WHERE oi.id = t.order_item_id)"},
+	{"seller_orders", []string{"subtotal", "tax", "tax_exclusive", "shipping_cost"}, "t.order_id"},
+	{"order_shipping", []string{"cost"}, "t.order_id"},
+	{"coupon_redemptions", []string{"discount"}, "t.order_id"},
+}
+
+// migrateOrderAmounts converts order amounts still stored as floating point to whole minor units of
+// their order's currency. Each table is rescaled and retyped in one transaction, so a table is either
+// converted completely or left as it was.
+func migrateOrderAmounts(db *sql.DB) error {
+	currencies, err := orderCurrencies(db)
+	if err != nil {
+		return err
+	}
+
+	for _, amounts := range orderAmounts {
+		rows, err := db.Query(`SELECT column_name FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = $1 AND column_name = ANY($2) AND data_type = 'double precision'`,
+			amounts.table, pq.Array(amounts.columns))
+		if err != nil {
+			return err
+		}
+		legacy := make([]string, 0)
+		for rows.Next() {
+			var column string
+			if err := rows.Scan(&column); err != nil {
+				rows.Close()
+				return err
+			}
+			legacy = append(legacy, column)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(legacy) == 0 {
+			continue
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		for _, column := range legacy {
+			for _, currency := range currencies {
+				if _, err := tx.Exec(`UPDATE `+amounts.table+` t SET `+column+` = ROUND(t.`+column+` * $1)
+					FROM orders o WHERE o.id = `+amounts.orderID+` AND o.currency = $2`,
+					math.Pow10(money.Exponent(currency)), currency); err != nil {
+					tx.Rollback()
+					return err
+				}
+			}
+			if _, err := tx.Exec(`ALTER TABLE ` + amounts.table + ` ALTER COLUMN ` + column + ` TYPE BIGINT USING ` + column + `::BIGINT`); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// orderCurrencies lists the currencies orders have been placed in
+func orderCurrencies(db *sql.DB) ([]string, error) {
+	rows, err := db.Query(`SELECT DISTINCT currency FROM orders`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	currencies := make([]string, 0)
+	for rows.Next() {
+		var currency string
+		if err := rows.Scan(&currency); err != nil {
+			return nil, err
+		}
+		currencies = append(currencies, currency)
+	}
+	return currencies, rows.Err()
+}
@@ -0,0 +1,40 @@
+package config
+
+import "database/sql"
+
+// createOrderTables creates the orders and order_items tables.
+func createOrderTables(db *sql.DB) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS orders (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			customer_id UUID NOT NULL,
+			location_id UUID NOT NULL,
+			status TEXT NOT NULL DEFAULT 'Pending',
+			total BIGINT NOT NULL DEFAULT 0,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			FOREIGN KEY (customer_id) REFERENCES customers(id),
+			FOREIGN KEY (location_id) REFERENCES locations(id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS order_items (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			order_id UUID NOT NULL,
+			item_id UUID NOT NULL,
+			warehouse_id UUID,
+			quantity INTEGER NOT NULL CHECK (quantity > 0),
+			price BIGINT NOT NULL,
+			FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE,
+			FOREIGN KEY (item_id) REFERENCES items(id),
+			FOREIGN KEY (warehouse_id) REFERENCES warehouses(id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_orders_customer ON orders (customer_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_order_items_order ON order_items (order_id)`,
+	}
+
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
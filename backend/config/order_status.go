@@ -0,0 +1,36 @@
+package config
+
+import "database/sql"
+
+// createOrderStatusTables creates the order_status_history table recording each status change of an order
+// or one of its seller orders, who made it and when. Orders placed before the history existed get a first
+// entry for the status they are in.
+func createOrderStatusTables(db *sql.DB) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS order_status_history (
+			id BIGSERIAL PRIMARY KEY,
+			order_id UUID NOT NULL,
+			seller_order_id UUID,
+			from_status TEXT NOT NULL DEFAULT '',
+			to_status TEXT NOT NULL,
+			actor_role TEXT NOT NULL,
+			actor_id UUID,
+			note TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE,
+			FOREIGN KEY (seller_order_id) REFERENCES seller_orders(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_order_status_history_order ON order_status_history (order_id, created_at)`,
+		`INSERT INTO order_status_history (order_id, to_status, actor_role, note, created_at)
+			SELECT o.id, o.status, 'system', 'recorded before status history', o.updated_at
+			FROM orders o
+			WHERE NOT EXISTS (SELECT 1 FROM order_status_history h WHERE h.order_id = o.id)`,
+	}
+
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package config
+
+import "database/sql"
+
+// createPaymentTables creates the payments table, one row per attempt to pay for an order. A payment
+// captured for an order that was already paid is kept as a duplicate of the one that paid it, alongside
+// the attempt it was made against when that one is the payment already captured.
+func createPaymentTables(db *sql.DB) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS payments (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			order_id UUID NOT NULL,
+			provider TEXT NOT NULL,
+			provider_order_id TEXT NOT NULL,
+			provider_payment_id TEXT UNIQUE,
+			amount BIGINT NOT NULL CHECK (amount > 0),
+			currency TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'created',
+			failure_reason TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			captured_at TIMESTAMPTZ,
+			FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_payments_order ON payments (order_id)`,
+		`ALTER TABLE payments ADD COLUMN IF NOT EXISTS duplicate_of UUID REFERENCES payments(id)`,
+		`ALTER TABLE payments DROP CONSTRAINT IF EXISTS payments_provider_order_id_key`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_payments_provider_order ON payments (provider_order_id) WHERE duplicate_of IS NULL`,
+		// At most one captured payment per order
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_payments_order_captured ON payments (order_id) WHERE status = 'captured'`,
+	}
+
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
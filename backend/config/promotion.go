@@ -0,0 +1,69 @@
+package config
+
+import "database/sql"
+
+// createPromotionTables creates the sales and coupons business admins run on their items and the record of
+// coupon redemptions, and adds the columns carts and orders keep their discounts in. Order lines keep the
+// list price they were sold against and their share of any coupon.
+func createPromotionTables(db *sql.DB) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS sales (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			business_admin_id UUID NOT NULL,
+			item_id UUID,
+			category TEXT,
+			kind TEXT NOT NULL CHECK (kind IN ('percentage', 'fixed')),
+			value DOUBLE PRECISION NOT NULL CHECK (value > 0),
+			starts_at TIMESTAMPTZ NOT NULL,
+			ends_at TIMESTAMPTZ NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			FOREIGN KEY (business_admin_id) REFERENCES business_admins(id) ON DELETE CASCADE,
+			FOREIGN KEY (item_id) REFERENCES items(id) ON DELETE CASCADE,
+			CHECK ((item_id IS NULL) <> (category IS NULL)),
+			CHECK (ends_at > starts_at)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_sales_business_admin ON sales (business_admin_id, ends_at)`,
+		`CREATE TABLE IF NOT EXISTS coupons (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			business_admin_id UUID NOT NULL,
+			code TEXT NOT NULL,
+			kind TEXT NOT NULL CHECK (kind IN ('percentage', 'fixed')),
+			value DOUBLE PRECISION NOT NULL CHECK (value > 0),
+			min_subtotal DOUBLE PRECISION NOT NULL DEFAULT 0,
+			starts_at TIMESTAMPTZ,
+			ends_at TIMESTAMPTZ,
+			max_uses INTEGER CHECK (max_uses > 0),
+			max_uses_per_customer INTEGER CHECK (max_uses_per_customer > 0),
+			stacks_with_sales BOOLEAN NOT NULL DEFAULT FALSE,
+			active BOOLEAN NOT NULL DEFAULT TRUE,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			FOREIGN KEY (business_admin_id) REFERENCES business_admins(id) ON DELETE CASCADE
+		)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_coupons_code ON coupons (UPPER(code))`,
+		`CREATE TABLE IF NOT EXISTS coupon_redemptions (
+			coupon_id UUID NOT NULL,
+			order_id UUID NOT NULL,
+			customer_id UUID NOT NULL,
+			discount BIGINT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (coupon_id, order_id),
+			FOREIGN KEY (coupon_id) REFERENCES coupons(id),
+			FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE,
+			FOREIGN KEY (customer_id) REFERENCES customers(id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_coupon_redemptions_customer ON coupon_redemptions (coupon_id, customer_id)`,
+		`ALTER TABLE carts ADD COLUMN IF NOT EXISTS coupon_code TEXT`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS discount BIGINT NOT NULL DEFAULT 0`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS coupon_id UUID REFERENCES coupons(id)`,
+		`ALTER TABLE order_items ADD COLUMN IF NOT EXISTS list_price BIGINT`,
+		`UPDATE order_items SET list_price = price WHERE list_price IS NULL`,
+		`ALTER TABLE order_items ADD COLUMN IF NOT EXISTS discount BIGINT NOT NULL DEFAULT 0`,
+	}
+
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
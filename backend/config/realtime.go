@@ -0,0 +1,36 @@
+package config
+
+import "database/sql"
+
+// createRealtimeTables creates the realtime_events table published events are kept in. Only an event's ID
+// is sent with NOTIFY, as its payload is limited to 8000 bytes, and each replica loads the event from here.
+// Events are pruned once every replica has had time to load them. Event tickets let browsers open an event
+// stream without putting their token in the URL, and are kept hashed until they are used or expire.
+func createRealtimeTables(db *sql.DB) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS realtime_events (
+			id UUID PRIMARY KEY,
+			type TEXT NOT NULL,
+			topics TEXT[] NOT NULL,
+			data JSONB NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_realtime_events_created ON realtime_events (created_at)`,
+		`CREATE TABLE IF NOT EXISTS event_tickets (
+			ticket_hash TEXT PRIMARY KEY,
+			user_id UUID NOT NULL,
+			roles TEXT[] NOT NULL,
+			role_types TEXT[] NOT NULL,
+			expires_at TIMESTAMPTZ NOT NULL,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_event_tickets_expires ON event_tickets (expires_at)`,
+	}
+
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package config
+
+import "database/sql"
+
+// createRefundTables creates the refunds, refund_items and refund_status_history tables and tracks how
+// much of each order line has been cancelled back into stock.
+func createRefundTables(db *sql.DB) error {
+	statements := []string{
+		`ALTER TABLE order_items ADD COLUMN IF NOT EXISTS cancelled_quantity INTEGER NOT NULL DEFAULT 0`,
+		`CREATE TABLE IF NOT EXISTS refunds (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			order_id UUID NOT NULL,
+			payment_id UUID NOT NULL,
+			provider_refund_id TEXT UNIQUE,
+			amount BIGINT NOT NULL CHECK (amount > 0),
+			currency TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			reason TEXT NOT NULL DEFAULT '',
+			initiated_by TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE,
+			FOREIGN KEY (payment_id) REFERENCES payments(id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS refund_items (
+			refund_id UUID NOT NULL,
+			order_item_id UUID NOT NULL,
+			quantity INTEGER NOT NULL CHECK (quantity > 0),
+			amount BIGINT NOT NULL,
+			restocked INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (refund_id, order_item_id),
+			FOREIGN KEY (refund_id) REFERENCES refunds(id) ON DELETE CASCADE,
+			FOREIGN KEY (order_item_id) REFERENCES order_items(id) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE IF NOT EXISTS refund_status_history (
+			id BIGSERIAL PRIMARY KEY,
+			refund_id UUID NOT NULL,
+			status TEXT NOT NULL,
+			note TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			FOREIGN KEY (refund_id) REFERENCES refunds(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_refunds_order ON refunds (order_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_refund_items_order_item ON refund_items (order_item_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_refund_status_history_refund ON refund_status_history (refund_id)`,
+	}
+
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package config
+
+import "database/sql"
+
+// createReviewTables creates customers' reviews of items they've had delivered, the flags users raise on
+// them and the item_ratings view listings read each item's average rating and review count from. Reviews
+// hidden by moderation don't count towards an item's rating. Flags record whether their author bought the
+// item, and reviews when a moderator last decided on them.
+func createReviewTables(db *sql.DB) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS reviews (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			item_id UUID NOT NULL,
+			customer_id UUID NOT NULL,
+			order_item_id UUID NOT NULL,
+			rating SMALLINT NOT NULL CHECK (rating BETWEEN 1 AND 5),
+			title TEXT NOT NULL DEFAULT '',
+			body TEXT NOT NULL DEFAULT '',
+			status TEXT NOT NULL DEFAULT 'published' CHECK (status IN ('published', 'hidden')),
+			seller_reply TEXT,
+			seller_replied_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			UNIQUE (item_id, customer_id),
+			FOREIGN KEY (item_id) REFERENCES items(id) ON DELETE CASCADE,
+			FOREIGN KEY (customer_id) REFERENCES customers(id),
+			FOREIGN KEY (order_item_id) REFERENCES order_items(id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_reviews_item ON reviews (item_id, created_at) WHERE status = 'published'`,
+		`CREATE TABLE IF NOT EXISTS review_flags (
+			review_id UUID NOT NULL,
+			user_id UUID NOT NULL,
+			reason TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (review_id, user_id),
+			FOREIGN KEY (review_id) REFERENCES reviews(id) ON DELETE CASCADE,
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		)`,
+		`ALTER TABLE review_flags ADD COLUMN IF NOT EXISTS verified_buyer BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE reviews ADD COLUMN IF NOT EXISTS moderated_at TIMESTAMPTZ`,
+		`ALTER TABLE reviews ADD COLUMN IF NOT EXISTS moderated_by UUID REFERENCES users(id)`,
+		`CREATE OR REPLACE VIEW item_ratings AS
+			SELECT item_id, ROUND(AVG(rating)::numeric, 2)::float8 AS rating, COUNT(*)::int AS review_count
+			FROM reviews
+			WHERE status = 'published'
+			GROUP BY item_id`,
+	}
+
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
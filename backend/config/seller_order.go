@@ -0,0 +1,52 @@
+package config
+
+import "database/sql"
+
+// createSellerOrderTables creates the seller_orders table, splitting each order into one sub-order per
+// business admin selling in it, and links order lines and shipments to their sub-order. Orders placed
+// before the split are backfilled with sub-orders in the order's own status.
+func createSellerOrderTables(db *sql.DB) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS seller_orders (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			order_id UUID NOT NULL,
+			business_admin_id UUID NOT NULL,
+			status TEXT NOT NULL DEFAULT 'Pending',
+			subtotal BIGINT NOT NULL DEFAULT 0,
+			shipping_cost BIGINT NOT NULL DEFAULT 0,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			UNIQUE (order_id, business_admin_id),
+			FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE,
+			FOREIGN KEY (business_admin_id) REFERENCES business_admins(id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_seller_orders_business_admin ON seller_orders (business_admin_id, created_at)`,
+		`ALTER TABLE order_items ADD COLUMN IF NOT EXISTS seller_order_id UUID REFERENCES seller_orders(id) ON DELETE CASCADE`,
+		`ALTER TABLE shipments ADD COLUMN IF NOT EXISTS seller_order_id UUID REFERENCES seller_orders(id)`,
+		`INSERT INTO seller_orders (order_id, business_admin_id, status, subtotal, shipping_cost, created_at, updated_at)
+			SELECT o.id, i.business_admin_id, o.status, SUM(oi.price * oi.quantity),
+				COALESCE((SELECT os.cost FROM order_shipping os WHERE os.order_id = o.id AND os.business_admin_id = i.business_admin_id), 0),
+				o.created_at, o.updated_at
+			FROM orders o
+			JOIN order_items oi ON oi.order_id = o.id
+			JOIN items i ON oi.item_id = i.id
+			WHERE oi.seller_order_id IS NULL
+			GROUP BY o.id, i.business_admin_id
+			ON CONFLICT (order_id, business_admin_id) DO NOTHING`,
+		`UPDATE order_items oi SET seller_order_id = so.id
+			FROM items i, seller_orders so
+			WHERE oi.item_id = i.id AND so.order_id = oi.order_id AND so.business_admin_id = i.business_admin_id
+				AND oi.seller_order_id IS NULL`,
+		`UPDATE shipments s SET seller_order_id = so.id
+			FROM seller_orders so
+			WHERE so.order_id = s.order_id AND so.business_admin_id = s.business_admin_id AND s.seller_order_id IS NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_order_items_seller_order ON order_items (seller_order_id)`,
+	}
+
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
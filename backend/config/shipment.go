@@ -0,0 +1,42 @@
+package config
+
+import "database/sql"
+
+// createShipmentTables creates the shipments table.
+func createShipmentTables(db *sql.DB) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS shipments (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			order_id UUID NOT NULL,
+			business_admin_id UUID NOT NULL,
+			pickup_location_id UUID NOT NULL,
+			drop_location_id UUID NOT NULL,
+			transporter_id UUID,
+			vehicle_id UUID,
+			weight DOUBLE PRECISION NOT NULL DEFAULT 0,
+			status TEXT NOT NULL DEFAULT 'pending',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			accepted_at TIMESTAMPTZ,
+			picked_up_at TIMESTAMPTZ,
+			delivered_at TIMESTAMPTZ,
+			cancelled_at TIMESTAMPTZ,
+			FOREIGN KEY (order_id) REFERENCES orders(id),
+			FOREIGN KEY (business_admin_id) REFERENCES business_admins(id),
+			FOREIGN KEY (pickup_location_id) REFERENCES locations(id),
+			FOREIGN KEY (drop_location_id) REFERENCES locations(id),
+			FOREIGN KEY (transporter_id) REFERENCES transporters(id),
+			FOREIGN KEY (vehicle_id) REFERENCES vehicles(id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_shipments_order ON shipments (order_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_shipments_transporter ON shipments (transporter_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_shipments_status ON shipments (status)`,
+	}
+
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
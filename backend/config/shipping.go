@@ -0,0 +1,58 @@
+package config
+
+import "database/sql"
+
+// createShippingTables creates the shipping tariffs, their distance bands and the shipping chosen for
+// each order, and seeds a platform tariff so orders can be quoted before any transporter adds one.
+func createShippingTables(db *sql.DB) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS shipping_tariffs (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			transporter_id UUID,
+			name TEXT NOT NULL,
+			base_fee DOUBLE PRECISION NOT NULL DEFAULT 0 CHECK (base_fee >= 0),
+			per_kg DOUBLE PRECISION NOT NULL DEFAULT 0 CHECK (per_kg >= 0),
+			active BOOLEAN NOT NULL DEFAULT TRUE,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			FOREIGN KEY (transporter_id) REFERENCES transporters(id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS shipping_tariff_bands (
+			tariff_id UUID NOT NULL,
+			max_distance_km DOUBLE PRECISION NOT NULL CHECK (max_distance_km > 0),
+			fee DOUBLE PRECISION NOT NULL DEFAULT 0 CHECK (fee >= 0),
+			transit_days INTEGER NOT NULL CHECK (transit_days >= 0),
+			PRIMARY KEY (tariff_id, max_distance_km),
+			FOREIGN KEY (tariff_id) REFERENCES shipping_tariffs(id) ON DELETE CASCADE
+		)`,
+		`WITH tariff AS (
+			INSERT INTO shipping_tariffs (name, base_fee, per_kg)
+			SELECT 'Standard', 2, 0.5 WHERE NOT EXISTS (SELECT 1 FROM shipping_tariffs)
+			RETURNING id
+		)
+		INSERT INTO shipping_tariff_bands (tariff_id, max_distance_km, fee, transit_days)
+		SELECT tariff.id, band.max_distance_km, band.fee, band.transit_days
+		FROM tariff, (VALUES (50.0, 3.0, 1), (250.0, 8.0, 2), (1000.0, 15.0, 4), (5000.0, 30.0, 7)) AS band (max_distance_km, fee, transit_days)`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS shipping_cost BIGINT NOT NULL DEFAULT 0`,
+		`CREATE TABLE IF NOT EXISTS order_shipping (
+			order_id UUID NOT NULL,
+			business_admin_id UUID NOT NULL,
+			tariff_id UUID NOT NULL,
+			distance_km DOUBLE PRECISION NOT NULL,
+			chargeable_weight DOUBLE PRECISION NOT NULL,
+			cost BIGINT NOT NULL,
+			transit_days INTEGER NOT NULL,
+			estimated_delivery TIMESTAMPTZ NOT NULL,
+			PRIMARY KEY (order_id, business_admin_id),
+			FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE,
+			FOREIGN KEY (business_admin_id) REFERENCES business_admins(id),
+			FOREIGN KEY (tariff_id) REFERENCES shipping_tariffs(id)
+		)`,
+	}
+
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package config
+
+import "database/sql"
+
+// createTaxTables creates the tax rules charged on order lines and the breakdown of the taxes each line was
+// charged. Rules without a business admin apply to every seller; sellers add their own for the jurisdictions
+// and categories they are registered for. Orders, seller orders and their lines keep their tax totals, with
+// tax_exclusive being the part added on top of the price.
+func createTaxTables(db *sql.DB) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS tax_rules (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			business_admin_id UUID,
+			name TEXT NOT NULL,
+			country TEXT NOT NULL,
+			state TEXT,
+			category TEXT,
+			scope TEXT NOT NULL DEFAULT 'any' CHECK (scope IN ('any', 'intrastate', 'interstate')),
+			rate DOUBLE PRECISION NOT NULL CHECK (rate > 0 AND rate <= 100),
+			inclusive BOOLEAN NOT NULL DEFAULT FALSE,
+			active BOOLEAN NOT NULL DEFAULT TRUE,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			FOREIGN KEY (business_admin_id) REFERENCES business_admins(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_tax_rules_country ON tax_rules (LOWER(country)) WHERE active`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS tax BIGINT NOT NULL DEFAULT 0`,
+		`ALTER TABLE seller_orders ADD COLUMN IF NOT EXISTS tax BIGINT NOT NULL DEFAULT 0`,
+		`ALTER TABLE seller_orders ADD COLUMN IF NOT EXISTS tax_exclusive BIGINT NOT NULL DEFAULT 0`,
+		`ALTER TABLE order_items ADD COLUMN IF NOT EXISTS tax BIGINT NOT NULL DEFAULT 0`,
+		`ALTER TABLE order_items ADD COLUMN IF NOT EXISTS tax_exclusive BIGINT NOT NULL DEFAULT 0`,
+		`CREATE TABLE IF NOT EXISTS order_item_taxes (
+			id BIGSERIAL PRIMARY KEY,
+			order_item_id UUID NOT NULL,
+			tax_rule_id UUID,
+			name TEXT NOT NULL,
+			jurisdiction TEXT NOT NULL,
+			rate DOUBLE PRECISION NOT NULL,
+			inclusive BOOLEAN NOT NULL,
+			taxable BIGINT NOT NULL,
+			amount BIGINT NOT NULL,
+			FOREIGN KEY (order_item_id) REFERENCES order_items(id) ON DELETE CASCADE,
+			FOREIGN KEY (tax_rule_id) REFERENCES tax_rules(id) ON DELETE SET NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_order_item_taxes_item ON order_item_taxes (order_item_id)`,
+	}
+
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package config
+
+import "database/sql"
+
+// createTrackingTables creates the vehicle_positions table and records when a vehicle's position was last updated.
+func createTrackingTables(db *sql.DB) error {
+	statements := []string{
+		`ALTER TABLE vehicles ADD COLUMN IF NOT EXISTS position_updated_at TIMESTAMPTZ`,
+		`CREATE TABLE IF NOT EXISTS vehicle_positions (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			vehicle_id UUID NOT NULL,
+			latitude FLOAT8 NOT NULL,
+			longitude FLOAT8 NOT NULL,
+			recorded_at TIMESTAMPTZ NOT NULL,
+			received_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			FOREIGN KEY (vehicle_id) REFERENCES vehicles(id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_vehicle_positions_vehicle_time ON vehicle_positions (vehicle_id, recorded_at)`,
+	}
+
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package config
+
+import "database/sql"
+
+// createWarehouseTables creates the warehouses, warehouse_stock and stock_transfers tables.
+// Stock levels are kept per warehouse and items.quantity is kept in sync as their total.
+func createWarehouseTables(db *sql.DB) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS warehouses (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			business_admin_id UUID NOT NULL,
+			name TEXT NOT NULL,
+			location_id UUID NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			FOREIGN KEY (business_admin_id) REFERENCES business_admins(id),
+			FOREIGN KEY (location_id) REFERENCES locations(id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS warehouse_stock (
+			warehouse_id UUID NOT NULL,
+			item_id UUID NOT NULL,
+			quantity INTEGER NOT NULL DEFAULT 0 CHECK (quantity >= 0),
+			PRIMARY KEY (warehouse_id, item_id),
+			FOREIGN KEY (warehouse_id) REFERENCES warehouses(id),
+			FOREIGN KEY (item_id) REFERENCES items(id) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE IF NOT EXISTS stock_transfers (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			item_id UUID NOT NULL,
+			from_warehouse_id UUID NOT NULL,
+			to_warehouse_id UUID NOT NULL,
+			quantity INTEGER NOT NULL CHECK (quantity > 0),
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			FOREIGN KEY (item_id) REFERENCES items(id) ON DELETE CASCADE,
+			FOREIGN KEY (from_warehouse_id) REFERENCES warehouses(id),
+			FOREIGN KEY (to_warehouse_id) REFERENCES warehouses(id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_warehouse_stock_item ON warehouse_stock (item_id)`,
+		// Keep items.quantity equal to the sum of its warehouse stock so listings stay correct
+		`CREATE OR REPLACE FUNCTION sync_item_quantity() RETURNS trigger AS $$
+		DECLARE
+			target UUID;
+		BEGIN
+			IF TG_OP = 'DELETE' THEN
+				target := OLD.item_id;
+			ELSE
+				target := NEW.item_id;
+			END IF;
+			UPDATE items SET quantity = (
+				SELECT COALESCE(SUM(quantity), 0) FROM warehouse_stock WHERE item_id = target
+			) WHERE id = target;
+			RETURN NULL;
+		END;
+		$$ LANGUAGE plpgsql;`,
+		`DROP TRIGGER IF EXISTS sync_item_quantity ON warehouse_stock`,
+		`CREATE TRIGGER sync_item_quantity
+		AFTER INSERT OR UPDATE OR DELETE ON warehouse_stock
+		FOR EACH ROW
+		EXECUTE FUNCTION sync_item_quantity();`,
+	}
+
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
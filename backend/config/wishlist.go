@@ -0,0 +1,82 @@
+package config
+
+import "database/sql"
+
+// createWishlistTables creates customers' wishlists and their back-in-stock subscriptions, and the trigger
+// that notifies subscribers when an item's quantity goes from zero to positive. Each subscription is
+// notified once: the trigger marks it and stores a notification for the customer in the same transaction,
+// so customers who are offline find it later, and also pushes it on the inventory channel alongside the
+// low inventory notifications for those who are connected. Subscribing again re-arms it.
+func createWishlistTables(db *sql.DB) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS wishlist_items (
+			customer_id UUID NOT NULL,
+			item_id UUID NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (customer_id, item_id),
+			FOREIGN KEY (customer_id) REFERENCES customers(id) ON DELETE CASCADE,
+			FOREIGN KEY (item_id) REFERENCES items(id) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE IF NOT EXISTS stock_subscriptions (
+			customer_id UUID NOT NULL,
+			item_id UUID NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			notified_at TIMESTAMPTZ,
+			PRIMARY KEY (customer_id, item_id),
+			FOREIGN KEY (customer_id) REFERENCES customers(id) ON DELETE CASCADE,
+			FOREIGN KEY (item_id) REFERENCES items(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_stock_subscriptions_waiting ON stock_subscriptions (item_id) WHERE notified_at IS NULL`,
+		`CREATE TABLE IF NOT EXISTS stock_notifications (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			customer_id UUID NOT NULL,
+			item_id UUID NOT NULL,
+			name TEXT NOT NULL,
+			quantity INT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			read_at TIMESTAMPTZ,
+			FOREIGN KEY (customer_id) REFERENCES customers(id) ON DELETE CASCADE,
+			FOREIGN KEY (item_id) REFERENCES items(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_stock_notifications_customer ON stock_notifications (customer_id, created_at DESC)`,
+		`CREATE OR REPLACE FUNCTION notify_back_in_stock() RETURNS trigger AS $$
+		DECLARE
+			subscriber UUID;
+			notification UUID;
+		BEGIN
+			FOR subscriber IN
+				UPDATE stock_subscriptions SET notified_at = NOW()
+				WHERE item_id = NEW.id AND notified_at IS NULL
+				RETURNING customer_id
+			LOOP
+				INSERT INTO stock_notifications (customer_id, item_id, name, quantity)
+				VALUES (subscriber, NEW.id, NEW.name, NEW.quantity)
+				RETURNING id INTO notification;
+				PERFORM pg_notify('inventory', json_build_object(
+					'event', 'back_in_stock',
+					'notification_id', notification,
+					'item_id', NEW.id,
+					'business_admin_id', NEW.business_admin_id,
+					'customer_id', subscriber,
+					'name', NEW.name,
+					'quantity', NEW.quantity
+				)::text);
+			END LOOP;
+			RETURN NEW;
+		END;
+		$$ LANGUAGE plpgsql;`,
+		`DROP TRIGGER IF EXISTS check_back_in_stock ON items`,
+		`CREATE TRIGGER check_back_in_stock
+		AFTER UPDATE OF quantity ON items
+		FOR EACH ROW
+		WHEN (OLD.quantity <= 0 AND NEW.quantity > 0)
+		EXECUTE FUNCTION notify_back_in_stock();`,
+	}
+
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+// Package documents lays out the invoices and packing slips sellers send with their part of an order
+// as PDF pages.
+package documents
+
+import (
+	"chainwave/backend/internal/models"
+	"chainwave/backend/internal/money"
+	"chainwave/backend/internal/pdf"
+	"fmt"
+	"strings"
+)
+
+const (
+	titleSize = 18.0
+	bodySize  = 9.0
+	rowHeight = 14.0
+	bottom    = pdf.PageHeight - pdf.Margin
+	right     = pdf.PageWidth - pdf.Margin
+)
+
+// column is a table column. Numeric columns are right-aligned against their right edge.
+type column struct {
+	title   string
+	x       float64
+	width   float64
+	numeric bool
+}
+
+// table writes rows under a header, starting new pages as they fill up
+type table struct {
+	doc     *pdf.Document
+	page    *pdf.Page
+	y       float64
+	columns []column
+}
+
+func newTable(doc *pdf.Document, page *pdf.Page, y float64, columns []column) *table {
+	t := &table{doc: doc, page: page, y: y, columns: columns}
+	t.header()
+	return t
+}
+
+func (t *table) header() {
+	for _, col := range t.columns {
+		t.cell(col, col.title, true)
+	}
+	t.y += 4
+	t.page.Line(pdf.Margin, t.y, right, t.y, 0.75)
+	t.y += rowHeight
+}
+
+// cell writes a value in a column. Text is cut short to fit, while amounts are written in full.
+func (t *table) cell(col column, value string, bold bool) {
+	if col.numeric {
+		t.page.TextRight(col.x+col.width, t.y, bodySize, bold, value)
+	} else {
+		t.page.Text(col.x, t.y, bodySize, bold, pdf.Fit(value, col.width, bodySize, bold))
+	}
+}
+
+// row writes one row, moving to a new page with the header repeated when this one is full
+func (t *table) row(values ...string) {
+	if t.y > bottom-rowHeight {
+		t.page = t.doc.AddPage()
+		t.y = pdf.Margin + rowHeight
+		t.header()
+	}
+	for i, col := range t.columns {
+		t.cell(col, values[i], false)
+	}
+	t.y += rowHeight
+}
+
+// space makes sure there is room for n more rows, starting a new page if there isn't
+func (t *table) space(n int) {
+	if t.y+float64(n)*rowHeight > bottom {
+		t.page = t.doc.AddPage()
+		t.y = pdf.Margin + rowHeight
+	}
+}
+
+// party writes a seller or buyer's name and address under a heading, returning where it ends
+func party(page *pdf.Page, x, y float64, heading string, p models.TaxParty) float64 {
+	page.Text(x, y, bodySize, true, heading)
+	y += rowHeight
+	lines := []string{p.Name, p.Address, joinNonEmpty(", ", p.City, p.State, p.PostalCode), p.Country}
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		page.Text(x, y, bodySize, false, pdf.Fit(line, 240, bodySize, false))
+		y += rowHeight - 2
+	}
+	return y
+}
+
+func joinNonEmpty(sep string, parts ...string) string {
+	nonEmpty := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if strings.TrimSpace(part) != "" {
+			nonEmpty = append(nonEmpty, strings.TrimSpace(part))
+		}
+	}
+	return strings.Join(nonEmpty, sep)
+}
+
+// amount formats an amount with its thousands grouped, such as "1,299.00"
+func amount(value money.Money) string {
+	s := value.Decimal()
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	whole, fraction := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, fraction = s[:i], s[i:]
+	}
+	for i := len(whole) - 3; i > 0; i -= 3 {
+		whole = whole[:i] + "," + whole[i:]
+	}
+	return sign + whole + fraction
+}
+
+func percent(rate float64) string {
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.3f", rate), "0"), ".") + "%"
+}
+
+// RenderInvoice lays out a seller's invoice for their part of an order from its tax summary
+func RenderInvoice(invoice models.OrderDocument, summary models.TaxSummary) []byte {
+	doc := pdf.New()
+	page := doc.AddPage()
+	cur := summary.Currency
+
+	y := pdf.Margin + titleSize
+	page.Text(pdf.Margin, y, titleSize, true, "Invoice")
+	page.TextRight(right, y, bodySize, true, invoice.Number)
+	page.TextRight(right, y+rowHeight, bodySize, false, "Issued "+invoice.CreatedAt.Format("2 Jan 2006"))
+	y += 2 * rowHeight
+	page.Text(pdf.Margin, y, bodySize, false, "Order "+summary.OrderID.String()+" placed "+summary.OrderedAt.Format("2 Jan 2006"))
+	y += 2 * rowHeight
+
+	sellerEnd := party(page, pdf.Margin, y, "Sold by", summary.Seller)
+	buyerEnd := party(page, pdf.PageWidth/2, y, "Bill to", summary.Buyer)
+	if buyerEnd > sellerEnd {
+		sellerEnd = buyerEnd
+	}
+	y = sellerEnd + rowHeight
+
+	lines := newTable(doc, page, y, []column{
+		{title: "Item", x: pdf.Margin, width: 175},
+		{title: "Qty", x: 225, width: 30, numeric: true},
+		{title: "Unit price", x: 260, width: 60, numeric: true},
+		{title: "Discount", x: 325, width: 50, numeric: true},
+		{title: "Net", x: 380, width: 55, numeric: true},
+		{title: "Tax", x: 440, width: 45, numeric: true},
+		{title: "Total", x: 490, width: right - 490, numeric: true},
+	})
+	for _, line := range summary.Lines {
+		lines.row(line.Name, fmt.Sprint(line.Quantity), amount(line.UnitPrice), amount(line.Discount),
+			amount(line.Net), amount(line.Tax), amount(line.Total))
+	}
+
+	if len(summary.Taxes) > 0 {
+		lines.space(len(summary.Taxes) + 2)
+		lines.y += rowHeight
+		taxes := newTable(doc, lines.page, lines.y, []column{
+			{title: "Tax", x: pdf.Margin, width: 150},
+			{title: "Jurisdiction", x: 205, width: 120},
+			{title: "Rate", x: 330, width: 50, numeric: true},
+			{title: "Taxable", x: 385, width: 70, numeric: true},
+			{title: "Amount", x: 460, width: right - 460, numeric: true},
+		})
+		for _, t := range summary.Taxes {
+			name := t.Name
+			if t.Inclusive {
+				name += " (included in price)"
+			}
+			taxes.row(name, t.Jurisdiction, percent(t.Rate), amount(t.Taxable), amount(t.Amount))
+		}
+		lines = taxes
+	}
+
+	lines.space(5)
+	page, y = lines.page, lines.y+rowHeight
+	totals := []struct {
+		label string
+		value money.Money
+	}{
+		{"Subtotal", summary.Subtotal},
+		{"Tax", summary.Tax},
+		{"Shipping", summary.ShippingCost},
+	}
+	for _, total := range totals {
+		page.Text(380, y, bodySize, false, total.label)
+		page.TextRight(right, y, bodySize, false, amount(total.value))
+		y += rowHeight
+	}
+	page.Line(380, y-rowHeight+4, right, y-rowHeight+4, 0.5)
+	page.Text(380, y+2, bodySize+1, true, "Total ("+cur+")")
+	page.TextRight(right, y+2, bodySize+1, true, amount(summary.Total))
+
+	return doc.Bytes()
+}
+
+// RenderPackingSlip lays out the packing slip for a seller's part of an order
+func RenderPackingSlip(slip models.PackingSlip) []byte {
+	doc := pdf.New()
+	page := doc.AddPage()
+
+	y := pdf.Margin + titleSize
+	page.Text(pdf.Margin, y, titleSize, true, "Packing slip")
+	page.TextRight(right, y, bodySize, false, "Placed "+slip.OrderedAt.Format("2 Jan 2006"))
+	y += 2 * rowHeight
+	page.Text(pdf.Margin, y, bodySize, false, "Order "+slip.OrderID.String())
+	y += rowHeight
+	page.Text(pdf.Margin, y, bodySize, false, "Package "+slip.SellerOrderID.String())
+	y += 2 * rowHeight
+
+	sellerEnd := party(page, pdf.Margin, y, "From", slip.Seller)
+	shipToEnd := party(page, pdf.PageWidth/2, y, "Ship to", slip.ShipTo)
+	if shipToEnd > sellerEnd {
+		sellerEnd = shipToEnd
+	}
+	y = sellerEnd + rowHeight
+
+	lines := newTable(doc, page, y, []column{
+		{title: "Item", x: pdf.Margin, width: 200},
+		{title: "Item ID", x: 255, width: 110},
+		{title: "Warehouse", x: 370, width: 80},
+		{title: "Qty", x: 455, width: 30, numeric: true},
+		{title: "Weight", x: 490, width: right - 490, numeric: true},
+	})
+	var items int
+	for _, line := range slip.Lines {
+		weight := strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.3f", line.Weight*float64(line.Quantity)), "0"), ".") + " " + line.WeightUnit
+		lines.row(line.Name, line.ItemID.String()[:8], line.Warehouse, fmt.Sprint(line.Quantity), weight)
+		items += line.Quantity
+	}
+	lines.space(2)
+	lines.page.Text(pdf.Margin, lines.y+rowHeight, bodySize, true, fmt.Sprintf("Total quantity: %d", items))
+
+	return doc.Bytes()
+}
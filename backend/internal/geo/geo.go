@@ -0,0 +1,62 @@
+package geo
+
+import "math"
+
+// EarthRadiusKm is the mean radius of the Earth in kilometres
+const EarthRadiusKm = 6371.0
+
+// Haversine returns the great-circle distance in kilometres between two points
+func Haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	dLat := toRadians(lat2 - lat1)
+	dLon := toRadians(lon2 - lon1)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * EarthRadiusKm * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+func toRadians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+// ValidCoordinates reports whether a latitude and longitude are within range
+func ValidCoordinates(latitude, longitude float64) bool {
+	return latitude >= -90 && latitude <= 90 && longitude >= -180 && longitude <= 180
+}
+
+// Point is a latitude and longitude
+type Point struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// Distance returns the great-circle distance in kilometres to another point
+func (p Point) Distance(to Point) float64 {
+	return Haversine(p.Latitude, p.Longitude, to.Latitude, to.Longitude)
+}
+
+// Circle is the area within a radius in kilometres of a point
+type Circle struct {
+	Center   Point
+	RadiusKm float64
+}
+
+// BoundingBox returns the latitude and longitude ranges enclosing the circle, for use as a cheap indexed
+// prefilter before the exact distance check. Near the poles or across the antimeridian the longitude
+// range widens to the whole globe.
+func (c Circle) BoundingBox() (minLat, maxLat, minLon, maxLon float64) {
+	latDelta := c.RadiusKm / EarthRadiusKm * 180 / math.Pi
+	minLat = math.Max(c.Center.Latitude-latDelta, -90)
+	maxLat = math.Min(c.Center.Latitude+latDelta, 90)
+
+	if minLat == -90 || maxLat == 90 {
+		return minLat, maxLat, -180, 180
+	}
+	lonDelta := latDelta / math.Cos(toRadians(c.Center.Latitude))
+	minLon = c.Center.Longitude - lonDelta
+	maxLon = c.Center.Longitude + lonDelta
+	if minLon < -180 || maxLon > 180 {
+		return minLat, maxLat, -180, 180
+	}
+	return minLat, maxLat, minLon, maxLon
+}
@@ -0,0 +1,38 @@
+package geocoding
+
+import (
+	"chainwave/backend/internal/models"
+	"context"
+)
+
+// Cache stores geocoder results by address key. A nil result with found set records an address
+// the geocoder couldn't locate, so it isn't looked up again.
+type Cache interface {
+	Get(key string) (result *models.GeocodeResult, found bool, err error)
+	Put(key string, result *models.GeocodeResult) error
+}
+
+// Cached wraps a geocoder so each address is only looked up once
+type Cached struct {
+	Geocoder Geocoder
+	Cache    Cache
+}
+
+// Geocode returns the cached result for an address, geocoding and caching it on a miss
+func (c *Cached) Geocode(ctx context.Context, address Address) (*models.GeocodeResult, error) {
+	key := address.Key()
+	if result, found, err := c.Cache.Get(key); err != nil {
+		return nil, err
+	} else if found {
+		return result, nil
+	}
+
+	result, err := c.Geocoder.Geocode(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Cache.Put(key, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
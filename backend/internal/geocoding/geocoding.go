@@ -0,0 +1,116 @@
+package geocoding
+
+import (
+	"chainwave/backend/internal/geo"
+	"chainwave/backend/internal/models"
+	"context"
+	"errors"
+	"log"
+	"strings"
+)
+
+var (
+	ErrInvalidCoordinates = errors.New("latitude must be between -90 and 90 and longitude between -180 and 180")
+	ErrAddressNotFound    = errors.New("address could not be located")
+	ErrCountryMismatch    = errors.New("coordinates are not within the stated country")
+)
+
+// countryMargin is how many degrees outside a country's bounding box coordinates may fall,
+// allowing for coastal addresses and imprecise boxes
+const countryMargin = 1.0
+
+// Geocoder resolves addresses to coordinates. It returns a nil result if the address can't be found.
+type Geocoder interface {
+	Geocode(ctx context.Context, address Address) (*models.GeocodeResult, error)
+}
+
+// Address is the structured address sent to a geocoder
+type Address struct {
+	Street     string
+	City       string
+	State      string
+	Country    string
+	PostalCode string
+}
+
+// AddressOf returns the structured address of a location
+func AddressOf(location models.Location) Address {
+	return Address{
+		Street:     location.Address,
+		City:       location.City,
+		State:      location.State,
+		Country:    location.Country,
+		PostalCode: location.PostalCode,
+	}
+}
+
+// Key returns a normalised form of the address, used to cache results
+func (a Address) Key() string {
+	parts := []string{a.Street, a.City, a.State, a.Country, a.PostalCode}
+	for i, part := range parts {
+		parts[i] = strings.ToLower(strings.Join(strings.Fields(part), " "))
+	}
+	return strings.Join(parts, "|")
+}
+
+// IsEmpty reports whether the address has nothing to look up
+func (a Address) IsEmpty() bool {
+	return strings.TrimSpace(a.Street+a.City+a.State+a.Country+a.PostalCode) == ""
+}
+
+// ResolveLocation validates a location's coordinates before it is saved.
+// Coordinates must be within range. A location sent without coordinates is placed by geocoding its address,
+// and one sent with coordinates must fall roughly within its stated country. If the geocoder is nil or
+// unavailable only the range check is applied, so an outage doesn't block registration.
+func ResolveLocation(ctx context.Context, geocoder Geocoder, location *models.Location) error {
+	if !geo.ValidCoordinates(location.Latitude, location.Longitude) {
+		return ErrInvalidCoordinates
+	}
+	if geocoder == nil {
+		return nil
+	}
+
+	// The frontend sends zeros when the user didn't pick a point on the map
+	if location.Latitude == 0 && location.Longitude == 0 {
+		address := AddressOf(*location)
+		if address.IsEmpty() {
+			return nil
+		}
+		result, err := geocoder.Geocode(ctx, address)
+		if err != nil {
+			log.Printf("geocoding %q failed: %v", address.Key(), err)
+			return nil
+		}
+		if result == nil {
+			return ErrAddressNotFound
+		}
+		location.Latitude = result.Latitude
+		location.Longitude = result.Longitude
+		return nil
+	}
+
+	if strings.TrimSpace(location.Country) == "" {
+		return nil
+	}
+	country, err := geocoder.Geocode(ctx, Address{Country: location.Country})
+	if err != nil {
+		log.Printf("geocoding country %q failed: %v", location.Country, err)
+		return nil
+	}
+	if country == nil {
+		return ErrAddressNotFound
+	}
+	if !withinBox(country.BoundingBox, location.Latitude, location.Longitude, countryMargin) {
+		return ErrCountryMismatch
+	}
+	return nil
+}
+
+func withinBox(box [4]float64, latitude, longitude, margin float64) bool {
+	// A zero box means the geocoder didn't return one, so there is nothing to check against
+	if box == [4]float64{} {
+		return true
+	}
+	return latitude >= box[0]-margin && latitude <= box[1]+margin &&
+		longitude >= box[2]-margin && longitude <= box[3]+margin
+}
@@ -0,0 +1,48 @@
+package geocoding
+
+import (
+	"chainwave/backend/internal/models"
+	"context"
+	"errors"
+	"testing"
+)
+
+// stubGeocoder answers every lookup with the same result
+type stubGeocoder struct {
+	result *models.GeocodeResult
+	err    error
+}
+
+func (s stubGeocoder) Geocode(ctx context.Context, address Address) (*models.GeocodeResult, error) {
+	return s.result, s.err
+}
+
+func TestResolveLocation(t *testing.T) {
+	india := &models.GeocodeResult{Latitude: 22, Longitude: 79, BoundingBox: [4]float64{6.5, 35.7, 68.1, 97.4}}
+	tests := []struct {
+		name     string
+		geocoder Geocoder
+		location models.Location
+		want     error
+		wantLat  float64
+	}{
+		{"out of range", nil, models.Location{Latitude: 91}, ErrInvalidCoordinates, 91},
+		{"no geocoder", nil, models.Location{Latitude: 12.9, Longitude: 77.6, Country: "India"}, nil, 12.9},
+		{"placed from address", stubGeocoder{result: india}, models.Location{City: "Nagpur", Country: "India"}, nil, 22},
+		{"address not found", stubGeocoder{}, models.Location{City: "Nowhere"}, ErrAddressNotFound, 0},
+		{"geocoder down", stubGeocoder{err: errors.New("timeout")}, models.Location{City: "Nagpur"}, nil, 0},
+		{"within country", stubGeocoder{result: india}, models.Location{Latitude: 12.9, Longitude: 77.6, Country: "India"}, nil, 12.9},
+		{"outside country", stubGeocoder{result: india}, models.Location{Latitude: 51.5, Longitude: -0.1, Country: "India"}, ErrCountryMismatch, 51.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			location := tt.location
+			if err := ResolveLocation(context.Background(), tt.geocoder, &location); err != tt.want {
+				t.Fatalf("ResolveLocation() error = %v, want %v", err, tt.want)
+			}
+			if location.Latitude != tt.wantLat {
+				t.Errorf("Latitude = %v, want %v", location.Latitude, tt.wantLat)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package geocoding
+
+import (
+	"chainwave/backend/internal/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Nominatim geocodes addresses with a Nominatim-compatible search API
+type Nominatim struct {
+	BaseURL   string
+	UserAgent string
+	Client    *http.Client
+
+	// MinInterval spaces out requests to respect the server's rate limit
+	MinInterval time.Duration
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+// NewNominatim creates a geocoder for the Nominatim server at baseURL,
+// limited to one request per second as the public servers require
+func NewNominatim(baseURL, userAgent string) *Nominatim {
+	return &Nominatim{
+		BaseURL:     strings.TrimRight(baseURL, "/"),
+		UserAgent:   userAgent,
+		Client:      &http.Client{Timeout: 10 * time.Second},
+		MinInterval: time.Second,
+	}
+}
+
+type nominatimPlace struct {
+	Lat         string   `json:"lat"`
+	Lon         string   `json:"lon"`
+	DisplayName string   `json:"display_name"`
+	BoundingBox []string `json:"boundingbox"`
+	Address     struct {
+		CountryCode string `json:"country_code"`
+	} `json:"address"`
+}
+
+// Geocode looks up an address with a structured search, returning the best match or nil if there is none
+func (n *Nominatim) Geocode(ctx context.Context, address Address) (*models.GeocodeResult, error) {
+	query := url.Values{}
+	query.Set("format", "jsonv2")
+	query.Set("limit", "1")
+	query.Set("addressdetails", "1")
+	setIfPresent(query, "street", address.Street)
+	setIfPresent(query, "city", address.City)
+	setIfPresent(query, "state", address.State)
+	setIfPresent(query, "country", address.Country)
+	setIfPresent(query, "postalcode", address.PostalCode)
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, n.BaseURL+"/search?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("User-Agent", n.UserAgent)
+	request.Header.Set("Accept", "application/json")
+
+	n.wait()
+	response, err := n.Client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("geocoder returned status %d", response.StatusCode)
+	}
+
+	var places []nominatimPlace
+	if err := json.NewDecoder(response.Body).Decode(&places); err != nil {
+		return nil, err
+	}
+	if len(places) == 0 {
+		return nil, nil
+	}
+	return places[0].result()
+}
+
+// wait blocks until MinInterval has passed since the previous request
+func (n *Nominatim) wait() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if wait := n.MinInterval - time.Since(n.last); wait > 0 {
+		time.Sleep(wait)
+	}
+	n.last = time.Now()
+}
+
+func (p nominatimPlace) result() (*models.GeocodeResult, error) {
+	latitude, err := strconv.ParseFloat(p.Lat, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid latitude %q: %w", p.Lat, err)
+	}
+	longitude, err := strconv.ParseFloat(p.Lon, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid longitude %q: %w", p.Lon, err)
+	}
+
+	result := &models.GeocodeResult{
+		Latitude:    latitude,
+		Longitude:   longitude,
+		CountryCode: strings.ToLower(p.Address.CountryCode),
+		DisplayName: p.DisplayName,
+	}
+	// Nominatim orders the box as min latitude, max latitude, min longitude, max longitude
+	if len(p.BoundingBox) == 4 {
+		for i, value := range p.BoundingBox {
+			if result.BoundingBox[i], err = strconv.ParseFloat(value, 64); err != nil {
+				return nil, fmt.Errorf("invalid bounding box %q: %w", p.BoundingBox, err)
+			}
+		}
+	}
+	return result, nil
+}
+
+func setIfPresent(query url.Values, key, value string) {
+	if value = strings.TrimSpace(value); value != "" {
+		query.Set(key, value)
+	}
+}
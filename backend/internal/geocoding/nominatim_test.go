@@ -0,0 +1,104 @@
+package geocoding
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newStubNominatim(t *testing.T, handler http.HandlerFunc) *Nominatim {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	n := NewNominatim(server.URL+"/", "chainwave-test")
+	n.MinInterval = 0
+	return n
+}
+
+func TestNominatimGeocode(t *testing.T) {
+	n := newStubNominatim(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/search" {
+			t.Errorf("path = %q, want /search", r.URL.Path)
+		}
+		if got := r.Header.Get("User-Agent"); got != "chainwave-test" {
+			t.Errorf("User-Agent = %q, want chainwave-test", got)
+		}
+		query := r.URL.Query()
+		want := map[string]string{
+			"format":     "jsonv2",
+			"limit":      "1",
+			"street":     "1 MG Road",
+			"city":       "Bengaluru",
+			"country":    "India",
+			"postalcode": "560001",
+		}
+		for key, value := range want {
+			if got := query.Get(key); got != value {
+				t.Errorf("query %s = %q, want %q", key, got, value)
+			}
+		}
+		if query.Has("state") {
+			t.Errorf("blank state was sent as %q", query.Get("state"))
+		}
+		w.Write([]byte(`[{"lat": "12.9756", "lon": "77.6050", "display_name": "MG Road, Bengaluru",
+			"boundingbox": ["12.97", "12.98", "77.60", "77.61"], "address": {"country_code": "IN"}}]`))
+	})
+
+	result, err := n.Geocode(context.Background(), Address{
+		Street: "1 MG Road", City: "Bengaluru", State: "  ", Country: "India", PostalCode: "560001",
+	})
+	if err != nil {
+		t.Fatalf("Geocode() error = %v", err)
+	}
+	if result == nil {
+		t.Fatal("Geocode() = nil, want a result")
+	}
+	if result.Latitude != 12.9756 || result.Longitude != 77.6050 {
+		t.Errorf("coordinates = %v, %v, want 12.9756, 77.605", result.Latitude, result.Longitude)
+	}
+	if result.CountryCode != "in" {
+		t.Errorf("CountryCode = %q, want in", result.CountryCode)
+	}
+	if result.BoundingBox != [4]float64{12.97, 12.98, 77.60, 77.61} {
+		t.Errorf("BoundingBox = %v", result.BoundingBox)
+	}
+}
+
+func TestNominatimGeocodeNoMatch(t *testing.T) {
+	n := newStubNominatim(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[]`))
+	})
+
+	result, err := n.Geocode(context.Background(), Address{City: "Nowhere"})
+	if err != nil {
+		t.Fatalf("Geocode() error = %v", err)
+	}
+	if result != nil {
+		t.Errorf("Geocode() = %+v, want nil", result)
+	}
+}
+
+func TestNominatimGeocodeErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"server error", http.StatusServiceUnavailable, ``},
+		{"malformed body", http.StatusOK, `{"lat":`},
+		{"invalid latitude", http.StatusOK, `[{"lat": "north", "lon": "77.6"}]`},
+		{"invalid bounding box", http.StatusOK, `[{"lat": "12.9", "lon": "77.6", "boundingbox": ["a", "b", "c", "d"]}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newStubNominatim(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			})
+			if _, err := n.Geocode(context.Background(), Address{City: "Bengaluru"}); err == nil {
+				t.Error("Geocode() error = nil, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"chainwave/backend/internal/middleware"
+	"chainwave/backend/internal/models"
+	"chainwave/backend/internal/payments"
+	"chainwave/backend/internal/repository"
+	"database/sql"
+	"net/http"
+	"time"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// cartTokenHeader carries the token of a guest's cart
+const cartTokenHeader = "X-Cart-Token"
+
+// guestCartLimit is how many guest carts one client address can create in guestCartWindow
+const (
+	guestCartLimit  = 20
+	guestCartWindow = time.Hour
+)
+
+// GetCartHandler handles fetching the cart of the customer, or of a guest by their cart token,
+// with every line revalidated against current prices and stock
+func GetCartHandler(db *sql.DB, c *gin.Context) {
+	cartID, ok := requestCartID(db, c, false)
+	if !ok {
+		return
+	}
+	if cartID == uuid.Nil {
+		c.JSON(http.StatusOK, models.Cart{Items: make([]models.CartItem, 0)})
+		return
+	}
+	writeCart(db, c, cartID, http.StatusOK)
+}
+
+// AddCartItemHandler handles adding a quantity of an item to the cart. A guest without a cart gets a new
+// one, and its token is returned in the X-Cart-Token header and the guest_token field.
+func AddCartItemHandler(db *sql.DB, c *gin.Context) {
+	var line models.OrderLineRequest
+	if err := c.ShouldBindJSON(&line); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if line.ItemID == uuid.Nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "item_id is required"})
+		return
+	}
+	if line.Quantity <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Quantity must be positive"})
+		return
+	}
+
+	cartID, ok := requestCartID(db, c, true)
+	if !ok {
+		return
+	}
+	if !writeCartError(c, repository.AddCartItem(db, cartID, line.ItemID, line.Quantity)) {
+		return
+	}
+	writeCart(db, c, cartID, http.StatusOK)
+}
+
+// UpdateCartItemHandler handles changing the quantity of an item in the cart, removing it at zero
+func UpdateCartItemHandler(db *sql.DB, c *gin.Context) {
+	var request struct {
+		Quantity int `json:"quantity"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.Quantity < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Quantity cannot be negative"})
+		return
+	}
+	itemID, err := uuid.Parse(c.Param("itemId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+
+	cartID, ok := requestCartID(db, c, false)
+	if !ok {
+		return
+	}
+	if cartID == uuid.Nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": repository.ErrCartItemNotFound.Error()})
+		return
+	}
+	if !writeCartError(c, repository.SetCartItemQuantity(db, cartID, itemID, request.Quantity)) {
+		return
+	}
+	writeCart(db, c, cartID, http.StatusOK)
+}
+
+// RemoveCartItemHandler handles removing an item from the cart
+func RemoveCartItemHandler(db *sql.DB, c *gin.Context) {
+	itemID, err := uuid.Parse(c.Param("itemId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+
+	cartID, ok := requestCartID(db, c, false)
+	if !ok {
+		return
+	}
+	if cartID == uuid.Nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": repository.ErrCartItemNotFound.Error()})
+		return
+	}
+	if !writeCartError(c, repository.RemoveCartItem(db, cartID, itemID)) {
+		return
+	}
+	writeCart(db, c, cartID, http.StatusOK)
+}
+
+// ApplyCartCouponHandler handles putting a coupon code on the cart, replacing any coupon already on it
+func ApplyCartCouponHandler(db *sql.DB, c *gin.Context) {
+	var request struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cartID, ok := requestCartID(db, c, true)
+	if !ok {
+		return
+	}
+	if !writeCartError(c, repository.SetCartCoupon(db, cartID, request.Code)) {
+		return
+	}
+	writeCart(db, c, cartID, http.StatusOK)
+}
+
+// RemoveCartCouponHandler handles taking the coupon off the cart
+func RemoveCartCouponHandler(db *sql.DB, c *gin.Context) {
+	cartID, ok := requestCartID(db, c, false)
+	if !ok {
+		return
+	}
+	if cartID == uuid.Nil {
+		c.JSON(http.StatusOK, models.Cart{Items: make([]models.CartItem, 0)})
+		return
+	}
+	if !writeCartError(c, repository.ClearCartCoupon(db, cartID)) {
+		return
+	}
+	writeCart(db, c, cartID, http.StatusOK)
+}
+
+// ClearCartHandler handles emptying the cart
+func ClearCartHandler(db *sql.DB, c *gin.Context) {
+	cartID, ok := requestCartID(db, c, false)
+	if !ok {
+		return
+	}
+	if cartID == uuid.Nil {
+		c.JSON(http.StatusOK, models.Cart{Items: make([]models.CartItem, 0)})
+		return
+	}
+	if !writeCartError(c, repository.ClearCart(db, cartID)) {
+		return
+	}
+	writeCart(db, c, cartID, http.StatusOK)
+}
+
+// MergeCartHandler handles moving a guest cart into the customer's cart, for customers who filled a cart
+// before logging in or before taking the customer role
+func MergeCartHandler(db *sql.DB, c *gin.Context) {
+	var request struct {
+		GuestToken string `json:"guest_token"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.GuestToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "guest_token is required"})
+		return
+	}
+
+	customerID, ok := getRoleID(c, "customer")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	if !writeCartError(c, repository.MergeGuestCart(db, request.GuestToken, customerID)) {
+		return
+	}
+	cartID, err := repository.GetCustomerCartID(db, customerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	writeCart(db, c, cartID, http.StatusOK)
+}
+
+// CheckoutCartHandler handles turning the customer's cart into an order and creating the provider order
+// they pay against. The cart is emptied once the order is placed.
+func CheckoutCartHandler(db *sql.DB, provider payments.Provider, currency string, c *gin.Context) {
+	var request struct {
+		LocationID *uuid.UUID `json:"location_id"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	customerID, ok := getRoleID(c, "customer")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	order, err := repository.CheckoutCart(db, customerID, request.LocationID)
+	if err == repository.ErrCartEmpty {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if !writeCreateOrderError(c, err) {
+		return
+	}
+	// The order is placed, so a retry must get this response back even if starting the payment fails
+	middleware.KeepIdempotencyKey(c)
+	publishOrderEvent(db, "order.created", order)
+
+	startPayment(db, provider, currency, c, order)
+}
+
+// requestCartID returns the ID of the cart a request is for: the customer's own cart, created on first
+// use, or else the guest cart named by the X-Cart-Token header. A guest without a token gets uuid.Nil, or
+// a new cart when create is set, as long as the client hasn't created too many. It writes the error response
+// and returns false if the token is unknown or no cart could be created.
+func requestCartID(db *sql.DB, c *gin.Context, create bool) (uuid.UUID, bool) {
+	if customerID, ok := getRoleID(c, "customer"); ok {
+		cartID, err := repository.GetCustomerCartID(db, customerID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return uuid.Nil, false
+		}
+		return cartID, true
+	}
+
+	token := c.GetHeader(cartTokenHeader)
+	if token == "" {
+		if !create {
+			return uuid.Nil, true
+		}
+		cartID, token, err := repository.CreateGuestCart(db, c.ClientIP(), guestCartLimit, guestCartWindow)
+		if !writeCartError(c, err) {
+			return uuid.Nil, false
+		}
+		c.Set("guestCartToken", token)
+		c.Header(cartTokenHeader, token)
+		return cartID, true
+	}
+
+	cartID, err := repository.GetGuestCartID(db, token)
+	if !writeCartError(c, err) {
+		return uuid.Nil, false
+	}
+	return cartID, true
+}
+
+// writeCart responds with a cart, including the token of a guest cart created by this request
+func writeCart(db *sql.DB, c *gin.Context, cartID uuid.UUID, status int) {
+	cart, err := repository.GetCart(db, cartID)
+	if !writeCartError(c, err) {
+		return
+	}
+	cart.GuestToken = c.GetString("guestCartToken")
+	c.JSON(status, cart)
+}
+
+// writeCartError maps errors from cart changes to responses.
+// It writes the error response and returns false if there was one.
+func writeCartError(c *gin.Context, err error) bool {
+	switch err {
+	case nil:
+		return true
+	case repository.ErrCartNotFound, repository.ErrCartItemNotFound, repository.ErrItemNotFound, repository.ErrCouponNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case repository.ErrInsufficientStock:
+		c.JSON(http.StatusConflict, gin.H{"error": "Insufficient stock"})
+	case repository.ErrCouponNotActive, repository.ErrCouponUsedUp, repository.ErrCouponLimitReached:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case repository.ErrTooManyGuestCarts:
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+	return false
+}
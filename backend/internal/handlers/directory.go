@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"chainwave/backend/internal/repository"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"github.com/gin-gonic/gin"
+)
+
+// pagination reads the limit and offset query parameters
+func pagination(c *gin.Context) (limit, offset int) {
+	limit, _ = strconv.Atoi(c.DefaultQuery("limit", "10"))
+	offset, _ = strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if limit <= 0 || limit > 100 {
+		limit = 10
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
+
+// GetSuppliersHandler lists suppliers, nearest first when lat and lon are given
+func GetSuppliersHandler(db *sql.DB, c *gin.Context) {
+	near, ok := nearQuery(c)
+	if !ok {
+		return
+	}
+	limit, offset := pagination(c)
+
+	suppliers, err := repository.ListSuppliers(db, near, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, suppliers)
+}
+
+// GetBusinessAdminsHandler lists business admins, nearest first when lat and lon are given
+func GetBusinessAdminsHandler(db *sql.DB, c *gin.Context) {
+	near, ok := nearQuery(c)
+	if !ok {
+		return
+	}
+	limit, offset := pagination(c)
+
+	businessAdmins, err := repository.ListBusinessAdmins(db, near, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, businessAdmins)
+}
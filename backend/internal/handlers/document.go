@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"chainwave/backend/internal/documents"
+	"chainwave/backend/internal/models"
+	"chainwave/backend/internal/repository"
+	"database/sql"
+	"net/http"
+	"os"
+	"path/filepath"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// documentsDir is where invoice and packing slip PDFs are saved, next to uploaded images. Unlike images
+// they aren't served statically, only through the download handlers.
+const documentsDir = "static/documents"
+
+// GetOrderDocumentsHandler handles listing the invoices and packing slips issued for an order
+func GetOrderDocumentsHandler(db *sql.DB, c *gin.Context) {
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	sellerID, ok := orderSellerScope(db, c, orderID)
+	if !ok {
+		return
+	}
+
+	docs, err := repository.GetOrderDocuments(db, orderID, sellerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, docs)
+}
+
+// DownloadInvoiceHandler handles downloading the invoice for a seller's part of an order, issuing it with
+// the seller's next invoice number the first time it is asked for
+func DownloadInvoiceHandler(db *sql.DB, c *gin.Context) {
+	orderID, sellerOrderID, businessAdminID, ok := requestDocumentSellerOrder(db, c)
+	if !ok {
+		return
+	}
+	summaries, err := repository.GetTaxSummaries(db, orderID, &businessAdminID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(summaries) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": repository.ErrSellerOrderNotFound.Error()})
+		return
+	}
+
+	store := func(doc models.OrderDocument) (string, error) {
+		return saveDocument(doc.SellerOrderID.String()+"-invoice.pdf", documents.RenderInvoice(doc, summaries[0]))
+	}
+	doc, path, err := repository.IssueInvoice(db, sellerOrderID, store)
+	if !writeDocumentError(c, err) {
+		return
+	}
+
+	// Invoices keep their number and date, so one missing from disk is rendered again as it was issued
+	if _, err := os.Stat(path); err != nil {
+		if path, err = store(*doc); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save invoice"})
+			return
+		}
+	}
+	c.FileAttachment(path, doc.Number+".pdf")
+}
+
+// DownloadPackingSlipHandler handles downloading the packing slip for a seller's part of an order, drawn up
+// afresh each time so it leaves out anything cancelled since
+func DownloadPackingSlipHandler(db *sql.DB, c *gin.Context) {
+	_, sellerOrderID, _, ok := requestDocumentSellerOrder(db, c)
+	if !ok {
+		return
+	}
+
+	slip, err := repository.GetPackingSlip(db, sellerOrderID)
+	if !writeDocumentError(c, err) {
+		return
+	}
+	path, err := saveDocument(sellerOrderID.String()+"-packing-slip.pdf", documents.RenderPackingSlip(*slip))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save packing slip"})
+		return
+	}
+	if _, err := repository.SavePackingSlip(db, sellerOrderID, path); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.FileAttachment(path, "packing-slip-"+sellerOrderID.String()[:8]+".pdf")
+}
+
+// requestDocumentSellerOrder reads the order and seller order a document is asked for and checks the caller
+// is the order's customer or the seller of that part of it. It returns the order, the seller order and its
+// seller. It writes the error response and returns false otherwise.
+func requestDocumentSellerOrder(db *sql.DB, c *gin.Context) (uuid.UUID, uuid.UUID, uuid.UUID, bool) {
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return uuid.Nil, uuid.Nil, uuid.Nil, false
+	}
+	sellerOrderID, err := uuid.Parse(c.Param("sellerOrderId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid seller order ID"})
+		return uuid.Nil, uuid.Nil, uuid.Nil, false
+	}
+	sellerID, ok := orderSellerScope(db, c, orderID)
+	if !ok {
+		return uuid.Nil, uuid.Nil, uuid.Nil, false
+	}
+
+	sellerOrderOrderID, businessAdminID, err := repository.GetSellerOrderParties(db, sellerOrderID)
+	if err != nil && err != repository.ErrSellerOrderNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return uuid.Nil, uuid.Nil, uuid.Nil, false
+	}
+	if err != nil || sellerOrderOrderID != orderID || (sellerID != nil && *sellerID != businessAdminID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": repository.ErrSellerOrderNotFound.Error()})
+		return uuid.Nil, uuid.Nil, uuid.Nil, false
+	}
+	return orderID, sellerOrderID, businessAdminID, true
+}
+
+// saveDocument writes a PDF to the documents directory and returns its path
+func saveDocument(name string, data []byte) (string, error) {
+	if err := os.MkdirAll(documentsDir, 0o755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(documentsDir, name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// writeDocumentError writes the response for an error issuing a document. It returns true if there was none.
+func writeDocumentError(c *gin.Context, err error) bool {
+	switch err {
+	case nil:
+		return true
+	case repository.ErrSellerOrderNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case repository.ErrDocumentNotIssuable:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"chainwave/backend/internal/models"
+	"chainwave/backend/internal/realtime"
+	"chainwave/backend/internal/repository"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"github.com/gin-gonic/gin"
+)
+
+// heartbeatInterval is how often a comment is sent to keep idle event streams open through proxies
+const heartbeatInterval = 20 * time.Second
+
+// reconnectDelay tells the browser how long to wait before reconnecting a dropped stream
+const reconnectDelay = 3 * time.Second
+
+// eventTicketTTL is how long a ticket for opening an event stream stays valid
+const eventTicketTTL = 30 * time.Second
+
+// CreateEventTicketHandler handles issuing a single use ticket for opening an event stream as the requesting
+// user's roles, so the stream's URL doesn't carry their token
+func CreateEventTicketHandler(db *sql.DB, c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+	roles, _ := c.Get("roles")
+	roleTypes, _ := c.Get("roleTypes")
+	roleIDs, _ := roles.([]string)
+	roleTypeNames, _ := roleTypes.([]string)
+	if roleIDs == nil || roleTypeNames == nil || len(roleIDs) != len(roleTypeNames) {
+		roleIDs, roleTypeNames = []string{}, []string{}
+	}
+
+	ticket, err := repository.CreateEventTicket(db, userID, roleIDs, roleTypeNames, eventTicketTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"ticket": ticket.Ticket, "expires_at": ticket.ExpiresAt})
+}
+
+// StreamEventsHandler handles a Server-Sent Events stream of the order, shipment and inventory events
+// for the requesting user's roles. Clients may narrow it with ?topics=customer:<id>,business_admin:<id>.
+// As tickets are single use, clients reconnect with a new ticket and ?last_event_id= to catch up.
+func StreamEventsHandler(hub *realtime.Hub, c *gin.Context) {
+	allowed := roleTopics(c)
+	if len(allowed) == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	topics := allowed
+	if requested := c.Query("topics"); requested != "" {
+		topics = make([]string, 0)
+		for _, topic := range strings.Split(requested, ",") {
+			if !containsString(allowed, topic) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Cannot subscribe to topic " + topic})
+				return
+			}
+			topics = append(topics, topic)
+		}
+	}
+
+	// Browsers send Last-Event-ID when they reconnect on their own
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+
+	subscription, missed := hub.Subscribe(topics, lastEventID)
+	defer hub.Unsubscribe(subscription)
+
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	c.Writer.Header().Set("Connection", "keep-alive")
+	c.Writer.Header().Set("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	fmt.Fprintf(c.Writer, "retry: %d\n\n", reconnectDelay.Milliseconds())
+	for _, event := range missed {
+		writeEvent(c, event)
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-subscription.Events:
+			if !ok {
+				return
+			}
+			writeEvent(c, event)
+		case <-heartbeat.C:
+			fmt.Fprint(c.Writer, ": ping\n\n")
+		}
+		c.Writer.Flush()
+	}
+}
+
+func writeEvent(c *gin.Context, event realtime.Event) {
+	fmt.Fprintf(c.Writer, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
+}
+
+// roleTopics returns the topics the requesting user may subscribe to, one per role they hold
+func roleTopics(c *gin.Context) []string {
+	topics := make([]string, 0)
+	for _, roleType := range []string{"customer", "business_admin", "transporter", "supplier"} {
+		if id, ok := getRoleID(c, roleType); ok {
+			topics = append(topics, roleType+":"+id.String())
+		}
+	}
+	return topics
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// publishOrderEvent pushes an order event to the customer and every seller in the order
+func publishOrderEvent(db *sql.DB, eventType string, order *models.Order) {
+	topics := []string{"customer:" + order.CustomerID.String()}
+	sellers, err := repository.GetOrderBusinessAdminIDs(db, order.ID)
+	if err != nil {
+		log.Printf("failed to find sellers for order %s: %v", order.ID, err)
+	}
+	for _, seller := range sellers {
+		topics = append(topics, "business_admin:"+seller.String())
+	}
+	realtime.PublishLogged(db, eventType, order, topics...)
+}
+
+// publishShipmentEvent pushes a shipment event to the seller, the assigned transporter and the customer
+func publishShipmentEvent(db *sql.DB, eventType string, shipment *models.Shipment) {
+	topics := []string{"business_admin:" + shipment.BusinessAdminID.String()}
+	if shipment.TransporterID != nil {
+		topics = append(topics, "transporter:"+shipment.TransporterID.String())
+	}
+	customerID, err := repository.GetOrderCustomerID(db, shipment.OrderID)
+	if err != nil {
+		log.Printf("failed to find customer for shipment %s: %v", shipment.ID, err)
+	} else {
+		topics = append(topics, "customer:"+customerID.String())
+	}
+	realtime.PublishLogged(db, eventType, shipment, topics...)
+}
@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"chainwave/backend/internal/models"
+	"chainwave/backend/internal/money"
 	"chainwave/backend/internal/repository"
+	"chainwave/backend/internal/units"
 	"database/sql"
 	"encoding/json"
 	"mime/multipart"
@@ -58,6 +60,23 @@ func AddItemHandler(db *sql.DB, c *gin.Context) {
 
 	item.BusinessAdminId = businessAdminId
 
+	// The price is entered in major units of the business admin's currency and stored in minor units
+	currency, err := repository.GetBusinessAdminCurrency(db, businessAdminId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	item.Price, err = money.Parse(c.PostForm("price"), currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid price"})
+		return
+	}
+
+	// Older clients send the dimensions as a single "LxWxH" field
+	if !normalizeItemUnits(c, &item, c.PostForm("dimensions")) {
+		return
+	}
+
 	// Handle image upload
 	file, err := c.FormFile("image")
 	if err != nil {
@@ -89,11 +108,32 @@ func AddItemHandler(db *sql.DB, c *gin.Context) {
 
 // EditItemHandler handles editing an existing item
 func EditItemHandler(db *sql.DB, c *gin.Context) {
-	var item models.Item
-	if err := c.ShouldBindJSON(&item); err != nil {
+	// Price is sent as a major-unit amount in the item's currency, as a number or a string
+	var request struct {
+		models.Item
+		Price json.Number `json:"Price"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	item := request.Item
+	if !normalizeItemUnits(c, &item, "") {
+		return
+	}
+	currency, err := repository.GetItemCurrency(db, item.Id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if item.Price, err = money.Parse(request.Price.String(), currency); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid price"})
+		return
+	}
 	if err := repository.EditItem(db, item); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -102,17 +142,27 @@ func EditItemHandler(db *sql.DB, c *gin.Context) {
 }
 
 // GetItemHandler handles fetching an item by its ID with details
-func GetItemHandler(db *sql.DB, c *gin.Context) {
+func GetItemHandler(db *sql.DB, rates money.RateSource, c *gin.Context) {
 	itemId, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
 		return
 	}
+	system, ok := unitSystem(db, c)
+	if !ok {
+		return
+	}
+	currency, ok := displayCurrency(db, c)
+	if !ok {
+		return
+	}
 	item, err := repository.GetItemById(db, itemId)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	localizeItem(&item.Weight, &item.WeightUnit, &item.Dimensions, system)
+	item.DisplayPrice, item.DisplaySalePrice = localizePrice(c, rates, item.Price, item.SalePrice, currency)
 
 	// Prepare multipart writer
 	multipartWriter := multipart.NewWriter(c.Writer)
@@ -182,14 +232,43 @@ func GetItemCountHandler(db *sql.DB, c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"count": count})
 }
 
-// GetItemsByCategoryHandler handles fetching items by category and includes images in the multipart response
-func GetItemsByCategoryHandler(db *sql.DB, c *gin.Context, category string, limit, offset int) {
+// GetItemsByCategoryHandler handles fetching items by category and includes images in the multipart response.
+// Items can be sorted by rating or number of reviews with the sort query parameter.
+func GetItemsByCategoryHandler(db *sql.DB, rates money.RateSource, c *gin.Context, category string, limit, offset int) {
+	near, ok := nearQuery(c)
+	if !ok {
+		return
+	}
+	system, ok := unitSystem(db, c)
+	if !ok {
+		return
+	}
+	currency, ok := displayCurrency(db, c)
+	if !ok {
+		return
+	}
+	sort := c.Query("sort")
+	if sort != "" && sort != models.ItemSortRating && sort != models.ItemSortReviews {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be rating or reviews"})
+		return
+	}
+
 	// The handler now receives category as a parameter from the query
-	items, err := repository.GetItemsByCategory(db, category, offset, limit)
+	var items []models.Item
+	var err error
+	if near != nil {
+		items, err = repository.GetItemsNear(db, category, *near, sort, offset, limit)
+	} else {
+		items, err = repository.GetItemsByCategory(db, category, sort, offset, limit)
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	for i := range items {
+		localizeItem(&items[i].Weight, &items[i].WeightUnit, &items[i].Dimensions, system)
+		items[i].DisplayPrice, items[i].DisplaySalePrice = localizePrice(c, rates, items[i].Price, items[i].SalePrice, currency)
+	}
 
 	// Prepare multipart writer
 	multipartWriter := multipart.NewWriter(c.Writer)
@@ -249,4 +328,118 @@ func GetItemsByCategoryHandler(db *sql.DB, c *gin.Context, category string, limi
 	}
 
 	multipartWriter.Close()
-}
\ No newline at end of file
+}
+
+// normalizeItemUnits validates an item's weight and any dimensions given and converts them to kilograms and
+// centimetres for storage. Dimensions are optional, and items without them ship on their weight alone. It
+// writes the error response and returns false if they are invalid.
+func normalizeItemUnits(c *gin.Context, item *models.Item, legacyDimensions string) bool {
+	if item.WeightUnit == "" {
+		item.WeightUnit = units.Kilogram
+	}
+	weight, err := units.ConvertWeight(item.Weight, item.WeightUnit, units.Kilogram)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Weight unit must be kg or lb"})
+		return false
+	}
+	if weight <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Weight must be positive"})
+		return false
+	}
+
+	dimensions := item.Dimensions
+	switch {
+	case dimensions.Length != 0 || dimensions.Width != 0 || dimensions.Height != 0:
+		if dimensions.Unit == "" {
+			dimensions.Unit = units.Centimetre
+		}
+		err = units.ValidateDimensions(dimensions)
+	case legacyDimensions != "":
+		dimensions, err = units.ParseDimensions(legacyDimensions)
+	default:
+		item.Weight, item.WeightUnit, item.Dimensions = weight, units.Kilogram, models.Dimensions{}
+		return true
+	}
+	if err == nil {
+		dimensions, err = units.ConvertDimensions(dimensions, units.Centimetre)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dimensions must be three positive lengths in cm or in, such as 30x20x10 cm"})
+		return false
+	}
+
+	item.Weight, item.WeightUnit, item.Dimensions = weight, units.Kilogram, dimensions
+	return true
+}
+
+// unitSystem returns the unit system to display items in, from the units query parameter or else the
+// user's preference. It writes the error response and returns false if the parameter is invalid.
+func unitSystem(db *sql.DB, c *gin.Context) (string, bool) {
+	if system := c.Query("units"); system != "" {
+		if !units.ValidSystem(system) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "units must be metric or imperial"})
+			return "", false
+		}
+		return system, true
+	}
+
+	if userId, exists := c.Get("userID"); exists {
+		if uid, err := uuid.Parse(userId.(string)); err == nil {
+			if system, err := repository.GetUnitSystem(db, uid); err == nil && units.ValidSystem(system) {
+				return system, true
+			}
+		}
+	}
+	return units.Metric, true
+}
+
+// localizeItem converts an item's weight and dimensions from the stored kilograms and centimetres to a unit system
+func localizeItem(weight *float64, weightUnit *string, dimensions *models.Dimensions, system string) {
+	if converted, err := units.ConvertWeight(*weight, *weightUnit, units.WeightUnit(system)); err == nil {
+		*weight, *weightUnit = converted, units.WeightUnit(system)
+	}
+	if converted, err := units.ConvertDimensions(*dimensions, units.LengthUnit(system)); err == nil {
+		*dimensions = converted
+	}
+}
+
+// displayCurrency returns the currency to show prices in, from the currency query parameter or else the
+// user's saved preference. It is empty when prices should be shown in the seller's currency only.
+func displayCurrency(db *sql.DB, c *gin.Context) (string, bool) {
+	if currency := c.Query("currency"); currency != "" {
+		if !money.Valid(currency) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported currency"})
+			return "", false
+		}
+		return currency, true
+	}
+
+	if userId, exists := c.Get("userID"); exists {
+		if uid, err := uuid.Parse(userId.(string)); err == nil {
+			if currency, err := repository.GetDisplayCurrency(db, uid); err == nil && money.Valid(currency) {
+				return currency, true
+			}
+		}
+	}
+	return "", true
+}
+
+// localizePrice converts an item's price and sale price to the display currency. Prices already in that
+// currency, or that the rate source cannot convert, are left without a display price.
+func localizePrice(c *gin.Context, rates money.RateSource, price money.Money, salePrice *money.Money, currency string) (*money.Money, *money.Money) {
+	if currency == "" || currency == price.Currency {
+		return nil, nil
+	}
+	display, err := money.Convert(c.Request.Context(), price, currency, rates)
+	if err != nil {
+		return nil, nil
+	}
+	if salePrice == nil {
+		return &display, nil
+	}
+	displaySale, err := money.Convert(c.Request.Context(), *salePrice, currency, rates)
+	if err != nil {
+		return &display, nil
+	}
+	return &display, &displaySale
+}
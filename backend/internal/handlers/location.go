@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"chainwave/backend/internal/geo"
+	"chainwave/backend/internal/geocoding"
+	"chainwave/backend/internal/models"
+	"chainwave/backend/internal/repository"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultSearchRadiusKm  = 25.0
+	maxSearchRadiusKm      = 500.0
+	maxLocationLabelLength = 50
+)
+
+// resolveLocation validates a location's coordinates and fills them in from its address when missing.
+// It writes the error response and returns false if the location is rejected.
+func resolveLocation(c *gin.Context, geocoder geocoding.Geocoder, location *models.Location) bool {
+	err := geocoding.ResolveLocation(c.Request.Context(), geocoder, location)
+	switch err {
+	case nil:
+		return true
+	case geocoding.ErrInvalidCoordinates, geocoding.ErrAddressNotFound, geocoding.ErrCountryMismatch:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+	return false
+}
+
+// nearQuery reads the lat, lon and radius (km) query parameters of a nearby search. It returns nil when
+// no point was given, and writes the error response and returns false if the parameters are invalid.
+func nearQuery(c *gin.Context) (*geo.Circle, bool) {
+	latParam, lonParam := c.Query("lat"), c.Query("lon")
+	if latParam == "" && lonParam == "" {
+		return nil, true
+	}
+
+	latitude, latErr := strconv.ParseFloat(latParam, 64)
+	longitude, lonErr := strconv.ParseFloat(lonParam, 64)
+	if latErr != nil || lonErr != nil || !geo.ValidCoordinates(latitude, longitude) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lat and lon must be valid coordinates"})
+		return nil, false
+	}
+
+	radius := defaultSearchRadiusKm
+	if radiusParam := c.Query("radius"); radiusParam != "" {
+		var err error
+		radius, err = strconv.ParseFloat(radiusParam, 64)
+		if err != nil || radius <= 0 || radius > maxSearchRadiusKm {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "radius must be greater than 0 and at most 500 km"})
+			return nil, false
+		}
+	}
+
+	return &geo.Circle{Center: geo.Point{Latitude: latitude, Longitude: longitude}, RadiusKm: radius}, true
+}
+
+// writeLocationError maps address book errors to responses
+func writeLocationError(c *gin.Context, err error) {
+	switch err {
+	case repository.ErrLocationNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case repository.ErrLocationInUse, repository.ErrNotCustomer:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// ownedLocation checks that a role is pointed at a location from the user's address book.
+// It writes the error response and returns false otherwise.
+func ownedLocation(db *sql.DB, c *gin.Context, userID, locationID uuid.UUID) bool {
+	owned, err := repository.LocationOwnedBy(db, userID, locationID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if !owned {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Location does not belong to this user"})
+		return false
+	}
+	return true
+}
+
+// bindLocation reads a location from the request body, validates its label and resolves its coordinates.
+// It writes the error response and returns false if the location is rejected.
+func bindLocation(c *gin.Context, geocoder geocoding.Geocoder, location *models.Location) bool {
+	if err := c.ShouldBindJSON(location); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	location.Label = strings.TrimSpace(location.Label)
+	if len(location.Label) > maxLocationLabelLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Label must be at most 50 characters"})
+		return false
+	}
+	return resolveLocation(c, geocoder, location)
+}
+
+// AddLocationHandler handles saving a new address to the user's address book
+func AddLocationHandler(db *sql.DB, geocoder geocoding.Geocoder, c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+	var location models.Location
+	if !bindLocation(c, geocoder, &location) {
+		return
+	}
+
+	locationID, err := repository.AddLocation(db, userID, location)
+	if err != nil {
+		writeLocationError(c, err)
+		return
+	}
+	location.ID = locationID
+
+	c.JSON(http.StatusCreated, location)
+}
+
+// GetLocationsHandler handles listing the user's address book
+func GetLocationsHandler(db *sql.DB, c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	locations, err := repository.GetLocationsByUser(db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, locations)
+}
+
+// GetLocationHandler handles fetching an address from the user's address book
+func GetLocationHandler(db *sql.DB, c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+	locationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid location ID"})
+		return
+	}
+
+	location, err := repository.GetUserLocation(db, userID, locationID)
+	if err != nil {
+		writeLocationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, location)
+}
+
+// EditLocationHandler handles updating an address in the user's address book. An address already used by an
+// order or shipment is saved under a new ID, which the response carries.
+func EditLocationHandler(db *sql.DB, geocoder geocoding.Geocoder, c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+	locationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid location ID"})
+		return
+	}
+	var location models.Location
+	if !bindLocation(c, geocoder, &location) {
+		return
+	}
+	location.ID = locationID
+
+	location.ID, err = repository.EditLocation(db, userID, location)
+	if err != nil {
+		writeLocationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, location)
+}
+
+// DeleteLocationHandler handles removing an unused address from the user's address book
+func DeleteLocationHandler(db *sql.DB, c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+	locationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid location ID"})
+		return
+	}
+
+	if err := repository.DeleteLocation(db, userID, locationID); err != nil {
+		writeLocationError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// SetDefaultShippingLocationHandler handles choosing the customer's default shipping address
+func SetDefaultShippingLocationHandler(db *sql.DB, c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+	locationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid location ID"})
+		return
+	}
+
+	if err := repository.SetDefaultShippingLocation(db, userID, locationID); err != nil {
+		writeLocationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"default_shipping_location_id": locationID})
+}
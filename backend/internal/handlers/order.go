@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"chainwave/backend/internal/models"
+	"chainwave/backend/internal/pricing"
+	"chainwave/backend/internal/repository"
+	"chainwave/backend/internal/shipping"
+	"database/sql"
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// CreateOrderHandler handles placing an order for the customer, optionally with a coupon code
+func CreateOrderHandler(db *sql.DB, c *gin.Context) {
+	var request struct {
+		Items      []models.OrderLineRequest `json:"items"`
+		LocationID *uuid.UUID                `json:"location_id"`
+		CouponCode string                    `json:"coupon_code"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	customerID, ok := getRoleID(c, "customer")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if !validOrderLines(c, request.Items) {
+		return
+	}
+
+	order, err := repository.CreateOrder(db, customerID, request.Items, request.LocationID, request.CouponCode)
+	if !writeCreateOrderError(c, err) {
+		return
+	}
+	publishOrderEvent(db, "order.created", order)
+	c.JSON(http.StatusCreated, order)
+}
+
+// writeCreateOrderError maps errors from creating an order to responses.
+// It writes the error response and returns false if there was one.
+func writeCreateOrderError(c *gin.Context, err error) bool {
+	if repository.IsCouponError(err) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return false
+	}
+	switch err {
+	case nil:
+		return true
+	case repository.ErrInsufficientStock:
+		c.JSON(http.StatusConflict, gin.H{"error": "Insufficient stock"})
+	case sql.ErrNoRows:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Item or customer location not found"})
+	case repository.ErrLocationNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case shipping.ErrNotServiceable, pricing.ErrMixedCurrencies:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+	return false
+}
+
+// GetOrdersHandler handles listing the customer's orders
+func GetOrdersHandler(db *sql.DB, c *gin.Context) {
+	customerID, ok := getRoleID(c, "customer")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	orders, err := repository.GetOrdersByCustomer(db, customerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, orders)
+}
+
+// GetOrderHandler handles fetching one of the customer's orders by its ID
+func GetOrderHandler(db *sql.DB, c *gin.Context) {
+	customerID, ok := getRoleID(c, "customer")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	order, err := repository.GetOrderByID(db, orderID)
+	if err == sql.ErrNoRows || (err == nil && order.CustomerID != customerID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, order)
+}
+
+// validOrderLines checks an order or quote has at least one item and only positive quantities.
+// It writes the error response and returns false otherwise.
+func validOrderLines(c *gin.Context, lines []models.OrderLineRequest) bool {
+	if len(lines) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Order must contain at least one item"})
+		return false
+	}
+	for _, line := range lines {
+		if line.Quantity <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Quantity must be positive"})
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"chainwave/backend/internal/models"
+	"chainwave/backend/internal/orderstate"
+	"chainwave/backend/internal/payments"
+	"chainwave/backend/internal/repository"
+	"database/sql"
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// UpdateOrderStatusHandler handles moving an order to a new status. Cancelling goes through the same path as
+// the cancel endpoint, returning stock and payment. Business admins and transporters otherwise move their own
+// part of the order, picked by seller_order_id when they have several, and the order follows once every
+// seller has reached the status. Changes the order state machine doesn't allow, or that the caller's role
+// may not make, are rejected with an error naming the statuses and roles that are.
+func UpdateOrderStatusHandler(db *sql.DB, provider payments.Provider, c *gin.Context) {
+	var request struct {
+		Status        string     `json:"status"`
+		Note          string     `json:"note"`
+		Role          string     `json:"role"`
+		SellerOrderID *uuid.UUID `json:"seller_order_id"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !orderstate.Known(request.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown order status " + request.Status})
+		return
+	}
+
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	if request.Status == models.OrderStatusCancelled {
+		cancelOrder(db, provider, c, orderID, request.Note)
+		return
+	}
+
+	actor, ok := requestOrderActor(c, request.Role)
+	if !ok {
+		return
+	}
+
+	err = repository.TransitionSellerOrder(db, orderID, request.SellerOrderID, request.Status, actor, request.Note)
+	if err == repository.ErrSellerOrderAmbiguous {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !writeRefundError(c, err) {
+		return
+	}
+
+	order, err := repository.GetOrderByID(db, orderID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	publishOrderEvent(db, "order.status_changed", order)
+	c.JSON(http.StatusOK, order)
+}
+
+// GetOrderHistoryHandler handles listing the status changes of an order and its seller orders for the
+// customer who placed it or a business admin selling in it
+func GetOrderHistoryHandler(db *sql.DB, c *gin.Context) {
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	if !canViewOrder(db, c, orderID) {
+		return
+	}
+
+	history, err := repository.GetOrderStatusHistory(db, orderID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
+
+// requestOrderActor returns who is changing an order's status: the role named in the request, or else
+// the caller's business admin, transporter or customer role in that order of preference.
+// It writes the error response and returns false if the caller doesn't hold the role.
+func requestOrderActor(c *gin.Context, role string) (orderstate.Actor, bool) {
+	roles := []string{orderstate.RoleBusinessAdmin, orderstate.RoleTransporter, orderstate.RoleCustomer}
+	if role != "" {
+		roles = []string{role}
+	}
+	for _, r := range roles {
+		if id, ok := getRoleID(c, r); ok {
+			return orderstate.Actor{Role: r, ID: id}, true
+		}
+	}
+	if role != "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have the " + role + " role"})
+	} else {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+	}
+	return orderstate.Actor{}, false
+}
@@ -0,0 +1,364 @@
+package handlers
+
+import (
+	"chainwave/backend/internal/geocoding"
+	"chainwave/backend/internal/middleware"
+	"chainwave/backend/internal/models"
+	"chainwave/backend/internal/payments"
+	"chainwave/backend/internal/repository"
+	"context"
+	"database/sql"
+	"io"
+	"log"
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxWebhookBody bounds the size of payment webhooks read into memory
+const maxWebhookBody = 1 << 20
+
+// CheckoutHandler handles placing an order from the checkout page and creating the provider order the
+// customer pays against. The shipping address is a saved location or an address typed at checkout,
+// which is added to the address book unless it is already there.
+func CheckoutHandler(db *sql.DB, provider payments.Provider, geocoder geocoding.Geocoder, currency string, c *gin.Context) {
+	var request struct {
+		Items []struct {
+			ID       uuid.UUID `json:"id"`
+			ItemID   uuid.UUID `json:"item_id"`
+			Quantity int       `json:"quantity"`
+		} `json:"items"`
+		LocationID *uuid.UUID `json:"location_id"`
+		CouponCode string     `json:"coupon_code"`
+		Address    *struct {
+			Street  string `json:"street"`
+			City    string `json:"city"`
+			State   string `json:"state"`
+			ZipCode string `json:"zipCode"`
+			Country string `json:"country"`
+		} `json:"address"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	customerID, ok := getRoleID(c, "customer")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// The cart sends items with their own ID, order lines use item_id
+	lines := make([]models.OrderLineRequest, 0, len(request.Items))
+	for _, item := range request.Items {
+		line := models.OrderLineRequest{ItemID: item.ItemID, Quantity: item.Quantity}
+		if line.ItemID == uuid.Nil {
+			line.ItemID = item.ID
+		}
+		lines = append(lines, line)
+	}
+	if !validOrderLines(c, lines) {
+		return
+	}
+
+	locationID := request.LocationID
+	if locationID == nil && request.Address != nil {
+		location := models.Location{
+			Address:    request.Address.Street,
+			City:       request.Address.City,
+			State:      request.Address.State,
+			PostalCode: request.Address.ZipCode,
+			Country:    request.Address.Country,
+		}
+		if locationID, ok = savedLocation(db, c, geocoder, location); !ok {
+			return
+		}
+	}
+
+	order, err := repository.CreateOrder(db, customerID, lines, locationID, request.CouponCode)
+	if !writeCreateOrderError(c, err) {
+		return
+	}
+	// The order is placed, so a retry must get this response back even if starting the payment fails
+	middleware.KeepIdempotencyKey(c)
+	publishOrderEvent(db, "order.created", order)
+
+	startPayment(db, provider, currency, c, order)
+}
+
+// PayOrderHandler handles retrying payment for one of the customer's pending orders
+func PayOrderHandler(db *sql.DB, provider payments.Provider, currency string, c *gin.Context) {
+	customerID, ok := getRoleID(c, "customer")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	order, err := repository.GetOrderByID(db, orderID)
+	if err == sql.ErrNoRows || (err == nil && order.CustomerID != customerID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = repository.CheckOrderPayable(db, order.ID)
+	if err == repository.ErrPaymentAlreadyCaptured || err == repository.ErrOrderNotPayable {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	startPayment(db, provider, currency, c, order)
+}
+
+// VerifyPaymentHandler handles the checkout's confirmation of a payment. Verifying a payment that was
+// already captured succeeds again without capturing it twice, and a different payment for an order already
+// paid is refunded.
+func VerifyPaymentHandler(db *sql.DB, provider payments.Provider, c *gin.Context) {
+	var request struct {
+		OrderID   string `json:"orderId"`
+		PaymentID string `json:"paymentId"`
+		Signature string `json:"signature"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.OrderID == "" || request.PaymentID == "" || request.Signature == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "orderId, paymentId and signature are required"})
+		return
+	}
+
+	customerID, ok := getRoleID(c, "customer")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	payment, err := repository.GetPaymentByProviderOrderID(db, request.OrderID)
+	if err == nil {
+		var orderCustomerID uuid.UUID
+		orderCustomerID, err = repository.GetOrderCustomerID(db, payment.OrderID)
+		if err == nil && orderCustomerID != customerID {
+			err = repository.ErrPaymentNotFound
+		}
+	}
+	if err == repository.ErrPaymentNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := provider.VerifyPayment(request.OrderID, request.PaymentID, request.Signature); err != nil {
+		if err := repository.FailPayment(db, request.OrderID, request.PaymentID, err.Error()); err != nil {
+			log.Printf("failed to record failed payment %s: %v", request.OrderID, err)
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	payment, ok = capturePayment(db, provider, c, request.OrderID, request.PaymentID)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, payment)
+}
+
+// PaymentWebhookHandler handles payment and refund status changes pushed by the provider
+func PaymentWebhookHandler(db *sql.DB, provider payments.Provider, c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBody))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read body"})
+		return
+	}
+
+	event, err := provider.ParseWebhook(body, c.GetHeader("X-Razorpay-Signature"))
+	if err == payments.ErrInvalidSignature {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	switch event.Type {
+	case payments.EventPaymentCaptured:
+		payment, captured, err := repository.CapturePayment(db, event.OrderID, event.PaymentID)
+		switch err {
+		case nil:
+			if captured {
+				announcePaidOrder(c.Request.Context(), db, provider, payment)
+			}
+		case repository.ErrPaymentAlreadyCaptured:
+			// The customer paid twice, so the second payment goes back to them
+			if err := refundDuplicatePayment(c.Request.Context(), db, provider, event.OrderID, event.PaymentID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		case repository.ErrPaymentNotFound:
+			// Acknowledged anyway, retrying would never succeed
+			log.Printf("ignoring captured payment %s for order %s: %v", event.PaymentID, event.OrderID, err)
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	case payments.EventPaymentFailed:
+		if err := repository.FailPayment(db, event.OrderID, event.PaymentID, event.FailureReason); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	case payments.EventRefundProcessed, payments.EventRefundFailed:
+		status := models.RefundStatusProcessed
+		if event.Type == payments.EventRefundFailed {
+			status = models.RefundStatusFailed
+		}
+		refundID, changed, err := repository.UpdateRefundByProviderID(db, event.RefundID, status, "reported by "+provider.Name())
+		switch err {
+		case nil:
+			if changed {
+				if refund, err := repository.GetRefundByID(db, refundID); err != nil {
+					log.Printf("failed to load refund %s: %v", refundID, err)
+				} else {
+					publishRefundEvent(db, "refund.status_changed", refund)
+				}
+			}
+		case repository.ErrRefundNotFound:
+			// Refunds are recorded once the provider answers, which can be after its webhook, so have it
+			// delivered again rather than losing the status
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// startPayment creates a provider order for the order's total and records the attempt. The response
+// carries what the checkout needs to open the provider's payment form. Orders are charged in the
+// currency they were priced in, falling back to the payments currency for older orders.
+func startPayment(db *sql.DB, provider payments.Provider, currency string, c *gin.Context, order *models.Order) {
+	if order.Currency != "" {
+		currency = order.Currency
+	}
+	providerOrder, err := provider.CreateOrder(c.Request.Context(), order.Total.Amount, currency, order.ID.String())
+	if err == payments.ErrInvalidAmount {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "order_id": order.ID})
+		return
+	}
+	if err != nil {
+		// The order stays pending and can be paid later through /api/orders/:id/pay
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Payment provider unavailable: " + err.Error(), "order_id": order.ID})
+		return
+	}
+
+	_, err = repository.AddPayment(db, models.Payment{
+		OrderID:         order.ID,
+		Provider:        provider.Name(),
+		ProviderOrderID: providerOrder.ID,
+		Amount:          providerOrder.Amount,
+		Currency:        providerOrder.Currency,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "order_id": order.ID})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"id":       providerOrder.ID,
+		"amount":   providerOrder.Amount,
+		"currency": providerOrder.Currency,
+		"provider": provider.Name(),
+		"order_id": order.ID,
+		"order":    order,
+	})
+}
+
+// capturePayment captures a payment verified at checkout and announces the order as paid the first time.
+// It writes the error response and returns false if the payment can't be captured.
+func capturePayment(db *sql.DB, provider payments.Provider, c *gin.Context, providerOrderID, providerPaymentID string) (*models.Payment, bool) {
+	payment, captured, err := repository.CapturePayment(db, providerOrderID, providerPaymentID)
+	switch err {
+	case nil:
+	case repository.ErrPaymentNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return nil, false
+	case repository.ErrPaymentAlreadyCaptured:
+		if err := refundDuplicatePayment(c.Request.Context(), db, provider, providerOrderID, providerPaymentID); err != nil {
+			log.Printf("failed to refund duplicate payment %s: %v", providerPaymentID, err)
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error() + ", this payment will be refunded"})
+		return nil, false
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	if captured {
+		announcePaidOrder(c.Request.Context(), db, provider, payment)
+	}
+	return payment, true
+}
+
+// announcePaidOrder publishes the order of a newly captured payment. A payment completed after its order
+// was cancelled is refunded instead.
+func announcePaidOrder(ctx context.Context, db *sql.DB, provider payments.Provider, payment *models.Payment) {
+	order, err := repository.GetOrderByID(db, payment.OrderID)
+	if err != nil {
+		log.Printf("failed to load paid order %s: %v", payment.OrderID, err)
+		return
+	}
+	if order.Status == models.OrderStatusCancelled {
+		refundCancelledOrder(ctx, db, provider, payment)
+		return
+	}
+	publishOrderEvent(db, "order.paid", order)
+}
+
+// savedLocation returns the ID of the matching address in the user's address book, adding the
+// location to it first if there is none. It writes the error response and returns false on failure.
+func savedLocation(db *sql.DB, c *gin.Context, geocoder geocoding.Geocoder, location models.Location) (*uuid.UUID, bool) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return nil, false
+	}
+
+	saved, err := repository.GetLocationsByUser(db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	for _, s := range saved {
+		if s.Address == location.Address && s.City == location.City && s.State == location.State &&
+			s.PostalCode == location.PostalCode && (location.Country == "" || s.Country == location.Country) {
+			return &s.ID, true
+		}
+	}
+
+	if !resolveLocation(c, geocoder, &location) {
+		return nil, false
+	}
+	locationID, err := repository.AddLocation(db, userID, location)
+	if err != nil {
+		writeLocationError(c, err)
+		return nil, false
+	}
+	return &locationID, true
+}
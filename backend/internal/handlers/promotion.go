@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"chainwave/backend/internal/models"
+	"chainwave/backend/internal/pricing"
+	"chainwave/backend/internal/repository"
+	"database/sql"
+	"time"
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// CreateSaleHandler handles a business admin starting a sale on one of their items or a category of them
+func CreateSaleHandler(db *sql.DB, c *gin.Context) {
+	var request struct {
+		ItemID   *uuid.UUID `json:"item_id"`
+		Category string     `json:"category"`
+		Kind     string     `json:"kind" binding:"required"`
+		Value    float64    `json:"value" binding:"required"`
+		StartsAt *time.Time `json:"starts_at"`
+		EndsAt   time.Time  `json:"ends_at" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	businessAdminID, ok := getRoleID(c, "business_admin")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	sale := models.Sale{
+		BusinessAdminID: businessAdminID,
+		ItemID:          request.ItemID,
+		Category:        request.Category,
+		Kind:            request.Kind,
+		Value:           request.Value,
+		StartsAt:        time.Now(),
+		EndsAt:          request.EndsAt,
+	}
+	if request.StartsAt != nil {
+		sale.StartsAt = *request.StartsAt
+	}
+	sale, err := repository.CreateSale(db, sale)
+	if !writePromotionError(c, err) {
+		return
+	}
+	c.JSON(http.StatusCreated, sale)
+}
+
+// GetSalesHandler handles listing the business admin's running and upcoming sales
+func GetSalesHandler(db *sql.DB, c *gin.Context) {
+	businessAdminID, ok := getRoleID(c, "business_admin")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	sales, err := repository.GetSalesByBusinessAdmin(db, businessAdminID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, sales)
+}
+
+// DeleteSaleHandler handles a business admin ending one of their sales
+func DeleteSaleHandler(db *sql.DB, c *gin.Context) {
+	businessAdminID, ok := getRoleID(c, "business_admin")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	saleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sale ID"})
+		return
+	}
+
+	err = repository.DeleteSale(db, saleID, businessAdminID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sale not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Sale deleted"})
+}
+
+// CreateCouponHandler handles a business admin creating a coupon for their items
+func CreateCouponHandler(db *sql.DB, c *gin.Context) {
+	var request struct {
+		Code               string     `json:"code" binding:"required"`
+		Kind               string     `json:"kind" binding:"required"`
+		Value              float64    `json:"value" binding:"required"`
+		MinSubtotal        float64    `json:"min_subtotal"`
+		StartsAt           *time.Time `json:"starts_at"`
+		EndsAt             *time.Time `json:"ends_at"`
+		MaxUses            *int       `json:"max_uses"`
+		MaxUsesPerCustomer *int       `json:"max_uses_per_customer"`
+		StacksWithSales    bool       `json:"stacks_with_sales"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.MinSubtotal < 0 || (request.MaxUses != nil && *request.MaxUses <= 0) ||
+		(request.MaxUsesPerCustomer != nil && *request.MaxUsesPerCustomer <= 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Minimum spend cannot be negative and usage limits must be positive"})
+		return
+	}
+
+	businessAdminID, ok := getRoleID(c, "business_admin")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	coupon, err := repository.CreateCoupon(db, models.Coupon{
+		BusinessAdminID:    businessAdminID,
+		Code:               request.Code,
+		Kind:               request.Kind,
+		Value:              request.Value,
+		MinSubtotal:        request.MinSubtotal,
+		StartsAt:           request.StartsAt,
+		EndsAt:             request.EndsAt,
+		MaxUses:            request.MaxUses,
+		MaxUsesPerCustomer: request.MaxUsesPerCustomer,
+		StacksWithSales:    request.StacksWithSales,
+	})
+	if !writePromotionError(c, err) {
+		return
+	}
+	c.JSON(http.StatusCreated, coupon)
+}
+
+// GetCouponsHandler handles listing the business admin's coupons with how often each has been used
+func GetCouponsHandler(db *sql.DB, c *gin.Context) {
+	businessAdminID, ok := getRoleID(c, "business_admin")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	coupons, err := repository.GetCouponsByBusinessAdmin(db, businessAdminID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, coupons)
+}
+
+// DeactivateCouponHandler handles a business admin withdrawing one of their coupons
+func DeactivateCouponHandler(db *sql.DB, c *gin.Context) {
+	businessAdminID, ok := getRoleID(c, "business_admin")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	couponID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid coupon ID"})
+		return
+	}
+
+	err = repository.DeactivateCoupon(db, couponID, businessAdminID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Coupon not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Coupon deactivated"})
+}
+
+// writePromotionError maps errors from creating sales and coupons to responses.
+// It writes the error response and returns false if there was one.
+func writePromotionError(c *gin.Context, err error) bool {
+	switch err {
+	case nil:
+		return true
+	case pricing.ErrInvalidKind, pricing.ErrInvalidValue, repository.ErrSaleTarget, repository.ErrPromotionWindow,
+		repository.ErrCouponCodeRequired:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case repository.ErrItemNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case repository.ErrCouponCodeTaken:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+	return false
+}
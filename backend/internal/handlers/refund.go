@@ -0,0 +1,382 @@
+package handlers
+
+import (
+	"chainwave/backend/internal/models"
+	"chainwave/backend/internal/orderstate"
+	"chainwave/backend/internal/payments"
+	"chainwave/backend/internal/realtime"
+	"chainwave/backend/internal/repository"
+	"chainwave/backend/internal/statemachine"
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// CancelOrderHandler handles cancelling an order before it ships. Customers may cancel their own orders
+// and a business admin may cancel an order made up only of their items. Stock goes back to the warehouses
+// it was taken from, and a paid order is refunded in full through the payment provider.
+func CancelOrderHandler(db *sql.DB, provider payments.Provider, c *gin.Context) {
+	var request struct {
+		Reason string `json:"reason"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	cancelOrder(db, provider, c, orderID, request.Reason)
+}
+
+// cancelOrder cancels an order for the customer or business admin making the request and responds with
+// the order and its refund
+func cancelOrder(db *sql.DB, provider payments.Provider, c *gin.Context, orderID uuid.UUID, reason string) {
+	customerID, isCustomer := getRoleID(c, "customer")
+	businessAdminID, isBusinessAdmin := getRoleID(c, "business_admin")
+	if !isCustomer && !isBusinessAdmin {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// A customer cancelling their own order does so as the customer even if they also sell
+	order, err := repository.GetOrderByID(db, orderID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	actor := orderstate.Actor{Role: orderstate.RoleBusinessAdmin, ID: businessAdminID}
+	if isCustomer && order.CustomerID == customerID {
+		actor = orderstate.Actor{Role: orderstate.RoleCustomer, ID: customerID}
+	}
+
+	cancelled, err := repository.CancelOrder(db, orderID, reason, actor, func(tx *sql.Tx, o *models.Order) error {
+		if actor.Role == orderstate.RoleCustomer {
+			return nil
+		}
+		if !isBusinessAdmin {
+			return repository.ErrOrderForbidden
+		}
+		soldOnlyBy, err := repository.OrderSoldOnlyBy(tx, o.ID, businessAdminID)
+		if err != nil {
+			return err
+		}
+		if !soldOnlyBy {
+			return repository.ErrOrderForbidden
+		}
+		return nil
+	})
+	if !writeRefundError(c, err) {
+		return
+	}
+
+	for i := range cancelled.Shipments {
+		publishShipmentEvent(db, "shipment.status_changed", &cancelled.Shipments[i])
+	}
+	refund := cancelled.Refund
+	if refund != nil {
+		refund = submitRefund(c.Request.Context(), db, provider, refund)
+	}
+
+	order, err = repository.GetOrderByID(db, orderID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	publishOrderEvent(db, "order.cancelled", order)
+	c.JSON(http.StatusOK, gin.H{"order": order, "refund": refund})
+}
+
+// RefundOrderHandler handles a business admin refunding quantities of their lines of a paid order, such as
+// items they can't supply or that were returned. Lines that haven't been shipped go back into stock.
+func RefundOrderHandler(db *sql.DB, provider payments.Provider, c *gin.Context) {
+	var request struct {
+		Items  []models.RefundLineRequest `json:"items"`
+		Reason string                     `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(request.Items) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Refund must contain at least one order line"})
+		return
+	}
+	for _, line := range request.Items {
+		if line.Quantity <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Quantity must be positive"})
+			return
+		}
+	}
+
+	businessAdminID, ok := getRoleID(c, "business_admin")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	refund, err := repository.CreateRefund(db, orderID, request.Items, request.Reason, orderstate.Actor{Role: orderstate.RoleBusinessAdmin, ID: businessAdminID}, func(tx *sql.Tx, o *models.Order, items []models.OrderItem) error {
+		soldBy, err := repository.ItemsSoldBy(tx, items, businessAdminID)
+		if err != nil {
+			return err
+		}
+		if !soldBy {
+			return repository.ErrOrderForbidden
+		}
+		return nil
+	})
+	if !writeRefundError(c, err) {
+		return
+	}
+
+	refund = submitRefund(c.Request.Context(), db, provider, refund)
+	c.JSON(http.StatusCreated, refund)
+}
+
+// GetOrderRefundsHandler handles listing an order's refunds with their status history, for the
+// customer who placed the order and the business admins selling in it
+func GetOrderRefundsHandler(db *sql.DB, c *gin.Context) {
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	if !canViewOrder(db, c, orderID) {
+		return
+	}
+
+	refunds, err := repository.GetRefundsByOrder(db, orderID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, refunds)
+}
+
+// RetryRefundHandler handles a business admin selling in an order sending one of its failed refunds
+// to the payment provider again
+func RetryRefundHandler(db *sql.DB, provider payments.Provider, c *gin.Context) {
+	businessAdminID, ok := getRoleID(c, "business_admin")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	refundID, err := uuid.Parse(c.Param("refundId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid refund ID"})
+		return
+	}
+
+	sellers, err := repository.GetOrderBusinessAdminIDs(db, orderID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !containsUUID(sellers, businessAdminID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+
+	refund, err := repository.RetryRefund(db, orderID, refundID)
+	if !writeRefundError(c, err) {
+		return
+	}
+	refund = submitRefund(c.Request.Context(), db, provider, refund)
+	c.JSON(http.StatusOK, refund)
+}
+
+// writeRefundError maps errors from cancelling orders and creating refunds to responses.
+// It writes the error response and returns false if there was one.
+func writeRefundError(c *gin.Context, err error) bool {
+	var transitionErr *statemachine.TransitionError
+	var roleErr *orderstate.RoleError
+	switch {
+	case err == nil:
+		return true
+	case err == sql.ErrNoRows:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+	case err == repository.ErrOrderItemNotFound, err == repository.ErrRefundNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err == repository.ErrOrderForbidden, errors.As(err, &roleErr):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case err == repository.ErrOrderShipped, err == repository.ErrRefundNotRetryable, errors.As(err, &transitionErr):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err == repository.ErrOrderNotPaid, err == repository.ErrRefundExceedsLine, err == repository.ErrRefundExceedsPaid:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+	return false
+}
+
+// submitRefund sends a pending refund to the payment provider and records the outcome. A refund the
+// provider rejects is marked failed and can be retried. One the provider didn't answer, as on a timeout,
+// may have been made all the same, so it stays pending and the stalled refunds job sends it again under
+// the same idempotency key to find out. This only logs its own errors and returns the refund as it now
+// stands.
+func submitRefund(ctx context.Context, db *sql.DB, provider payments.Provider, refund *models.Refund) *models.Refund {
+	status, providerRefundID, note := models.RefundStatusFailed, (*string)(nil), ""
+
+	payment, err := repository.GetPaymentByID(db, refund.PaymentID)
+	switch {
+	case err != nil:
+		note = err.Error()
+	case payment.ProviderPaymentID == nil:
+		note = "payment has no provider payment ID"
+	default:
+		result, err := provider.Refund(ctx, *payment.ProviderPaymentID, refund.Amount, refund.ID.String())
+		if err != nil && !payments.Rejected(err) {
+			status, note = models.RefundStatusPending, "no answer from "+provider.Name()+", will check again: "+err.Error()
+		} else if err != nil {
+			note = err.Error()
+		} else {
+			providerRefundID = &result.ID
+			status, note = refundStatus(result.Status), "sent to "+provider.Name()
+		}
+	}
+
+	if err := repository.RecordRefundResult(db, refund.ID, status, providerRefundID, note); err != nil {
+		log.Printf("failed to record result of refund %s: %v", refund.ID, err)
+		return refund
+	}
+	updated, err := repository.GetRefundByID(db, refund.ID)
+	if err != nil {
+		log.Printf("failed to load refund %s: %v", refund.ID, err)
+		return refund
+	}
+	publishRefundEvent(db, "refund.status_changed", updated)
+	return updated
+}
+
+// ResubmitStalledRefunds sends the refunds left pending for longer than age without an answer from the
+// payment provider to it again, and returns how many it sent
+func ResubmitStalledRefunds(ctx context.Context, db *sql.DB, provider payments.Provider, age time.Duration) (int, error) {
+	refunds, err := repository.ClaimStalledRefunds(db, age)
+	if err != nil {
+		return 0, err
+	}
+	for i := range refunds {
+		submitRefund(ctx, db, provider, &refunds[i])
+	}
+	return len(refunds), nil
+}
+
+// refundStatus maps a provider's refund status to ours. Anything not yet final is pending.
+func refundStatus(status string) string {
+	switch status {
+	case payments.RefundProcessed:
+		return models.RefundStatusProcessed
+	case payments.RefundFailed:
+		return models.RefundStatusFailed
+	default:
+		return models.RefundStatusPending
+	}
+}
+
+// refundCancelledOrder refunds a payment captured after its order was cancelled
+func refundCancelledOrder(ctx context.Context, db *sql.DB, provider payments.Provider, payment *models.Payment) {
+	refund, err := repository.RefundCancelledOrder(db, payment.OrderID, "payment received after the order was cancelled")
+	if err != nil {
+		log.Printf("failed to refund cancelled order %s: %v", payment.OrderID, err)
+		return
+	}
+	if refund != nil {
+		submitRefund(ctx, db, provider, refund)
+	}
+}
+
+// refundDuplicatePayment refunds a payment captured for an order that was already paid. Recording the
+// duplicate and its refund is the part that must not be lost, so only an error doing that is returned.
+func refundDuplicatePayment(ctx context.Context, db *sql.DB, provider payments.Provider, providerOrderID, providerPaymentID string) error {
+	refund, err := repository.RefundDuplicatePayment(db, providerOrderID, providerPaymentID)
+	if err != nil {
+		return err
+	}
+	if refund != nil {
+		log.Printf("refunding duplicate payment %s for order %s", providerPaymentID, refund.OrderID)
+		submitRefund(ctx, db, provider, refund)
+	}
+	return nil
+}
+
+// canViewOrder reports whether the requesting role placed an order or sells items in it.
+// It writes the error response and returns false otherwise.
+func canViewOrder(db *sql.DB, c *gin.Context, orderID uuid.UUID) bool {
+	customerID, err := repository.GetOrderCustomerID(db, orderID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if id, ok := getRoleID(c, "customer"); ok && id == customerID {
+		return true
+	}
+
+	if businessAdminID, ok := getRoleID(c, "business_admin"); ok {
+		sellers, err := repository.GetOrderBusinessAdminIDs(db, orderID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return false
+		}
+		if containsUUID(sellers, businessAdminID) {
+			return true
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+	return false
+}
+
+func containsUUID(values []uuid.UUID, value uuid.UUID) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// publishRefundEvent pushes a refund event to the order's customer and every seller in the order
+func publishRefundEvent(db *sql.DB, eventType string, refund *models.Refund) {
+	topics := make([]string, 0)
+	customerID, err := repository.GetOrderCustomerID(db, refund.OrderID)
+	if err != nil {
+		log.Printf("failed to find customer for refund %s: %v", refund.ID, err)
+	} else {
+		topics = append(topics, "customer:"+customerID.String())
+	}
+	sellers, err := repository.GetOrderBusinessAdminIDs(db, refund.OrderID)
+	if err != nil {
+		log.Printf("failed to find sellers for refund %s: %v", refund.ID, err)
+	}
+	for _, seller := range sellers {
+		topics = append(topics, "business_admin:"+seller.String())
+	}
+	realtime.PublishLogged(db, eventType, refund, topics...)
+}
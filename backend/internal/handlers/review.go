@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"chainwave/backend/internal/models"
+	"chainwave/backend/internal/realtime"
+	"chainwave/backend/internal/repository"
+	"database/sql"
+	"log"
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// reviewRequest is the rating and text of a review a customer writes or edits
+type reviewRequest struct {
+	Rating int    `json:"rating" binding:"required"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+}
+
+// CreateReviewHandler handles a customer reviewing an item they've had delivered
+func CreateReviewHandler(db *sql.DB, c *gin.Context) {
+	var request struct {
+		ItemID uuid.UUID `json:"item_id" binding:"required"`
+		reviewRequest
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	customerID, ok := getRoleID(c, "customer")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	review, err := repository.CreateReview(db, customerID, request.ItemID, request.Rating, request.Title, request.Body)
+	if !writeReviewError(c, err) {
+		return
+	}
+	publishReviewEvent(db, "review.created", review)
+	c.JSON(http.StatusCreated, review)
+}
+
+// GetItemReviewsHandler handles listing the published reviews of an item, newest first
+func GetItemReviewsHandler(db *sql.DB, c *gin.Context) {
+	itemID, err := uuid.Parse(c.Query("item_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+	limit, offset := pagination(c)
+
+	reviews, err := repository.GetItemReviews(db, itemID, offset, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, reviews)
+}
+
+// GetSellerReviewsHandler handles a business admin listing the reviews of their items, including hidden ones
+// and how often each has been flagged. flagged=true lists only reviews users have flagged.
+func GetSellerReviewsHandler(db *sql.DB, c *gin.Context) {
+	businessAdminID, ok := getRoleID(c, "business_admin")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	limit, offset := pagination(c)
+
+	reviews, err := repository.GetSellerReviews(db, businessAdminID, c.Query("flagged") == "true", offset, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, reviews)
+}
+
+// UpdateReviewHandler handles a customer editing their review
+func UpdateReviewHandler(db *sql.DB, c *gin.Context) {
+	reviewID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		return
+	}
+	var request reviewRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	customerID, ok := getRoleID(c, "customer")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	review, err := repository.UpdateReview(db, customerID, reviewID, request.Rating, request.Title, request.Body)
+	if !writeReviewError(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, review)
+}
+
+// DeleteReviewHandler handles a customer deleting their review
+func DeleteReviewHandler(db *sql.DB, c *gin.Context) {
+	reviewID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		return
+	}
+	customerID, ok := getRoleID(c, "customer")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if !writeReviewError(c, repository.DeleteReview(db, customerID, reviewID)) {
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ReplyToReviewHandler handles a business admin replying publicly to a review of one of their items. An empty
+// reply takes their reply down.
+func ReplyToReviewHandler(db *sql.DB, c *gin.Context) {
+	reviewID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		return
+	}
+	var request struct {
+		Reply string `json:"reply"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	businessAdminID, ok := getRoleID(c, "business_admin")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	review, err := repository.ReplyToReview(db, businessAdminID, reviewID, request.Reply)
+	if !writeReviewError(c, err) {
+		return
+	}
+	if review.SellerReply != "" {
+		realtime.PublishLogged(db, "review.replied", review, "customer:"+review.CustomerID.String())
+	}
+	c.JSON(http.StatusOK, review)
+}
+
+// FlagReviewHandler handles a user reporting a review for moderation. Reviews enough verified buyers flag are
+// hidden until a moderator decides on them.
+func FlagReviewHandler(db *sql.DB, c *gin.Context) {
+	reviewID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		return
+	}
+	var request struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	flag, err := repository.FlagReview(db, userID, reviewID, request.Reason)
+	if !writeReviewError(c, err) {
+		return
+	}
+	c.JSON(http.StatusCreated, flag)
+}
+
+// GetModerationQueueHandler handles a moderator listing the reviews flagged since they were last moderated
+func GetModerationQueueHandler(db *sql.DB, c *gin.Context) {
+	limit, offset := pagination(c)
+
+	reviews, err := repository.GetModerationQueue(db, offset, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, reviews)
+}
+
+// GetReviewFlagsHandler handles a moderator reading the flags raised on a review
+func GetReviewFlagsHandler(db *sql.DB, c *gin.Context) {
+	reviewID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		return
+	}
+
+	flags, err := repository.GetReviewFlags(db, reviewID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, flags)
+}
+
+// ModerateReviewHandler handles a moderator publishing or hiding a review, including restoring one that
+// flags hid. The review's author is told of the decision.
+func ModerateReviewHandler(db *sql.DB, c *gin.Context) {
+	reviewID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		return
+	}
+	var request struct {
+		Status string `json:"status" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	review, err := repository.ModerateReview(db, userID, reviewID, request.Status)
+	if !writeReviewError(c, err) {
+		return
+	}
+	realtime.PublishLogged(db, "review.moderated", review, "customer:"+review.CustomerID.String())
+	c.JSON(http.StatusOK, review)
+}
+
+// publishReviewEvent pushes a review event to the seller of the reviewed item
+func publishReviewEvent(db *sql.DB, eventType string, review *models.Review) {
+	businessAdminID, err := repository.GetItemBusinessAdminId(db, review.ItemID)
+	if err != nil {
+		log.Printf("failed to find seller for review %s: %v", review.ID, err)
+		return
+	}
+	realtime.PublishLogged(db, eventType, review, "business_admin:"+businessAdminID.String())
+}
+
+// writeReviewError writes the response for an error with a review. It returns true if there was none.
+func writeReviewError(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case repository.IsReviewError(err):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err == repository.ErrReviewNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err == repository.ErrReviewNotVerified, err == repository.ErrFlagOwnReview:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case err == repository.ErrAlreadyReviewed, err == repository.ErrReviewAlreadyFlagged:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+	return false
+}
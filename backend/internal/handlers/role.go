@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"chainwave/backend/internal/geocoding"
 	"chainwave/backend/internal/models"
+	"chainwave/backend/internal/money"
 	"chainwave/backend/internal/repository"
 	"database/sql"
 	"net/http"
@@ -11,7 +13,7 @@ import (
 )
 
 // AddCustomerHandler handles adding a new customer
-func AddCustomerHandler(db *sql.DB, c *gin.Context) {
+func AddCustomerHandler(db *sql.DB, geocoder geocoding.Geocoder, c *gin.Context) {
 	var request struct {
 		Customer models.Customer `json:"customer"`
 		Location models.Location `json:"location"`
@@ -47,10 +49,17 @@ func AddCustomerHandler(db *sql.DB, c *gin.Context) {
 		return
 	}
 
+	// Point at a saved address when given, otherwise validate the new location and geocode it if needed
+	if request.Customer.LocationId != uuid.Nil {
+		request.Location = models.Location{ID: request.Customer.LocationId}
+	} else if !resolveLocation(c, geocoder, &request.Location) {
+		return
+	}
+
 	// Add the customer and location
 	customerID, locationID, err := repository.AddCustomer(db, uid, request.Customer, request.Location)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeLocationError(c, err)
 		return
 	}
 
@@ -70,7 +79,16 @@ func EditCustomerHandler(db *sql.DB, c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if err := repository.EditCustomer(db, customer); err != nil {
+	userID, ok := getUserID(c)
+	if !ok || !ownedLocation(db, c, userID, customer.LocationId) {
+		return
+	}
+	err := repository.EditCustomer(db, userID, customer)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Customer not found"})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -78,7 +96,7 @@ func EditCustomerHandler(db *sql.DB, c *gin.Context) {
 }
 
 // AddBusinessAdminHandler handles adding a new business admin
-func AddBusinessAdminHandler(db *sql.DB, c *gin.Context) {
+func AddBusinessAdminHandler(db *sql.DB, geocoder geocoding.Geocoder, defaultCurrency string, c *gin.Context) {
 	var request struct {
 		BusinessAdmin models.BusinessAdmin `json:"businessAdmin"`
 		Location models.Location `json:"location"`
@@ -109,9 +127,24 @@ func AddBusinessAdminHandler(db *sql.DB, c *gin.Context) {
 		return
 	}
 
+	// Items are listed in the business admin's currency, the payments currency unless they choose another
+	if request.BusinessAdmin.Currency == "" {
+		request.BusinessAdmin.Currency = defaultCurrency
+	}
+	if !money.Valid(request.BusinessAdmin.Currency) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported currency"})
+		return
+	}
+
+	if request.BusinessAdmin.LocationId != uuid.Nil {
+		request.Location = models.Location{ID: request.BusinessAdmin.LocationId}
+	} else if !resolveLocation(c, geocoder, &request.Location) {
+		return
+	}
+
 	businessAdminID, locationID, err := repository.AddBusinessAdmin(db, uid, request.BusinessAdmin, request.Location)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeLocationError(c, err)
 		return
 	}
 
@@ -130,7 +163,20 @@ func EditBusinessAdminHandler(db *sql.DB, c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if err := repository.EditBusinessAdmin(db, businessAdmin); err != nil {
+	if businessAdmin.Currency != "" && !money.Valid(businessAdmin.Currency) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported currency"})
+		return
+	}
+	userID, ok := getUserID(c)
+	if !ok || !ownedLocation(db, c, userID, businessAdmin.LocationId) {
+		return
+	}
+	err := repository.EditBusinessAdmin(db, userID, businessAdmin)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Business Admin not found"})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -138,7 +184,7 @@ func EditBusinessAdminHandler(db *sql.DB, c *gin.Context) {
 }
 
 // AddTransporterHandler handles adding a new transporter
-func AddTransporterHandler(db *sql.DB, c *gin.Context) {
+func AddTransporterHandler(db *sql.DB, geocoder geocoding.Geocoder, c *gin.Context) {
 	var request struct {
 		Transporter models.Transporter `json:"transporter"`
 		Location    models.Location    `json:"location"`
@@ -170,9 +216,15 @@ func AddTransporterHandler(db *sql.DB, c *gin.Context) {
 		return
 	}
 
+	if request.Transporter.LocationId != uuid.Nil {
+		request.Location = models.Location{ID: request.Transporter.LocationId}
+	} else if !resolveLocation(c, geocoder, &request.Location) {
+		return
+	}
+
 	transporterID, locationID, vehicleID, err := repository.AddTransporter(db, uid, request.Transporter, request.Location, request.Vehicle)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeLocationError(c, err)
 		return
 	}
 
@@ -192,7 +244,16 @@ func EditTransporterHandler(db *sql.DB, c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if err := repository.EditTransporter(db, transporter); err != nil {
+	userID, ok := getUserID(c)
+	if !ok || !ownedLocation(db, c, userID, transporter.LocationId) {
+		return
+	}
+	err := repository.EditTransporter(db, userID, transporter)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transporter not found"})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -200,7 +261,7 @@ func EditTransporterHandler(db *sql.DB, c *gin.Context) {
 }
 
 // AddSupplierHandler handles adding a new supplier
-func AddSupplierHandler(db *sql.DB, c *gin.Context) {
+func AddSupplierHandler(db *sql.DB, geocoder geocoding.Geocoder, c *gin.Context) {
 	var request struct {
 		Supplier models.Supplier `json:"supplier"`
 		Location models.Location `json:"location"`
@@ -231,9 +292,15 @@ func AddSupplierHandler(db *sql.DB, c *gin.Context) {
 		return
 	}
 
+	if request.Supplier.LocationId != uuid.Nil {
+		request.Location = models.Location{ID: request.Supplier.LocationId}
+	} else if !resolveLocation(c, geocoder, &request.Location) {
+		return
+	}
+
 	supplierID, locationID, err := repository.AddSupplier(db, uid, request.Supplier, request.Location)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeLocationError(c, err)
 		return
 	}
 
@@ -252,7 +319,16 @@ func EditSupplierHandler(db *sql.DB, c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if err := repository.EditSupplier(db, supplier); err != nil {
+	userID, ok := getUserID(c)
+	if !ok || !ownedLocation(db, c, userID, supplier.LocationId) {
+		return
+	}
+	err := repository.EditSupplier(db, userID, supplier)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Supplier not found"})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -359,3 +435,45 @@ func getRoleTypes(roles []models.Role) []string {
     }
     return roleTypes
 }
+
+// getRoleID returns the ID of the given role type from the roles set by AuthAdminMiddleware
+func getRoleID(c *gin.Context, roleType string) (uuid.UUID, bool) {
+	roles, rolesExists := c.Get("roles")
+	roleTypes, roleTypesExists := c.Get("roleTypes")
+	if !rolesExists || !roleTypesExists {
+		return uuid.Nil, false
+	}
+
+	rolesSlice := roles.([]string)
+	roleTypesSlice := roleTypes.([]string)
+	if len(rolesSlice) != len(roleTypesSlice) {
+		return uuid.Nil, false
+	}
+
+	for i, t := range roleTypesSlice {
+		if t == roleType {
+			id, err := uuid.Parse(rolesSlice[i])
+			if err != nil {
+				return uuid.Nil, false
+			}
+			return id, true
+		}
+	}
+	return uuid.Nil, false
+}
+
+// getUserID returns the ID of the authenticated user.
+// It writes the error response and returns false if it is missing.
+func getUserID(c *gin.Context) (uuid.UUID, bool) {
+	userId, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return uuid.Nil, false
+	}
+	uid, err := uuid.Parse(userId.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return uuid.Nil, false
+	}
+	return uid, true
+}
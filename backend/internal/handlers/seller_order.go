@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"chainwave/backend/internal/repository"
+	"database/sql"
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// GetSellerOrdersHandler handles listing the business admin's parts of customer orders with their lines and
+// payouts, optionally filtered by ?status=
+func GetSellerOrdersHandler(db *sql.DB, c *gin.Context) {
+	businessAdminID, ok := getRoleID(c, "business_admin")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	sellerOrders, err := repository.GetSellerOrdersByBusinessAdmin(db, businessAdminID, c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, sellerOrders)
+}
+
+// GetSellerOrderHandler handles fetching one of the business admin's seller orders with its lines, shipments and payout
+func GetSellerOrderHandler(db *sql.DB, c *gin.Context) {
+	businessAdminID, ok := getRoleID(c, "business_admin")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	sellerOrderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid seller order ID"})
+		return
+	}
+
+	sellerOrder, err := repository.GetSellerOrder(db, sellerOrderID, businessAdminID)
+	if err == repository.ErrSellerOrderNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, sellerOrder)
+}
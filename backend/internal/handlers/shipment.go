@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"chainwave/backend/internal/matching"
+	"chainwave/backend/internal/models"
+	"chainwave/backend/internal/repository"
+	"chainwave/backend/internal/statemachine"
+	"database/sql"
+	"errors"
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// CreateShipmentHandler handles a business admin creating a shipment for their part of an order
+func CreateShipmentHandler(db *sql.DB, c *gin.Context) {
+	var request struct {
+		OrderID     uuid.UUID  `json:"order_id"`
+		WarehouseID *uuid.UUID `json:"warehouse_id"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	businessAdminID, ok := getRoleID(c, "business_admin")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	shipment, err := repository.CreateShipment(db, request.OrderID, businessAdminID, request.WarehouseID)
+	if err == repository.ErrNoItemsForSeller {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Order has no items from this business admin"})
+		return
+	}
+	if err == repository.ErrSellerOrderCancelled {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order or warehouse not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	publishShipmentEvent(db, "shipment.created", shipment)
+	c.JSON(http.StatusCreated, shipment)
+}
+
+// GetShipmentsHandler handles listing the shipments of the business admin or transporter making the request
+func GetShipmentsHandler(db *sql.DB, c *gin.Context) {
+	var shipments []models.Shipment
+	var err error
+	// A user holding both roles sees their transporter shipments unless ?role=business_admin is given
+	if transporterID, ok := getRoleID(c, "transporter"); ok && c.Query("role") != "business_admin" {
+		shipments, err = repository.GetShipmentsByTransporter(db, transporterID)
+	} else if businessAdminID, ok := getRoleID(c, "business_admin"); ok {
+		shipments, err = repository.GetShipmentsByBusinessAdmin(db, businessAdminID)
+	} else {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, shipments)
+}
+
+// GetAvailableShipmentsHandler handles listing the shipments a transporter can accept
+func GetAvailableShipmentsHandler(db *sql.DB, c *gin.Context) {
+	if _, ok := getRoleID(c, "transporter"); !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	shipments, err := repository.GetPendingShipments(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, shipments)
+}
+
+// GetShipmentHandler handles fetching a shipment visible to the business admin or transporter making the request
+func GetShipmentHandler(db *sql.DB, c *gin.Context) {
+	shipmentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid shipment ID"})
+		return
+	}
+
+	shipment, err := repository.GetShipmentByID(db, shipmentID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shipment not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !canViewShipment(c, shipment) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shipment not found"})
+		return
+	}
+	c.JSON(http.StatusOK, shipment)
+}
+
+// GetShipmentMatchesHandler handles ranking the vehicles that can carry a shipment
+func GetShipmentMatchesHandler(db *sql.DB, c *gin.Context) {
+	shipmentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid shipment ID"})
+		return
+	}
+
+	shipment, err := repository.GetShipmentByID(db, shipmentID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shipment not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The seller and any transporter looking for work may see who can carry it
+	_, isTransporter := getRoleID(c, "transporter")
+	if !isTransporter && !canViewShipment(c, shipment) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shipment not found"})
+		return
+	}
+
+	pickup, drop, err := repository.GetShipmentCoordinates(db, shipment.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// Vehicles can't be ranked by distance to a place without coordinates, and couldn't accept the shipment
+	if pickup == nil || drop == nil {
+		c.JSON(http.StatusOK, []models.VehicleMatch{})
+		return
+	}
+
+	vehicles, err := repository.GetVehiclesWithCapacity(db, shipment.Weight)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	matches := matching.RankVehicles(vehicles, matching.Request{
+		PickupLatitude:  pickup.Latitude,
+		PickupLongitude: pickup.Longitude,
+		DropLatitude:    drop.Latitude,
+		DropLongitude:   drop.Longitude,
+		Weight:          shipment.Weight,
+	})
+	c.JSON(http.StatusOK, matches)
+}
+
+// AcceptShipmentHandler handles a transporter accepting a pending shipment with one of their vehicles
+func AcceptShipmentHandler(db *sql.DB, c *gin.Context) {
+	var request struct {
+		VehicleID uuid.UUID `json:"vehicle_id"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	transporterID, ok := getRoleID(c, "transporter")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	shipmentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid shipment ID"})
+		return
+	}
+
+	shipment, err := repository.AcceptShipment(db, shipmentID, transporterID, request.VehicleID)
+	writeShipmentTransition(db, c, shipment, nil, err)
+}
+
+// PickUpShipmentHandler handles a transporter marking their shipment as picked up
+func PickUpShipmentHandler(db *sql.DB, c *gin.Context) {
+	transitionTransporterShipment(db, c, models.ShipmentStatusPickedUp)
+}
+
+// DeliverShipmentHandler handles a transporter marking their shipment as delivered
+func DeliverShipmentHandler(db *sql.DB, c *gin.Context) {
+	transitionTransporterShipment(db, c, models.ShipmentStatusDelivered)
+}
+
+// CancelShipmentHandler handles a business admin cancelling a shipment before it is picked up
+func CancelShipmentHandler(db *sql.DB, c *gin.Context) {
+	businessAdminID, ok := getRoleID(c, "business_admin")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	shipmentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid shipment ID"})
+		return
+	}
+
+	shipment, order, err := repository.TransitionShipment(db, shipmentID, models.ShipmentStatusCancelled, func(tx *sql.Tx, s *models.Shipment) error {
+		if s.BusinessAdminID != businessAdminID {
+			return repository.ErrShipmentForbidden
+		}
+		return nil
+	})
+	writeShipmentTransition(db, c, shipment, order, err)
+}
+
+// transitionTransporterShipment moves a shipment assigned to the requesting transporter to a new status
+func transitionTransporterShipment(db *sql.DB, c *gin.Context, to string) {
+	transporterID, ok := getRoleID(c, "transporter")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	shipmentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid shipment ID"})
+		return
+	}
+
+	shipment, order, err := repository.TransitionShipment(db, shipmentID, to, func(tx *sql.Tx, s *models.Shipment) error {
+		if s.TransporterID == nil || *s.TransporterID != transporterID {
+			return repository.ErrShipmentForbidden
+		}
+		return nil
+	})
+	writeShipmentTransition(db, c, shipment, order, err)
+}
+
+// writeShipmentTransition writes the response for a shipment status change and pushes it to the parties,
+// along with the change to the order when the shipment moved it
+func writeShipmentTransition(db *sql.DB, c *gin.Context, shipment *models.Shipment, order *models.Order, err error) {
+	var transitionErr *statemachine.TransitionError
+	switch {
+	case err == nil:
+		publishShipmentEvent(db, "shipment.status_changed", shipment)
+		if order != nil {
+			publishOrderEvent(db, "order.status_changed", order)
+		}
+		c.JSON(http.StatusOK, shipment)
+	case err == sql.ErrNoRows:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shipment not found"})
+	case err == repository.ErrShipmentForbidden, err == repository.ErrVehicleNotOwned:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case err == repository.ErrInsufficientCapacity, err == matching.ErrOverCapacity:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err == matching.ErrBeyondMaxDistance, err == matching.ErrVehicleUnavailable, err == repository.ErrShipmentUnlocated:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.As(err, &transitionErr):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// canViewShipment reports whether the requesting role created or is assigned to a shipment
+func canViewShipment(c *gin.Context, shipment *models.Shipment) bool {
+	if businessAdminID, ok := getRoleID(c, "business_admin"); ok && shipment.BusinessAdminID == businessAdminID {
+		return true
+	}
+	if transporterID, ok := getRoleID(c, "transporter"); ok && shipment.TransporterID != nil && *shipment.TransporterID == transporterID {
+		return true
+	}
+	return false
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"chainwave/backend/internal/models"
+	"chainwave/backend/internal/money"
+	"chainwave/backend/internal/pricing"
+	"chainwave/backend/internal/repository"
+	"chainwave/backend/internal/shipping"
+	"database/sql"
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// QuoteShippingHandler handles quoting shipping for the customer's cart, to their default shipping
+// address unless a saved address is given
+func QuoteShippingHandler(db *sql.DB, c *gin.Context) {
+	var request struct {
+		Items      []models.OrderLineRequest `json:"items"`
+		LocationID *uuid.UUID                `json:"location_id"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	customerID, ok := getRoleID(c, "customer")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	if !validOrderLines(c, request.Items) {
+		return
+	}
+
+	destination, err := repository.GetShippingDestination(db, customerID, request.LocationID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Customer location not found"})
+		return
+	}
+	if err != nil {
+		writeLocationError(c, err)
+		return
+	}
+	// Orders to an address without coordinates aren't charged shipping and are left for the seller to price
+	if destination == nil {
+		c.JSON(http.StatusOK, gin.H{"packages": []models.ShippingPackage{}, "total": 0})
+		return
+	}
+
+	packages, err := repository.QuoteShipping(db, *destination, request.Items)
+	if err == shipping.ErrNotServiceable || err == pricing.ErrMixedCurrencies {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Total the cheapest quote of every package, which is what an order would be charged
+	total := money.New(0, packages[0].Quotes[0].Cost.Currency)
+	for _, pkg := range packages {
+		if total, err = total.Add(pkg.Quotes[0].Cost); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"packages": packages, "total": total.Major(), "currency": total.Currency})
+}
+
+// AddShippingTariffHandler handles adding a tariff to the transporter's rate card. Tariffs charge in
+// defaultCurrency unless they name another, and only quote for items sold in the same currency.
+func AddShippingTariffHandler(db *sql.DB, defaultCurrency string, c *gin.Context) {
+	var tariff models.ShippingTariff
+	if err := c.ShouldBindJSON(&tariff); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	transporterID, ok := getRoleID(c, "transporter")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	if tariff.Currency == "" {
+		tariff.Currency = defaultCurrency
+	}
+	if !validShippingTariff(c, tariff) {
+		return
+	}
+	tariff.TransporterID = &transporterID
+
+	created, err := repository.AddShippingTariff(db, tariff)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+// GetShippingTariffsHandler handles listing the transporter's tariffs
+func GetShippingTariffsHandler(db *sql.DB, c *gin.Context) {
+	transporterID, ok := getRoleID(c, "transporter")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	tariffs, err := repository.GetShippingTariffsByTransporter(db, transporterID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, tariffs)
+}
+
+// DeleteShippingTariffHandler handles withdrawing one of the transporter's tariffs
+func DeleteShippingTariffHandler(db *sql.DB, c *gin.Context) {
+	transporterID, ok := getRoleID(c, "transporter")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	tariffID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tariff ID"})
+		return
+	}
+
+	err = repository.DeactivateShippingTariff(db, transporterID, tariffID)
+	if err == repository.ErrTariffNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// validShippingTariff checks a tariff has a name, a supported currency, no negative fees and distinct positive distance bands.
+// It writes the error response and returns false otherwise.
+func validShippingTariff(c *gin.Context, tariff models.ShippingTariff) bool {
+	if tariff.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tariff name is required"})
+		return false
+	}
+	if !money.Valid(tariff.Currency) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported currency"})
+		return false
+	}
+	if tariff.BaseFee < 0 || tariff.PerKg < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Fees cannot be negative"})
+		return false
+	}
+	if len(tariff.Bands) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tariff must have at least one distance band"})
+		return false
+	}
+
+	seen := make(map[float64]bool)
+	for _, band := range tariff.Bands {
+		if band.MaxDistanceKm <= 0 || band.Fee < 0 || band.TransitDays < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Bands need a positive distance, a fee and transit days that are not negative"})
+			return false
+		}
+		if seen[band.MaxDistanceKm] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Bands must have distinct distances"})
+			return false
+		}
+		seen[band.MaxDistanceKm] = true
+	}
+	return true
+}
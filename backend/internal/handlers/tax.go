@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"chainwave/backend/internal/models"
+	"chainwave/backend/internal/repository"
+	"database/sql"
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// CreateTaxRuleHandler handles a business admin adding a tax they charge on sales into a jurisdiction
+func CreateTaxRuleHandler(db *sql.DB, c *gin.Context) {
+	var request struct {
+		Name      string  `json:"name" binding:"required"`
+		Country   string  `json:"country" binding:"required"`
+		State     string  `json:"state"`
+		Category  string  `json:"category"`
+		Scope     string  `json:"scope"`
+		Rate      float64 `json:"rate" binding:"required"`
+		Inclusive bool    `json:"inclusive"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	businessAdminID, ok := getRoleID(c, "business_admin")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	rule, err := repository.CreateTaxRule(db, models.TaxRule{
+		BusinessAdminID: &businessAdminID,
+		Name:            request.Name,
+		Country:         request.Country,
+		State:           request.State,
+		Category:        request.Category,
+		Scope:           request.Scope,
+		Rate:            request.Rate,
+		Inclusive:       request.Inclusive,
+	})
+	if repository.IsTaxRuleError(err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, rule)
+}
+
+// GetTaxRulesHandler handles listing the tax rules the business admin charges, their own and everyone's
+func GetTaxRulesHandler(db *sql.DB, c *gin.Context) {
+	businessAdminID, ok := getRoleID(c, "business_admin")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	rules, err := repository.GetTaxRules(db, businessAdminID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+// DeleteTaxRuleHandler handles a business admin withdrawing one of their tax rules
+func DeleteTaxRuleHandler(db *sql.DB, c *gin.Context) {
+	businessAdminID, ok := getRoleID(c, "business_admin")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	ruleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tax rule ID"})
+		return
+	}
+
+	err = repository.DeactivateTaxRule(db, businessAdminID, ruleID)
+	if err == repository.ErrTaxRuleNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetOrderTaxSummaryHandler handles fetching the invoice-ready tax summary of an order. The customer gets
+// one for each seller in the order, and a seller only the one for their part of it.
+func GetOrderTaxSummaryHandler(db *sql.DB, c *gin.Context) {
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	sellerID, ok := orderSellerScope(db, c, orderID)
+	if !ok {
+		return
+	}
+
+	summaries, err := repository.GetTaxSummaries(db, orderID, sellerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, summaries)
+}
+
+// orderSellerScope checks the caller can view an order and returns the seller whose part of it they may see:
+// nil for the order's customer, who sees every seller's part, and the caller's business admin otherwise.
+// It writes the error response and returns false if they can't view the order.
+func orderSellerScope(db *sql.DB, c *gin.Context, orderID uuid.UUID) (*uuid.UUID, bool) {
+	if !canViewOrder(db, c, orderID) {
+		return nil, false
+	}
+	customerID, err := repository.GetOrderCustomerID(db, orderID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if id, ok := getRoleID(c, "customer"); ok && id == customerID {
+		return nil, true
+	}
+	businessAdminID, _ := getRoleID(c, "business_admin")
+	return &businessAdminID, true
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"chainwave/backend/internal/geo"
+	"chainwave/backend/internal/models"
+	"chainwave/backend/internal/repository"
+	"chainwave/backend/internal/tracking"
+	"database/sql"
+	"net/http"
+	"time"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxPositionBatch caps how many GPS pings can be sent in one request
+const maxPositionBatch = 500
+
+// RecordVehiclePositionsHandler handles a transporter pushing a batch of GPS pings for one of their vehicles
+func RecordVehiclePositionsHandler(db *sql.DB, c *gin.Context) {
+	var request struct {
+		Positions []models.VehiclePosition `json:"positions"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	transporterID, ok := getRoleID(c, "transporter")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	vehicleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid vehicle ID"})
+		return
+	}
+
+	if len(request.Positions) == 0 || len(request.Positions) > maxPositionBatch {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Between 1 and 500 positions must be sent"})
+		return
+	}
+	now := time.Now()
+	for _, position := range request.Positions {
+		if !geo.ValidCoordinates(position.Latitude, position.Longitude) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Coordinates out of range"})
+			return
+		}
+		// Allow for some clock drift on the device but reject pings from the future
+		if position.RecordedAt.IsZero() || position.RecordedAt.After(now.Add(5*time.Minute)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recorded_at timestamp"})
+			return
+		}
+	}
+
+	owns, err := repository.TransporterOwnsVehicle(db, transporterID, vehicleID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !owns {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Vehicle does not belong to this transporter"})
+		return
+	}
+
+	if err := repository.RecordVehiclePositions(db, vehicleID, request.Positions); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"recorded": len(request.Positions)})
+}
+
+// TrackShipmentHandler handles fetching the live position, trail and ETA of a shipment
+func TrackShipmentHandler(db *sql.DB, c *gin.Context) {
+	shipmentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid shipment ID"})
+		return
+	}
+
+	shipment, err := repository.GetShipmentByID(db, shipmentID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shipment not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The customer who placed the order can track it, as can the seller and transporter
+	allowed := canViewShipment(c, shipment)
+	if customerID, ok := getRoleID(c, "customer"); ok && !allowed {
+		orderCustomerID, err := repository.GetOrderCustomerID(db, shipment.OrderID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		allowed = orderCustomerID == customerID
+	}
+	if !allowed {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shipment not found"})
+		return
+	}
+
+	pickup, drop, err := repository.GetShipmentCoordinates(db, shipment.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The trail covers the time the vehicle has been assigned to the shipment
+	trail := make([]models.VehiclePosition, 0)
+	var stored *models.VehiclePosition
+	if shipment.VehicleID != nil && shipment.AcceptedAt != nil {
+		until := time.Now()
+		if shipment.DeliveredAt != nil {
+			until = *shipment.DeliveredAt
+		} else if shipment.CancelledAt != nil {
+			until = *shipment.CancelledAt
+		}
+		trail, err = repository.GetVehicleTrail(db, *shipment.VehicleID, *shipment.AcceptedAt, until)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if len(trail) == 0 {
+			stored, err = repository.GetVehiclePosition(db, *shipment.VehicleID, *shipment.AcceptedAt)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+	}
+
+	view := tracking.Track(*shipment, trail, stored, pickup, drop, time.Now())
+	c.JSON(http.StatusOK, view)
+}
@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"chainwave/backend/internal/models"
+	"chainwave/backend/internal/money"
 	"chainwave/backend/internal/repository"
+	"chainwave/backend/internal/units"
 	"database/sql"
 	"log"
 	"net/http"
@@ -81,8 +83,9 @@ func RegisterUser(db *sql.DB, c *gin.Context) {
 // Login handler
 func LoginUser(db *sql.DB, c *gin.Context) {
 	var loginData struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email     string `json:"email"`
+		Password  string `json:"password"`
+		CartToken string `json:"cart_token"` // Guest cart to merge into the user's customer cart
 	}
 
 	// Bind JSON input to the loginData struct
@@ -115,12 +118,26 @@ func LoginUser(db *sql.DB, c *gin.Context) {
 		return
 	}
 
+	// Carry over what the user put in their cart before logging in
+	cartMerged := false
+	if loginData.CartToken != "" {
+		err := repository.MergeGuestCartForUser(db, loginData.CartToken, user.Id)
+		switch err {
+		case nil:
+			cartMerged = true
+		case repository.ErrNotCustomer, repository.ErrCartNotFound:
+		default:
+			log.Printf("failed to merge guest cart for user %s: %v", user.Id, err)
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"message":  "Login successful",
-		"token":    tokenString,
-		"user_id":  user.Id,
-		"username": user.Username,
-		"email":    user.Email,
+		"message":     "Login successful",
+		"token":       tokenString,
+		"user_id":     user.Id,
+		"username":    user.Username,
+		"email":       user.Email,
+		"cart_merged": cartMerged,
 	})
 }
 
@@ -237,3 +254,59 @@ func UpdatePasswordHandler(db *sql.DB, c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "Password updated successfully"})
 }
+
+// Update unit system handler
+func UpdateUnitSystemHandler(db *sql.DB, c *gin.Context) {
+	var unitData struct {
+		UnitSystem string `json:"unit_system"`
+	}
+	if err := c.BindJSON(&unitData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Bad Request"})
+		return
+	}
+	if !units.ValidSystem(unitData.UnitSystem) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unit system must be metric or imperial"})
+		return
+	}
+
+	uid, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := repository.UpdateUnitSystem(db, uid, unitData.UnitSystem); err != nil {
+		log.Print(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Unit system updated successfully"})
+}
+
+// UpdateCurrencyHandler sets the currency the user sees item prices converted to
+func UpdateCurrencyHandler(db *sql.DB, c *gin.Context) {
+	var currencyData struct {
+		Currency string `json:"currency"`
+	}
+	if err := c.BindJSON(&currencyData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Bad Request"})
+		return
+	}
+	if !money.Valid(currencyData.Currency) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported currency"})
+		return
+	}
+
+	uid, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := repository.UpdateDisplayCurrency(db, uid, currencyData.Currency); err != nil {
+		log.Print(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Currency updated successfully"})
+}
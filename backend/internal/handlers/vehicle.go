@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"chainwave/backend/internal/geo"
+	"chainwave/backend/internal/models"
+	"chainwave/backend/internal/repository"
+	"chainwave/backend/internal/routing"
+	"database/sql"
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// AddVehicleHandler handles a transporter adding a vehicle to their fleet
+func AddVehicleHandler(db *sql.DB, c *gin.Context) {
+	var vehicle models.Vehicle
+	if err := c.ShouldBindJSON(&vehicle); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	transporterID, ok := getRoleID(c, "transporter")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	if !validVehicleDetails(c, vehicle) {
+		return
+	}
+	if vehicle.Status == "" {
+		vehicle.Status = models.VehicleStatusActive
+	}
+	if !validVehicleStatus(vehicle.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Status must be active, maintenance or offline"})
+		return
+	}
+
+	vehicle.TransporterID = transporterID
+	vehicle.CurrentCapacity = 0
+	vehicle.RetiredAt = nil
+	id, err := repository.AddVehicle(db, vehicle)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	vehicle.ID = id
+	c.JSON(http.StatusCreated, vehicle)
+}
+
+// GetVehiclesHandler handles listing a transporter's fleet. Retired vehicles are included with ?include_retired=true.
+func GetVehiclesHandler(db *sql.DB, c *gin.Context) {
+	transporterID, ok := getRoleID(c, "transporter")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	vehicles, err := repository.GetVehiclesByTransporter(db, transporterID, c.Query("include_retired") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, vehicles)
+}
+
+// EditVehicleHandler handles a transporter editing the details of one of their vehicles
+func EditVehicleHandler(db *sql.DB, c *gin.Context) {
+	vehicle, ok := ownedVehicle(db, c)
+	if !ok {
+		return
+	}
+
+	var request models.Vehicle
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !validVehicleDetails(c, request) {
+		return
+	}
+	if vehicle.RetiredAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Vehicle is retired"})
+		return
+	}
+
+	vehicle.Make = request.Make
+	vehicle.Model = request.Model
+	vehicle.Year = request.Year
+	vehicle.MaxDistance = request.MaxDistance
+	vehicle.MaxCapacity = request.MaxCapacity
+	err := repository.EditVehicle(db, *vehicle)
+	if err == repository.ErrCapacityBelowLoad {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, vehicle)
+}
+
+// SetVehicleStatusHandler handles a transporter marking a vehicle active, in maintenance or offline
+func SetVehicleStatusHandler(db *sql.DB, c *gin.Context) {
+	vehicle, ok := ownedVehicle(db, c)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Status string `json:"status"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !validVehicleStatus(request.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Status must be active, maintenance or offline"})
+		return
+	}
+	if vehicle.RetiredAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Vehicle is retired"})
+		return
+	}
+
+	if err := repository.SetVehicleStatus(db, vehicle.ID, request.Status); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	vehicle.Status = request.Status
+	c.JSON(http.StatusOK, vehicle)
+}
+
+// AssignVehicleDriverHandler handles a transporter assigning the driver of one of their vehicles
+func AssignVehicleDriverHandler(db *sql.DB, c *gin.Context) {
+	vehicle, ok := ownedVehicle(db, c)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		DriverName    string `json:"driver_name"`
+		DriverContact string `json:"driver_contact"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := repository.AssignVehicleDriver(db, vehicle.ID, request.DriverName, request.DriverContact); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	vehicle.DriverName = request.DriverName
+	vehicle.DriverContact = request.DriverContact
+	c.JSON(http.StatusOK, vehicle)
+}
+
+// RetireVehicleHandler handles a transporter permanently retiring one of their vehicles
+func RetireVehicleHandler(db *sql.DB, c *gin.Context) {
+	vehicle, ok := ownedVehicle(db, c)
+	if !ok {
+		return
+	}
+
+	err := repository.RetireVehicle(db, vehicle.ID)
+	if err == repository.ErrVehicleInUse {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Vehicle retired successfully"})
+}
+
+// GetVehicleRouteHandler handles planning the itinerary of a transporter's vehicle through its assigned shipments
+func GetVehicleRouteHandler(db *sql.DB, c *gin.Context) {
+	vehicle, ok := ownedVehicle(db, c)
+	if !ok {
+		return
+	}
+
+	shipments, err := repository.GetActiveShipmentsForVehicle(db, vehicle.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	start := geo.Point{Latitude: vehicle.Latitude, Longitude: vehicle.Longitude}
+	stops, err := routing.Plan(start, vehicle.MaxCapacity, shipments)
+	if err == routing.ErrInfeasible {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	plan := models.RoutePlan{VehicleID: vehicle.ID, Stops: stops}
+	if len(stops) > 0 {
+		plan.TotalDistance = stops[len(stops)-1].CumulativeDistance
+	}
+	c.JSON(http.StatusOK, plan)
+}
+
+// ownedVehicle loads the vehicle in the :id parameter and checks it belongs to the transporter making the request.
+// It writes the error response and returns false if it doesn't.
+func ownedVehicle(db *sql.DB, c *gin.Context) (*models.Vehicle, bool) {
+	transporterID, ok := getRoleID(c, "transporter")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+	vehicleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid vehicle ID"})
+		return nil, false
+	}
+
+	vehicle, err := repository.GetVehicleByID(db, vehicleID)
+	if err == sql.ErrNoRows || (err == nil && vehicle.TransporterID != transporterID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Vehicle not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return vehicle, true
+}
+
+// validVehicleDetails checks the capacity and distance of a vehicle, writing the error response if they are invalid
+func validVehicleDetails(c *gin.Context, vehicle models.Vehicle) bool {
+	if vehicle.MaxCapacity <= 0 || vehicle.MaxDistance <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Max capacity and max distance must be positive"})
+		return false
+	}
+	return true
+}
+
+func validVehicleStatus(status string) bool {
+	switch status {
+	case models.VehicleStatusActive, models.VehicleStatusMaintenance, models.VehicleStatusOffline:
+		return true
+	}
+	return false
+}
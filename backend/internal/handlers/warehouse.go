@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"chainwave/backend/internal/geocoding"
+	"chainwave/backend/internal/models"
+	"chainwave/backend/internal/repository"
+	"database/sql"
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// AddWarehouseHandler handles adding a new warehouse for the business admin
+func AddWarehouseHandler(db *sql.DB, geocoder geocoding.Geocoder, c *gin.Context) {
+	var request struct {
+		Warehouse models.Warehouse `json:"warehouse"`
+		Location  models.Location  `json:"location"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	businessAdminID, ok := getRoleID(c, "business_admin")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	if request.Warehouse.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Warehouse name is required"})
+		return
+	}
+	request.Warehouse.BusinessAdminID = businessAdminID
+	if request.Warehouse.LocationID != uuid.Nil {
+		request.Location = models.Location{ID: request.Warehouse.LocationID}
+	} else if !resolveLocation(c, geocoder, &request.Location) {
+		return
+	}
+
+	warehouseID, locationID, err := repository.AddWarehouse(db, request.Warehouse, request.Location)
+	if err != nil {
+		writeLocationError(c, err)
+		return
+	}
+
+	request.Warehouse.ID = warehouseID
+	request.Warehouse.LocationID = locationID
+
+	c.JSON(http.StatusCreated, request.Warehouse)
+}
+
+// GetWarehousesHandler handles listing the business admin's warehouses
+func GetWarehousesHandler(db *sql.DB, c *gin.Context) {
+	businessAdminID, ok := getRoleID(c, "business_admin")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	warehouses, err := repository.GetWarehousesByBusinessAdmin(db, businessAdminID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, warehouses)
+}
+
+// GetWarehouseStockHandler handles fetching the stock levels of a warehouse
+func GetWarehouseStockHandler(db *sql.DB, c *gin.Context) {
+	warehouse, ok := ownedWarehouse(db, c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	stock, err := repository.GetWarehouseStock(db, warehouse.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, stock)
+}
+
+// SetWarehouseStockHandler handles setting the stock level of an item in a warehouse
+func SetWarehouseStockHandler(db *sql.DB, c *gin.Context) {
+	warehouse, ok := ownedWarehouse(db, c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var stock models.WarehouseStock
+	if err := c.ShouldBindJSON(&stock); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if stock.Quantity < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Quantity cannot be negative"})
+		return
+	}
+
+	// Only the seller of the item may stock it
+	itemOwner, err := repository.GetItemBusinessAdminId(db, stock.ItemID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if itemOwner != warehouse.BusinessAdminID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Item does not belong to this business admin"})
+		return
+	}
+
+	stock.WarehouseID = warehouse.ID
+	if err := repository.SetWarehouseStock(db, stock); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, stock)
+}
+
+// TransferStockHandler handles moving stock of an item between two of the business admin's warehouses
+func TransferStockHandler(db *sql.DB, c *gin.Context) {
+	var transfer models.StockTransfer
+	if err := c.ShouldBindJSON(&transfer); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if transfer.Quantity <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Quantity must be positive"})
+		return
+	}
+	if transfer.FromWarehouseID == transfer.ToWarehouseID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Source and destination warehouses must differ"})
+		return
+	}
+
+	if _, ok := ownedWarehouse(db, c, transfer.FromWarehouseID.String()); !ok {
+		return
+	}
+	if _, ok := ownedWarehouse(db, c, transfer.ToWarehouseID.String()); !ok {
+		return
+	}
+
+	id, err := repository.TransferStock(db, transfer)
+	if err == repository.ErrInsufficientStock {
+		c.JSON(http.StatusConflict, gin.H{"error": "Insufficient stock in source warehouse"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	transfer.ID = id
+	c.JSON(http.StatusCreated, transfer)
+}
+
+// GetStockTransfersHandler handles listing the transfers into and out of a warehouse
+func GetStockTransfersHandler(db *sql.DB, c *gin.Context) {
+	warehouse, ok := ownedWarehouse(db, c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	transfers, err := repository.GetStockTransfersByWarehouse(db, warehouse.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, transfers)
+}
+
+// ownedWarehouse loads a warehouse and checks it belongs to the business admin making the request.
+// It writes the error response and returns false if it doesn't.
+func ownedWarehouse(db *sql.DB, c *gin.Context, id string) (*models.Warehouse, bool) {
+	businessAdminID, ok := getRoleID(c, "business_admin")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+
+	warehouseID, err := uuid.Parse(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid warehouse ID"})
+		return nil, false
+	}
+
+	warehouse, err := repository.GetWarehouseByID(db, warehouseID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Warehouse not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if warehouse.BusinessAdminID != businessAdminID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Warehouse does not belong to this business admin"})
+		return nil, false
+	}
+	return warehouse, true
+}
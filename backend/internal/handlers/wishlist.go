@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"chainwave/backend/internal/money"
+	"chainwave/backend/internal/repository"
+	"database/sql"
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// GetWishlistHandler handles a customer fetching their wishlist, with prices and units localized as in listings
+func GetWishlistHandler(db *sql.DB, rates money.RateSource, c *gin.Context) {
+	customerID, ok := getRoleID(c, "customer")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	system, ok := unitSystem(db, c)
+	if !ok {
+		return
+	}
+	currency, ok := displayCurrency(db, c)
+	if !ok {
+		return
+	}
+
+	wishlist, err := repository.GetWishlist(db, customerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for i := range wishlist {
+		item := &wishlist[i].Item
+		localizeItem(&item.Weight, &item.WeightUnit, &item.Dimensions, system)
+		item.DisplayPrice, item.DisplaySalePrice = localizePrice(c, rates, item.Price, item.SalePrice, currency)
+	}
+	c.JSON(http.StatusOK, wishlist)
+}
+
+// AddWishlistItemHandler handles a customer saving an item to their wishlist
+func AddWishlistItemHandler(db *sql.DB, c *gin.Context) {
+	customerID, itemID, ok := wishlistRequest(c)
+	if !ok {
+		return
+	}
+	if !writeWishlistError(c, repository.AddWishlistItem(db, customerID, itemID)) {
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// RemoveWishlistItemHandler handles a customer taking an item off their wishlist
+func RemoveWishlistItemHandler(db *sql.DB, c *gin.Context) {
+	customerID, itemID, ok := wishlistRequest(c)
+	if !ok {
+		return
+	}
+	if !writeWishlistError(c, repository.RemoveWishlistItem(db, customerID, itemID)) {
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetStockSubscriptionsHandler handles a customer listing the items they asked to be told are back in stock
+func GetStockSubscriptionsHandler(db *sql.DB, c *gin.Context) {
+	customerID, ok := getRoleID(c, "customer")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	subs, err := repository.GetStockSubscriptions(db, customerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, subs)
+}
+
+// SubscribeToStockHandler handles a customer asking to be notified when an item out of stock comes back.
+// The notification is stored for them and pushed as an inventory.back_in_stock event on their event stream.
+func SubscribeToStockHandler(db *sql.DB, c *gin.Context) {
+	customerID, itemID, ok := wishlistRequest(c)
+	if !ok {
+		return
+	}
+
+	sub, err := repository.SubscribeToStock(db, customerID, itemID)
+	if !writeWishlistError(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, sub)
+}
+
+// UnsubscribeFromStockHandler handles a customer cancelling a back-in-stock subscription
+func UnsubscribeFromStockHandler(db *sql.DB, c *gin.Context) {
+	customerID, itemID, ok := wishlistRequest(c)
+	if !ok {
+		return
+	}
+	if !writeWishlistError(c, repository.UnsubscribeFromStock(db, customerID, itemID)) {
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetStockNotificationsHandler handles a customer listing their back-in-stock notifications, including those
+// sent while they were offline. ?unread=true leaves out the ones already read.
+func GetStockNotificationsHandler(db *sql.DB, c *gin.Context) {
+	customerID, ok := getRoleID(c, "customer")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	limit, offset := pagination(c)
+
+	notifications, err := repository.GetStockNotifications(db, customerID, c.Query("unread") == "true", limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, notifications)
+}
+
+// MarkStockNotificationReadHandler handles a customer marking a back-in-stock notification as read
+func MarkStockNotificationReadHandler(db *sql.DB, c *gin.Context) {
+	customerID, ok := getRoleID(c, "customer")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	notificationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification ID"})
+		return
+	}
+
+	notification, err := repository.MarkStockNotificationRead(db, customerID, notificationID)
+	if !writeWishlistError(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, notification)
+}
+
+// wishlistRequest reads the customer and the item from the path. It writes the error response and returns
+// false if either is missing.
+func wishlistRequest(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	customerID, ok := getRoleID(c, "customer")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	itemID, err := uuid.Parse(c.Param("itemId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return customerID, itemID, true
+}
+
+// writeWishlistError writes the response for an error with a wishlist or subscription. It returns true if
+// there was none.
+func writeWishlistError(c *gin.Context, err error) bool {
+	switch err {
+	case nil:
+		return true
+	case repository.ErrItemNotFound, repository.ErrNotInWishlist, repository.ErrNotSubscribed, repository.ErrStockNotificationNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case repository.ErrItemInStock:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+	return false
+}
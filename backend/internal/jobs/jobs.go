@@ -0,0 +1,21 @@
+package jobs
+
+import (
+	"log"
+	"time"
+)
+
+// RunEvery runs a job on a fixed interval for the lifetime of the process, logging failures.
+// The first run happens immediately.
+func RunEvery(name string, interval time.Duration, job func() error) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := job(); err != nil {
+				log.Printf("job %s failed: %v", name, err)
+			}
+			<-ticker.C
+		}
+	}()
+}
@@ -0,0 +1,97 @@
+package matching
+
+import (
+	"chainwave/backend/internal/geo"
+	"chainwave/backend/internal/models"
+	"errors"
+	"math"
+	"sort"
+)
+
+var (
+	ErrVehicleUnavailable = errors.New("vehicle is not available for new shipments")
+	ErrBeyondMaxDistance  = errors.New("pickup is beyond the vehicle's max distance")
+	ErrOverCapacity       = errors.New("shipment weight exceeds the vehicle's remaining capacity")
+)
+
+// Request describes the shipment a carrier is needed for
+type Request struct {
+	PickupLatitude  float64
+	PickupLongitude float64
+	DropLatitude    float64
+	DropLongitude   float64
+	Weight          float64
+}
+
+// RemainingCapacity returns how much more weight a vehicle can carry
+func RemainingCapacity(vehicle models.Vehicle) float64 {
+	return vehicle.MaxCapacity - vehicle.CurrentCapacity
+}
+
+// ValidateAssignment checks that a vehicle is active, can reach the pickup within its max distance
+// and has enough remaining capacity for the weight
+func ValidateAssignment(vehicle models.Vehicle, pickup geo.Point, weight float64) error {
+	if vehicle.Status != models.VehicleStatusActive || vehicle.RetiredAt != nil {
+		return ErrVehicleUnavailable
+	}
+	if RemainingCapacity(vehicle) < weight {
+		return ErrOverCapacity
+	}
+	if pickup.Distance(geo.Point{Latitude: vehicle.Latitude, Longitude: vehicle.Longitude}) > vehicle.MaxDistance {
+		return ErrBeyondMaxDistance
+	}
+	return nil
+}
+
+// Weights of closeness to the pickup and spare capacity in a vehicle's score. They add up to 1.
+const (
+	DistanceWeight = 0.7
+	CapacityWeight = 0.3
+)
+
+// RankVehicles returns the vehicles that can carry the request, best scored first.
+// A vehicle is eligible if it has enough remaining capacity for the weight and the pickup is within
+// its max distance. Each is scored on how close it is to the pickup and how much capacity it has to
+// spare, both relative to the other eligible vehicles, so a slightly farther vehicle with much more room
+// can outrank the nearest one. Vehicles with the same score are ordered nearest first.
+func RankVehicles(vehicles []models.Vehicle, request Request) []models.VehicleMatch {
+	tripDistance := geo.Haversine(request.PickupLatitude, request.PickupLongitude, request.DropLatitude, request.DropLongitude)
+
+	pickup := geo.Point{Latitude: request.PickupLatitude, Longitude: request.PickupLongitude}
+
+	matches := make([]models.VehicleMatch, 0)
+	var farthest, roomiest float64
+	for _, vehicle := range vehicles {
+		if ValidateAssignment(vehicle, pickup, request.Weight) != nil {
+			continue
+		}
+		match := models.VehicleMatch{
+			Vehicle:           vehicle,
+			DistanceToPickup:  pickup.Distance(geo.Point{Latitude: vehicle.Latitude, Longitude: vehicle.Longitude}),
+			TripDistance:      tripDistance,
+			RemainingCapacity: RemainingCapacity(vehicle),
+		}
+		farthest = math.Max(farthest, match.DistanceToPickup)
+		roomiest = math.Max(roomiest, match.RemainingCapacity)
+		matches = append(matches, match)
+	}
+
+	for i := range matches {
+		closeness, room := 1.0, 1.0
+		if farthest > 0 {
+			closeness = 1 - matches[i].DistanceToPickup/farthest
+		}
+		if roomiest > 0 {
+			room = matches[i].RemainingCapacity / roomiest
+		}
+		matches[i].Score = DistanceWeight*closeness + CapacityWeight*room
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].DistanceToPickup < matches[j].DistanceToPickup
+	})
+	return matches
+}
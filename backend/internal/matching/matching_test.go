@@ -0,0 +1,71 @@
+package matching
+
+import (
+	"chainwave/backend/internal/models"
+	"testing"
+)
+
+func vehicleAt(make string, latitude, maxCapacity, currentCapacity float64) models.Vehicle {
+	return models.Vehicle{
+		Make:            make,
+		Latitude:        latitude,
+		MaxDistance:     100,
+		MaxCapacity:     maxCapacity,
+		CurrentCapacity: currentCapacity,
+		Status:          models.VehicleStatusActive,
+	}
+}
+
+func TestRankVehicles(t *testing.T) {
+	// About 111 km to a degree of latitude, so 0.09 is roughly 10 km from the pickup at the origin
+	tests := []struct {
+		name     string
+		vehicles []models.Vehicle
+		want     []string
+	}{
+		{
+			name:     "much more room outranks slightly nearer",
+			vehicles: []models.Vehicle{vehicleAt("near", 0.09, 20, 10), vehicleAt("roomy", 0.108, 100, 0)},
+			want:     []string{"roomy", "near"},
+		},
+		{
+			name:     "much nearer outranks more room",
+			vehicles: []models.Vehicle{vehicleAt("roomy", 0.45, 100, 0), vehicleAt("near", 0.009, 20, 10)},
+			want:     []string{"near", "roomy"},
+		},
+		{
+			name:     "ineligible left out",
+			vehicles: []models.Vehicle{vehicleAt("full", 0.01, 20, 18), vehicleAt("far", 1.5, 100, 0), vehicleAt("ok", 0.2, 20, 0)},
+			want:     []string{"ok"},
+		},
+		{
+			// With the anchor setting the farthest distance and the most room, the nearer vehicle scores
+			// 0.7*0.75 + 0.3*0.10 and the roomier one 0.7*0.6 + 0.3*0.45, both 0.555
+			name: "equal scores nearest first",
+			vehicles: []models.Vehicle{
+				vehicleAt("roomier", 0.32, 50, 5), vehicleAt("nearer", 0.2, 20, 10), vehicleAt("anchor", 0.8, 100, 0),
+			},
+			want: []string{"nearer", "roomier", "anchor"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches := RankVehicles(tt.vehicles, Request{DropLatitude: 1, Weight: 5})
+			got := make([]string, len(matches))
+			for i, match := range matches {
+				got[i] = match.Vehicle.Make
+				if match.Score < 0 || match.Score > 1 {
+					t.Errorf("%s scored %v, want a score from 0 to 1", match.Vehicle.Make, match.Score)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("RankVehicles() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("RankVehicles() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
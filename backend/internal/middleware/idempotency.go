@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"bytes"
+	"chainwave/backend/internal/repository"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IdempotencyKeyHeader names the header clients set to make a POST safe to retry
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// maxIdempotencyKeyLength bounds the keys clients may send
+const maxIdempotencyKeyLength = 255
+
+// idempotencyCommittedKey is the context key KeepIdempotencyKey sets
+const idempotencyCommittedKey = "idempotencyCommitted"
+
+// KeepIdempotencyKey tells IdempotencyMiddleware the request has committed a change, such as placing an
+// order, so its response is stored and replayed even if it ends in a server error. Otherwise a retry with
+// the same key would make the change a second time.
+func KeepIdempotencyKey(c *gin.Context) {
+	c.Set(idempotencyCommittedKey, true)
+}
+
+// recordingWriter keeps a copy of the response body as it is written
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// IdempotencyMiddleware honours the Idempotency-Key header on POST requests from authenticated users.
+// The first request with a key runs and its response is stored for ttl; a retry with the same key and the
+// same method, path and body gets that response replayed with an Idempotent-Replayed header instead of
+// running again. Reusing a key for a different request is rejected with 422, and a retry arriving while the
+// first request is still running with 409. Server errors aren't stored, so those requests can be retried,
+// unless the handler called KeepIdempotencyKey after committing a change.
+// It must run after the authentication middleware, as keys are scoped to the user.
+func IdempotencyMiddleware(db *sql.DB, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		scope := c.GetString("userID")
+		if c.Request.Method != http.MethodPost || key == "" || scope == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key must be at most 255 characters"})
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := sha256.New()
+		hash.Write([]byte(c.Request.Method + " " + c.Request.URL.RequestURI() + "\n"))
+		hash.Write(body)
+		requestHash := hex.EncodeToString(hash.Sum(nil))
+
+		stored, err := repository.ClaimIdempotencyKey(db, scope, key, requestHash, ttl)
+		switch {
+		case err == repository.ErrIdempotencyKeyReused:
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		case err == repository.ErrIdempotencyKeyInProgress:
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		case stored != nil:
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(stored.StatusCode, stored.ContentType, stored.Body)
+			c.Abort()
+			return
+		}
+
+		writer := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		if writer.Status() >= http.StatusInternalServerError && !c.GetBool(idempotencyCommittedKey) {
+			if err := repository.ReleaseIdempotencyKey(db, scope, key); err != nil {
+				log.Printf("failed to release idempotency key: %v", err)
+			}
+			return
+		}
+		err = repository.SaveIdempotentResponse(db, scope, key, repository.IdempotentResponse{
+			StatusCode:  writer.Status(),
+			ContentType: writer.Header().Get("Content-Type"),
+			Body:        writer.body.Bytes(),
+		})
+		if err != nil {
+			log.Printf("failed to store response for idempotency key: %v", err)
+		}
+	}
+}
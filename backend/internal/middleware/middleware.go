@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"chainwave/backend/internal/repository"
 	"net/http"
 	"strings"
 
@@ -14,7 +15,8 @@ func CORSMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization") 
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Cart-Token, Idempotency-Key")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Cart-Token, Idempotent-Replayed")
 		if c.Request.Method == http.MethodOptions {
 			c.JSON(http.StatusOK, nil)
 			return
@@ -104,6 +106,34 @@ func RoleSwitchMiddleware(db *sql.DB) gin.HandlerFunc {
 }
 
 
+// ModeratorMiddleware only lets the 'admin' user through, for moderating what other users post.
+// It must run after AuthMiddleware.
+func ModeratorMiddleware(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusForbidden, gin.H{"error": "User ID not found"})
+			c.Abort()
+			return
+		}
+
+		var username string
+		err := db.QueryRow("SELECT username FROM users WHERE id = $1", userID).Scan(&username)
+		if err != nil && err != sql.ErrNoRows {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve username"})
+			c.Abort()
+			return
+		}
+		if username != "admin" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only moderators can do this"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
 // AuthAdminMiddleware extracts the user ID from the JWT token, fetches the roles from the database, and sets them in the context.
 func AuthAdminMiddleware(secretKey string, db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -173,4 +203,35 @@ func FormContentTypeMiddleware() gin.HandlerFunc {
 		c.Writer.Header().Set("Content-Type", "multipart/form-data")
 		c.Next()
 	}
-}
\ No newline at end of file
+}
+
+// EventTicketMiddleware authenticates an event stream with a single use ticket from the "ticket" query parameter,
+// setting the user and roles it was issued for. Browsers can't set headers on EventSource connections, and a ticket
+// in the URL is harmless in access logs once it has been used, unlike a token.
+func EventTicketMiddleware(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ticket := c.Query("ticket")
+		if ticket == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Event ticket required"})
+			c.Abort()
+			return
+		}
+
+		redeemed, err := repository.RedeemEventTicket(db, ticket)
+		if err == repository.ErrEventTicketInvalid {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+
+		c.Set("userID", redeemed.UserID.String())
+		c.Set("roles", redeemed.Roles)
+		c.Set("roleTypes", redeemed.RoleTypes)
+		c.Next()
+	}
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Cart struct is a customer's or guest's shopping cart. It is revalidated against current prices and
+// stock whenever it is read, and Orderable is false while any line can't be ordered as it stands.
+// Amounts are in Currency, the sellers' currency. Subtotal is after sales and Total after the coupon too;
+// CouponError says why a coupon no longer applies.
+type Cart struct {
+	ID            uuid.UUID  `json:"id"`
+	CustomerID    *uuid.UUID `json:"customer_id,omitempty"`
+	GuestToken    string     `json:"guest_token,omitempty"`
+	Items         []CartItem `json:"items"`
+	ItemCount     int        `json:"item_count"`
+	Currency      string     `json:"currency,omitempty"`
+	Subtotal      float64    `json:"subtotal"`
+	CouponCode    string     `json:"coupon_code,omitempty"`
+	CouponError   string     `json:"coupon_error,omitempty"`
+	CurrencyError string     `json:"currency_error,omitempty"`
+	Discount      float64    `json:"discount"`
+	Total         float64    `json:"total"`
+	Orderable     bool       `json:"orderable"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// CartItem struct is a line of a cart. Price is the item's current price after any sale on ListPrice and
+// AddedPrice what it cost when the line was last changed, Discount is its share of the coupon and Available
+// is the stock currently on hand.
+type CartItem struct {
+	ItemID          uuid.UUID `json:"item_id"`
+	Name            string    `json:"name"`
+	ImageURL        string    `json:"image_url"`
+	BusinessAdminID uuid.UUID `json:"business_admin_id"`
+	Quantity        int       `json:"quantity"`
+	Currency        string    `json:"currency"`
+	ListPrice       float64   `json:"list_price"`
+	Price           float64   `json:"price"`
+	AddedPrice      float64   `json:"added_price"`
+	PriceChanged    bool      `json:"price_changed"`
+	Available       int       `json:"available"`
+	InStock         bool      `json:"in_stock"`
+	Discount        float64   `json:"discount"`
+	LineTotal       float64   `json:"line_total"`
+}
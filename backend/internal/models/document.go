@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Kinds of order document
+const (
+	DocumentInvoice     = "invoice"
+	DocumentPackingSlip = "packing_slip"
+)
+
+// OrderDocument struct is an invoice or packing slip issued for one seller's part of an order. Number is
+// the seller's sequential invoice number and is empty for packing slips.
+type OrderDocument struct {
+	ID              uuid.UUID `json:"id"`
+	OrderID         uuid.UUID `json:"order_id"`
+	SellerOrderID   uuid.UUID `json:"seller_order_id"`
+	BusinessAdminID uuid.UUID `json:"business_admin_id"`
+	Kind            string    `json:"kind"`
+	Number          string    `json:"number,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// PackingSlipLine struct is an item to pack, with the quantity still to ship after cancellations
+type PackingSlipLine struct {
+	ItemID     uuid.UUID `json:"item_id"`
+	Name       string    `json:"name"`
+	Warehouse  string    `json:"warehouse"`
+	Quantity   int       `json:"quantity"`
+	Weight     float64   `json:"weight"`
+	WeightUnit string    `json:"weight_unit"`
+}
+
+// PackingSlip struct is what goes in the box with one seller's part of an order
+type PackingSlip struct {
+	OrderID       uuid.UUID         `json:"order_id"`
+	SellerOrderID uuid.UUID         `json:"seller_order_id"`
+	OrderedAt     time.Time         `json:"ordered_at"`
+	Seller        TaxParty          `json:"seller"`
+	ShipTo        TaxParty          `json:"ship_to"`
+	Lines         []PackingSlipLine `json:"lines"`
+}
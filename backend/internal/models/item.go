@@ -1,36 +1,66 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"chainwave/backend/internal/money"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Dimensions struct is the length, width and height of an item in a length unit
+type Dimensions struct {
+	Length float64 `form:"length" json:"length"`
+	Width  float64 `form:"width" json:"width"`
+	Height float64 `form:"height" json:"height"`
+	Unit   string  `form:"dimension_unit" json:"unit"`
+}
 
 // Item struct
 type Item struct {
-	Id              uuid.UUID `form:"id"`
-	BusinessAdminId uuid.UUID `form:"business_admin_id"`
-	Name            string    `form:"name"`
-	Description     string    `form:"description"`
-	Price           float64   `form:"price"`
-	Weight          float64   `form:"weight"`
-	Dimensions      string    `form:"dimensions"`
-	Category        string    `form:"category"`
-	Quantity        int       `form:"quantity"`
-	ImageURL        string    `form:"image_url"`
+	Id              uuid.UUID   `form:"id"`
+	BusinessAdminId uuid.UUID   `form:"business_admin_id"`
+	Name            string      `form:"name"`
+	Description     string      `form:"description"`
+	Price           money.Money `form:"-"` // in the seller's currency, read from the price field by handlers
+	Weight          float64     `form:"weight"`
+	WeightUnit      string      `form:"weight_unit"`
+	Dimensions      Dimensions
+	Category        string       `form:"category"`
+	Quantity        int          `form:"quantity"`
+	ImageURL        string       `form:"image_url"`
+	Distance        *float64     `form:"-" json:"Distance,omitempty"`  // km from the searched point, set by nearby searches
+	SalePrice       *money.Money `form:"-" json:"SalePrice,omitempty"` // price under the best running sale, if any
+	SaleEndsAt      *time.Time   `form:"-" json:"SaleEndsAt,omitempty"`
+	// Price and SalePrice converted to the currency the customer views prices in, when it differs
+	DisplayPrice     *money.Money `form:"-" json:"DisplayPrice,omitempty"`
+	DisplaySalePrice *money.Money `form:"-" json:"DisplaySalePrice,omitempty"`
+	// Average rating and number of reviews, not counting reviews hidden by moderation
+	Rating      float64 `form:"-"`
+	ReviewCount int     `form:"-"`
 }
 
 // ItemWithDetail struct includes business admin and location details
 type ItemWithDetail struct {
-	Id                       uuid.UUID `json:"id"`
-	BusinessAdminId          uuid.UUID `json:"business_admin_id"`
-	Name                     string    `json:"name"`
-	Description              string    `json:"description"`
-	Price                    float64   `json:"price"`
-	Weight                   float64   `json:"weight"`
-	Dimensions               string    `json:"dimensions"`
-	Category                 string    `json:"category"`
-	Quantity                 int       `json:"quantity"`
-	ImageURL                 string    `json:"image_url"`
-	BusinessAdminCompanyName string    `json:"business_admin_company_name"`
-	BusinessAdminContactInfo string    `json:"business_admin_contact_info"`
-	LocationAddress          string    `json:"location_address"`
-	LocationCity             string    `json:"location_city"`
-	LocationState            string    `json:"location_state"`
+	Id                       uuid.UUID    `json:"id"`
+	BusinessAdminId          uuid.UUID    `json:"business_admin_id"`
+	Name                     string       `json:"name"`
+	Description              string       `json:"description"`
+	Price                    money.Money  `json:"price"`
+	Weight                   float64      `json:"weight"`
+	WeightUnit               string       `json:"weight_unit"`
+	Dimensions               Dimensions   `json:"dimensions"`
+	Category                 string       `json:"category"`
+	Quantity                 int          `json:"quantity"`
+	ImageURL                 string       `json:"image_url"`
+	BusinessAdminCompanyName string       `json:"business_admin_company_name"`
+	BusinessAdminContactInfo string       `json:"business_admin_contact_info"`
+	LocationAddress          string       `json:"location_address"`
+	LocationCity             string       `json:"location_city"`
+	LocationState            string       `json:"location_state"`
+	SalePrice                *money.Money `json:"sale_price,omitempty"`
+	SaleEndsAt               *time.Time   `json:"sale_ends_at,omitempty"`
+	DisplayPrice             *money.Money `json:"display_price,omitempty"`
+	DisplaySalePrice         *money.Money `json:"display_sale_price,omitempty"`
+	Rating                   float64      `json:"rating"`
+	ReviewCount              int          `json:"review_count"`
 }
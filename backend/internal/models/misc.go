@@ -1,19 +1,34 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Vehicle availability statuses
+const (
+	VehicleStatusActive      = "active"
+	VehicleStatusMaintenance = "maintenance"
+	VehicleStatusOffline     = "offline"
+)
 
 // Vehicle struct
 type Vehicle struct {
-	ID              uuid.UUID `json:"id"`
-	TransporterID   uuid.UUID `json:"transporter_id"`
-	Make            string    `json:"make"`
-	Model           string    `json:"model"`
-	Year            int       `json:"year"`
-	Latitude        float64   `json:"latitude"`
-	Longitude       float64   `json:"longitude"`
-	MaxDistance     float64   `json:"max_distance"`
-	MaxCapacity     float64   `json:"max_capacity"`
-	CurrentCapacity float64   `json:"current_capacity"`
+	ID              uuid.UUID  `json:"id"`
+	TransporterID   uuid.UUID  `json:"transporter_id"`
+	Make            string     `json:"make"`
+	Model           string     `json:"model"`
+	Year            int        `json:"year"`
+	Latitude        float64    `json:"latitude"`
+	Longitude       float64    `json:"longitude"`
+	MaxDistance     float64    `json:"max_distance"`
+	MaxCapacity     float64    `json:"max_capacity"`
+	CurrentCapacity float64    `json:"current_capacity"`
+	Status          string     `json:"status"`
+	DriverName      string     `json:"driver_name"`
+	DriverContact   string     `json:"driver_contact"`
+	RetiredAt       *time.Time `json:"retired_at,omitempty"`
 }
 
 // Location struct
@@ -26,4 +41,39 @@ type Location struct {
 	PostalCode string    `json:"postal_code"`
 	Latitude   float64   `json:"latitude"`
 	Longitude  float64   `json:"longitude"`
+	Label      string    `json:"label"`
+}
+
+// SavedLocation struct is a location in a user's address book
+type SavedLocation struct {
+	Location
+	DefaultShipping bool `json:"default_shipping"`
+}
+
+// VehicleMatch struct is a vehicle eligible to carry a shipment, with how far it is from the pickup and
+// the score it was ranked by, from 0 to 1
+type VehicleMatch struct {
+	Vehicle           Vehicle `json:"vehicle"`
+	DistanceToPickup  float64 `json:"distance_to_pickup"`
+	TripDistance      float64 `json:"trip_distance"`
+	RemainingCapacity float64 `json:"remaining_capacity"`
+	Score             float64 `json:"score"`
+}
+
+// VehiclePosition struct is a GPS ping sent by a vehicle
+type VehiclePosition struct {
+	ID         uuid.UUID `json:"id"`
+	VehicleID  uuid.UUID `json:"vehicle_id"`
+	Latitude   float64   `json:"latitude"`
+	Longitude  float64   `json:"longitude"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// GeocodeResult struct is where a geocoder placed an address
+type GeocodeResult struct {
+	Latitude    float64    `json:"latitude"`
+	Longitude   float64    `json:"longitude"`
+	CountryCode string     `json:"country_code"`
+	DisplayName string     `json:"display_name"`
+	BoundingBox [4]float64 `json:"bounding_box"` // min latitude, max latitude, min longitude, max longitude
 }
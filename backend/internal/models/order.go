@@ -0,0 +1,101 @@
+package models
+
+import (
+	"chainwave/backend/internal/money"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Order statuses, matching the values shown on the orders page
+const (
+	OrderStatusPending    = "Pending"
+	OrderStatusProcessing = "Processing"
+	OrderStatusShipped    = "Shipped"
+	OrderStatusDelivered  = "Delivered"
+	OrderStatusCancelled  = "Cancelled"
+)
+
+// Order struct. Amounts are in Currency, which the customer is charged in. Total includes the shipping cost
+// of every seller's package and the tax charged on top of prices, less the coupon Discount. Tax is every
+// tax on the order, including taxes already part of prices.
+type Order struct {
+	ID           uuid.UUID       `json:"id"`
+	CustomerID   uuid.UUID       `json:"customer_id"`
+	LocationID   uuid.UUID       `json:"location_id"`
+	Status       string          `json:"status"`
+	Currency     string          `json:"currency"`
+	Total        money.Money     `json:"total"`
+	ShippingCost money.Money     `json:"shipping_cost"`
+	Discount     money.Money     `json:"discount"`
+	Tax          money.Money     `json:"tax"`
+	CouponCode   string          `json:"coupon_code,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+	Items        []OrderItem     `json:"items"`
+	Shipping     []ShippingQuote `json:"shipping"`
+	SellerOrders []SellerOrder   `json:"seller_orders"`
+}
+
+// SellerOrder struct is the part of an order sold by one business admin, fulfilled and shipped on its own.
+// Subtotal is their lines after sales and coupon, Tax the tax on them and TaxExclusive the part of it charged on top of prices.
+// Payout is what the seller is owed for it: their lines, tax charged on top and shipping, less what has been refunded, and
+// nothing once it is cancelled.
+type SellerOrder struct {
+	ID              uuid.UUID   `json:"id"`
+	OrderID         uuid.UUID   `json:"order_id"`
+	BusinessAdminID uuid.UUID   `json:"business_admin_id"`
+	Status          string      `json:"status"`
+	Subtotal        money.Money `json:"subtotal"`
+	Tax             money.Money `json:"tax"`
+	TaxExclusive    money.Money `json:"tax_exclusive"`
+	ShippingCost    money.Money `json:"shipping_cost"`
+	Refunded        money.Money `json:"refunded"`
+	Payout          money.Money `json:"payout"`
+	CreatedAt       time.Time   `json:"created_at"`
+	UpdatedAt       time.Time   `json:"updated_at"`
+	Items           []OrderItem `json:"items,omitempty"`
+	Shipments       []Shipment  `json:"shipments,omitempty"`
+}
+
+// OrderItem struct is a single line of an order, fulfilled from one warehouse. Price is the unit price
+// after any sale on ListPrice, and Discount the line's share of the order's coupon. Tax is every tax on the
+// line, broken down in Taxes, and TaxExclusive the part of it charged on top of the price.
+// CancelledQuantity has been returned to stock and RefundedQuantity refunded to the customer.
+type OrderItem struct {
+	ID                uuid.UUID      `json:"id"`
+	OrderID           uuid.UUID      `json:"order_id"`
+	SellerOrderID     *uuid.UUID     `json:"seller_order_id,omitempty"`
+	ItemID            uuid.UUID      `json:"item_id"`
+	WarehouseID       *uuid.UUID     `json:"warehouse_id,omitempty"`
+	Quantity          int            `json:"quantity"`
+	ListPrice         money.Money    `json:"list_price"`
+	Price             money.Money    `json:"price"`
+	Discount          money.Money    `json:"discount"`
+	Tax               money.Money    `json:"tax"`
+	TaxExclusive      money.Money    `json:"tax_exclusive"`
+	Taxes             []OrderItemTax `json:"taxes,omitempty"`
+	CancelledQuantity int            `json:"cancelled_quantity"`
+	RefundedQuantity  int            `json:"refunded_quantity"`
+}
+
+// OrderStatusChange struct is an entry in an order's status history. SellerOrderID is set when one seller's
+// part of the order moved, and FromStatus is empty for the status an order was placed in. ActorID is the
+// customer, business admin or transporter who made the change, and nil for the system.
+type OrderStatusChange struct {
+	ID            int64      `json:"id"`
+	OrderID       uuid.UUID  `json:"order_id"`
+	SellerOrderID *uuid.UUID `json:"seller_order_id,omitempty"`
+	FromStatus    string     `json:"from_status"`
+	ToStatus      string     `json:"to_status"`
+	ActorRole     string     `json:"actor_role"`
+	ActorID       *uuid.UUID `json:"actor_id,omitempty"`
+	Note          string     `json:"note"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// OrderLineRequest struct is an item and quantity requested by a customer
+type OrderLineRequest struct {
+	ItemID   uuid.UUID `json:"item_id"`
+	Quantity int       `json:"quantity"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Payment statuses
+const (
+	PaymentStatusCreated  = "created"
+	PaymentStatusCaptured = "captured"
+	PaymentStatusFailed   = "failed"
+	// PaymentStatusDuplicate is a payment captured for an order that was already paid, refunded in full
+	PaymentStatusDuplicate = "duplicate"
+)
+
+// Payment struct is an attempt to pay for an order through a payment provider.
+// Amount is in the currency's minor unit, such as paise or cents. DuplicateOf is set on a duplicate payment
+// to the payment that paid the order first.
+type Payment struct {
+	ID                uuid.UUID  `json:"id"`
+	OrderID           uuid.UUID  `json:"order_id"`
+	Provider          string     `json:"provider"`
+	ProviderOrderID   string     `json:"provider_order_id"`
+	ProviderPaymentID *string    `json:"provider_payment_id,omitempty"`
+	Amount            int64      `json:"amount"`
+	Currency          string     `json:"currency"`
+	Status            string     `json:"status"`
+	FailureReason     string     `json:"failure_reason,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+	CapturedAt        *time.Time `json:"captured_at,omitempty"`
+	DuplicateOf       *uuid.UUID `json:"duplicate_of,omitempty"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Sale struct is a discount a business admin runs for a period on one of their items, or on all their
+// items in a category when ItemID is nil. Kind is percentage or fixed.
+type Sale struct {
+	ID              uuid.UUID  `json:"id"`
+	BusinessAdminID uuid.UUID  `json:"business_admin_id"`
+	ItemID          *uuid.UUID `json:"item_id,omitempty"`
+	Category        string     `json:"category,omitempty"`
+	Kind            string     `json:"kind"`
+	Value           float64    `json:"value"`
+	StartsAt        time.Time  `json:"starts_at"`
+	EndsAt          time.Time  `json:"ends_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// Coupon struct is a discount on a business admin's items that customers claim with a code. Limits left
+// nil don't apply, and Uses counts redemptions on orders that weren't cancelled.
+type Coupon struct {
+	ID                 uuid.UUID  `json:"id"`
+	BusinessAdminID    uuid.UUID  `json:"business_admin_id"`
+	Code               string     `json:"code"`
+	Kind               string     `json:"kind"`
+	Value              float64    `json:"value"`
+	MinSubtotal        float64    `json:"min_subtotal"`
+	StartsAt           *time.Time `json:"starts_at,omitempty"`
+	EndsAt             *time.Time `json:"ends_at,omitempty"`
+	MaxUses            *int       `json:"max_uses,omitempty"`
+	MaxUsesPerCustomer *int       `json:"max_uses_per_customer,omitempty"`
+	StacksWithSales    bool       `json:"stacks_with_sales"`
+	Active             bool       `json:"active"`
+	Uses               int        `json:"uses"`
+	CreatedAt          time.Time  `json:"created_at"`
+}
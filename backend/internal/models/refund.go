@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Refund statuses
+const (
+	RefundStatusPending   = "pending"
+	RefundStatusProcessed = "processed"
+	RefundStatusFailed    = "failed"
+)
+
+// Refund struct returns part or all of an order's captured payment to the customer.
+// Amount is in the payment currency's minor unit and includes any shipping refunded on top of the items.
+type Refund struct {
+	ID               uuid.UUID            `json:"id"`
+	OrderID          uuid.UUID            `json:"order_id"`
+	PaymentID        uuid.UUID            `json:"payment_id"`
+	ProviderRefundID *string              `json:"provider_refund_id,omitempty"`
+	Amount           int64                `json:"amount"`
+	Currency         string               `json:"currency"`
+	Status           string               `json:"status"`
+	Reason           string               `json:"reason"`
+	InitiatedBy      string               `json:"initiated_by"`
+	CreatedAt        time.Time            `json:"created_at"`
+	UpdatedAt        time.Time            `json:"updated_at"`
+	Items            []RefundItem         `json:"items"`
+	History          []RefundStatusChange `json:"history"`
+}
+
+// RefundItem struct is the quantity of an order line a refund covers. Restocked is how much of it
+// went back into stock, which only happens while the order hasn't left the seller.
+type RefundItem struct {
+	OrderItemID uuid.UUID `json:"order_item_id"`
+	ItemID      uuid.UUID `json:"item_id"`
+	Quantity    int       `json:"quantity"`
+	Amount      int64     `json:"amount"`
+	Restocked   int       `json:"restocked"`
+}
+
+// RefundStatusChange struct records a refund reaching a status
+type RefundStatusChange struct {
+	Status    string    `json:"status"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RefundLineRequest struct is a quantity of an order line a seller wants to refund
+type RefundLineRequest struct {
+	OrderItemID uuid.UUID `json:"order_item_id"`
+	Quantity    int       `json:"quantity"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Review moderation statuses. Reviews are hidden once enough verified buyers flag them, and moderators
+// can hide or restore any review.
+const (
+	ReviewStatusPublished = "published"
+	ReviewStatusHidden    = "hidden"
+)
+
+// Item listing sort orders by reviews, alongside the default and nearest first
+const (
+	ItemSortRating  = "rating"
+	ItemSortReviews = "reviews"
+)
+
+// Review struct is a customer's rating of an item they've had delivered, verified by the order line it
+// came on, with the seller's reply if they've given one. FlagCount is only shown to the seller and moderators,
+// and ModeratedAt is when a moderator last hid or restored the review.
+type Review struct {
+	ID              uuid.UUID  `json:"id"`
+	ItemID          uuid.UUID  `json:"item_id"`
+	CustomerID      uuid.UUID  `json:"customer_id"`
+	CustomerName    string     `json:"customer_name"`
+	OrderItemID     uuid.UUID  `json:"order_item_id"`
+	Rating          int        `json:"rating"`
+	Title           string     `json:"title"`
+	Body            string     `json:"body"`
+	Status          string     `json:"status"`
+	FlagCount       int        `json:"flag_count,omitempty"`
+	SellerReply     string     `json:"seller_reply,omitempty"`
+	SellerRepliedAt *time.Time `json:"seller_replied_at,omitempty"`
+	ModeratedAt     *time.Time `json:"moderated_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// ReviewFlag struct is a user reporting a review for moderation. VerifiedBuyer is set when the user has had
+// the item delivered themselves.
+type ReviewFlag struct {
+	ReviewID      uuid.UUID `json:"review_id"`
+	UserID        uuid.UUID `json:"user_id"`
+	Reason        string    `json:"reason"`
+	VerifiedBuyer bool      `json:"verified_buyer"`
+	CreatedAt     time.Time `json:"created_at"`
+}
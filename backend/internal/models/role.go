@@ -18,6 +18,7 @@ type BusinessAdmin struct {
 	ContactInfo  string    `json:"contact_info"`
 	LocationId   uuid.UUID `json:"location_id"`
 	UserId       uuid.UUID `json:"user_id"`
+	Currency     string    `json:"currency"`
 }
 
 // Transporter struct
@@ -47,4 +48,18 @@ type Role struct {
 	BusinessAdminId *uuid.UUID `json:"business_admin_id,omitempty"`
 	TransporterId   *uuid.UUID `json:"transporter_id,omitempty"`
 	SupplierId      *uuid.UUID `json:"supplier_id,omitempty"`
-}
\ No newline at end of file
+}
+
+// SupplierListing struct is a supplier in the directory along with its location
+type SupplierListing struct {
+	Supplier
+	Location Location `json:"location"`
+	Distance *float64 `json:"distance,omitempty"`
+}
+
+// BusinessAdminListing struct is a business admin in the directory along with its location
+type BusinessAdminListing struct {
+	BusinessAdmin
+	Location Location `json:"location"`
+	Distance *float64 `json:"distance,omitempty"`
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Shipment statuses
+const (
+	ShipmentStatusPending   = "pending"
+	ShipmentStatusAccepted  = "accepted"
+	ShipmentStatusPickedUp  = "picked_up"
+	ShipmentStatusDelivered = "delivered"
+	ShipmentStatusCancelled = "cancelled"
+)
+
+// Shipment struct moves part of an order from a pickup location to a drop location
+type Shipment struct {
+	ID               uuid.UUID  `json:"id"`
+	OrderID          uuid.UUID  `json:"order_id"`
+	SellerOrderID    *uuid.UUID `json:"seller_order_id,omitempty"`
+	BusinessAdminID  uuid.UUID  `json:"business_admin_id"`
+	PickupLocationID uuid.UUID  `json:"pickup_location_id"`
+	DropLocationID   uuid.UUID  `json:"drop_location_id"`
+	TransporterID    *uuid.UUID `json:"transporter_id,omitempty"`
+	VehicleID        *uuid.UUID `json:"vehicle_id,omitempty"`
+	Weight           float64    `json:"weight"`
+	Status           string     `json:"status"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	AcceptedAt       *time.Time `json:"accepted_at,omitempty"`
+	PickedUpAt       *time.Time `json:"picked_up_at,omitempty"`
+	DeliveredAt      *time.Time `json:"delivered_at,omitempty"`
+	CancelledAt      *time.Time `json:"cancelled_at,omitempty"`
+}
+
+// ShipmentTracking struct is the live view of a shipment shown to the customer. RemainingDistance and ETA
+// are left out when the pickup or drop location has no coordinates.
+type ShipmentTracking struct {
+	ShipmentID        uuid.UUID         `json:"shipment_id"`
+	Status            string            `json:"status"`
+	CurrentPosition   *VehiclePosition  `json:"current_position,omitempty"`
+	Trail             []VehiclePosition `json:"trail"`
+	RemainingDistance *float64          `json:"remaining_distance,omitempty"`
+	ETA               *time.Time        `json:"eta,omitempty"`
+}
+
+// Route stop types
+const (
+	RouteStopPickup = "pickup"
+	RouteStopDrop   = "drop"
+)
+
+// RouteStop struct is one stop of a vehicle's itinerary
+type RouteStop struct {
+	Sequence           int       `json:"sequence"`
+	ShipmentID         uuid.UUID `json:"shipment_id"`
+	Type               string    `json:"type"`
+	LocationID         uuid.UUID `json:"location_id"`
+	Latitude           float64   `json:"latitude"`
+	Longitude          float64   `json:"longitude"`
+	Distance           float64   `json:"distance"`
+	CumulativeDistance float64   `json:"cumulative_distance"`
+	Load               float64   `json:"load"`
+}
+
+// RoutePlan struct is the ordered itinerary for a vehicle's assigned shipments
+type RoutePlan struct {
+	VehicleID     uuid.UUID   `json:"vehicle_id"`
+	Stops         []RouteStop `json:"stops"`
+	TotalDistance float64     `json:"total_distance"`
+}
+
+// ShipmentWithLocations struct is a shipment along with its pickup and drop locations
+type ShipmentWithLocations struct {
+	Shipment
+	Pickup Location `json:"pickup"`
+	Drop   Location `json:"drop"`
+}
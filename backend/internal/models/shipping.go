@@ -0,0 +1,50 @@
+package models
+
+import (
+	"chainwave/backend/internal/money"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DistanceBand struct is the fee and transit time of a tariff for trips up to a distance
+type DistanceBand struct {
+	MaxDistanceKm float64 `json:"max_distance_km"`
+	Fee           float64 `json:"fee"`
+	TransitDays   int     `json:"transit_days"`
+}
+
+// ShippingTariff struct is a transporter's rate card, with fees in Currency. Platform tariffs have no transporter.
+type ShippingTariff struct {
+	ID            uuid.UUID      `json:"id"`
+	TransporterID *uuid.UUID     `json:"transporter_id,omitempty"`
+	Name          string         `json:"name"`
+	Currency      string         `json:"currency"`
+	BaseFee       float64        `json:"base_fee"`
+	PerKg         float64        `json:"per_kg"`
+	Bands         []DistanceBand `json:"bands"`
+	CreatedAt     time.Time      `json:"created_at"`
+}
+
+// ShippingQuote struct is the price and delivery estimate of shipping one seller's items with a tariff, in the tariff's currency
+type ShippingQuote struct {
+	BusinessAdminID   uuid.UUID   `json:"business_admin_id"`
+	TariffID          uuid.UUID   `json:"tariff_id"`
+	TariffName        string      `json:"tariff_name"`
+	TransporterID     *uuid.UUID  `json:"transporter_id,omitempty"`
+	DistanceKm        float64     `json:"distance_km"`
+	ChargeableWeight  float64     `json:"chargeable_weight"`
+	Cost              money.Money `json:"cost"`
+	TransitDays       int         `json:"transit_days"`
+	EstimatedDelivery time.Time   `json:"estimated_delivery"`
+}
+
+// ShippingPackage struct is the part of a cart shipped by one seller, with a quote from every tariff serving it
+type ShippingPackage struct {
+	BusinessAdminID  uuid.UUID       `json:"business_admin_id"`
+	DistanceKm       float64         `json:"distance_km"`
+	Weight           float64         `json:"weight"`
+	VolumetricWeight float64         `json:"volumetric_weight"`
+	ChargeableWeight float64         `json:"chargeable_weight"`
+	Quotes           []ShippingQuote `json:"quotes"`
+}
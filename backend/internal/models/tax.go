@@ -0,0 +1,89 @@
+package models
+
+import (
+	"chainwave/backend/internal/money"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TaxRule struct is a tax charged on sales to buyers in a country, or one of its states when State is set.
+// Rules with a Category apply to items of that category only, and Scope limits a rule to sales from within
+// the buyer's state (intrastate) or from another state (interstate). Rate is a percentage, and inclusive
+// rules are already part of item prices. BusinessAdminID is nil for rules every seller charges.
+type TaxRule struct {
+	ID              uuid.UUID  `json:"id"`
+	BusinessAdminID *uuid.UUID `json:"business_admin_id,omitempty"`
+	Name            string     `json:"name"`
+	Country         string     `json:"country"`
+	State           string     `json:"state,omitempty"`
+	Category        string     `json:"category,omitempty"`
+	Scope           string     `json:"scope"`
+	Rate            float64    `json:"rate"`
+	Inclusive       bool       `json:"inclusive"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// OrderItemTax struct is one tax charged on an order line, kept as it was when the order was placed.
+// Taxable is the line's amount without tax that Rate was applied to.
+type OrderItemTax struct {
+	TaxRuleID    *uuid.UUID  `json:"tax_rule_id,omitempty"`
+	Name         string      `json:"name"`
+	Jurisdiction string      `json:"jurisdiction"`
+	Rate         float64     `json:"rate"`
+	Inclusive    bool        `json:"inclusive"`
+	Taxable      money.Money `json:"taxable"`
+	Amount       money.Money `json:"amount"`
+}
+
+// TaxParty struct is the seller or buyer named on a tax summary
+type TaxParty struct {
+	Name       string `json:"name"`
+	Address    string `json:"address"`
+	City       string `json:"city"`
+	State      string `json:"state"`
+	Country    string `json:"country"`
+	PostalCode string `json:"postal_code"`
+}
+
+// TaxSummaryLine struct is an order line on a tax summary. Net is the line after discounts without tax,
+// and Total what the buyer pays for it with tax.
+type TaxSummaryLine struct {
+	OrderItemID uuid.UUID   `json:"order_item_id"`
+	ItemID      uuid.UUID   `json:"item_id"`
+	Name        string      `json:"name"`
+	Quantity    int         `json:"quantity"`
+	UnitPrice   money.Money `json:"unit_price"`
+	Discount    money.Money `json:"discount"`
+	Net         money.Money `json:"net"`
+	Tax         money.Money `json:"tax"`
+	Total       money.Money `json:"total"`
+}
+
+// TaxTotal struct is the total of one tax over a summary's lines
+type TaxTotal struct {
+	Name         string      `json:"name"`
+	Jurisdiction string      `json:"jurisdiction"`
+	Rate         float64     `json:"rate"`
+	Inclusive    bool        `json:"inclusive"`
+	Taxable      money.Money `json:"taxable"`
+	Amount       money.Money `json:"amount"`
+}
+
+// TaxSummary struct is what an invoice for one seller's part of an order shows: the parties, the lines
+// with their tax, and the totals of each tax. Total is Subtotal and Tax plus shipping.
+type TaxSummary struct {
+	OrderID         uuid.UUID        `json:"order_id"`
+	SellerOrderID   uuid.UUID        `json:"seller_order_id"`
+	BusinessAdminID uuid.UUID        `json:"business_admin_id"`
+	Currency        string           `json:"currency"`
+	OrderedAt       time.Time        `json:"ordered_at"`
+	Seller          TaxParty         `json:"seller"`
+	Buyer           TaxParty         `json:"buyer"`
+	Lines           []TaxSummaryLine `json:"lines"`
+	Taxes           []TaxTotal       `json:"taxes"`
+	Subtotal        money.Money      `json:"subtotal"`
+	Tax             money.Money      `json:"tax"`
+	ShippingCost    money.Money      `json:"shipping_cost"`
+	Total           money.Money      `json:"total"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Warehouse struct
+type Warehouse struct {
+	ID              uuid.UUID `json:"id"`
+	BusinessAdminID uuid.UUID `json:"business_admin_id"`
+	Name            string    `json:"name"`
+	LocationID      uuid.UUID `json:"location_id"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// WarehouseStock struct holds the stock level of an item in a warehouse
+type WarehouseStock struct {
+	WarehouseID uuid.UUID `json:"warehouse_id"`
+	ItemID      uuid.UUID `json:"item_id"`
+	Quantity    int       `json:"quantity"`
+}
+
+// StockTransfer struct records stock moved between two warehouses
+type StockTransfer struct {
+	ID              uuid.UUID `json:"id"`
+	ItemID          uuid.UUID `json:"item_id"`
+	FromWarehouseID uuid.UUID `json:"from_warehouse_id"`
+	ToWarehouseID   uuid.UUID `json:"to_warehouse_id"`
+	Quantity        int       `json:"quantity"`
+	CreatedAt       time.Time `json:"created_at"`
+}
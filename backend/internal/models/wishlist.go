@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WishlistItem struct is an item a customer has saved for later. Subscribed is true while they are waiting
+// to be told it is back in stock.
+type WishlistItem struct {
+	Item       Item      `json:"item"`
+	InStock    bool      `json:"in_stock"`
+	Subscribed bool      `json:"subscribed"`
+	AddedAt    time.Time `json:"added_at"`
+}
+
+// StockSubscription struct is a customer asking to be notified when an item out of stock comes back.
+// NotifiedAt is set once they have been.
+type StockSubscription struct {
+	ItemID     uuid.UUID  `json:"item_id"`
+	Name       string     `json:"name"`
+	Quantity   int        `json:"quantity"`
+	CreatedAt  time.Time  `json:"created_at"`
+	NotifiedAt *time.Time `json:"notified_at,omitempty"`
+}
+
+// StockNotification struct is a customer being told an item they subscribed to is back in stock, kept until
+// they have seen it. Name and Quantity are as they were when it was restocked.
+type StockNotification struct {
+	ID        uuid.UUID  `json:"id"`
+	ItemID    uuid.UUID  `json:"item_id"`
+	Name      string     `json:"name"`
+	Quantity  int        `json:"quantity"`
+	CreatedAt time.Time  `json:"created_at"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+}
@@ -0,0 +1,73 @@
+package money
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input    string
+		currency string
+		want     int64
+		wantErr  error
+	}{
+		{"19.99", "INR", 1999, nil},
+		{"19.9", "INR", 1990, nil},
+		{"19", "INR", 1900, nil},
+		{" 0.50 ", "USD", 50, nil},
+		{"19.990", "INR", 1999, nil},
+		{"-3.25", "EUR", -325, nil},
+		{"1500", "JPY", 1500, nil},
+		{"1.234", "KWD", 1234, nil},
+		{"19.999", "INR", 0, ErrInvalidAmount},
+		{"1.5", "JPY", 0, ErrInvalidAmount},
+		{".50", "INR", 0, ErrInvalidAmount},
+		{"1e3", "INR", 0, ErrInvalidAmount},
+		{"1,000", "INR", 0, ErrInvalidAmount},
+		{"", "INR", 0, ErrInvalidAmount},
+		{"99999999999999999999", "INR", 0, ErrInvalidAmount},
+		{"10", "XYZ", 0, ErrUnknownCurrency},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input+" "+tt.currency, func(t *testing.T) {
+			got, err := Parse(tt.input, tt.currency)
+			if err != tt.wantErr {
+				t.Fatalf("Parse() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (got.Amount != tt.want || got.Currency != tt.currency) {
+				t.Errorf("Parse() = %+v, want %d %s", got, tt.want, tt.currency)
+			}
+		})
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{New(1999, "INR"), "19.99"},
+		{New(5, "USD"), "0.05"},
+		{New(-325, "EUR"), "-3.25"},
+		{New(1500, "JPY"), "1500"},
+		{New(1234, "KWD"), "1.234"},
+	}
+	for _, tt := range tests {
+		if got := tt.money.Decimal(); got != tt.want {
+			t.Errorf("%+v.Decimal() = %q, want %q", tt.money, got, tt.want)
+		}
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	if got := FromMajor(0.1+0.2, "INR"); got.Amount != 30 {
+		t.Errorf("FromMajor(0.3) = %d, want 30", got.Amount)
+	}
+	if got := New(999, "INR").Percent(18); got.Amount != 180 {
+		t.Errorf("Percent(18) = %d, want 180", got.Amount)
+	}
+	if _, err := New(100, "INR").Add(New(100, "USD")); err != ErrCurrencyMismatch {
+		t.Errorf("Add() error = %v, want %v", err, ErrCurrencyMismatch)
+	}
+	if got, _ := New(100, "INR").Sub(New(250, "INR")); got.Amount != -150 {
+		t.Errorf("Sub() = %d, want -150", got.Amount)
+	}
+}
@@ -0,0 +1,86 @@
+package money
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var ErrRateUnavailable = errors.New("no exchange rate between these currencies")
+
+// RateSource provides exchange rates between currencies
+type RateSource interface {
+	// Rate returns how many units of the to currency one unit of the from currency buys
+	Rate(ctx context.Context, from, to string) (float64, error)
+}
+
+// Convert converts an amount to another currency, rounding to the nearest minor unit
+func Convert(ctx context.Context, m Money, to string, source RateSource) (Money, error) {
+	if m.Currency == to {
+		return m, nil
+	}
+	if !Valid(to) {
+		return Money{}, ErrUnknownCurrency
+	}
+	rate, err := source.Rate(ctx, m.Currency, to)
+	if err != nil {
+		return Money{}, err
+	}
+	return FromMajor(m.Major()*rate, to), nil
+}
+
+// StaticRates is a fixed table of exchange rates against a base currency, giving how many units of each
+// currency one unit of the base buys. Rates between two other currencies are crossed through the base.
+type StaticRates struct {
+	Base  string
+	Rates map[string]float64
+}
+
+// Rate returns the rate between two currencies in the table
+func (s StaticRates) Rate(ctx context.Context, from, to string) (float64, error) {
+	fromRate, ok := s.baseRate(from)
+	if !ok {
+		return 0, ErrRateUnavailable
+	}
+	toRate, ok := s.baseRate(to)
+	if !ok {
+		return 0, ErrRateUnavailable
+	}
+	return toRate / fromRate, nil
+}
+
+func (s StaticRates) baseRate(currency string) (float64, bool) {
+	if currency == s.Base {
+		return 1, true
+	}
+	rate, ok := s.Rates[currency]
+	return rate, ok && rate > 0
+}
+
+// ParseStaticRates reads a rate table written as "USD=0.012,EUR=0.011", each the number of units of
+// the currency one unit of base buys
+func ParseStaticRates(base, spec string) (StaticRates, error) {
+	if !Valid(base) {
+		return StaticRates{}, ErrUnknownCurrency
+	}
+	rates := StaticRates{Base: base, Rates: make(map[string]float64)}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		currency, value, ok := strings.Cut(entry, "=")
+		currency = strings.ToUpper(strings.TrimSpace(currency))
+		if !ok || !Valid(currency) {
+			return StaticRates{}, errors.New("invalid exchange rate " + strconv.Quote(entry))
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || rate <= 0 || math.IsInf(rate, 0) {
+			return StaticRates{}, errors.New("invalid exchange rate " + strconv.Quote(entry))
+		}
+		rates.Rates[currency] = rate
+	}
+	return rates, nil
+}
@@ -0,0 +1,51 @@
+package money
+
+import (
+	"context"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	rates, err := ParseStaticRates("INR", " usd=0.012, EUR=0.011,")
+	if err != nil {
+		t.Fatalf("ParseStaticRates() error = %v", err)
+	}
+
+	tests := []struct {
+		from Money
+		to   string
+		want int64
+	}{
+		{New(100000, "INR"), "INR", 100000},
+		{New(100000, "INR"), "USD", 1200},
+		{New(1200, "USD"), "INR", 100000},
+		{New(1200, "USD"), "EUR", 1100},
+		{New(100000, "INR"), "JPY", 0},
+	}
+	for _, tt := range tests {
+		got, err := Convert(context.Background(), tt.from, tt.to, rates)
+		if tt.to == "JPY" {
+			if err != ErrRateUnavailable {
+				t.Errorf("Convert(%v, JPY) error = %v, want %v", tt.from, err, ErrRateUnavailable)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Convert(%v, %s) error = %v", tt.from, tt.to, err)
+		}
+		if got.Amount != tt.want || got.Currency != tt.to {
+			t.Errorf("Convert(%v, %s) = %v, want %d", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestParseStaticRatesInvalid(t *testing.T) {
+	for _, spec := range []string{"USD", "XYZ=1", "USD=0", "USD=-1", "USD=abc"} {
+		if _, err := ParseStaticRates("INR", spec); err == nil {
+			t.Errorf("ParseStaticRates(%q) error = nil, want an error", spec)
+		}
+	}
+	if _, err := ParseStaticRates("XYZ", ""); err != ErrUnknownCurrency {
+		t.Errorf("ParseStaticRates with base XYZ error = %v, want %v", err, ErrUnknownCurrency)
+	}
+}
@@ -0,0 +1,118 @@
+// Package orderstate defines the statuses an order moves through and who may move it.
+// Seller orders follow the same machine as the orders they are part of.
+package orderstate
+
+import (
+	"chainwave/backend/internal/models"
+	"chainwave/backend/internal/statemachine"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Roles that move orders. RoleSystem covers changes the backend makes on its own, such as when a payment is captured.
+const (
+	RoleCustomer      = "customer"
+	RoleBusinessAdmin = "business_admin"
+	RoleTransporter   = "transporter"
+	RoleSystem        = "system"
+)
+
+// Machine lists the status changes an order may go through. Orders can only be cancelled before they are
+// shipped; after that the seller refunds individual lines instead.
+var Machine = statemachine.New("order", map[string][]string{
+	models.OrderStatusPending:    {models.OrderStatusProcessing, models.OrderStatusCancelled},
+	models.OrderStatusProcessing: {models.OrderStatusShipped, models.OrderStatusCancelled},
+	models.OrderStatusShipped:    {models.OrderStatusDelivered},
+	models.OrderStatusDelivered:  {},
+	models.OrderStatusCancelled:  {},
+})
+
+// statuses lists every order status in the order they are reached
+var statuses = []string{
+	models.OrderStatusPending,
+	models.OrderStatusProcessing,
+	models.OrderStatusShipped,
+	models.OrderStatusDelivered,
+	models.OrderStatusCancelled,
+}
+
+// triggers lists the roles that may make each transition. Orders start processing once they are paid for,
+// which only the backend sees; sellers ship and deliver themselves or through a transporter.
+var triggers = map[string]map[string][]string{
+	models.OrderStatusPending: {
+		models.OrderStatusProcessing: {RoleSystem},
+		models.OrderStatusCancelled:  {RoleCustomer, RoleBusinessAdmin, RoleSystem},
+	},
+	models.OrderStatusProcessing: {
+		models.OrderStatusShipped:   {RoleBusinessAdmin, RoleTransporter},
+		models.OrderStatusCancelled: {RoleCustomer, RoleBusinessAdmin, RoleSystem},
+	},
+	models.OrderStatusShipped: {
+		models.OrderStatusDelivered: {RoleBusinessAdmin, RoleTransporter},
+	},
+}
+
+// Actor is who changed an order's status. ID is the ID of the customer, business admin or transporter,
+// and uuid.Nil for the system.
+type Actor struct {
+	Role string
+	ID   uuid.UUID
+}
+
+// System is the backend acting on its own
+var System = Actor{Role: RoleSystem}
+
+// RoleError is returned when a role may not make an otherwise allowed transition
+type RoleError struct {
+	From    string
+	To      string
+	Role    string
+	Allowed []string
+}
+
+func (e *RoleError) Error() string {
+	return fmt.Sprintf("a %s cannot move an order from %q to %q, only %s can", e.Role, e.From, e.To, strings.Join(e.Allowed, " or "))
+}
+
+// Known reports whether status is an order status
+func Known(status string) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Roles returns the roles that may move an order from one status to another
+func Roles(from, to string) []string {
+	return triggers[from][to]
+}
+
+// Check returns a *statemachine.TransitionError if moving from one status to another is not allowed,
+// and a *RoleError if it is but not by the given role
+func Check(from, to, role string) error {
+	if err := Machine.Transition(from, to); err != nil {
+		return err
+	}
+	for _, allowed := range Roles(from, to) {
+		if allowed == role {
+			return nil
+		}
+	}
+	return &RoleError{From: from, To: to, Role: role, Allowed: Roles(from, to)}
+}
+
+// Path returns the statuses an order passes through to get from one status to another, so that a
+// delivery reported while still processing goes by shipped first. It is empty if to can't be reached.
+func Path(from, to string) []string {
+	if Machine.Can(from, to) {
+		return []string{to}
+	}
+	if to == models.OrderStatusDelivered && Machine.Can(from, models.OrderStatusShipped) {
+		return []string{models.OrderStatusShipped, to}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package orderstate
+
+import (
+	"chainwave/backend/internal/models"
+	"chainwave/backend/internal/statemachine"
+	"errors"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	const (
+		pending    = models.OrderStatusPending
+		processing = models.OrderStatusProcessing
+		shipped    = models.OrderStatusShipped
+		delivered  = models.OrderStatusDelivered
+		cancelled  = models.OrderStatusCancelled
+	)
+	roles := []string{RoleCustomer, RoleBusinessAdmin, RoleTransporter, RoleSystem}
+
+	// allowed lists the roles that may make each transition the machine allows
+	allowed := []struct {
+		from, to string
+		roles    []string
+	}{
+		{pending, processing, []string{RoleSystem}},
+		{pending, cancelled, []string{RoleCustomer, RoleBusinessAdmin, RoleSystem}},
+		{processing, shipped, []string{RoleBusinessAdmin, RoleTransporter}},
+		{processing, cancelled, []string{RoleCustomer, RoleBusinessAdmin, RoleSystem}},
+		{shipped, delivered, []string{RoleBusinessAdmin, RoleTransporter}},
+	}
+	for _, tt := range allowed {
+		for _, role := range roles {
+			t.Run(tt.from+" to "+tt.to+" by "+role, func(t *testing.T) {
+				err := Check(tt.from, tt.to, role)
+				if !contains(tt.roles, role) {
+					var roleErr *RoleError
+					if !errors.As(err, &roleErr) {
+						t.Fatalf("Check() error = %v, want a *RoleError", err)
+					}
+					if roleErr.Role != role || len(roleErr.Allowed) != len(tt.roles) {
+						t.Errorf("RoleError = %+v, want role %s and allowed %v", roleErr, role, tt.roles)
+					}
+					return
+				}
+				if err != nil {
+					t.Errorf("Check() error = %v, want nil", err)
+				}
+			})
+		}
+	}
+
+	// The machine rejects these whoever asks
+	forbidden := []struct{ from, to string }{
+		{pending, shipped},
+		{pending, delivered},
+		{processing, pending},
+		{processing, delivered},
+		{shipped, cancelled},
+		{shipped, processing},
+		{delivered, cancelled},
+		{cancelled, processing},
+		{pending, pending},
+	}
+	for _, tt := range forbidden {
+		for _, role := range roles {
+			t.Run(tt.from+" to "+tt.to+" by "+role, func(t *testing.T) {
+				var transitionErr *statemachine.TransitionError
+				if err := Check(tt.from, tt.to, role); !errors.As(err, &transitionErr) {
+					t.Errorf("Check() error = %v, want a *statemachine.TransitionError", err)
+				}
+			})
+		}
+	}
+
+	if err := Check("Lost", delivered, RoleSystem); err == nil {
+		t.Error("Check() from an unknown status error = nil")
+	}
+}
+
+func TestRoleError(t *testing.T) {
+	err := Check(models.OrderStatusPending, models.OrderStatusProcessing, RoleCustomer)
+	want := `a customer cannot move an order from "Pending" to "Processing", only system can`
+	if err == nil || err.Error() != want {
+		t.Errorf("Check() error = %v, want %s", err, want)
+	}
+
+	err = Check(models.OrderStatusShipped, models.OrderStatusDelivered, RoleCustomer)
+	want = `a customer cannot move an order from "Shipped" to "Delivered", only business_admin or transporter can`
+	if err == nil || err.Error() != want {
+		t.Errorf("Check() error = %v, want %s", err, want)
+	}
+}
+
+func TestPath(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     []string
+	}{
+		{models.OrderStatusPending, models.OrderStatusProcessing, []string{models.OrderStatusProcessing}},
+		{models.OrderStatusProcessing, models.OrderStatusShipped, []string{models.OrderStatusShipped}},
+		{models.OrderStatusShipped, models.OrderStatusDelivered, []string{models.OrderStatusDelivered}},
+		{models.OrderStatusProcessing, models.OrderStatusCancelled, []string{models.OrderStatusCancelled}},
+		// A delivery reported while still processing goes by shipped first
+		{models.OrderStatusProcessing, models.OrderStatusDelivered, []string{models.OrderStatusShipped, models.OrderStatusDelivered}},
+		// Only deliveries skip ahead, and never from before payment
+		{models.OrderStatusPending, models.OrderStatusDelivered, nil},
+		{models.OrderStatusPending, models.OrderStatusShipped, nil},
+		{models.OrderStatusShipped, models.OrderStatusCancelled, nil},
+		{models.OrderStatusDelivered, models.OrderStatusDelivered, nil},
+		{models.OrderStatusCancelled, models.OrderStatusDelivered, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+" to "+tt.to, func(t *testing.T) {
+			got := Path(tt.from, tt.to)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Path() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("Path() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestKnown(t *testing.T) {
+	for _, status := range statuses {
+		if !Known(status) {
+			t.Errorf("Known(%q) = false", status)
+		}
+	}
+	if Known("Lost") || Known("") {
+		t.Error("Known() = true for a status that isn't an order status")
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
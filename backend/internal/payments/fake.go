@@ -0,0 +1,63 @@
+package payments
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Fake is a provider for local development that creates orders without calling anyone. Payments are
+// signed like Razorpay's with Secret, so a client can pay by signing "order_id|payment_id" itself, and
+// webhooks take Razorpay's body format signed with the same secret.
+type Fake struct {
+	Secret string
+
+	mu      sync.Mutex
+	refunds map[string]*Refund
+}
+
+// Name identifies fake payments
+func (f *Fake) Name() string {
+	return "fake"
+}
+
+// CreateOrder returns an order with a random ID
+func (f *Fake) CreateOrder(ctx context.Context, amount int64, currency, receipt string) (*Order, error) {
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	return &Order{ID: "order_fake_" + uuid.NewString(), Amount: amount, Currency: currency}, nil
+}
+
+// VerifyPayment checks the signature is the HMAC-SHA256 of "order_id|payment_id" keyed with Secret
+func (f *Fake) VerifyPayment(orderID, paymentID, signature string) error {
+	return verifySignature(f.Secret, orderID+"|"+paymentID, signature)
+}
+
+// Refund returns a processed refund with a random ID, or the one already made for the receipt
+func (f *Fake) Refund(ctx context.Context, paymentID string, amount int64, receipt string) (*Refund, error) {
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if refund, ok := f.refunds[receipt]; ok {
+		return refund, nil
+	}
+	if f.refunds == nil {
+		f.refunds = make(map[string]*Refund)
+	}
+	refund := &Refund{ID: "rfnd_fake_" + uuid.NewString(), Amount: amount, Status: RefundProcessed}
+	f.refunds[receipt] = refund
+	return refund, nil
+}
+
+// ParseWebhook checks the signature is the HMAC-SHA256 of the body keyed with Secret
+func (f *Fake) ParseWebhook(body []byte, signature string) (*WebhookEvent, error) {
+	if err := verifySignature(f.Secret, string(body), signature); err != nil {
+		return nil, err
+	}
+
+	return parseRazorpayWebhook(body)
+}
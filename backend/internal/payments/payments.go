@@ -0,0 +1,117 @@
+// Package payments creates orders with a payment provider and verifies the payments customers make
+// against them, either from the checkout callback or from the provider's webhooks.
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// Webhook event types that change a payment's or refund's status
+const (
+	EventPaymentCaptured = "payment.captured"
+	EventPaymentFailed   = "payment.failed"
+	EventRefundProcessed = "refund.processed"
+	EventRefundFailed    = "refund.failed"
+)
+
+// Refund statuses reported by providers
+const (
+	RefundPending   = "pending"
+	RefundProcessed = "processed"
+	RefundFailed    = "failed"
+)
+
+var (
+	ErrInvalidSignature = errors.New("payment signature is invalid")
+	ErrInvalidAmount    = errors.New("payment amount must be positive")
+)
+
+// APIError is a request the provider answered with an error status
+type APIError struct {
+	StatusCode  int
+	Description string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("provider returned status %d: %s", e.StatusCode, e.Description)
+}
+
+// Rejected reports whether err means the provider definitely turned a request down, as opposed to a
+// timeout, a dropped connection or a server error after which it may still have acted on it
+func Rejected(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 400 && apiErr.StatusCode < 500
+	}
+	return errors.Is(err, ErrInvalidAmount)
+}
+
+// Order is an order created with the provider that the customer pays against.
+// Amount is in the currency's minor unit, such as paise or cents.
+type Order struct {
+	ID       string `json:"id"`
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// Refund is money returned to the customer from a captured payment, in the currency's minor unit
+type Refund struct {
+	ID     string `json:"id"`
+	Amount int64  `json:"amount"`
+	Status string `json:"status"`
+}
+
+// WebhookEvent is a payment or refund status change reported by the provider.
+// RefundID is only set for refund events.
+type WebhookEvent struct {
+	Type          string
+	OrderID       string
+	PaymentID     string
+	RefundID      string
+	FailureReason string
+}
+
+// Provider is a payment provider customers pay through at checkout
+type Provider interface {
+	// Name identifies the provider in the payments table
+	Name() string
+	// CreateOrder registers an amount to be paid, receipt being our own reference for it
+	CreateOrder(ctx context.Context, amount int64, currency, receipt string) (*Order, error)
+	// VerifyPayment checks the signature the checkout returned for a payment against an order
+	VerifyPayment(orderID, paymentID, signature string) error
+	// Refund returns part or all of a captured payment, receipt being our own reference for the refund.
+	// The receipt is also the idempotency key, so sending the same refund again never pays it out twice.
+	Refund(ctx context.Context, paymentID string, amount int64, receipt string) (*Refund, error)
+	// ParseWebhook verifies a webhook's signature and extracts the payment or refund event from its body.
+	// Events that don't affect payments or refunds are returned with an empty Type.
+	ParseWebhook(body []byte, signature string) (*WebhookEvent, error)
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of message keyed with secret
+func Sign(secret, message string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(message))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifySignature compares a hex encoded signature in constant time. Nothing verifies without a secret, as
+// anyone could sign with an empty key.
+func verifySignature(secret, message, signature string) error {
+	if secret == "" {
+		return ErrInvalidSignature
+	}
+	expected, err := hex.DecodeString(Sign(secret, message))
+	if err != nil {
+		return err
+	}
+	actual, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(expected, actual) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
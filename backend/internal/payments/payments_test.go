@@ -0,0 +1,156 @@
+package payments
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestVerifySignature(t *testing.T) {
+	const secret = "test_secret"
+	valid := Sign(secret, "order_1|pay_1")
+
+	tests := []struct {
+		name      string
+		secret    string
+		message   string
+		signature string
+		want      error
+	}{
+		{"valid", secret, "order_1|pay_1", valid, nil},
+		{"tampered message", secret, "order_1|pay_2", valid, ErrInvalidSignature},
+		{"tampered signature", secret, "order_1|pay_1", Sign(secret, "order_1|pay_2"), ErrInvalidSignature},
+		{"other secret", "another_secret", "order_1|pay_1", valid, ErrInvalidSignature},
+		{"empty secret", "", "order_1|pay_1", Sign("", "order_1|pay_1"), ErrInvalidSignature},
+		{"non-hex signature", secret, "order_1|pay_1", "not-a-signature", ErrInvalidSignature},
+		{"truncated signature", secret, "order_1|pay_1", valid[:len(valid)-2], ErrInvalidSignature},
+		{"empty signature", secret, "order_1|pay_1", "", ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := verifySignature(tt.secret, tt.message, tt.signature); err != tt.want {
+				t.Errorf("verifySignature() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyPayment(t *testing.T) {
+	providers := map[string]Provider{
+		"razorpay": NewRazorpay("key_id", "key_secret", "webhook_secret"),
+		"fake":     &Fake{Secret: "key_secret"},
+	}
+	for name, provider := range providers {
+		t.Run(name, func(t *testing.T) {
+			if err := provider.VerifyPayment("order_1", "pay_1", Sign("key_secret", "order_1|pay_1")); err != nil {
+				t.Errorf("VerifyPayment() error = %v, want nil", err)
+			}
+			if err := provider.VerifyPayment("order_1", "pay_2", Sign("key_secret", "order_1|pay_1")); err != ErrInvalidSignature {
+				t.Errorf("VerifyPayment() with another payment error = %v, want %v", err, ErrInvalidSignature)
+			}
+		})
+	}
+
+	// The checkout is signed with the API secret, not the webhook secret
+	razorpay := NewRazorpay("key_id", "key_secret", "webhook_secret")
+	if err := razorpay.VerifyPayment("order_1", "pay_1", Sign("webhook_secret", "order_1|pay_1")); err != ErrInvalidSignature {
+		t.Errorf("VerifyPayment() signed with the webhook secret error = %v, want %v", err, ErrInvalidSignature)
+	}
+}
+
+func TestParseWebhook(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want WebhookEvent
+	}{
+		{
+			name: "payment captured",
+			body: `{"event": "payment.captured", "payload": {"payment": {"entity": {"id": "pay_1", "order_id": "order_1"}}}}`,
+			want: WebhookEvent{Type: EventPaymentCaptured, OrderID: "order_1", PaymentID: "pay_1"},
+		},
+		{
+			name: "payment failed",
+			body: `{"event": "payment.failed", "payload": {"payment": {"entity": {"id": "pay_1", "order_id": "order_1", "error_description": "card declined"}}}}`,
+			want: WebhookEvent{Type: EventPaymentFailed, OrderID: "order_1", PaymentID: "pay_1", FailureReason: "card declined"},
+		},
+		{
+			name: "refund processed",
+			body: `{"event": "refund.processed", "payload": {"refund": {"entity": {"id": "rfnd_1", "payment_id": "pay_1"}}}}`,
+			want: WebhookEvent{Type: EventRefundProcessed, PaymentID: "pay_1", RefundID: "rfnd_1"},
+		},
+		{
+			name: "refund failed",
+			body: `{"event": "refund.failed", "payload": {"payment": {"entity": {"id": "pay_1", "order_id": "order_1"}}, "refund": {"entity": {"id": "rfnd_1", "payment_id": "pay_1"}}}}`,
+			want: WebhookEvent{Type: EventRefundFailed, OrderID: "order_1", PaymentID: "pay_1", RefundID: "rfnd_1"},
+		},
+		{
+			name: "other event",
+			body: `{"event": "order.paid", "payload": {"payment": {"entity": {"id": "pay_1", "order_id": "order_1"}}}}`,
+			want: WebhookEvent{OrderID: "order_1", PaymentID: "pay_1"},
+		},
+	}
+	razorpay := NewRazorpay("key_id", "key_secret", "webhook_secret")
+	fake := &Fake{Secret: "webhook_secret"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, provider := range map[string]Provider{"razorpay": razorpay, "fake": fake} {
+				event, err := provider.ParseWebhook([]byte(tt.body), Sign("webhook_secret", tt.body))
+				if err != nil {
+					t.Fatalf("%s: ParseWebhook() error = %v", name, err)
+				}
+				if *event != tt.want {
+					t.Errorf("%s: ParseWebhook() = %+v, want %+v", name, *event, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseWebhookErrors(t *testing.T) {
+	body := `{"event": "payment.captured", "payload": {"payment": {"entity": {"id": "pay_1", "order_id": "order_1"}}}}`
+	tests := []struct {
+		name      string
+		provider  Provider
+		body      string
+		signature string
+		invalid   bool
+	}{
+		{"tampered body", NewRazorpay("key_id", "key_secret", "webhook_secret"), body + " ", Sign("webhook_secret", body), true},
+		{"signed with the API secret", NewRazorpay("key_id", "key_secret", "webhook_secret"), body, Sign("key_secret", body), true},
+		{"no webhook secret", NewRazorpay("key_id", "key_secret", ""), body, Sign("", body), true},
+		{"non-hex signature", NewRazorpay("key_id", "key_secret", "webhook_secret"), body, "zz", true},
+		{"fake without a secret", &Fake{}, body, Sign("", body), true},
+		{"malformed body", NewRazorpay("key_id", "key_secret", "webhook_secret"), `{"event":`, Sign("webhook_secret", `{"event":`), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := tt.provider.ParseWebhook([]byte(tt.body), tt.signature)
+			if err == nil {
+				t.Fatalf("ParseWebhook() = %+v, want an error", event)
+			}
+			if tt.invalid != errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("ParseWebhook() error = %v, invalid signature %v", err, tt.invalid)
+			}
+		})
+	}
+}
+
+func TestRejected(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"bad request", &APIError{StatusCode: 400, Description: "amount exceeds payment"}, true},
+		{"server error", &APIError{StatusCode: 502}, false},
+		{"invalid amount", ErrInvalidAmount, true},
+		{"timeout", errors.New("context deadline exceeded"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Rejected(tt.err); got != tt.want {
+				t.Errorf("Rejected() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
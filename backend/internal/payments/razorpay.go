@@ -0,0 +1,174 @@
+package payments
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Razorpay takes payments through Razorpay Checkout
+type Razorpay struct {
+	KeyID         string
+	KeySecret     string
+	WebhookSecret string
+	BaseURL       string
+	Client        *http.Client
+}
+
+// NewRazorpay creates a provider for the Razorpay account with the given API keys and webhook secret
+func NewRazorpay(keyID, keySecret, webhookSecret string) *Razorpay {
+	return &Razorpay{
+		KeyID:         keyID,
+		KeySecret:     keySecret,
+		WebhookSecret: webhookSecret,
+		BaseURL:       "https://api.razorpay.com/v1",
+		Client:        &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// Name identifies Razorpay payments
+func (r *Razorpay) Name() string {
+	return "razorpay"
+}
+
+// CreateOrder creates a Razorpay order for the amount
+func (r *Razorpay) CreateOrder(ctx context.Context, amount int64, currency, receipt string) (*Order, error) {
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	var order Order
+	err := r.post(ctx, "/orders", "", map[string]interface{}{
+		"amount":   amount,
+		"currency": currency,
+		"receipt":  receipt,
+	}, &order)
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// Refund refunds the amount of a captured payment, keyed on the receipt so a retry after a timeout returns
+// the refund already made. Razorpay reports most refunds as processed straight away and sends a
+// refund.processed or refund.failed webhook for the rest.
+func (r *Razorpay) Refund(ctx context.Context, paymentID string, amount int64, receipt string) (*Refund, error) {
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	var refund Refund
+	err := r.post(ctx, "/payments/"+url.PathEscape(paymentID)+"/refund", receipt, map[string]interface{}{
+		"amount":  amount,
+		"receipt": receipt,
+	}, &refund)
+	if err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+// post sends a JSON request to the Razorpay API and decodes a successful response into result. A non-empty
+// idempotencyKey is sent so Razorpay answers repeats of the request with the result of the first.
+func (r *Razorpay) post(ctx context.Context, path, idempotencyKey string, body interface{}, result interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(r.BaseURL, "/")+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	request.SetBasicAuth(r.KeyID, r.KeySecret)
+	request.Header.Set("Content-Type", "application/json")
+	if idempotencyKey != "" {
+		request.Header.Set("X-Razorpay-Idempotency", idempotencyKey)
+	}
+
+	response, err := r.Client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode != http.StatusOK {
+		var failure struct {
+			Error struct {
+				Description string `json:"description"`
+			} `json:"error"`
+		}
+		json.Unmarshal(data, &failure)
+		return &APIError{StatusCode: response.StatusCode, Description: failure.Error.Description}
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("razorpay returned status %d: %w", response.StatusCode, err)
+	}
+	return nil
+}
+
+// VerifyPayment checks the checkout signature, an HMAC-SHA256 of "order_id|payment_id" keyed with the API secret
+func (r *Razorpay) VerifyPayment(orderID, paymentID, signature string) error {
+	return verifySignature(r.KeySecret, orderID+"|"+paymentID, signature)
+}
+
+// razorpayWebhook is the part of a Razorpay webhook body describing a payment and any refund of it
+type razorpayWebhook struct {
+	Event   string `json:"event"`
+	Payload struct {
+		Payment struct {
+			Entity struct {
+				ID               string `json:"id"`
+				OrderID          string `json:"order_id"`
+				ErrorDescription string `json:"error_description"`
+			} `json:"entity"`
+		} `json:"payment"`
+		Refund struct {
+			Entity struct {
+				ID        string `json:"id"`
+				PaymentID string `json:"payment_id"`
+			} `json:"entity"`
+		} `json:"refund"`
+	} `json:"payload"`
+}
+
+// ParseWebhook checks the X-Razorpay-Signature header, an HMAC-SHA256 of the body keyed with the webhook secret
+func (r *Razorpay) ParseWebhook(body []byte, signature string) (*WebhookEvent, error) {
+	if err := verifySignature(r.WebhookSecret, string(body), signature); err != nil {
+		return nil, err
+	}
+
+	return parseRazorpayWebhook(body)
+}
+
+// parseRazorpayWebhook extracts the payment or refund event from a Razorpay webhook body
+func parseRazorpayWebhook(body []byte) (*WebhookEvent, error) {
+	var webhook razorpayWebhook
+	if err := json.Unmarshal(body, &webhook); err != nil {
+		return nil, err
+	}
+	event := &WebhookEvent{
+		OrderID:       webhook.Payload.Payment.Entity.OrderID,
+		PaymentID:     webhook.Payload.Payment.Entity.ID,
+		FailureReason: webhook.Payload.Payment.Entity.ErrorDescription,
+	}
+	switch webhook.Event {
+	case EventPaymentCaptured, EventPaymentFailed:
+		event.Type = webhook.Event
+	case EventRefundProcessed, EventRefundFailed:
+		event.Type = webhook.Event
+		event.RefundID = webhook.Payload.Refund.Entity.ID
+		if event.PaymentID == "" {
+			event.PaymentID = webhook.Payload.Refund.Entity.PaymentID
+		}
+	}
+	return event, nil
+}
@@ -0,0 +1,66 @@
+package payments
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRazorpayRefundIdempotencyKey(t *testing.T) {
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/payments/pay_1/refund" {
+			t.Errorf("path = %q, want /payments/pay_1/refund", r.URL.Path)
+		}
+		keys = append(keys, r.Header.Get("X-Razorpay-Idempotency"))
+		w.Write([]byte(`{"id": "rfnd_1", "amount": 500, "status": "processed"}`))
+	}))
+	defer server.Close()
+	razorpay := NewRazorpay("key_id", "key_secret", "webhook_secret")
+	razorpay.BaseURL = server.URL
+
+	for i := 0; i < 2; i++ {
+		refund, err := razorpay.Refund(context.Background(), "pay_1", 500, "refund-1")
+		if err != nil {
+			t.Fatalf("Refund() error = %v", err)
+		}
+		if refund.ID != "rfnd_1" || refund.Status != RefundProcessed {
+			t.Errorf("Refund() = %+v", refund)
+		}
+	}
+	if len(keys) != 2 || keys[0] != "refund-1" || keys[1] != "refund-1" {
+		t.Errorf("idempotency keys = %v, want refund-1 on both requests", keys)
+	}
+}
+
+func TestRazorpayRefundRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": {"description": "The refund amount is greater than the payment"}}`))
+	}))
+	defer server.Close()
+	razorpay := NewRazorpay("key_id", "key_secret", "webhook_secret")
+	razorpay.BaseURL = server.URL
+
+	_, err := razorpay.Refund(context.Background(), "pay_1", 500, "refund-1")
+	if !Rejected(err) {
+		t.Errorf("Refund() error = %v, want a rejection", err)
+	}
+}
+
+func TestFakeRefundIdempotent(t *testing.T) {
+	fake := &Fake{Secret: "secret"}
+	first, err := fake.Refund(context.Background(), "pay_1", 500, "refund-1")
+	if err != nil {
+		t.Fatalf("Refund() error = %v", err)
+	}
+	again, err := fake.Refund(context.Background(), "pay_1", 500, "refund-1")
+	if err != nil || again.ID != first.ID {
+		t.Errorf("Refund() again = %+v, %v, want %s", again, err, first.ID)
+	}
+	other, err := fake.Refund(context.Background(), "pay_1", 500, "refund-2")
+	if err != nil || other.ID == first.ID {
+		t.Errorf("Refund() with another receipt = %+v, %v, want a new refund", other, err)
+	}
+}
@@ -0,0 +1,194 @@
+// Package pricing works out what items cost once sales and coupons are applied. Listings, carts and
+// orders all price through it so the price a customer is shown is the price they are charged. Amounts are
+// worked out in whole minor units, so line totals and discount shares always add up exactly.
+//
+// The stacking rules are:
+//   - an item takes the single sale that gives it the lowest price; sales never stack with each other
+//   - at most one coupon applies, and only to items of the business admin who issued it
+//   - a coupon applies on top of sale prices only if it is marked as stacking with sales, otherwise
+//     items on sale are left out of it
+package pricing
+
+import (
+	"chainwave/backend/internal/money"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Kinds of discount
+const (
+	KindPercentage = "percentage"
+	KindFixed      = "fixed"
+)
+
+var (
+	ErrInvalidKind          = errors.New("discount kind must be percentage or fixed")
+	ErrInvalidValue         = errors.New("discount value must be positive, and percentages at most 100")
+	ErrMixedCurrencies      = errors.New("items priced in different currencies must be ordered separately")
+	ErrCouponNotApplicable  = errors.New("coupon does not apply to any item in the order")
+	ErrCouponBelowThreshold = errors.New("order does not reach the coupon's minimum spend")
+)
+
+// Sale is a time-limited discount on an item, set on the item itself or on its category. Fixed values
+// are in the major unit of the item's currency.
+type Sale struct {
+	ID     uuid.UUID
+	Kind   string
+	Value  float64
+	EndsAt time.Time
+}
+
+// Coupon is a discount a customer claims with a code. MinSubtotal is the least the items it applies to must
+// come to before it can be used. Fixed values and MinSubtotal are in the major unit of the items' currency.
+type Coupon struct {
+	ID              uuid.UUID
+	Code            string
+	BusinessAdminID uuid.UUID
+	Kind            string
+	Value           float64
+	MinSubtotal     float64
+	StacksWithSales bool
+}
+
+// Line is a quantity of an item to price, with the sales running on it
+type Line struct {
+	ItemID          uuid.UUID
+	BusinessAdminID uuid.UUID
+	Quantity        int
+	ListPrice       money.Money
+	Sales           []Sale
+}
+
+// PricedLine is a line with its discounts applied. UnitPrice is the price after any sale, Discount the
+// line's share of the coupon and Total what the line comes to after both.
+type PricedLine struct {
+	Line
+	UnitPrice money.Money
+	Sale      *Sale
+	Discount  money.Money
+	Total     money.Money
+}
+
+// Result is a set of priced lines in one currency. Subtotal is before the coupon, Discount is the
+// coupon's and Total after it.
+type Result struct {
+	Currency string
+	Lines    []PricedLine
+	Subtotal money.Money
+	Discount money.Money
+	Total    money.Money
+}
+
+// ValidateDiscount checks a discount kind and value make sense
+func ValidateDiscount(kind string, value float64) error {
+	switch kind {
+	case KindPercentage:
+		if value <= 0 || value > 100 {
+			return ErrInvalidValue
+		}
+	case KindFixed:
+		if value <= 0 {
+			return ErrInvalidValue
+		}
+	default:
+		return ErrInvalidKind
+	}
+	return nil
+}
+
+// SalePrice returns an item's price under the sale that takes the most off it, and that sale.
+// It returns the list price and nil when no sale lowers it.
+func SalePrice(listPrice money.Money, sales []Sale) (money.Money, *Sale) {
+	price := listPrice
+	var best *Sale
+	for i := range sales {
+		discounted := discounted(listPrice, sales[i].Kind, sales[i].Value)
+		if discounted.Amount < price.Amount {
+			price = discounted
+			best = &sales[i]
+		}
+	}
+	return price, best
+}
+
+// Price prices lines under their sales and an optional coupon. The lines must all be in the same
+// currency. It fails with ErrCouponNotApplicable or ErrCouponBelowThreshold if the coupon can't be used
+// on them.
+func Price(lines []Line, coupon *Coupon) (Result, error) {
+	var result Result
+	if len(lines) > 0 {
+		result.Currency = lines[0].ListPrice.Currency
+	}
+	result.Lines = make([]PricedLine, len(lines))
+	result.Subtotal = money.New(0, result.Currency)
+	result.Discount = money.New(0, result.Currency)
+	eligible := make([]int, 0)
+	var subtotal, eligibleSubtotal int64
+	for i, line := range lines {
+		if line.ListPrice.Currency != result.Currency {
+			return result, ErrMixedCurrencies
+		}
+		priced := PricedLine{Line: line, Discount: money.New(0, result.Currency)}
+		priced.UnitPrice, priced.Sale = SalePrice(line.ListPrice, line.Sales)
+		priced.Total = priced.UnitPrice.Mul(line.Quantity)
+		result.Lines[i] = priced
+		subtotal += priced.Total.Amount
+
+		if coupon != nil && line.BusinessAdminID == coupon.BusinessAdminID && (priced.Sale == nil || coupon.StacksWithSales) {
+			eligible = append(eligible, i)
+			eligibleSubtotal += priced.Total.Amount
+		}
+	}
+	result.Subtotal = money.New(subtotal, result.Currency)
+	result.Total = result.Subtotal
+
+	if coupon == nil {
+		return result, nil
+	}
+	if len(eligible) == 0 || eligibleSubtotal == 0 {
+		return result, ErrCouponNotApplicable
+	}
+	minimum := money.FromMajor(coupon.MinSubtotal, result.Currency)
+	if eligibleSubtotal < minimum.Amount {
+		return result, fmt.Errorf("%w of %s", ErrCouponBelowThreshold, minimum)
+	}
+
+	// Spread the discount over the lines it covers in proportion to their totals, with whatever
+	// rounding leaves over going to the last line
+	eligibleAmount := money.New(eligibleSubtotal, result.Currency)
+	discount := eligibleSubtotal - discounted(eligibleAmount, coupon.Kind, coupon.Value).Amount
+	remaining := discount
+	for n, i := range eligible {
+		share := remaining
+		if n < len(eligible)-1 {
+			share = int64(math.Round(float64(discount) * float64(result.Lines[i].Total.Amount) / float64(eligibleSubtotal)))
+			if share > remaining {
+				share = remaining
+			}
+		}
+		remaining -= share
+		result.Lines[i].Discount = money.New(share, result.Currency)
+		result.Lines[i].Total = money.New(result.Lines[i].Total.Amount-share, result.Currency)
+	}
+	result.Discount = money.New(discount, result.Currency)
+	result.Total = money.New(subtotal-discount, result.Currency)
+	return result, nil
+}
+
+// discounted returns an amount less a percentage or fixed discount, never below zero
+func discounted(amount money.Money, kind string, value float64) money.Money {
+	switch kind {
+	case KindPercentage:
+		amount.Amount -= amount.Percent(value).Amount
+	case KindFixed:
+		amount.Amount -= money.FromMajor(value, amount.Currency).Amount
+	}
+	if amount.Amount < 0 {
+		amount.Amount = 0
+	}
+	return amount
+}
@@ -0,0 +1,138 @@
+package pricing
+
+import (
+	"chainwave/backend/internal/money"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestPriceDiscountSplit(t *testing.T) {
+	seller := uuid.New()
+	other := uuid.New()
+	halfOff := Sale{ID: uuid.New(), Kind: KindPercentage, Value: 50, EndsAt: time.Now().Add(time.Hour)}
+	line := func(businessAdminID uuid.UUID, price int64, quantity int, sales ...Sale) Line {
+		return Line{ItemID: uuid.New(), BusinessAdminID: businessAdminID, Quantity: quantity, ListPrice: money.New(price, "INR"), Sales: sales}
+	}
+
+	tests := []struct {
+		name      string
+		lines     []Line
+		coupon    *Coupon
+		discounts []int64
+		total     int64
+	}{
+		{
+			name:      "no coupon",
+			lines:     []Line{line(seller, 1000, 2)},
+			discounts: []int64{0},
+			total:     2000,
+		},
+		{
+			// 10% of 633 is 63; the shares of 100 and 200 round to 10 and 20 and the last line takes the other 33
+			name:      "percentage remainder to last line",
+			lines:     []Line{line(seller, 100, 1), line(seller, 200, 1), line(seller, 333, 1)},
+			coupon:    &Coupon{ID: uuid.New(), BusinessAdminID: seller, Kind: KindPercentage, Value: 10},
+			discounts: []int64{10, 20, 33},
+			total:     570,
+		},
+		{
+			name:      "fixed split by line total",
+			lines:     []Line{line(seller, 100, 3), line(seller, 700, 1)},
+			coupon:    &Coupon{ID: uuid.New(), BusinessAdminID: seller, Kind: KindFixed, Value: 1},
+			discounts: []int64{30, 70},
+			total:     900,
+		},
+		{
+			name:      "only the coupon seller's lines",
+			lines:     []Line{line(other, 500, 1), line(seller, 500, 1)},
+			coupon:    &Coupon{ID: uuid.New(), BusinessAdminID: seller, Kind: KindPercentage, Value: 20},
+			discounts: []int64{0, 100},
+			total:     900,
+		},
+		{
+			name:      "sale items left out",
+			lines:     []Line{line(seller, 1000, 1, halfOff), line(seller, 1000, 1)},
+			coupon:    &Coupon{ID: uuid.New(), BusinessAdminID: seller, Kind: KindPercentage, Value: 10},
+			discounts: []int64{0, 100},
+			total:     1400,
+		},
+		{
+			name:      "stacking with sales",
+			lines:     []Line{line(seller, 1000, 1, halfOff), line(seller, 1000, 1)},
+			coupon:    &Coupon{ID: uuid.New(), BusinessAdminID: seller, Kind: KindPercentage, Value: 10, StacksWithSales: true},
+			discounts: []int64{50, 100},
+			total:     1350,
+		},
+		{
+			name:      "fixed discount capped at the subtotal",
+			lines:     []Line{line(seller, 300, 1), line(seller, 200, 1)},
+			coupon:    &Coupon{ID: uuid.New(), BusinessAdminID: seller, Kind: KindFixed, Value: 10},
+			discounts: []int64{300, 200},
+			total:     0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Price(tt.lines, tt.coupon)
+			if err != nil {
+				t.Fatalf("Price() error = %v", err)
+			}
+			var discount, total int64
+			for i, priced := range result.Lines {
+				if priced.Discount.Amount != tt.discounts[i] {
+					t.Errorf("line %d discount = %d, want %d", i, priced.Discount.Amount, tt.discounts[i])
+				}
+				discount += priced.Discount.Amount
+				total += priced.Total.Amount
+			}
+			if result.Discount.Amount != discount {
+				t.Errorf("Discount = %d, want the lines' sum %d", result.Discount.Amount, discount)
+			}
+			if result.Total.Amount != tt.total || total != tt.total {
+				t.Errorf("Total = %d and lines add up to %d, want %d", result.Total.Amount, total, tt.total)
+			}
+		})
+	}
+}
+
+func TestPriceErrors(t *testing.T) {
+	seller := uuid.New()
+	inr := Line{BusinessAdminID: seller, Quantity: 1, ListPrice: money.New(1000, "INR")}
+	usd := Line{BusinessAdminID: seller, Quantity: 1, ListPrice: money.New(1000, "USD")}
+
+	tests := []struct {
+		name   string
+		lines  []Line
+		coupon *Coupon
+		want   error
+	}{
+		{"mixed currencies", []Line{inr, usd}, nil, ErrMixedCurrencies},
+		{"other seller's coupon", []Line{inr}, &Coupon{BusinessAdminID: uuid.New(), Kind: KindPercentage, Value: 10}, ErrCouponNotApplicable},
+		{"below minimum spend", []Line{inr}, &Coupon{BusinessAdminID: seller, Kind: KindPercentage, Value: 10, MinSubtotal: 20}, ErrCouponBelowThreshold},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Price(tt.lines, tt.coupon); !errors.Is(err, tt.want) {
+				t.Errorf("Price() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSalePrice(t *testing.T) {
+	listPrice := money.New(10000, "INR")
+	tenOff := Sale{ID: uuid.New(), Kind: KindPercentage, Value: 10}
+	thirtyOff := Sale{ID: uuid.New(), Kind: KindFixed, Value: 30}
+
+	price, sale := SalePrice(listPrice, []Sale{tenOff, thirtyOff})
+	if price.Amount != 7000 || sale == nil || sale.ID != thirtyOff.ID {
+		t.Errorf("SalePrice() = %d, %v, want 7000 with the fixed sale", price.Amount, sale)
+	}
+	price, sale = SalePrice(listPrice, nil)
+	if price != listPrice || sale != nil {
+		t.Errorf("SalePrice() with no sales = %d, %v, want the list price", price.Amount, sale)
+	}
+}
@@ -0,0 +1,120 @@
+package realtime
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// recentEvents is how many events the hub keeps so reconnecting clients can catch up
+const recentEvents = 256
+
+// subscriptionBuffer is how many events may queue for a slow subscriber before new ones are dropped
+const subscriptionBuffer = 64
+
+// Event is a message pushed to every subscriber of any of its topics
+type Event struct {
+	ID     string          `json:"id"`
+	Topics []string        `json:"topics"`
+	Type   string          `json:"type"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// NewEvent creates an event with a fresh ID and data marshalled to JSON
+func NewEvent(eventType string, data interface{}, topics ...string) (Event, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{ID: uuid.NewString(), Topics: topics, Type: eventType, Data: raw}, nil
+}
+
+// Subscription receives the events of the topics it subscribed to
+type Subscription struct {
+	topics map[string]bool
+	Events chan Event
+}
+
+func (s *Subscription) wants(event Event) bool {
+	for _, topic := range event.Topics {
+		if s.topics[topic] {
+			return true
+		}
+	}
+	return false
+}
+
+// Hub fans events out to the subscribers connected to this process
+type Hub struct {
+	mu          sync.RWMutex
+	subscribers map[*Subscription]struct{}
+	recent      []Event
+}
+
+// NewHub creates an empty hub
+func NewHub() *Hub {
+	return &Hub{subscribers: make(map[*Subscription]struct{})}
+}
+
+// Subscribe registers a subscriber for the given topics. If lastEventID is one of the hub's recent
+// events, the events after it that match the topics are returned so the client can catch up.
+func (h *Hub) Subscribe(topics []string, lastEventID string) (*Subscription, []Event) {
+	s := &Subscription{topics: make(map[string]bool), Events: make(chan Event, subscriptionBuffer)}
+	for _, topic := range topics {
+		s.topics[topic] = true
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.subscribers[s] = struct{}{}
+
+	missed := make([]Event, 0)
+	if lastEventID == "" {
+		return s, missed
+	}
+	for i, event := range h.recent {
+		if event.ID != lastEventID {
+			continue
+		}
+		for _, later := range h.recent[i+1:] {
+			if s.wants(later) {
+				missed = append(missed, later)
+			}
+		}
+		break
+	}
+	return s, missed
+}
+
+// Unsubscribe removes a subscriber and closes its channel
+func (h *Hub) Unsubscribe(s *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subscribers[s]; ok {
+		delete(h.subscribers, s)
+		close(s.Events)
+	}
+}
+
+// Dispatch delivers an event to the local subscribers of its topics.
+// Subscribers that have fallen too far behind miss the event rather than blocking the hub.
+func (h *Hub) Dispatch(event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.recent = append(h.recent, event)
+	if len(h.recent) > recentEvents {
+		h.recent = h.recent[len(h.recent)-recentEvents:]
+	}
+
+	for s := range h.subscribers {
+		if !s.wants(event) {
+			continue
+		}
+		select {
+		case s.Events <- event:
+		default:
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package realtime
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// EventsChannel is the Postgres channel events are published on so every backend replica receives them
+const EventsChannel = "chainwave_events"
+
+// InventoryChannel is the Postgres channel the low inventory and back-in-stock triggers notify on
+const InventoryChannel = "inventory"
+
+// inventoryBackInStock marks notifications from the notify_back_in_stock trigger. The low inventory trigger
+// sends no event.
+const inventoryBackInStock = "back_in_stock"
+
+// inventoryNotification is the payload sent by the notify_low_inventory and notify_back_in_stock triggers.
+// Back-in-stock notifications are sent once for each subscribed customer, with the ID of the notification
+// stored for them.
+type inventoryNotification struct {
+	Event           string `json:"event,omitempty"`
+	NotificationID  string `json:"notification_id,omitempty"`
+	ItemID          string `json:"item_id"`
+	BusinessAdminID string `json:"business_admin_id"`
+	CustomerID      string `json:"customer_id,omitempty"`
+	Name            string `json:"name"`
+	Quantity        int    `json:"quantity"`
+}
+
+// Publish sends an event to every replica. The event is stored in the realtime_events table and only its ID
+// goes through Postgres NOTIFY, whose payloads are limited to 8000 bytes; each replica's Listen loop loads it
+// from there and hands it to its hub, including the one that published it.
+func Publish(db *sql.DB, event Event) error {
+	_, err := db.Exec(`WITH stored AS (
+			INSERT INTO realtime_events (id, type, topics, data) VALUES ($1, $2, $3, $4) RETURNING id
+		)
+		SELECT pg_notify($5, id::text) FROM stored`,
+		event.ID, event.Type, pq.Array(event.Topics), string(event.Data), EventsChannel)
+	return err
+}
+
+// loadEvent fetches a published event by its ID
+func loadEvent(db *sql.DB, id string) (Event, error) {
+	var event Event
+	var data []byte
+	err := db.QueryRow(`SELECT id, type, topics, data FROM realtime_events WHERE id = $1`, id).Scan(
+		&event.ID, &event.Type, pq.Array(&event.Topics), &data)
+	event.Data = data
+	return event, err
+}
+
+// PruneEvents deletes stored events older than age, long after every replica has loaded them, and returns
+// how many were deleted
+func PruneEvents(db *sql.DB, age time.Duration) (int64, error) {
+	result, err := db.Exec(`DELETE FROM realtime_events WHERE created_at < NOW() - $1 * INTERVAL '1 second'`, age.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// PublishLogged publishes an event built from data, logging rather than returning failures.
+// It is meant for handlers where the change has already been committed and the push is best effort.
+func PublishLogged(db *sql.DB, eventType string, data interface{}, topics ...string) {
+	event, err := NewEvent(eventType, data, topics...)
+	if err == nil {
+		err = Publish(db, event)
+	}
+	if err != nil {
+		log.Printf("failed to publish %s event: %v", eventType, err)
+	}
+}
+
+// Listen subscribes to the events and inventory channels and dispatches notifications to the hub
+// until the process exits, loading published events through db. The listener reconnects on its own
+// if the connection drops.
+func (h *Hub) Listen(db *sql.DB, databaseURL string) error {
+	listener := pq.NewListener(databaseURL, 10*time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("realtime listener: %v", err)
+		}
+	})
+	if err := listener.Listen(EventsChannel); err != nil {
+		return err
+	}
+	if err := listener.Listen(InventoryChannel); err != nil {
+		return err
+	}
+
+	go func() {
+		for {
+			select {
+			case notification := <-listener.Notify:
+				// A nil notification means the connection was re-established and some may have been missed
+				if notification == nil {
+					continue
+				}
+				h.handleNotification(db, notification)
+			case <-time.After(90 * time.Second):
+				go listener.Ping()
+			}
+		}
+	}()
+	return nil
+}
+
+func (h *Hub) handleNotification(db *sql.DB, notification *pq.Notification) {
+	switch notification.Channel {
+	case EventsChannel:
+		event, err := loadEvent(db, notification.Extra)
+		if err != nil {
+			log.Printf("realtime: failed to load event %s: %v", notification.Extra, err)
+			return
+		}
+		h.Dispatch(event)
+	case InventoryChannel:
+		var inventory inventoryNotification
+		if err := json.Unmarshal([]byte(notification.Extra), &inventory); err != nil {
+			log.Printf("realtime: invalid inventory payload: %v", err)
+			return
+		}
+		var event Event
+		var err error
+		if inventory.Event == inventoryBackInStock {
+			event, err = NewEvent("inventory.back_in_stock", inventory, "customer:"+inventory.CustomerID)
+		} else {
+			event, err = NewEvent("inventory.low", inventory, "business_admin:"+inventory.BusinessAdminID)
+		}
+		if err != nil {
+			log.Printf("realtime: %v", err)
+			return
+		}
+		h.Dispatch(event)
+	}
+}
@@ -0,0 +1,443 @@
+package repository
+
+import (
+	"chainwave/backend/internal/models"
+	"chainwave/backend/internal/money"
+	"chainwave/backend/internal/pricing"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrCartNotFound     = errors.New("cart not found")
+	ErrCartItemNotFound = errors.New("item is not in the cart")
+	ErrCartEmpty        = errors.New("cart is empty")
+	ErrItemNotFound     = errors.New("item not found")
+
+	ErrTooManyGuestCarts = errors.New("too many carts created, try again later")
+)
+
+// GetCustomerCartID returns the ID of a customer's cart, creating the cart if they don't have one yet
+func GetCustomerCartID(db *sql.DB, customerID uuid.UUID) (uuid.UUID, error) {
+	return customerCartID(db, customerID)
+}
+
+func customerCartID(q queryer, customerID uuid.UUID) (uuid.UUID, error) {
+	var id uuid.UUID
+	err := q.QueryRow(`INSERT INTO carts (id, customer_id) VALUES (uuid_generate_v4(), $1)
+		ON CONFLICT (customer_id) DO UPDATE SET customer_id = EXCLUDED.customer_id RETURNING id`, customerID).Scan(&id)
+	return id, err
+}
+
+// CreateGuestCart creates an empty cart for a visitor who hasn't logged in. It returns the cart's ID and
+// the token the guest presents to use it. It fails with ErrTooManyGuestCarts if the client's address
+// already created limit guest carts within the window.
+func CreateGuestCart(db *sql.DB, clientIP string, limit int, window time.Duration) (uuid.UUID, string, error) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return uuid.Nil, "", err
+	}
+	token := hex.EncodeToString(secret)
+
+	var id uuid.UUID
+	err := db.QueryRow(`INSERT INTO carts (id, guest_token, created_ip)
+		SELECT uuid_generate_v4(), $1, $2
+		WHERE (SELECT COUNT(*) FROM carts
+			WHERE created_ip = $2 AND customer_id IS NULL AND created_at > NOW() - $4 * INTERVAL '1 second') < $3
+		RETURNING id`, token, clientIP, limit, window.Seconds()).Scan(&id)
+	if err == sql.ErrNoRows {
+		return uuid.Nil, "", ErrTooManyGuestCarts
+	}
+	return id, token, err
+}
+
+// GetGuestCartID returns the ID of the guest cart with a token
+func GetGuestCartID(db *sql.DB, token string) (uuid.UUID, error) {
+	var id uuid.UUID
+	err := db.QueryRow(`SELECT id FROM carts WHERE guest_token = $1 AND customer_id IS NULL`, token).Scan(&id)
+	if err == sql.ErrNoRows {
+		return uuid.Nil, ErrCartNotFound
+	}
+	return id, err
+}
+
+// GetCart fetches a cart with its lines checked against the items' current prices, sales and stock, and
+// priced under its coupon. A coupon that no longer applies is left on the cart with CouponError saying why,
+// and the cart can't be ordered until it is removed. Neither can a cart holding items priced in different
+// currencies, which has no totals and says so in CurrencyError.
+func GetCart(db *sql.DB, cartID uuid.UUID) (*models.Cart, error) {
+	var cart models.Cart
+	var couponCode sql.NullString
+	err := db.QueryRow(`SELECT id, customer_id, coupon_code, updated_at FROM carts WHERE id = $1`, cartID).Scan(&cart.ID, &cart.CustomerID, &couponCode, &cart.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrCartNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`SELECT ci.item_id, i.name, i.image_url, i.business_admin_id, ci.quantity, i.price_minor, i.currency, ci.added_price, i.quantity
+		FROM cart_items ci JOIN items i ON ci.item_id = i.id
+		WHERE ci.cart_id = $1 ORDER BY ci.added_at, ci.item_id`, cartID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cart.Items = make([]models.CartItem, 0)
+	cart.Orderable = true
+	lines := make([]pricing.Line, 0)
+	itemIDs := make([]uuid.UUID, 0)
+	for rows.Next() {
+		var item models.CartItem
+		var listPrice money.Money
+		if err := rows.Scan(&item.ItemID, &item.Name, &item.ImageURL, &item.BusinessAdminID, &item.Quantity,
+			&listPrice.Amount, &listPrice.Currency, &item.AddedPrice, &item.Available); err != nil {
+			return nil, err
+		}
+		item.Currency = listPrice.Currency
+		item.ListPrice = listPrice.Major()
+		item.InStock = item.Available >= item.Quantity
+
+		cart.Items = append(cart.Items, item)
+		cart.ItemCount += item.Quantity
+		cart.Orderable = cart.Orderable && item.InStock
+		lines = append(lines, pricing.Line{ItemID: item.ItemID, BusinessAdminID: item.BusinessAdminID, Quantity: item.Quantity, ListPrice: listPrice})
+		itemIDs = append(itemIDs, item.ItemID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	cart.Orderable = cart.Orderable && len(cart.Items) > 0
+
+	sales, err := activeSales(db, itemIDs)
+	if err != nil {
+		return nil, err
+	}
+	for i := range lines {
+		lines[i].Sales = sales[lines[i].ItemID]
+	}
+
+	var coupon *pricing.Coupon
+	if couponCode.Valid {
+		cart.CouponCode = couponCode.String
+		coupon, err = loadCoupon(db, couponCode.String, cart.CustomerID, false)
+		if IsCouponError(err) {
+			cart.CouponError = err.Error()
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	priced, err := pricing.Price(lines, coupon)
+	if err == pricing.ErrMixedCurrencies {
+		// Price each line on its own so the customer still sees what everything costs
+		cart.CurrencyError = err.Error()
+		priced.Lines = make([]pricing.PricedLine, len(lines))
+		for i, line := range lines {
+			single, _ := pricing.Price([]pricing.Line{line}, nil)
+			priced.Lines[i] = single.Lines[0]
+		}
+	} else if err != nil {
+		cart.CouponError = err.Error()
+		priced, _ = pricing.Price(lines, nil)
+	}
+	cart.Orderable = cart.Orderable && cart.CouponError == "" && cart.CurrencyError == ""
+
+	for i, line := range priced.Lines {
+		item := &cart.Items[i]
+		item.Price = line.UnitPrice.Major()
+		item.Discount = line.Discount.Major()
+		item.PriceChanged = money.FromMajor(item.AddedPrice, item.Currency).Amount != line.UnitPrice.Amount
+		item.LineTotal = line.UnitPrice.Mul(item.Quantity).Major()
+	}
+	cart.Currency = priced.Currency
+	cart.Subtotal = priced.Subtotal.Major()
+	cart.Discount = priced.Discount.Major()
+	cart.Total = priced.Total.Major()
+	return &cart, nil
+}
+
+// AddCartItem adds quantity of an item to a cart on top of any already in it, as long as there is
+// enough stock for the new total. The line's price is refreshed to the item's current price.
+func AddCartItem(db *sql.DB, cartID, itemID uuid.UUID, quantity int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	var inCart int
+	err = tx.QueryRow(`SELECT quantity FROM cart_items WHERE cart_id = $1 AND item_id = $2 FOR UPDATE`, cartID, itemID).Scan(&inCart)
+	if err != nil && err != sql.ErrNoRows {
+		tx.Rollback()
+		return err
+	}
+	if err := putCartItem(tx, cartID, itemID, inCart+quantity); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetCartItemQuantity changes the quantity of a line already in a cart, removing it when quantity is zero
+func SetCartItemQuantity(db *sql.DB, cartID, itemID uuid.UUID, quantity int) error {
+	if quantity == 0 {
+		return RemoveCartItem(db, cartID, itemID)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	var inCart int
+	err = tx.QueryRow(`SELECT quantity FROM cart_items WHERE cart_id = $1 AND item_id = $2 FOR UPDATE`, cartID, itemID).Scan(&inCart)
+	if err == sql.ErrNoRows {
+		err = ErrCartItemNotFound
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := putCartItem(tx, cartID, itemID, quantity); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// RemoveCartItem removes an item from a cart
+func RemoveCartItem(db *sql.DB, cartID, itemID uuid.UUID) error {
+	result, err := db.Exec(`DELETE FROM cart_items WHERE cart_id = $1 AND item_id = $2`, cartID, itemID)
+	if err != nil {
+		return err
+	}
+	if err := expectUpdated(result); err != nil {
+		return ErrCartItemNotFound
+	}
+	_, err = db.Exec(`UPDATE carts SET updated_at = NOW() WHERE id = $1`, cartID)
+	return err
+}
+
+// ClearCart removes every line from a cart
+func ClearCart(db *sql.DB, cartID uuid.UUID) error {
+	if _, err := db.Exec(`DELETE FROM cart_items WHERE cart_id = $1`, cartID); err != nil {
+		return err
+	}
+	_, err := db.Exec(`UPDATE carts SET updated_at = NOW() WHERE id = $1`, cartID)
+	return err
+}
+
+// MergeGuestCart moves the lines of a guest cart into a customer's cart when the guest logs in, then
+// deletes the guest cart. Quantities of an item in both carts are added together as far as stock allows,
+// without lowering what the customer already had.
+func MergeGuestCart(db *sql.DB, token string, customerID uuid.UUID) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	var guestCartID uuid.UUID
+	err = tx.QueryRow(`SELECT id FROM carts WHERE guest_token = $1 AND customer_id IS NULL FOR UPDATE`, token).Scan(&guestCartID)
+	if err == sql.ErrNoRows {
+		err = ErrCartNotFound
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	cartID, err := customerCartID(tx, customerID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	rows, err := tx.Query(`SELECT g.item_id, g.quantity, COALESCE(c.quantity, 0), i.quantity, i.price_minor, i.currency
+		FROM cart_items g
+		JOIN items i ON g.item_id = i.id
+		LEFT JOIN cart_items c ON c.cart_id = $2 AND c.item_id = g.item_id
+		WHERE g.cart_id = $1`, guestCartID, cartID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	type mergedLine struct {
+		itemID   uuid.UUID
+		quantity int
+		price    money.Money
+	}
+	lines := make([]mergedLine, 0)
+	for rows.Next() {
+		var line mergedLine
+		var guest, existing, stock int
+		if err := rows.Scan(&line.itemID, &guest, &existing, &stock, &line.price.Amount, &line.price.Currency); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return err
+		}
+		line.quantity = existing + guest
+		if line.quantity > stock {
+			line.quantity = stock
+		}
+		if line.quantity < existing {
+			line.quantity = existing
+		}
+		// Keep an out of stock line so the customer sees what they had picked
+		if line.quantity == 0 {
+			line.quantity = guest
+		}
+		lines = append(lines, line)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, line := range lines {
+		price, err := currentPrice(tx, line.itemID, line.price)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		_, err = tx.Exec(`INSERT INTO cart_items (cart_id, item_id, quantity, added_price) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (cart_id, item_id) DO UPDATE SET quantity = EXCLUDED.quantity, added_price = EXCLUDED.added_price, updated_at = NOW()`,
+			cartID, line.itemID, line.quantity, price.Major())
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM carts WHERE id = $1`, guestCartID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE carts SET updated_at = NOW() WHERE id = $1`, cartID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// MergeGuestCartForUser merges a guest cart into the cart of the user's customer role, failing with
+// ErrNotCustomer if they don't have one
+func MergeGuestCartForUser(db *sql.DB, token string, userID uuid.UUID) error {
+	var customerID uuid.UUID
+	err := db.QueryRow(`SELECT id FROM customers WHERE user_id = $1`, userID).Scan(&customerID)
+	if err == sql.ErrNoRows {
+		return ErrNotCustomer
+	}
+	if err != nil {
+		return err
+	}
+	return MergeGuestCart(db, token, customerID)
+}
+
+// CheckoutCart turns a customer's cart into an order at the items' current prices, less the cart's coupon,
+// and empties the cart.
+// It ships like CreateOrder, and the cart is left untouched if the order can't be placed.
+func CheckoutCart(db *sql.DB, customerID uuid.UUID, locationID *uuid.UUID) (*models.Order, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	var cartID uuid.UUID
+	var couponCode sql.NullString
+	err = tx.QueryRow(`SELECT id, coupon_code FROM carts WHERE customer_id = $1 FOR UPDATE`, customerID).Scan(&cartID, &couponCode)
+	if err == sql.ErrNoRows {
+		err = ErrCartEmpty
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	rows, err := tx.Query(`SELECT item_id, quantity FROM cart_items WHERE cart_id = $1 ORDER BY added_at, item_id`, cartID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	lines := make([]models.OrderLineRequest, 0)
+	for rows.Next() {
+		var line models.OrderLineRequest
+		if err := rows.Scan(&line.ItemID, &line.Quantity); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if len(lines) == 0 {
+		tx.Rollback()
+		return nil, ErrCartEmpty
+	}
+
+	order, err := createOrder(tx, customerID, lines, locationID, couponCode.String)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM cart_items WHERE cart_id = $1`, cartID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if _, err := tx.Exec(`UPDATE carts SET coupon_code = NULL, updated_at = NOW() WHERE id = $1`, cartID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return order, tx.Commit()
+}
+
+// PruneGuestCarts deletes guest carts that haven't changed for the given age, or for emptyAge if they hold
+// no items, and returns how many were deleted
+func PruneGuestCarts(db *sql.DB, age, emptyAge time.Duration) (int64, error) {
+	result, err := db.Exec(`DELETE FROM carts c WHERE c.customer_id IS NULL AND (
+			c.updated_at < NOW() - $1 * INTERVAL '1 second'
+			OR (c.updated_at < NOW() - $2 * INTERVAL '1 second' AND NOT EXISTS (SELECT 1 FROM cart_items ci WHERE ci.cart_id = c.id))
+		)`, age.Seconds(), emptyAge.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// putCartItem sets the quantity of an item in a cart at its current price after any sale, failing with
+// ErrInsufficientStock if there isn't enough of it
+func putCartItem(tx *sql.Tx, cartID, itemID uuid.UUID, quantity int) error {
+	var price money.Money
+	var stock int
+	err := tx.QueryRow(`SELECT price_minor, currency, quantity FROM items WHERE id = $1`, itemID).Scan(&price.Amount, &price.Currency, &stock)
+	if err == sql.ErrNoRows {
+		return ErrItemNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if quantity > stock {
+		return ErrInsufficientStock
+	}
+	if price, err = currentPrice(tx, itemID, price); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT INTO cart_items (cart_id, item_id, quantity, added_price) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (cart_id, item_id) DO UPDATE SET quantity = EXCLUDED.quantity, added_price = EXCLUDED.added_price, updated_at = NOW()`,
+		cartID, itemID, quantity, price.Major())
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE carts SET updated_at = NOW() WHERE id = $1`, cartID)
+	return err
+}
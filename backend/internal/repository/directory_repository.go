@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"chainwave/backend/internal/geo"
+	"chainwave/backend/internal/models"
+	"database/sql"
+	"fmt"
+)
+
+// distanceSQL returns an SQL expression for the haversine distance in km between the coordinates of
+// the locations alias and the point held in the given query parameters
+func distanceSQL(alias string, latParam, lonParam int) string {
+	return fmt.Sprintf(`(2 * %[4]f * ASIN(SQRT(
+		POWER(SIN(RADIANS(%[1]s.latitude - $%[2]d) / 2), 2) +
+		COS(RADIANS($%[2]d)) * COS(RADIANS(%[1]s.latitude)) * POWER(SIN(RADIANS(%[1]s.longitude - $%[3]d) / 2), 2)
+	)))`, alias, latParam, lonParam, geo.EarthRadiusKm)
+}
+
+// nearSQL returns a condition keeping locations of the alias inside a circle. The indexed bounding box
+// narrows the rows before the exact distance check. It appends its parameters to args.
+func nearSQL(alias string, near geo.Circle, args *[]interface{}) (condition string, distance string) {
+	minLat, maxLat, minLon, maxLon := near.BoundingBox()
+	n := len(*args)
+	*args = append(*args, minLat, maxLat, minLon, maxLon, near.Center.Latitude, near.Center.Longitude, near.RadiusKm)
+	distance = distanceSQL(alias, n+5, n+6)
+	condition = fmt.Sprintf(`%[1]s.latitude BETWEEN $%[2]d AND $%[3]d AND %[1]s.longitude BETWEEN $%[4]d AND $%[5]d AND %[6]s <= $%[7]d`,
+		alias, n+1, n+2, n+3, n+4, distance, n+7)
+	return condition, distance
+}
+
+// ListSuppliers fetches the supplier directory. With near set, only suppliers inside the circle are
+// returned, nearest first; otherwise all suppliers are returned by name.
+func ListSuppliers(db *sql.DB, near *geo.Circle, limit, offset int) ([]models.SupplierListing, error) {
+	args := []interface{}{}
+	where, order, distance := "TRUE", "s.supplier_name", "NULL::FLOAT8"
+	if near != nil {
+		where, distance = nearSQL("l", *near, &args)
+		order = "distance"
+	}
+	args = append(args, limit, offset)
+
+	rows, err := db.Query(fmt.Sprintf(`SELECT s.id, COALESCE(s.supplier_name, ''), COALESCE(s.contact_info, ''), COALESCE(s.address, ''), s.location_id, s.user_id,
+			l.id, COALESCE(l.address, ''), COALESCE(l.city, ''), COALESCE(l.state, ''), COALESCE(l.country, ''), COALESCE(l.postal_code, ''),
+			COALESCE(l.latitude, 0), COALESCE(l.longitude, 0), %s AS distance
+		FROM suppliers s JOIN locations l ON s.location_id = l.id
+		WHERE %s ORDER BY %s LIMIT $%d OFFSET $%d`, distance, where, order, len(args)-1, len(args)), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suppliers := make([]models.SupplierListing, 0)
+	for rows.Next() {
+		var s models.SupplierListing
+		l := &s.Location
+		if err := rows.Scan(&s.Id, &s.SupplierName, &s.ContactInfo, &s.Address, &s.LocationId, &s.UserId,
+			&l.ID, &l.Address, &l.City, &l.State, &l.Country, &l.PostalCode, &l.Latitude, &l.Longitude, &s.Distance); err != nil {
+			return nil, err
+		}
+		suppliers = append(suppliers, s)
+	}
+	return suppliers, rows.Err()
+}
+
+// ListBusinessAdmins fetches the business admin directory. With near set, only business admins inside
+// the circle are returned, nearest first; otherwise all business admins are returned by company name.
+func ListBusinessAdmins(db *sql.DB, near *geo.Circle, limit, offset int) ([]models.BusinessAdminListing, error) {
+	args := []interface{}{}
+	where, order, distance := "TRUE", "b.company_name", "NULL::FLOAT8"
+	if near != nil {
+		where, distance = nearSQL("l", *near, &args)
+		order = "distance"
+	}
+	args = append(args, limit, offset)
+
+	rows, err := db.Query(fmt.Sprintf(`SELECT b.id, COALESCE(b.company_name, ''), COALESCE(b.contact_info, ''), b.location_id, b.user_id, b.currency,
+			l.id, COALESCE(l.address, ''), COALESCE(l.city, ''), COALESCE(l.state, ''), COALESCE(l.country, ''), COALESCE(l.postal_code, ''),
+			COALESCE(l.latitude, 0), COALESCE(l.longitude, 0), %s AS distance
+		FROM business_admins b JOIN locations l ON b.location_id = l.id
+		WHERE %s ORDER BY %s LIMIT $%d OFFSET $%d`, distance, where, order, len(args)-1, len(args)), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	businessAdmins := make([]models.BusinessAdminListing, 0)
+	for rows.Next() {
+		var b models.BusinessAdminListing
+		l := &b.Location
+		if err := rows.Scan(&b.Id, &b.CompanyName, &b.ContactInfo, &b.LocationId, &b.UserId, &b.Currency,
+			&l.ID, &l.Address, &l.City, &l.State, &l.Country, &l.PostalCode, &l.Latitude, &l.Longitude, &b.Distance); err != nil {
+			return nil, err
+		}
+		businessAdmins = append(businessAdmins, b)
+	}
+	return businessAdmins, rows.Err()
+}
@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"chainwave/backend/internal/models"
+	"chainwave/backend/internal/units"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+var ErrDocumentNotIssuable = errors.New("documents are issued once the order has been paid, and not for cancelled orders")
+
+// documentColumns selects an order document along with the order and seller it belongs to
+const documentColumns = `d.id, so.order_id, d.seller_order_id, so.business_admin_id, d.kind, COALESCE(d.number, ''), d.created_at, d.path`
+
+func scanDocument(row rowScanner) (*models.OrderDocument, string, error) {
+	var doc models.OrderDocument
+	var path string
+	err := row.Scan(&doc.ID, &doc.OrderID, &doc.SellerOrderID, &doc.BusinessAdminID, &doc.Kind, &doc.Number, &doc.CreatedAt, &path)
+	if err != nil {
+		return nil, "", err
+	}
+	return &doc, path, nil
+}
+
+// GetSellerOrderParties fetches the order a seller order is part of and the business admin selling it
+func GetSellerOrderParties(db *sql.DB, sellerOrderID uuid.UUID) (uuid.UUID, uuid.UUID, error) {
+	var orderID, businessAdminID uuid.UUID
+	err := db.QueryRow(`SELECT order_id, business_admin_id FROM seller_orders WHERE id = $1`, sellerOrderID).Scan(&orderID, &businessAdminID)
+	if err == sql.ErrNoRows {
+		err = ErrSellerOrderNotFound
+	}
+	return orderID, businessAdminID, err
+}
+
+// GetOrderDocuments fetches the documents issued for an order, or only for businessAdminID's part of it
+// when it is set
+func GetOrderDocuments(db *sql.DB, orderID uuid.UUID, businessAdminID *uuid.UUID) ([]models.OrderDocument, error) {
+	rows, err := db.Query(`SELECT `+documentColumns+` FROM order_documents d JOIN seller_orders so ON d.seller_order_id = so.id
+		WHERE so.order_id = $1 AND ($2::uuid IS NULL OR so.business_admin_id = $2)
+		ORDER BY d.created_at`, orderID, businessAdminID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	docs := make([]models.OrderDocument, 0)
+	for rows.Next() {
+		doc, _, err := scanDocument(rows)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, *doc)
+	}
+	return docs, rows.Err()
+}
+
+// GetOrderDocument fetches a seller order's document of a kind and where its PDF is kept. It returns
+// sql.ErrNoRows if none has been issued.
+func GetOrderDocument(db *sql.DB, sellerOrderID uuid.UUID, kind string) (*models.OrderDocument, string, error) {
+	return scanDocument(db.QueryRow(`SELECT `+documentColumns+` FROM order_documents d JOIN seller_orders so ON d.seller_order_id = so.id
+		WHERE d.seller_order_id = $1 AND d.kind = $2`, sellerOrderID, kind))
+}
+
+// IssueInvoice gives a seller order's invoice the seller's next invoice number and records it. store renders
+// and saves the PDF for the invoice and returns where it was saved; the number is only used up if it succeeds.
+// An invoice already issued is returned as it is.
+func IssueInvoice(db *sql.DB, sellerOrderID uuid.UUID, store func(doc models.OrderDocument) (string, error)) (*models.OrderDocument, string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, "", err
+	}
+
+	doc := models.OrderDocument{SellerOrderID: sellerOrderID, Kind: models.DocumentInvoice}
+	var status string
+	err = tx.QueryRow(`SELECT order_id, business_admin_id, status FROM seller_orders WHERE id = $1 FOR UPDATE`, sellerOrderID).Scan(
+		&doc.OrderID, &doc.BusinessAdminID, &status)
+	if err == sql.ErrNoRows {
+		err = ErrSellerOrderNotFound
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, "", err
+	}
+
+	issued, path, err := scanDocument(tx.QueryRow(`SELECT `+documentColumns+` FROM order_documents d JOIN seller_orders so ON d.seller_order_id = so.id
+		WHERE d.seller_order_id = $1 AND d.kind = $2`, sellerOrderID, doc.Kind))
+	if err == nil {
+		return issued, path, tx.Commit()
+	}
+	if err != sql.ErrNoRows {
+		tx.Rollback()
+		return nil, "", err
+	}
+	if status == models.OrderStatusPending || status == models.OrderStatusCancelled {
+		tx.Rollback()
+		return nil, "", ErrDocumentNotIssuable
+	}
+
+	var number int64
+	err = tx.QueryRow(`INSERT INTO invoice_counters (business_admin_id, last_number) VALUES ($1, 1)
+		ON CONFLICT (business_admin_id) DO UPDATE SET last_number = invoice_counters.last_number + 1
+		RETURNING last_number`, doc.BusinessAdminID).Scan(&number)
+	if err != nil {
+		tx.Rollback()
+		return nil, "", err
+	}
+	doc.Number = InvoiceNumber(number)
+
+	err = tx.QueryRow(`SELECT uuid_generate_v4(), NOW()`).Scan(&doc.ID, &doc.CreatedAt)
+	if err != nil {
+		tx.Rollback()
+		return nil, "", err
+	}
+	path, err = store(doc)
+	if err != nil {
+		tx.Rollback()
+		return nil, "", err
+	}
+	_, err = tx.Exec(`INSERT INTO order_documents (id, seller_order_id, kind, number, path, created_at) VALUES ($1, $2, $3, $4, $5, $6)`,
+		doc.ID, doc.SellerOrderID, doc.Kind, doc.Number, path, doc.CreatedAt)
+	if err != nil {
+		tx.Rollback()
+		return nil, "", err
+	}
+	return &doc, path, tx.Commit()
+}
+
+// InvoiceNumber formats the nth invoice a seller issues
+func InvoiceNumber(n int64) string {
+	return fmt.Sprintf("INV-%06d", n)
+}
+
+// SavePackingSlip records where a seller order's packing slip was saved, replacing any earlier one
+func SavePackingSlip(db *sql.DB, sellerOrderID uuid.UUID, path string) (*models.OrderDocument, error) {
+	var id uuid.UUID
+	err := db.QueryRow(`INSERT INTO order_documents (seller_order_id, kind, path) VALUES ($1, $2, $3)
+		ON CONFLICT (seller_order_id, kind) DO UPDATE SET path = EXCLUDED.path, created_at = NOW()
+		RETURNING id`, sellerOrderID, models.DocumentPackingSlip, path).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+	doc, _, err := GetOrderDocument(db, sellerOrderID, models.DocumentPackingSlip)
+	return doc, err
+}
+
+// GetPackingSlip fetches what to pack for a seller order: the lines still to ship after cancellations, the
+// warehouses they ship from and where they go. Cancelled and unpaid seller orders have nothing to pack.
+func GetPackingSlip(db *sql.DB, sellerOrderID uuid.UUID) (*models.PackingSlip, error) {
+	slip := models.PackingSlip{SellerOrderID: sellerOrderID}
+	var status string
+	err := db.QueryRow(`SELECT so.order_id, so.status, o.created_at,
+			COALESCE(b.company_name, ''), COALESCE(sl.address, ''), COALESCE(sl.city, ''), COALESCE(sl.state, ''),
+			COALESCE(sl.country, ''), COALESCE(sl.postal_code, ''),
+			COALESCE(c.customer_name, ''), COALESCE(bl.address, ''), COALESCE(bl.city, ''), COALESCE(bl.state, ''),
+			COALESCE(bl.country, ''), COALESCE(bl.postal_code, '')
+		FROM seller_orders so
+		JOIN orders o ON so.order_id = o.id
+		JOIN business_admins b ON so.business_admin_id = b.id
+		LEFT JOIN locations sl ON b.location_id = sl.id
+		JOIN customers c ON o.customer_id = c.id
+		LEFT JOIN locations bl ON o.location_id = bl.id
+		WHERE so.id = $1`, sellerOrderID).Scan(&slip.OrderID, &status, &slip.OrderedAt,
+		&slip.Seller.Name, &slip.Seller.Address, &slip.Seller.City, &slip.Seller.State, &slip.Seller.Country, &slip.Seller.PostalCode,
+		&slip.ShipTo.Name, &slip.ShipTo.Address, &slip.ShipTo.City, &slip.ShipTo.State, &slip.ShipTo.Country, &slip.ShipTo.PostalCode)
+	if err == sql.ErrNoRows {
+		return nil, ErrSellerOrderNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if status == models.OrderStatusPending || status == models.OrderStatusCancelled {
+		return nil, ErrDocumentNotIssuable
+	}
+
+	rows, err := db.Query(`SELECT oi.item_id, COALESCE(i.name, ''), COALESCE(w.name, ''), oi.quantity - oi.cancelled_quantity,
+			COALESCE(i.weight, 0)
+		FROM order_items oi
+		LEFT JOIN items i ON oi.item_id = i.id
+		LEFT JOIN warehouses w ON oi.warehouse_id = w.id
+		WHERE oi.seller_order_id = $1 AND oi.quantity > oi.cancelled_quantity
+		ORDER BY w.name, i.name`, sellerOrderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	slip.Lines = make([]models.PackingSlipLine, 0)
+	for rows.Next() {
+		line := models.PackingSlipLine{WeightUnit: units.Kilogram}
+		if err := rows.Scan(&line.ItemID, &line.Name, &line.Warehouse, &line.Quantity, &line.Weight); err != nil {
+			return nil, err
+		}
+		slip.Lines = append(slip.Lines, line)
+	}
+	return &slip, rows.Err()
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+var ErrEventTicketInvalid = errors.New("event ticket is invalid, expired or already used")
+
+// EventTicket lets a browser open one event stream as the user and roles it was issued for
+type EventTicket struct {
+	Ticket    string
+	UserID    uuid.UUID
+	Roles     []string
+	RoleTypes []string
+	ExpiresAt time.Time
+}
+
+// CreateEventTicket issues a single use ticket for the user's roles, valid for ttl. Only its hash is stored.
+func CreateEventTicket(db *sql.DB, userID uuid.UUID, roles, roleTypes []string, ttl time.Duration) (*EventTicket, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	ticket := EventTicket{Ticket: hex.EncodeToString(secret), UserID: userID, Roles: roles, RoleTypes: roleTypes}
+	err := db.QueryRow(`INSERT INTO event_tickets (ticket_hash, user_id, roles, role_types, expires_at)
+		VALUES ($1, $2, $3, $4, NOW() + $5 * INTERVAL '1 second') RETURNING expires_at`,
+		hashEventTicket(ticket.Ticket), userID, pq.Array(roles), pq.Array(roleTypes), ttl.Seconds()).Scan(&ticket.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return &ticket, nil
+}
+
+// RedeemEventTicket uses up a ticket, failing with ErrEventTicketInvalid if it doesn't exist, has expired or
+// was already used
+func RedeemEventTicket(db *sql.DB, ticket string) (*EventTicket, error) {
+	redeemed := EventTicket{Ticket: ticket}
+	err := db.QueryRow(`DELETE FROM event_tickets WHERE ticket_hash = $1 AND expires_at > NOW()
+		RETURNING user_id, roles, role_types, expires_at`, hashEventTicket(ticket)).Scan(
+		&redeemed.UserID, pq.Array(&redeemed.Roles), pq.Array(&redeemed.RoleTypes), &redeemed.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrEventTicketInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &redeemed, nil
+}
+
+// PruneEventTickets deletes tickets that expired without being used and returns how many there were
+func PruneEventTickets(db *sql.DB) (int64, error) {
+	result, err := db.Exec(`DELETE FROM event_tickets WHERE expires_at <= NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func hashEventTicket(ticket string) string {
+	sum := sha256.Sum256([]byte(ticket))
+	return hex.EncodeToString(sum[:])
+}
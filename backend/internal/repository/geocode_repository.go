@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"chainwave/backend/internal/models"
+	"database/sql"
+)
+
+// GeocodeCache stores geocoder results in the geocode_cache table
+type GeocodeCache struct {
+	DB *sql.DB
+}
+
+// Get fetches a cached result that hasn't expired. found is false on a miss; a nil result with
+// found set means the address was looked up before and couldn't be located.
+func (g GeocodeCache) Get(key string) (*models.GeocodeResult, bool, error) {
+	var located bool
+	var result models.GeocodeResult
+	err := g.DB.QueryRow(`SELECT located, latitude, longitude, country_code, display_name, min_latitude, max_latitude, min_longitude, max_longitude
+		FROM geocode_cache WHERE query = $1 AND created_at > NOW() - INTERVAL '30 days'`, key).Scan(
+		&located, &result.Latitude, &result.Longitude, &result.CountryCode, &result.DisplayName,
+		&result.BoundingBox[0], &result.BoundingBox[1], &result.BoundingBox[2], &result.BoundingBox[3])
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if !located {
+		return nil, true, nil
+	}
+	return &result, true, nil
+}
+
+// Put caches a result, or the fact an address couldn't be located if result is nil
+func (g GeocodeCache) Put(key string, result *models.GeocodeResult) error {
+	located := result != nil
+	if result == nil {
+		result = &models.GeocodeResult{}
+	}
+	_, err := g.DB.Exec(`INSERT INTO geocode_cache (query, located, latitude, longitude, country_code, display_name, min_latitude, max_latitude, min_longitude, max_longitude)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (query) DO UPDATE SET located = EXCLUDED.located, latitude = EXCLUDED.latitude, longitude = EXCLUDED.longitude,
+			country_code = EXCLUDED.country_code, display_name = EXCLUDED.display_name,
+			min_latitude = EXCLUDED.min_latitude, max_latitude = EXCLUDED.max_latitude,
+			min_longitude = EXCLUDED.min_longitude, max_longitude = EXCLUDED.max_longitude, created_at = NOW()`,
+		key, located, result.Latitude, result.Longitude, result.CountryCode, result.DisplayName,
+		result.BoundingBox[0], result.BoundingBox[1], result.BoundingBox[2], result.BoundingBox[3])
+	return err
+}
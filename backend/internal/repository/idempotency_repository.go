@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	ErrIdempotencyKeyReused     = errors.New("Idempotency-Key has already been used for a different request")
+	ErrIdempotencyKeyInProgress = errors.New("a request with this Idempotency-Key is still being processed")
+)
+
+// idempotencyAbandonAfter is how long a request may hold its key unfinished before a retry takes it over,
+// in case the server handling it went away
+const idempotencyAbandonAfter = time.Minute
+
+// IdempotentResponse is the stored response to a request made with an Idempotency-Key
+type IdempotentResponse struct {
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
+
+// ClaimIdempotencyKey claims a key for a request with the given hash until ttl has passed. It returns nil
+// when the request should go ahead and the stored response when it has already been answered. It fails
+// with ErrIdempotencyKeyReused if the key was used for a different request and ErrIdempotencyKeyInProgress
+// while the first request with it is still running. Expired keys are claimed afresh.
+func ClaimIdempotencyKey(db *sql.DB, scope, key, requestHash string, ttl time.Duration) (*IdempotentResponse, error) {
+	result, err := db.Exec(`INSERT INTO idempotency_keys (scope, key, request_hash, expires_at)
+		VALUES ($1, $2, $3, NOW() + $4 * INTERVAL '1 second')
+		ON CONFLICT (scope, key) DO UPDATE SET request_hash = EXCLUDED.request_hash, status_code = NULL, content_type = '',
+			response_body = NULL, created_at = NOW(), expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at < NOW()
+			OR (idempotency_keys.status_code IS NULL AND idempotency_keys.request_hash = EXCLUDED.request_hash
+				AND idempotency_keys.created_at < NOW() - $5 * INTERVAL '1 second')`,
+		scope, key, requestHash, ttl.Seconds(), idempotencyAbandonAfter.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if affected == 1 {
+		return nil, nil
+	}
+
+	var storedHash string
+	var statusCode sql.NullInt64
+	var response IdempotentResponse
+	err = db.QueryRow(`SELECT request_hash, status_code, content_type, response_body FROM idempotency_keys WHERE scope = $1 AND key = $2`,
+		scope, key).Scan(&storedHash, &statusCode, &response.ContentType, &response.Body)
+	if err == sql.ErrNoRows {
+		// Released by a failed first attempt in the meantime
+		return nil, ErrIdempotencyKeyInProgress
+	}
+	if err != nil {
+		return nil, err
+	}
+	if storedHash != requestHash {
+		return nil, ErrIdempotencyKeyReused
+	}
+	if !statusCode.Valid {
+		return nil, ErrIdempotencyKeyInProgress
+	}
+	response.StatusCode = int(statusCode.Int64)
+	return &response, nil
+}
+
+// SaveIdempotentResponse stores the response to the request holding a key, to be replayed on retries
+func SaveIdempotentResponse(db *sql.DB, scope, key string, response IdempotentResponse) error {
+	_, err := db.Exec(`UPDATE idempotency_keys SET status_code = $1, content_type = $2, response_body = $3 WHERE scope = $4 AND key = $5`,
+		response.StatusCode, response.ContentType, response.Body, scope, key)
+	return err
+}
+
+// ReleaseIdempotencyKey frees a key whose request failed without a response worth replaying, so it can be retried
+func ReleaseIdempotencyKey(db *sql.DB, scope, key string) error {
+	_, err := db.Exec(`DELETE FROM idempotency_keys WHERE scope = $1 AND key = $2 AND status_code IS NULL`, scope, key)
+	return err
+}
+
+// PruneIdempotencyKeys deletes expired keys and returns how many were deleted
+func PruneIdempotencyKeys(db *sql.DB) (int64, error) {
+	result, err := db.Exec(`DELETE FROM idempotency_keys WHERE expires_at < NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
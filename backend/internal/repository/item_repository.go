@@ -1,23 +1,66 @@
 package repository
 
 import (
+	"chainwave/backend/internal/geo"
 	"chainwave/backend/internal/models"
+	"chainwave/backend/internal/pricing"
+	"chainwave/backend/internal/units"
 	"database/sql"
+	"fmt"
 	"github.com/google/uuid"
 )
 
-// AddItem adds a new item to the database
+// itemDimensionColumns selects the stored dimensions of an item, zero when unknown
+const itemDimensionColumns = `COALESCE(i.length_cm, 0), COALESCE(i.width_cm, 0), COALESCE(i.height_cm, 0)`
+
+// itemRatingColumns selects an item's average rating and review count from the item_ratings view joined as r
+const itemRatingColumns = `COALESCE(r.rating, 0), COALESCE(r.review_count, 0)`
+
+// itemSortSQL orders item listings by a sort order from the query. Items without reviews come last when
+// sorting by rating, and ties fall back to the order nearby searches and plain listings already use.
+func itemSortSQL(sort string, fallback string) string {
+	switch sort {
+	case models.ItemSortRating:
+		return "ORDER BY COALESCE(r.rating, 0) DESC, COALESCE(r.review_count, 0) DESC, " + fallback
+	case models.ItemSortReviews:
+		return "ORDER BY COALESCE(r.review_count, 0) DESC, COALESCE(r.rating, 0) DESC, " + fallback
+	}
+	return "ORDER BY " + fallback
+}
+
+// storedUnits sets the units items are stored in, kilograms and centimetres, on scanned values
+func storedUnits(weightUnit *string, dimensions *models.Dimensions) {
+	*weightUnit = units.Kilogram
+	if dimensions.Length > 0 {
+		dimensions.Unit = units.Centimetre
+	}
+}
+
+// nullableLength stores unknown dimensions as NULL
+func nullableLength(value float64) interface{} {
+	if value <= 0 {
+		return nil
+	}
+	return value
+}
+
+// AddItem adds a new item to the database. Its weight and dimensions must be in kilograms and centimetres.
 func AddItem(db *sql.DB, item models.Item) (uuid.UUID, error) {
 	var id uuid.UUID
-	err := db.QueryRow(`INSERT INTO items (id, business_admin_id, name, description, price, weight, dimensions, category, quantity, image_url) VALUES (uuid_generate_v4(), $1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`,
-		item.BusinessAdminId, item.Name, item.Description, item.Price, item.Weight, item.Dimensions, item.Category, item.Quantity, item.ImageURL).Scan(&id)
+	err := db.QueryRow(`INSERT INTO items (id, business_admin_id, name, description, price_minor, currency, weight, length_cm, width_cm, height_cm, category, quantity, image_url) VALUES (uuid_generate_v4(), $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id`,
+		item.BusinessAdminId, item.Name, item.Description, item.Price.Amount, item.Price.Currency, item.Weight,
+		nullableLength(item.Dimensions.Length), nullableLength(item.Dimensions.Width), nullableLength(item.Dimensions.Height),
+		item.Category, item.Quantity, item.ImageURL).Scan(&id)
 	return id, err
 }
 
-// EditItem updates an existing item in the database
+// EditItem updates an existing item in the database. Its weight and dimensions must be in kilograms and centimetres,
+// and its price in the currency it was listed in.
 func EditItem(db *sql.DB, item models.Item) error {
-	_, err := db.Exec(`UPDATE items SET name = $1, description = $2, price = $3, weight = $4, dimensions = $5, category = $6, quantity = $7, image_url = $8 WHERE id = $9`,
-		item.Name, item.Description, item.Price, item.Weight, item.Dimensions, item.Category, item.Quantity, item.ImageURL, item.Id)
+	_, err := db.Exec(`UPDATE items SET name = $1, description = $2, price_minor = $3, weight = $4, length_cm = $5, width_cm = $6, height_cm = $7, category = $8, quantity = $9, image_url = $10 WHERE id = $11`,
+		item.Name, item.Description, item.Price.Amount, item.Weight,
+		nullableLength(item.Dimensions.Length), nullableLength(item.Dimensions.Width), nullableLength(item.Dimensions.Height),
+		item.Category, item.Quantity, item.ImageURL, item.Id)
 	return err
 }
 
@@ -26,20 +69,35 @@ func GetItemById(db *sql.DB, itemId uuid.UUID) (models.ItemWithDetail, error) {
 	var item models.ItemWithDetail
 	err := db.QueryRow(`
 		SELECT 
-			i.id, i.name, i.description, i.price, i.weight, i.dimensions, 
+			i.id, i.name, i.description, i.price_minor, i.currency, i.weight, `+itemDimensionColumns+`,
 			i.category, i.quantity, i.image_url,
 			b.company_name, b.contact_info,
-			l.address, l.city, l.state
+			l.address, l.city, l.state, `+itemRatingColumns+`
 		FROM items i
 		LEFT JOIN business_admins b ON i.business_admin_id = b.id
 		LEFT JOIN locations l ON b.location_id = l.id
+		LEFT JOIN item_ratings r ON r.item_id = i.id
 		WHERE i.id = $1`, itemId).Scan(
-		&item.Id, &item.Name, &item.Description, &item.Price, &item.Weight, 
-		&item.Dimensions, &item.Category, &item.Quantity, &item.ImageURL,
+		&item.Id, &item.Name, &item.Description, &item.Price.Amount, &item.Price.Currency, &item.Weight,
+		&item.Dimensions.Length, &item.Dimensions.Width, &item.Dimensions.Height, &item.Category, &item.Quantity, &item.ImageURL,
 		&item.BusinessAdminCompanyName, &item.BusinessAdminContactInfo,
-		&item.LocationAddress, &item.LocationCity, &item.LocationState,
+		&item.LocationAddress, &item.LocationCity, &item.LocationState, &item.Rating, &item.ReviewCount,
 	)
-	return item, err
+	storedUnits(&item.WeightUnit, &item.Dimensions)
+	if err != nil {
+		return item, err
+	}
+
+	sales, err := activeSales(db, []uuid.UUID{item.Id})
+	if err != nil {
+		return item, err
+	}
+	if price, sale := pricing.SalePrice(item.Price, sales[item.Id]); sale != nil {
+		endsAt := sale.EndsAt
+		item.SalePrice = &price
+		item.SaleEndsAt = &endsAt
+	}
+	return item, nil
 }
 
 // DeleteItem deletes an item from the database
@@ -55,15 +113,79 @@ func GetItemCount(db *sql.DB) (int, error) {
 	return count, err
 }
 
-// GetItemsByCategory fetches a list of items from the database
-func GetItemsByCategory(db *sql.DB, category string, offset int, limit int) ([]models.Item, error) {
-	var rows *sql.Rows
-	var err error
-	if category == "" {
-		rows, err = db.Query(`SELECT id, name, description, price, weight, dimensions, category, quantity, image_url FROM items OFFSET $1 LIMIT $2`, offset, limit)
-	} else {
-		rows, err = db.Query(`SELECT id, name, description, price, weight, dimensions, category, quantity, image_url FROM items WHERE category = $1 OFFSET $2 LIMIT $3`, category, offset, limit)
+// GetItemsByCategory fetches a list of items from the database, optionally by category, in a sort order
+// from models.ItemSort* or unsorted when sort is empty
+func GetItemsByCategory(db *sql.DB, category string, sort string, offset int, limit int) ([]models.Item, error) {
+	orderBy := ""
+	if sort != "" {
+		orderBy = itemSortSQL(sort, "i.name, i.id")
+	}
+	rows, err := db.Query(`SELECT i.id, i.name, i.description, i.price_minor, i.currency, i.weight, `+itemDimensionColumns+`, i.category, i.quantity, i.image_url, `+itemRatingColumns+`
+		FROM items i
+		LEFT JOIN item_ratings r ON r.item_id = i.id
+		WHERE ($1 = '' OR i.category = $1) `+orderBy+` OFFSET $2 LIMIT $3`, category, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.Item
+	for rows.Next() {
+		var item models.Item
+		if err := rows.Scan(&item.Id, &item.Name, &item.Description, &item.Price.Amount, &item.Price.Currency, &item.Weight, &item.Dimensions.Length, &item.Dimensions.Width, &item.Dimensions.Height, &item.Category, &item.Quantity, &item.ImageURL, &item.Rating, &item.ReviewCount); err != nil {
+			return nil, err
+		}
+		storedUnits(&item.WeightUnit, &item.Dimensions)
+		items = append(items, item)
 	}
+	if err := applySales(db, items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// GetItemCurrency fetches the currency an item is priced in
+func GetItemCurrency(db *sql.DB, itemId uuid.UUID) (string, error) {
+	var currency string
+	err := db.QueryRow(`SELECT currency FROM items WHERE id = $1`, itemId).Scan(&currency)
+	return currency, err
+}
+
+// GetItemBusinessAdminId fetches the ID of the business admin selling an item
+func GetItemBusinessAdminId(db *sql.DB, itemId uuid.UUID) (uuid.UUID, error) {
+	var businessAdminId uuid.UUID
+	err := db.QueryRow(`SELECT business_admin_id FROM items WHERE id = $1`, itemId).Scan(&businessAdminId)
+	return businessAdminId, err
+}
+
+// GetItemsNear fetches items sold by business admins inside a circle, optionally by category, in a sort order
+// from models.ItemSort* or nearest first when sort is empty. An item ships from whichever is nearest of its
+// seller's own location and their warehouses holding stock of it, and its distance is measured from there.
+func GetItemsNear(db *sql.DB, category string, near geo.Circle, sort string, offset int, limit int) ([]models.Item, error) {
+	args := []interface{}{category}
+	where, distance := nearSQL("o", near, &args)
+	args = append(args, offset, limit)
+
+	rows, err := db.Query(fmt.Sprintf(`SELECT i.id, i.name, i.description, i.price_minor, i.currency, i.weight, %s, i.category, i.quantity, i.image_url, %s, origin.distance
+		FROM items i
+		JOIN business_admins b ON i.business_admin_id = b.id
+		JOIN LATERAL (
+			SELECT %s AS distance
+			FROM (
+				SELECT l.latitude, l.longitude FROM locations l WHERE l.id = b.location_id
+				UNION ALL
+				SELECT l.latitude, l.longitude
+				FROM warehouses w
+				JOIN warehouse_stock ws ON ws.warehouse_id = w.id AND ws.item_id = i.id AND ws.quantity > 0
+				JOIN locations l ON w.location_id = l.id
+				WHERE w.business_admin_id = b.id
+			) o
+			WHERE %s
+			ORDER BY distance LIMIT 1
+		) origin ON TRUE
+		LEFT JOIN item_ratings r ON r.item_id = i.id
+		WHERE ($1 = '' OR i.category = $1)
+		%s OFFSET $%d LIMIT $%d`, itemDimensionColumns, itemRatingColumns, distance, where, itemSortSQL(sort, "origin.distance"), len(args)-1, len(args)), args...)
 	if err != nil {
 		return nil, err
 	}
@@ -72,10 +194,14 @@ func GetItemsByCategory(db *sql.DB, category string, offset int, limit int) ([]m
 	var items []models.Item
 	for rows.Next() {
 		var item models.Item
-		if err := rows.Scan(&item.Id, &item.Name, &item.Description, &item.Price, &item.Weight, &item.Dimensions, &item.Category, &item.Quantity, &item.ImageURL); err != nil {
+		if err := rows.Scan(&item.Id, &item.Name, &item.Description, &item.Price.Amount, &item.Price.Currency, &item.Weight, &item.Dimensions.Length, &item.Dimensions.Width, &item.Dimensions.Height, &item.Category, &item.Quantity, &item.ImageURL, &item.Rating, &item.ReviewCount, &item.Distance); err != nil {
 			return nil, err
 		}
+		storedUnits(&item.WeightUnit, &item.Dimensions)
 		items = append(items, item)
 	}
+	if err := applySales(db, items); err != nil {
+		return nil, err
+	}
 	return items, nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,231 @@
+package repository
+
+import (
+	"chainwave/backend/internal/models"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrLocationNotFound = errors.New("location not found in the user's address book")
+	ErrLocationInUse    = errors.New("location is still used by a role, warehouse, order or shipment")
+	ErrNotCustomer      = errors.New("user is not a customer")
+)
+
+// saveLocation returns the ID of the location a role or warehouse should point at. A location with an ID
+// is reused from the user's address book, anything else is added to it.
+func saveLocation(tx *sql.Tx, userID uuid.UUID, location models.Location) (uuid.UUID, error) {
+	if location.ID != uuid.Nil {
+		var owned bool
+		err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM locations WHERE id = $1 AND user_id = $2)`, location.ID, userID).Scan(&owned)
+		if err != nil {
+			return uuid.Nil, err
+		}
+		if !owned {
+			return uuid.Nil, ErrLocationNotFound
+		}
+		return location.ID, nil
+	}
+
+	var locationID uuid.UUID
+	err := tx.QueryRow(`INSERT INTO locations (id, address, city, state, country, postal_code, latitude, longitude, label, user_id) VALUES (uuid_generate_v4(), $1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`,
+		location.Address, location.City, location.State, location.Country, location.PostalCode, location.Latitude, location.Longitude, location.Label, userID).Scan(&locationID)
+	return locationID, err
+}
+
+// AddLocation adds a location to a user's address book
+func AddLocation(db *sql.DB, userID uuid.UUID, location models.Location) (uuid.UUID, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	location.ID = uuid.Nil
+	locationID, err := saveLocation(tx, userID, location)
+	if err != nil {
+		tx.Rollback()
+		return uuid.Nil, err
+	}
+
+	return locationID, tx.Commit()
+}
+
+// GetLocationsByUser fetches a user's address book, flagging the customer's default shipping address
+func GetLocationsByUser(db *sql.DB, userID uuid.UUID) ([]models.SavedLocation, error) {
+	rows, err := db.Query(`SELECT `+locationColumns+`,
+			COALESCE(id = (SELECT default_shipping_location_id FROM customers WHERE user_id = $1), FALSE)
+		FROM locations WHERE user_id = $1 ORDER BY label, address`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	locations := make([]models.SavedLocation, 0)
+	for rows.Next() {
+		var l models.SavedLocation
+		if err := rows.Scan(&l.ID, &l.Address, &l.City, &l.State, &l.Country, &l.PostalCode, &l.Latitude, &l.Longitude, &l.Label, &l.DefaultShipping); err != nil {
+			return nil, err
+		}
+		locations = append(locations, l)
+	}
+	return locations, rows.Err()
+}
+
+// GetUserLocation retrieves a location from a user's address book
+func GetUserLocation(db *sql.DB, userID, id uuid.UUID) (*models.Location, error) {
+	var location models.Location
+	err := db.QueryRow(`SELECT `+locationColumns+` FROM locations WHERE id = $1 AND user_id = $2`, id, userID).Scan(
+		&location.ID, &location.Address, &location.City, &location.State, &location.Country, &location.PostalCode, &location.Latitude, &location.Longitude, &location.Label)
+	if err == sql.ErrNoRows {
+		return nil, ErrLocationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &location, nil
+}
+
+// LocationOwnedBy reports whether a location is in a user's address book
+func LocationOwnedBy(db *sql.DB, userID, id uuid.UUID) (bool, error) {
+	var owned bool
+	err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM locations WHERE id = $1 AND user_id = $2)`, id, userID).Scan(&owned)
+	return owned, err
+}
+
+// EditLocation updates a location in a user's address book and returns its ID. Orders and shipments keep
+// the address they were placed with, so a location they point at is left as it was and the edit is saved as
+// a new location that takes its place in the address book and for the user's roles and warehouses.
+func EditLocation(db *sql.DB, userID uuid.UUID, location models.Location) (uuid.UUID, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	err = tx.QueryRow(`SELECT id FROM locations WHERE id = $1 AND user_id = $2 FOR UPDATE`, location.ID, userID).Scan(&location.ID)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return uuid.Nil, ErrLocationNotFound
+	}
+	if err != nil {
+		tx.Rollback()
+		return uuid.Nil, err
+	}
+
+	var placed bool
+	err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM orders WHERE location_id = $1)
+		OR EXISTS (SELECT 1 FROM shipments WHERE pickup_location_id = $1 OR drop_location_id = $1)`, location.ID).Scan(&placed)
+	if err != nil {
+		tx.Rollback()
+		return uuid.Nil, err
+	}
+
+	if !placed {
+		_, err = tx.Exec(`UPDATE locations SET address = $1, city = $2, state = $3, country = $4, postal_code = $5, latitude = $6, longitude = $7, label = $8
+			WHERE id = $9`,
+			location.Address, location.City, location.State, location.Country, location.PostalCode, location.Latitude, location.Longitude, location.Label, location.ID)
+		if err != nil {
+			tx.Rollback()
+			return uuid.Nil, err
+		}
+		return location.ID, tx.Commit()
+	}
+
+	oldID := location.ID
+	location.ID = uuid.Nil
+	newID, err := saveLocation(tx, userID, location)
+	if err != nil {
+		tx.Rollback()
+		return uuid.Nil, err
+	}
+
+	statements := []string{
+		`UPDATE customers SET location_id = $1 WHERE location_id = $2 AND user_id = $3`,
+		`UPDATE customers SET default_shipping_location_id = $1 WHERE default_shipping_location_id = $2 AND user_id = $3`,
+		`UPDATE business_admins SET location_id = $1 WHERE location_id = $2 AND user_id = $3`,
+		`UPDATE transporters SET location_id = $1 WHERE location_id = $2 AND user_id = $3`,
+		`UPDATE suppliers SET location_id = $1 WHERE location_id = $2 AND user_id = $3`,
+		`UPDATE warehouses w SET location_id = $1 FROM business_admins b
+			WHERE w.business_admin_id = b.id AND w.location_id = $2 AND b.user_id = $3`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement, newID, oldID, userID); err != nil {
+			tx.Rollback()
+			return uuid.Nil, err
+		}
+	}
+
+	// The old address stays for the orders and shipments using it but leaves the address book
+	if _, err = tx.Exec(`UPDATE locations SET user_id = NULL WHERE id = $1`, oldID); err != nil {
+		tx.Rollback()
+		return uuid.Nil, err
+	}
+
+	return newID, tx.Commit()
+}
+
+// DeleteLocation removes a location from a user's address book unless something still points at it
+func DeleteLocation(db *sql.DB, userID, id uuid.UUID) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(`SELECT id FROM locations WHERE id = $1 AND user_id = $2 FOR UPDATE`, id, userID).Scan(&id)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return ErrLocationNotFound
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	var inUse bool
+	err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM customers WHERE location_id = $1 OR default_shipping_location_id = $1)
+		OR EXISTS (SELECT 1 FROM business_admins WHERE location_id = $1)
+		OR EXISTS (SELECT 1 FROM transporters WHERE location_id = $1)
+		OR EXISTS (SELECT 1 FROM suppliers WHERE location_id = $1)
+		OR EXISTS (SELECT 1 FROM warehouses WHERE location_id = $1)
+		OR EXISTS (SELECT 1 FROM orders WHERE location_id = $1)
+		OR EXISTS (SELECT 1 FROM shipments WHERE pickup_location_id = $1 OR drop_location_id = $1)`, id).Scan(&inUse)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if inUse {
+		tx.Rollback()
+		return ErrLocationInUse
+	}
+
+	if _, err = tx.Exec(`DELETE FROM locations WHERE id = $1`, id); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetDefaultShippingLocation makes a location from the user's address book their customer's default shipping address
+func SetDefaultShippingLocation(db *sql.DB, userID, id uuid.UUID) error {
+	owned, err := LocationOwnedBy(db, userID, id)
+	if err != nil {
+		return err
+	}
+	if !owned {
+		return ErrLocationNotFound
+	}
+
+	result, err := db.Exec(`UPDATE customers SET default_shipping_location_id = $1 WHERE user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return ErrNotCustomer
+	}
+	return nil
+}
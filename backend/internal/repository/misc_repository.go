@@ -7,11 +7,15 @@ import (
 )
 
 
+// locationColumns selects a location, treating missing fields as empty
+const locationColumns = `id, COALESCE(address, ''), COALESCE(city, ''), COALESCE(state, ''), COALESCE(country, ''),
+	COALESCE(postal_code, ''), COALESCE(latitude, 0), COALESCE(longitude, 0), COALESCE(label, '')`
+
 // GetLocationByID retrieves a location by its ID
 func GetLocationByID(db *sql.DB, id uuid.UUID) (*models.Location, error) {
 	var location models.Location
-	query := `SELECT id, address, city, state, country, postal_code, latitude, longitude FROM locations WHERE id = $1`
-	err := db.QueryRow(query, id).Scan(&location.ID, &location.Address, &location.City, &location.State, &location.Country, &location.PostalCode, &location.Latitude, &location.Longitude)
+	query := `SELECT ` + locationColumns + ` FROM locations WHERE id = $1`
+	err := db.QueryRow(query, id).Scan(&location.ID, &location.Address, &location.City, &location.State, &location.Country, &location.PostalCode, &location.Latitude, &location.Longitude, &location.Label)
 	if err != nil {
 		return nil, err
 	}
@@ -20,11 +24,5 @@ func GetLocationByID(db *sql.DB, id uuid.UUID) (*models.Location, error) {
 
 // GetVehicleByID retrieves a vehicle by its ID
 func GetVehicleByID(db *sql.DB, id uuid.UUID) (*models.Vehicle, error) {
-	var vehicle models.Vehicle
-	query := `SELECT id, transporter_id, make, model, year, latitude, longitude, max_distance, max_capacity, current_capacity FROM vehicles WHERE id = $1`
-	err := db.QueryRow(query, id).Scan(&vehicle.ID, &vehicle.TransporterID, &vehicle.Make, &vehicle.Model, &vehicle.Year, &vehicle.Latitude, &vehicle.Longitude, &vehicle.MaxDistance, &vehicle.MaxCapacity, &vehicle.CurrentCapacity)
-	if err != nil {
-		return nil, err
-	}
-	return &vehicle, nil
+	return scanVehicle(db.QueryRow(`SELECT `+vehicleColumns+` `+vehicleFrom+` WHERE v.id = $1`, id))
 }
@@ -0,0 +1,511 @@
+package repository
+
+import (
+	"chainwave/backend/internal/geo"
+	"chainwave/backend/internal/models"
+	"chainwave/backend/internal/money"
+	"chainwave/backend/internal/orderstate"
+	"chainwave/backend/internal/pricing"
+	"chainwave/backend/internal/tax"
+	"database/sql"
+	"math"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// orderItemColumns selects an order line along with how much of it has been refunded by refunds that haven't failed
+const orderItemColumns = `oi.id, oi.order_id, oi.seller_order_id, oi.item_id, oi.warehouse_id, oi.quantity,
+	COALESCE(oi.list_price, oi.price), oi.price, oi.discount, oi.tax, oi.tax_exclusive,
+	(SELECT o.currency FROM orders o WHERE o.id = oi.order_id), oi.cancelled_quantity,
+	COALESCE((SELECT SUM(ri.quantity) FROM refund_items ri JOIN refunds r ON ri.refund_id = r.id
+		WHERE ri.order_item_id = oi.id AND r.status <> 'failed'), 0)`
+
+func scanOrderItem(row rowScanner) (models.OrderItem, error) {
+	var item models.OrderItem
+	var currency string
+	err := row.Scan(&item.ID, &item.OrderID, &item.SellerOrderID, &item.ItemID, &item.WarehouseID, &item.Quantity,
+		&item.ListPrice.Amount, &item.Price.Amount, &item.Discount.Amount, &item.Tax.Amount, &item.TaxExclusive.Amount, &currency,
+		&item.CancelledQuantity, &item.RefundedQuantity)
+	inCurrency(currency, &item.ListPrice, &item.Price, &item.Discount, &item.Tax, &item.TaxExclusive)
+	return item, err
+}
+
+// orderColumns selects an order along with the code of the coupon used on it
+const orderColumns = `id, customer_id, location_id, status, currency, total, shipping_cost, discount, tax,
+	COALESCE((SELECT code FROM coupons WHERE coupons.id = orders.coupon_id), ''), created_at, updated_at`
+
+func scanOrder(row rowScanner) (*models.Order, error) {
+	var order models.Order
+	err := row.Scan(&order.ID, &order.CustomerID, &order.LocationID, &order.Status, &order.Currency,
+		&order.Total.Amount, &order.ShippingCost.Amount, &order.Discount.Amount, &order.Tax.Amount,
+		&order.CouponCode, &order.CreatedAt, &order.UpdatedAt)
+	inCurrency(order.Currency, &order.Total, &order.ShippingCost, &order.Discount, &order.Tax)
+	return &order, err
+}
+
+// inCurrency sets the currency of amounts scanned in minor units
+func inCurrency(currency string, amounts ...*money.Money) {
+	for _, amount := range amounts {
+		amount.Currency = currency
+	}
+}
+
+// stockAllocation is a quantity of an item taken from one warehouse.
+// WarehouseID is nil when the item has no per-warehouse stock and is taken from items.quantity.
+type stockAllocation struct {
+	WarehouseID *uuid.UUID
+	Quantity    int
+}
+
+// warehouseCandidate is a warehouse holding stock of an item
+type warehouseCandidate struct {
+	WarehouseID uuid.UUID
+	Quantity    int
+	Distance    float64
+}
+
+// CreateOrder creates an order for a customer, fulfilling each line from the nearest warehouses with stock.
+// It ships to locationID, which must be in the customer's address book, or to their default shipping address when nil.
+// Items are charged at their sale prices, less the coupon with couponCode when it is set.
+func CreateOrder(db *sql.DB, customerID uuid.UUID, lines []models.OrderLineRequest, locationID *uuid.UUID, couponCode string) (*models.Order, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := createOrder(tx, customerID, lines, locationID, couponCode)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return order, tx.Commit()
+}
+
+// createOrder creates an order inside a transaction
+func createOrder(tx *sql.Tx, customerID uuid.UUID, lines []models.OrderLineRequest, locationID *uuid.UUID, couponCode string) (*models.Order, error) {
+	order := models.Order{CustomerID: customerID, Status: models.OrderStatusPending}
+
+	var err error
+	var latitude, longitude sql.NullFloat64
+	var buyer tax.Place
+	if locationID != nil {
+		err = tx.QueryRow(`SELECT l.id, l.latitude, l.longitude, COALESCE(l.country, ''), COALESCE(l.state, '') FROM customers c
+			JOIN locations l ON l.user_id = c.user_id WHERE c.id = $1 AND l.id = $2`,
+			customerID, *locationID).Scan(&order.LocationID, &latitude, &longitude, &buyer.Country, &buyer.State)
+		if err == sql.ErrNoRows {
+			err = ErrLocationNotFound
+		}
+	} else {
+		// Ship to the customer's default shipping address, falling back to their own location
+		err = tx.QueryRow(`SELECT l.id, l.latitude, l.longitude, COALESCE(l.country, ''), COALESCE(l.state, '') FROM customers c
+			JOIN locations l ON l.id = COALESCE(c.default_shipping_location_id, c.location_id) WHERE c.id = $1`,
+			customerID).Scan(&order.LocationID, &latitude, &longitude, &buyer.Country, &buyer.State)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Price the lines the same way listings and carts do, so the customer pays what they were shown
+	priceLines := make([]pricing.Line, len(lines))
+	itemIDs := make([]uuid.UUID, len(lines))
+	categories := make([]string, len(lines))
+	for i, line := range lines {
+		priceLines[i] = pricing.Line{ItemID: line.ItemID, Quantity: line.Quantity}
+		if err := tx.QueryRow(`SELECT price_minor, currency, business_admin_id, COALESCE(category, '') FROM items WHERE id = $1`, line.ItemID).Scan(
+			&priceLines[i].ListPrice.Amount, &priceLines[i].ListPrice.Currency, &priceLines[i].BusinessAdminID, &categories[i]); err != nil {
+			return nil, err
+		}
+		itemIDs[i] = line.ItemID
+	}
+	sales, err := activeSales(tx, itemIDs)
+	if err != nil {
+		return nil, err
+	}
+	for i := range priceLines {
+		priceLines[i].Sales = sales[priceLines[i].ItemID]
+	}
+	var coupon *pricing.Coupon
+	if couponCode != "" {
+		if coupon, err = loadCoupon(tx, couponCode, &customerID, true); err != nil {
+			return nil, err
+		}
+	}
+	priced, err := pricing.Price(priceLines, coupon)
+	if err != nil {
+		return nil, err
+	}
+	order.Currency = priced.Currency
+	zero := money.New(0, order.Currency)
+	order.Total, order.ShippingCost, order.Discount, order.Tax = zero, zero, zero, zero
+
+	err = tx.QueryRow(`INSERT INTO orders (id, customer_id, location_id, status, currency) VALUES (uuid_generate_v4(), $1, $2, $3, $4) RETURNING id, created_at, updated_at`,
+		customerID, order.LocationID, order.Status, order.Currency).Scan(&order.ID, &order.CreatedAt, &order.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	placedBy := orderstate.Actor{Role: orderstate.RoleCustomer, ID: customerID}
+	if err := recordOrderStatus(tx, order.ID, nil, "", order.Status, placedBy, "order placed"); err != nil {
+		return nil, err
+	}
+
+	// Each seller fulfils their own part of the order as a seller order and charges tax under their own
+	// rules as well as everyone's
+	sellerOrders := make(map[uuid.UUID]*models.SellerOrder)
+	sellerTaxRules := make(map[uuid.UUID][]tax.Rule)
+	sellers := make([]uuid.UUID, 0)
+	for i, line := range lines {
+		pricedLine := priced.Lines[i]
+		businessAdminID := pricedLine.BusinessAdminID
+
+		sellerOrder, ok := sellerOrders[businessAdminID]
+		if !ok {
+			sellerOrder = &models.SellerOrder{OrderID: order.ID, BusinessAdminID: businessAdminID, Status: order.Status,
+				Subtotal: zero, Tax: zero, TaxExclusive: zero, ShippingCost: zero, Refunded: zero}
+			err = tx.QueryRow(`INSERT INTO seller_orders (id, order_id, business_admin_id, status) VALUES (uuid_generate_v4(), $1, $2, $3) RETURNING id, created_at, updated_at`,
+				order.ID, businessAdminID, sellerOrder.Status).Scan(&sellerOrder.ID, &sellerOrder.CreatedAt, &sellerOrder.UpdatedAt)
+			if err != nil {
+				return nil, err
+			}
+			sellerOrders[businessAdminID] = sellerOrder
+			sellers = append(sellers, businessAdminID)
+
+			sellerTaxRules[businessAdminID], err = taxRules(tx, businessAdminID, buyer.Country)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		allocations, err := allocateStock(tx, line.ItemID, line.Quantity, latitude, longitude)
+		if err != nil {
+			return nil, err
+		}
+
+		// Split the line's share of the coupon over the warehouses it ships from, in whole minor units
+		discount := pricedLine.Discount.Amount
+		for j, allocation := range allocations {
+			share := discount
+			if j < len(allocations)-1 {
+				share = discount * int64(allocation.Quantity) / int64(line.Quantity)
+			}
+			discount -= share
+
+			// Tax the line by where it ships from and to
+			from, err := shipsFrom(tx, businessAdminID, allocation.WarehouseID)
+			if err != nil {
+				return nil, err
+			}
+			lineDiscount := money.New(share, order.Currency)
+			lineTotal, err := pricedLine.UnitPrice.Mul(allocation.Quantity).Sub(lineDiscount)
+			if err != nil {
+				return nil, err
+			}
+			lineTax := tax.Calculate(lineTotal, tax.Applicable(sellerTaxRules[businessAdminID], from, buyer, categories[i]))
+
+			orderItem := models.OrderItem{
+				OrderID:       order.ID,
+				SellerOrderID: &sellerOrder.ID,
+				ItemID:        line.ItemID,
+				WarehouseID:   allocation.WarehouseID,
+				Quantity:      allocation.Quantity,
+				ListPrice:     pricedLine.ListPrice,
+				Price:         pricedLine.UnitPrice,
+				Discount:      lineDiscount,
+				Tax:           lineTax.Tax,
+				TaxExclusive:  lineTax.Added,
+			}
+			err = tx.QueryRow(`INSERT INTO order_items (id, order_id, seller_order_id, item_id, warehouse_id, quantity, list_price, price, discount, tax, tax_exclusive) VALUES (uuid_generate_v4(), $1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`,
+				orderItem.OrderID, orderItem.SellerOrderID, orderItem.ItemID, orderItem.WarehouseID, orderItem.Quantity,
+				orderItem.ListPrice.Amount, orderItem.Price.Amount, orderItem.Discount.Amount, orderItem.Tax.Amount, orderItem.TaxExclusive.Amount).Scan(&orderItem.ID)
+			if err != nil {
+				return nil, err
+			}
+			orderItem.Taxes, err = addOrderItemTaxes(tx, orderItem.ID, lineTax)
+			if err != nil {
+				return nil, err
+			}
+			order.Items = append(order.Items, orderItem)
+			if err := addAmounts(
+				runningTotal{&order.Total, lineTax.Gross},
+				runningTotal{&order.Tax, lineTax.Tax},
+				runningTotal{&sellerOrder.Subtotal, lineTotal},
+				runningTotal{&sellerOrder.Tax, lineTax.Tax},
+				runningTotal{&sellerOrder.TaxExclusive, lineTax.Added},
+			); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Ship each seller's package with its cheapest tariff. Orders to an address without coordinates
+	// can't be quoted and are left for the seller to price.
+	order.Shipping = make([]models.ShippingQuote, 0)
+	if latitude.Valid && longitude.Valid {
+		packages, err := QuoteShipping(tx, geo.Point{Latitude: latitude.Float64, Longitude: longitude.Float64}, lines)
+		if err != nil {
+			return nil, err
+		}
+		for _, pkg := range packages {
+			quote := pkg.Quotes[0]
+			_, err = tx.Exec(`INSERT INTO order_shipping (order_id, business_admin_id, tariff_id, distance_km, chargeable_weight, cost, transit_days, estimated_delivery) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+				order.ID, quote.BusinessAdminID, quote.TariffID, quote.DistanceKm, quote.ChargeableWeight, quote.Cost.Amount, quote.TransitDays, quote.EstimatedDelivery)
+			if err != nil {
+				return nil, err
+			}
+			order.Shipping = append(order.Shipping, quote)
+			totals := []runningTotal{{&order.Total, quote.Cost}, {&order.ShippingCost, quote.Cost}}
+			if sellerOrder, ok := sellerOrders[quote.BusinessAdminID]; ok {
+				totals = append(totals, runningTotal{&sellerOrder.ShippingCost, quote.Cost})
+			}
+			if err := addAmounts(totals...); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var couponID *uuid.UUID
+	if coupon != nil && !priced.Discount.IsZero() {
+		couponID = &coupon.ID
+		order.Discount = priced.Discount
+		order.CouponCode = coupon.Code
+		if _, err := tx.Exec(`INSERT INTO coupon_redemptions (coupon_id, order_id, customer_id, discount) VALUES ($1, $2, $3, $4)`,
+			coupon.ID, order.ID, customerID, order.Discount.Amount); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := tx.Exec(`UPDATE orders SET total = $1, shipping_cost = $2, discount = $3, tax = $4, coupon_id = $5 WHERE id = $6`,
+		order.Total.Amount, order.ShippingCost.Amount, order.Discount.Amount, order.Tax.Amount, couponID, order.ID); err != nil {
+		return nil, err
+	}
+
+	order.SellerOrders = make([]models.SellerOrder, 0, len(sellers))
+	for _, businessAdminID := range sellers {
+		sellerOrder := sellerOrders[businessAdminID]
+		if sellerOrder.Payout, err = sellerPayout(sellerOrder); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(`UPDATE seller_orders SET subtotal = $1, tax = $2, tax_exclusive = $3, shipping_cost = $4 WHERE id = $5`,
+			sellerOrder.Subtotal.Amount, sellerOrder.Tax.Amount, sellerOrder.TaxExclusive.Amount, sellerOrder.ShippingCost.Amount, sellerOrder.ID); err != nil {
+			return nil, err
+		}
+		order.SellerOrders = append(order.SellerOrders, *sellerOrder)
+	}
+
+	return &order, nil
+}
+
+// runningTotal is an amount to add to a total being summed
+type runningTotal struct {
+	total  *money.Money
+	amount money.Money
+}
+
+// addAmounts adds each amount to its total, failing if any is in a different currency
+func addAmounts(totals ...runningTotal) error {
+	for _, t := range totals {
+		sum, err := t.total.Add(t.amount)
+		if err != nil {
+			return err
+		}
+		*t.total = sum
+	}
+	return nil
+}
+
+// allocateStock reserves quantity of an item, taking it from the warehouses nearest to the given point first
+func allocateStock(tx *sql.Tx, itemID uuid.UUID, quantity int, latitude, longitude sql.NullFloat64) ([]stockAllocation, error) {
+	rows, err := tx.Query(`SELECT ws.warehouse_id, ws.quantity, l.latitude, l.longitude
+		FROM warehouse_stock ws
+		JOIN warehouses w ON ws.warehouse_id = w.id
+		JOIN locations l ON w.location_id = l.id
+		WHERE ws.item_id = $1
+		FOR UPDATE OF ws`, itemID)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]warehouseCandidate, 0)
+	for rows.Next() {
+		var candidate warehouseCandidate
+		var warehouseLatitude, warehouseLongitude sql.NullFloat64
+		if err := rows.Scan(&candidate.WarehouseID, &candidate.Quantity, &warehouseLatitude, &warehouseLongitude); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		candidate.Distance = math.Inf(1)
+		if latitude.Valid && longitude.Valid && warehouseLatitude.Valid && warehouseLongitude.Valid {
+			candidate.Distance = geo.Haversine(latitude.Float64, longitude.Float64, warehouseLatitude.Float64, warehouseLongitude.Float64)
+		}
+		candidates = append(candidates, candidate)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Items that were never stocked into a warehouse keep using the single items.quantity
+	if len(candidates) == 0 {
+		result, err := tx.Exec(`UPDATE items SET quantity = quantity - $1 WHERE id = $2 AND quantity >= $1`, quantity, itemID)
+		if err != nil {
+			return nil, err
+		}
+		if affected, err := result.RowsAffected(); err != nil {
+			return nil, err
+		} else if affected == 0 {
+			return nil, ErrInsufficientStock
+		}
+		return []stockAllocation{{Quantity: quantity}}, nil
+	}
+
+	allocations := pickWarehouses(candidates, quantity)
+	if allocations == nil {
+		return nil, ErrInsufficientStock
+	}
+
+	for _, allocation := range allocations {
+		_, err := tx.Exec(`UPDATE warehouse_stock SET quantity = quantity - $1 WHERE warehouse_id = $2 AND item_id = $3`,
+			allocation.Quantity, *allocation.WarehouseID, itemID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return allocations, nil
+}
+
+// pickWarehouses prefers the nearest single warehouse that can fill the whole quantity and
+// otherwise splits it across warehouses nearest first. It returns nil if total stock is short.
+func pickWarehouses(candidates []warehouseCandidate, quantity int) []stockAllocation {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Distance < candidates[j].Distance
+	})
+
+	for _, candidate := range candidates {
+		if candidate.Quantity >= quantity {
+			warehouseID := candidate.WarehouseID
+			return []stockAllocation{{WarehouseID: &warehouseID, Quantity: quantity}}
+		}
+	}
+
+	allocations := make([]stockAllocation, 0)
+	remaining := quantity
+	for _, candidate := range candidates {
+		if remaining == 0 {
+			break
+		}
+		if candidate.Quantity == 0 {
+			continue
+		}
+		take := candidate.Quantity
+		if take > remaining {
+			take = remaining
+		}
+		warehouseID := candidate.WarehouseID
+		allocations = append(allocations, stockAllocation{WarehouseID: &warehouseID, Quantity: take})
+		remaining -= take
+	}
+	if remaining > 0 {
+		return nil
+	}
+	return allocations
+}
+
+// GetOrderByID fetches an order along with its lines
+func GetOrderByID(db *sql.DB, id uuid.UUID) (*models.Order, error) {
+	order, err := scanOrder(db.QueryRow(`SELECT `+orderColumns+` FROM orders WHERE id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	order.Items, err = getOrderItems(db, order.ID)
+	if err != nil {
+		return nil, err
+	}
+	order.Shipping, err = getOrderShipping(db, order.ID)
+	if err != nil {
+		return nil, err
+	}
+	order.SellerOrders, err = getSellerOrders(db, order.ID)
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// GetOrdersByCustomer fetches all orders placed by a customer, newest first
+func GetOrdersByCustomer(db *sql.DB, customerID uuid.UUID) ([]models.Order, error) {
+	rows, err := db.Query(`SELECT `+orderColumns+` FROM orders WHERE customer_id = $1 ORDER BY created_at DESC`, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := make([]models.Order, 0)
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, *order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range orders {
+		orders[i].Items, err = getOrderItems(db, orders[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		orders[i].Shipping, err = getOrderShipping(db, orders[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		orders[i].SellerOrders, err = getSellerOrders(db, orders[i].ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return orders, nil
+}
+
+// getOrderItems fetches the lines of an order with their taxes
+func getOrderItems(db *sql.DB, orderID uuid.UUID) ([]models.OrderItem, error) {
+	rows, err := db.Query(`SELECT `+orderItemColumns+` FROM order_items oi WHERE oi.order_id = $1`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]models.OrderItem, 0)
+	for rows.Next() {
+		item, err := scanOrderItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, attachOrderItemTaxes(db, items)
+}
+
+// GetOrderBusinessAdminIDs fetches the IDs of the business admins selling items in an order
+func GetOrderBusinessAdminIDs(db *sql.DB, orderID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := db.Query(`SELECT DISTINCT i.business_admin_id FROM order_items oi JOIN items i ON oi.item_id = i.id WHERE oi.order_id = $1`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]uuid.UUID, 0)
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"chainwave/backend/internal/models"
+	"chainwave/backend/internal/orderstate"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+var ErrSellerOrderAmbiguous = errors.New("order has several seller orders you can move, seller_order_id is required")
+
+// recordOrderStatus adds an entry to an order's status history
+func recordOrderStatus(tx *sql.Tx, orderID uuid.UUID, sellerOrderID *uuid.UUID, from, to string, actor orderstate.Actor, note string) error {
+	var actorID *uuid.UUID
+	if actor.ID != uuid.Nil {
+		actorID = &actor.ID
+	}
+	_, err := tx.Exec(`INSERT INTO order_status_history (order_id, seller_order_id, from_status, to_status, actor_role, actor_id, note)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`, orderID, sellerOrderID, from, to, actor.Role, actorID, note)
+	return err
+}
+
+// setOrderStatus moves an order along its path to a status, recording each step in its history
+func setOrderStatus(tx *sql.Tx, orderID uuid.UUID, from, to string, actor orderstate.Actor, note string) error {
+	for _, status := range orderstate.Path(from, to) {
+		if _, err := tx.Exec(`UPDATE orders SET status = $1, updated_at = NOW() WHERE id = $2`, status, orderID); err != nil {
+			return err
+		}
+		if err := recordOrderStatus(tx, orderID, nil, from, status, actor, note); err != nil {
+			return err
+		}
+		from = status
+	}
+	return nil
+}
+
+// setSellerOrderStatus moves a seller order along its path to a status, recording each step in the order's history
+func setSellerOrderStatus(tx *sql.Tx, sellerOrder models.SellerOrder, to string, actor orderstate.Actor, note string) error {
+	from := sellerOrder.Status
+	for _, status := range orderstate.Path(from, to) {
+		if _, err := tx.Exec(`UPDATE seller_orders SET status = $1, updated_at = NOW() WHERE id = $2`, status, sellerOrder.ID); err != nil {
+			return err
+		}
+		if err := recordOrderStatus(tx, sellerOrder.OrderID, &sellerOrder.ID, from, status, actor, note); err != nil {
+			return err
+		}
+		from = status
+	}
+	return nil
+}
+
+// lockSellerOrders locks the seller orders of an order in the given statuses, or all of them when none are given
+func lockSellerOrders(tx *sql.Tx, orderID uuid.UUID, statuses ...string) ([]models.SellerOrder, error) {
+	rows, err := tx.Query(`SELECT so.id, so.order_id, so.business_admin_id, so.status, so.subtotal, so.shipping_cost, o.currency, so.created_at, so.updated_at
+		FROM seller_orders so JOIN orders o ON so.order_id = o.id WHERE so.order_id = $1 ORDER BY so.created_at, so.id FOR UPDATE OF so`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sellerOrders := make([]models.SellerOrder, 0)
+	for rows.Next() {
+		var so models.SellerOrder
+		var currency string
+		if err := rows.Scan(&so.ID, &so.OrderID, &so.BusinessAdminID, &so.Status, &so.Subtotal.Amount, &so.ShippingCost.Amount, &currency, &so.CreatedAt, &so.UpdatedAt); err != nil {
+			return nil, err
+		}
+		inCurrency(currency, &so.Subtotal, &so.ShippingCost)
+		if len(statuses) > 0 && !containsStatus(statuses, so.Status) {
+			continue
+		}
+		sellerOrders = append(sellerOrders, so)
+	}
+	return sellerOrders, rows.Err()
+}
+
+// TransitionSellerOrder moves the actor's part of an order to a status and rolls the order up after it.
+// A business admin moves their own seller order and a transporter one they carry a shipment of; when the
+// actor could move several, sellerOrderID picks which. The change is rejected with a
+// *statemachine.TransitionError if the order machine doesn't allow it and a *orderstate.RoleError if
+// the actor's role may not make it.
+func TransitionSellerOrder(db *sql.DB, orderID uuid.UUID, sellerOrderID *uuid.UUID, to string, actor orderstate.Actor, note string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	var orderStatus string
+	if err := tx.QueryRow(`SELECT status FROM orders WHERE id = $1 FOR UPDATE`, orderID).Scan(&orderStatus); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	var actorMatches string
+	switch actor.Role {
+	case orderstate.RoleBusinessAdmin:
+		actorMatches = `so.business_admin_id = $3`
+	case orderstate.RoleTransporter:
+		actorMatches = `EXISTS (SELECT 1 FROM shipments s WHERE s.seller_order_id = so.id AND s.transporter_id = $3 AND s.status <> '` + models.ShipmentStatusCancelled + `')`
+	default:
+		tx.Rollback()
+		if err := orderstate.Check(orderStatus, to, actor.Role); err != nil {
+			return err
+		}
+		return ErrOrderForbidden
+	}
+
+	rows, err := tx.Query(`SELECT so.id, so.order_id, so.business_admin_id, so.status FROM seller_orders so
+		WHERE so.order_id = $1 AND ($2::uuid IS NULL OR so.id = $2) AND `+actorMatches+`
+		FOR UPDATE`, orderID, sellerOrderID, actor.ID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	candidates := make([]models.SellerOrder, 0)
+	for rows.Next() {
+		var so models.SellerOrder
+		if err := rows.Scan(&so.ID, &so.OrderID, &so.BusinessAdminID, &so.Status); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return err
+		}
+		candidates = append(candidates, so)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	switch len(candidates) {
+	case 0:
+		tx.Rollback()
+		// Let roles that could never make the change know why rather than that the order isn't theirs
+		if err := orderstate.Check(orderStatus, to, actor.Role); err != nil {
+			return err
+		}
+		return ErrOrderForbidden
+	case 1:
+	default:
+		tx.Rollback()
+		return ErrSellerOrderAmbiguous
+	}
+
+	sellerOrder := candidates[0]
+	if err := orderstate.Check(sellerOrder.Status, to, actor.Role); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := setSellerOrderStatus(tx, sellerOrder, to, actor, note); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := syncOrderStatus(tx, orderID, actor, note); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetOrderStatusHistory fetches the status changes of an order and its seller orders, oldest first
+func GetOrderStatusHistory(db *sql.DB, orderID uuid.UUID) ([]models.OrderStatusChange, error) {
+	rows, err := db.Query(`SELECT id, order_id, seller_order_id, from_status, to_status, actor_role, actor_id, note, created_at
+		FROM order_status_history WHERE order_id = $1 ORDER BY created_at, id`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := make([]models.OrderStatusChange, 0)
+	for rows.Next() {
+		var change models.OrderStatusChange
+		if err := rows.Scan(&change.ID, &change.OrderID, &change.SellerOrderID, &change.FromStatus, &change.ToStatus,
+			&change.ActorRole, &change.ActorID, &change.Note, &change.CreatedAt); err != nil {
+			return nil, err
+		}
+		history = append(history, change)
+	}
+	return history, rows.Err()
+}
+
+func containsStatus(statuses []string, status string) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,222 @@
+package repository
+
+import (
+	"chainwave/backend/internal/models"
+	"chainwave/backend/internal/orderstate"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrPaymentNotFound        = errors.New("payment not found")
+	ErrPaymentAlreadyCaptured = errors.New("order has already been paid")
+	ErrOrderNotPayable        = errors.New("only pending orders can be paid")
+)
+
+const paymentColumns = `id, order_id, provider, provider_order_id, provider_payment_id, amount, currency, status, failure_reason, created_at, updated_at, captured_at, duplicate_of`
+
+func scanPayment(row rowScanner) (*models.Payment, error) {
+	var p models.Payment
+	err := row.Scan(&p.ID, &p.OrderID, &p.Provider, &p.ProviderOrderID, &p.ProviderPaymentID, &p.Amount, &p.Currency,
+		&p.Status, &p.FailureReason, &p.CreatedAt, &p.UpdatedAt, &p.CapturedAt, &p.DuplicateOf)
+	if err == sql.ErrNoRows {
+		return nil, ErrPaymentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// AddPayment records an attempt to pay for an order with the order created at the provider
+func AddPayment(db *sql.DB, payment models.Payment) (*models.Payment, error) {
+	return scanPayment(db.QueryRow(`INSERT INTO payments (id, order_id, provider, provider_order_id, amount, currency, status) VALUES (uuid_generate_v4(), $1, $2, $3, $4, $5, $6) RETURNING `+paymentColumns,
+		payment.OrderID, payment.Provider, payment.ProviderOrderID, payment.Amount, payment.Currency, models.PaymentStatusCreated))
+}
+
+// GetPaymentByProviderOrderID retrieves a payment by the ID of its order at the provider
+func GetPaymentByProviderOrderID(db *sql.DB, providerOrderID string) (*models.Payment, error) {
+	return scanPayment(db.QueryRow(`SELECT `+paymentColumns+` FROM payments WHERE provider_order_id = $1 AND duplicate_of IS NULL`, providerOrderID))
+}
+
+// GetPaymentsByOrder fetches every attempt to pay for an order, newest first
+func GetPaymentsByOrder(db *sql.DB, orderID uuid.UUID) ([]models.Payment, error) {
+	rows, err := db.Query(`SELECT `+paymentColumns+` FROM payments WHERE order_id = $1 ORDER BY created_at DESC`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payments := make([]models.Payment, 0)
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, *payment)
+	}
+	return payments, rows.Err()
+}
+
+// CheckOrderPayable returns an error unless an order is pending and not yet paid
+func CheckOrderPayable(db *sql.DB, orderID uuid.UUID) error {
+	var status string
+	var paid bool
+	err := db.QueryRow(`SELECT status, EXISTS (SELECT 1 FROM payments WHERE order_id = $1 AND status = $2) FROM orders WHERE id = $1`,
+		orderID, models.PaymentStatusCaptured).Scan(&status, &paid)
+	if err != nil {
+		return err
+	}
+	if paid {
+		return ErrPaymentAlreadyCaptured
+	}
+	if status != models.OrderStatusPending {
+		return ErrOrderNotPayable
+	}
+	return nil
+}
+
+// CapturePayment marks a verified payment as captured and moves its order from Pending to Processing.
+// Capturing the same provider payment again returns the payment unchanged with captured false, so
+// retried verifications and webhooks never capture twice.
+func CapturePayment(db *sql.DB, providerOrderID, providerPaymentID string) (payment *models.Payment, captured bool, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, false, err
+	}
+
+	payment, err = scanPayment(tx.QueryRow(`SELECT `+paymentColumns+` FROM payments WHERE provider_order_id = $1 AND duplicate_of IS NULL FOR UPDATE`, providerOrderID))
+	if err != nil {
+		tx.Rollback()
+		return nil, false, err
+	}
+	if payment.Status == models.PaymentStatusCaptured {
+		tx.Rollback()
+		if payment.ProviderPaymentID != nil && *payment.ProviderPaymentID == providerPaymentID {
+			return payment, false, nil
+		}
+		return nil, false, ErrPaymentAlreadyCaptured
+	}
+
+	// Lock the order so two attempts for it can't both be captured
+	var orderStatus string
+	if err := tx.QueryRow(`SELECT status FROM orders WHERE id = $1 FOR UPDATE`, payment.OrderID).Scan(&orderStatus); err != nil {
+		tx.Rollback()
+		return nil, false, err
+	}
+	var paid bool
+	err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM payments WHERE order_id = $1 AND status = $2)`, payment.OrderID, models.PaymentStatusCaptured).Scan(&paid)
+	if err != nil {
+		tx.Rollback()
+		return nil, false, err
+	}
+	if paid {
+		tx.Rollback()
+		return nil, false, ErrPaymentAlreadyCaptured
+	}
+
+	payment, err = scanPayment(tx.QueryRow(`UPDATE payments SET status = $1, provider_payment_id = $2, failure_reason = '', captured_at = NOW(), updated_at = NOW()
+		WHERE id = $3 RETURNING `+paymentColumns, models.PaymentStatusCaptured, providerPaymentID, payment.ID))
+	if err != nil {
+		tx.Rollback()
+		return nil, false, err
+	}
+
+	if orderStatus == models.OrderStatusPending {
+		sellerOrders, err := lockSellerOrders(tx, payment.OrderID, models.OrderStatusPending)
+		if err != nil {
+			tx.Rollback()
+			return nil, false, err
+		}
+		for _, sellerOrder := range sellerOrders {
+			if err := setSellerOrderStatus(tx, sellerOrder, models.OrderStatusProcessing, orderstate.System, "payment captured"); err != nil {
+				tx.Rollback()
+				return nil, false, err
+			}
+		}
+		if err := setOrderStatus(tx, payment.OrderID, orderStatus, models.OrderStatusProcessing, orderstate.System, "payment captured"); err != nil {
+			tx.Rollback()
+			return nil, false, err
+		}
+	}
+
+	return payment, true, tx.Commit()
+}
+
+// FailPayment records why a payment attempt failed. Captured and duplicate payments are left alone.
+func FailPayment(db *sql.DB, providerOrderID, providerPaymentID, reason string) error {
+	_, err := db.Exec(`UPDATE payments SET status = $1, provider_payment_id = COALESCE(NULLIF($2, ''), provider_payment_id), failure_reason = $3, updated_at = NOW()
+		WHERE provider_order_id = $4 AND duplicate_of IS NULL AND status NOT IN ($5, $6)`,
+		models.PaymentStatusFailed, providerPaymentID, reason, providerOrderID, models.PaymentStatusCaptured, models.PaymentStatusDuplicate)
+	return err
+}
+
+// RefundDuplicatePayment records a payment captured for an order that was already paid and creates a
+// pending refund of all of it, to be sent to the payment provider once it is committed. The duplicate is
+// stored on the attempt it was made against, or alongside it when that attempt is the one that paid. It
+// returns nil if the duplicate was already recorded, as when a webhook is delivered again.
+func RefundDuplicatePayment(db *sql.DB, providerOrderID, providerPaymentID string) (*models.Refund, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	attempt, err := scanPayment(tx.QueryRow(`SELECT `+paymentColumns+` FROM payments WHERE provider_order_id = $1 AND duplicate_of IS NULL FOR UPDATE`, providerOrderID))
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	var recorded bool
+	err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM payments WHERE provider_payment_id = $1 AND status = $2)`,
+		providerPaymentID, models.PaymentStatusDuplicate).Scan(&recorded)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if recorded || (attempt.ProviderPaymentID != nil && *attempt.ProviderPaymentID == providerPaymentID && attempt.Status == models.PaymentStatusCaptured) {
+		tx.Rollback()
+		return nil, nil
+	}
+
+	var paidBy uuid.UUID
+	err = tx.QueryRow(`SELECT id FROM payments WHERE order_id = $1 AND status = $2`, attempt.OrderID, models.PaymentStatusCaptured).Scan(&paidBy)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return nil, ErrOrderNotPaid
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	var duplicate *models.Payment
+	if attempt.Status == models.PaymentStatusCaptured {
+		duplicate, err = scanPayment(tx.QueryRow(`INSERT INTO payments (id, order_id, provider, provider_order_id, provider_payment_id, amount, currency, status, captured_at, duplicate_of)
+			VALUES (uuid_generate_v4(), $1, $2, $3, $4, $5, $6, $7, NOW(), $8) RETURNING `+paymentColumns,
+			attempt.OrderID, attempt.Provider, attempt.ProviderOrderID, providerPaymentID, attempt.Amount, attempt.Currency, models.PaymentStatusDuplicate, paidBy))
+	} else {
+		duplicate, err = scanPayment(tx.QueryRow(`UPDATE payments SET status = $1, provider_payment_id = $2, failure_reason = '', captured_at = NOW(), updated_at = NOW(), duplicate_of = $3
+			WHERE id = $4 RETURNING `+paymentColumns, models.PaymentStatusDuplicate, providerPaymentID, paidBy, attempt.ID))
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	refund, err := insertRefund(tx, models.Refund{
+		OrderID:     duplicate.OrderID,
+		PaymentID:   duplicate.ID,
+		Amount:      duplicate.Amount,
+		Currency:    duplicate.Currency,
+		Reason:      "order was already paid",
+		InitiatedBy: RefundBySystem,
+		Items:       make([]models.RefundItem, 0),
+	}, "duplicate payment")
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return refund, tx.Commit()
+}
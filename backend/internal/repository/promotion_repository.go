@@ -0,0 +1,327 @@
+package repository
+
+import (
+	"chainwave/backend/internal/models"
+	"chainwave/backend/internal/money"
+	"chainwave/backend/internal/pricing"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrSaleTarget         = errors.New("a sale must name either an item or a category, not both")
+	ErrPromotionWindow    = errors.New("a promotion must end after it starts")
+	ErrCouponCodeRequired = errors.New("coupon code is required")
+	ErrCouponCodeTaken    = errors.New("coupon code is already in use")
+	ErrCouponNotFound     = errors.New("coupon not found")
+	ErrCouponNotActive    = errors.New("coupon is not valid at this time")
+	ErrCouponUsedUp       = errors.New("coupon has been used the maximum number of times")
+	ErrCouponLimitReached = errors.New("you have already used this coupon the maximum number of times")
+)
+
+// IsCouponError reports whether an error is one of the reasons a coupon can't be used
+func IsCouponError(err error) bool {
+	switch {
+	case err == ErrCouponNotFound, err == ErrCouponNotActive, err == ErrCouponUsedUp, err == ErrCouponLimitReached,
+		err == pricing.ErrCouponNotApplicable, errors.Is(err, pricing.ErrCouponBelowThreshold):
+		return true
+	}
+	return false
+}
+
+const saleColumns = `id, business_admin_id, item_id, COALESCE(category, ''), kind, value, starts_at, ends_at, created_at`
+
+func scanSale(row rowScanner) (models.Sale, error) {
+	var sale models.Sale
+	err := row.Scan(&sale.ID, &sale.BusinessAdminID, &sale.ItemID, &sale.Category, &sale.Kind, &sale.Value, &sale.StartsAt, &sale.EndsAt, &sale.CreatedAt)
+	return sale, err
+}
+
+// couponColumns selects a coupon along with how many orders that weren't cancelled have used it
+const couponColumns = `c.id, c.business_admin_id, c.code, c.kind, c.value, c.min_subtotal, c.starts_at, c.ends_at, c.max_uses,
+	c.max_uses_per_customer, c.stacks_with_sales, c.active, c.created_at,
+	(SELECT COUNT(*) FROM coupon_redemptions cr JOIN orders o ON cr.order_id = o.id
+		WHERE cr.coupon_id = c.id AND o.status <> 'Cancelled')`
+
+func scanCoupon(row rowScanner) (models.Coupon, error) {
+	var coupon models.Coupon
+	err := row.Scan(&coupon.ID, &coupon.BusinessAdminID, &coupon.Code, &coupon.Kind, &coupon.Value, &coupon.MinSubtotal,
+		&coupon.StartsAt, &coupon.EndsAt, &coupon.MaxUses, &coupon.MaxUsesPerCustomer, &coupon.StacksWithSales, &coupon.Active,
+		&coupon.CreatedAt, &coupon.Uses)
+	return coupon, err
+}
+
+// CreateSale starts a sale on one of a business admin's items, or on all their items in a category
+func CreateSale(db *sql.DB, sale models.Sale) (models.Sale, error) {
+	if err := pricing.ValidateDiscount(sale.Kind, sale.Value); err != nil {
+		return sale, err
+	}
+	if (sale.ItemID == nil) == (sale.Category == "") {
+		return sale, ErrSaleTarget
+	}
+	if !sale.EndsAt.After(sale.StartsAt) {
+		return sale, ErrPromotionWindow
+	}
+	if sale.ItemID != nil {
+		var exists bool
+		err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM items WHERE id = $1 AND business_admin_id = $2)`,
+			*sale.ItemID, sale.BusinessAdminID).Scan(&exists)
+		if err != nil {
+			return sale, err
+		}
+		if !exists {
+			return sale, ErrItemNotFound
+		}
+	}
+
+	var category interface{}
+	if sale.Category != "" {
+		category = sale.Category
+	}
+	return scanSale(db.QueryRow(`INSERT INTO sales (id, business_admin_id, item_id, category, kind, value, starts_at, ends_at)
+		VALUES (uuid_generate_v4(), $1, $2, $3, $4, $5, $6, $7) RETURNING `+saleColumns,
+		sale.BusinessAdminID, sale.ItemID, category, sale.Kind, sale.Value, sale.StartsAt, sale.EndsAt))
+}
+
+// GetSalesByBusinessAdmin fetches a business admin's sales that haven't ended yet, soonest first
+func GetSalesByBusinessAdmin(db *sql.DB, businessAdminID uuid.UUID) ([]models.Sale, error) {
+	rows, err := db.Query(`SELECT `+saleColumns+` FROM sales WHERE business_admin_id = $1 AND ends_at > NOW() ORDER BY starts_at, id`, businessAdminID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sales := make([]models.Sale, 0)
+	for rows.Next() {
+		sale, err := scanSale(rows)
+		if err != nil {
+			return nil, err
+		}
+		sales = append(sales, sale)
+	}
+	return sales, rows.Err()
+}
+
+// DeleteSale ends a business admin's sale by deleting it. Orders already placed keep the price they got.
+func DeleteSale(db *sql.DB, id, businessAdminID uuid.UUID) error {
+	result, err := db.Exec(`DELETE FROM sales WHERE id = $1 AND business_admin_id = $2`, id, businessAdminID)
+	if err != nil {
+		return err
+	}
+	return expectUpdated(result)
+}
+
+// CreateCoupon creates a coupon for a business admin's items. Codes are unique regardless of case.
+func CreateCoupon(db *sql.DB, coupon models.Coupon) (models.Coupon, error) {
+	coupon.Code = strings.TrimSpace(coupon.Code)
+	if coupon.Code == "" {
+		return coupon, ErrCouponCodeRequired
+	}
+	if err := pricing.ValidateDiscount(coupon.Kind, coupon.Value); err != nil {
+		return coupon, err
+	}
+	if coupon.StartsAt != nil && coupon.EndsAt != nil && !coupon.EndsAt.After(*coupon.StartsAt) {
+		return coupon, ErrPromotionWindow
+	}
+
+	var taken bool
+	if err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM coupons WHERE UPPER(code) = UPPER($1))`, coupon.Code).Scan(&taken); err != nil {
+		return coupon, err
+	}
+	if taken {
+		return coupon, ErrCouponCodeTaken
+	}
+
+	var id uuid.UUID
+	err := db.QueryRow(`INSERT INTO coupons (id, business_admin_id, code, kind, value, min_subtotal, starts_at, ends_at, max_uses, max_uses_per_customer, stacks_with_sales)
+		VALUES (uuid_generate_v4(), $1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`,
+		coupon.BusinessAdminID, coupon.Code, coupon.Kind, coupon.Value, coupon.MinSubtotal, coupon.StartsAt, coupon.EndsAt,
+		coupon.MaxUses, coupon.MaxUsesPerCustomer, coupon.StacksWithSales).Scan(&id)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return coupon, ErrCouponCodeTaken
+		}
+		return coupon, err
+	}
+	return scanCoupon(db.QueryRow(`SELECT `+couponColumns+` FROM coupons c WHERE c.id = $1`, id))
+}
+
+// GetCouponsByBusinessAdmin fetches a business admin's coupons, newest first
+func GetCouponsByBusinessAdmin(db *sql.DB, businessAdminID uuid.UUID) ([]models.Coupon, error) {
+	rows, err := db.Query(`SELECT `+couponColumns+` FROM coupons c WHERE c.business_admin_id = $1 ORDER BY c.created_at DESC`, businessAdminID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	coupons := make([]models.Coupon, 0)
+	for rows.Next() {
+		coupon, err := scanCoupon(rows)
+		if err != nil {
+			return nil, err
+		}
+		coupons = append(coupons, coupon)
+	}
+	return coupons, rows.Err()
+}
+
+// DeactivateCoupon stops a business admin's coupon from being used. It isn't deleted, as orders refer to it.
+func DeactivateCoupon(db *sql.DB, id, businessAdminID uuid.UUID) error {
+	result, err := db.Exec(`UPDATE coupons SET active = FALSE WHERE id = $1 AND business_admin_id = $2`, id, businessAdminID)
+	if err != nil {
+		return err
+	}
+	return expectUpdated(result)
+}
+
+// SetCartCoupon applies a coupon code to a cart once it has checked the code can be used by the cart's
+// customer. Whether it applies to the cart's items is worked out each time the cart is priced.
+func SetCartCoupon(db *sql.DB, cartID uuid.UUID, code string) error {
+	var customerID *uuid.UUID
+	err := db.QueryRow(`SELECT customer_id FROM carts WHERE id = $1`, cartID).Scan(&customerID)
+	if err == sql.ErrNoRows {
+		return ErrCartNotFound
+	}
+	if err != nil {
+		return err
+	}
+	coupon, err := loadCoupon(db, code, customerID, false)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`UPDATE carts SET coupon_code = $1, updated_at = NOW() WHERE id = $2`, coupon.Code, cartID)
+	return err
+}
+
+// ClearCartCoupon removes the coupon from a cart
+func ClearCartCoupon(db *sql.DB, cartID uuid.UUID) error {
+	_, err := db.Exec(`UPDATE carts SET coupon_code = NULL, updated_at = NOW() WHERE id = $1`, cartID)
+	return err
+}
+
+// loadCoupon fetches the coupon with a code, checking it is active, running and, when customerID is set,
+// within its usage limits for that customer. lock holds the coupon until the transaction ends so concurrent
+// orders can't both take its last use.
+func loadCoupon(q queryer, code string, customerID *uuid.UUID, lock bool) (*pricing.Coupon, error) {
+	query := `SELECT id, code, business_admin_id, kind, value, min_subtotal, stacks_with_sales, active, starts_at, ends_at,
+		max_uses, max_uses_per_customer FROM coupons WHERE UPPER(code) = UPPER($1)`
+	if lock {
+		query += ` FOR UPDATE`
+	}
+
+	var coupon pricing.Coupon
+	var active bool
+	var startsAt, endsAt *time.Time
+	var maxUses, maxUsesPerCustomer sql.NullInt64
+	err := q.QueryRow(query, strings.TrimSpace(code)).Scan(&coupon.ID, &coupon.Code, &coupon.BusinessAdminID, &coupon.Kind, &coupon.Value,
+		&coupon.MinSubtotal, &coupon.StacksWithSales, &active, &startsAt, &endsAt, &maxUses, &maxUsesPerCustomer)
+	if err == sql.ErrNoRows {
+		return nil, ErrCouponNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !active {
+		return nil, ErrCouponNotFound
+	}
+	now := time.Now()
+	if (startsAt != nil && now.Before(*startsAt)) || (endsAt != nil && !now.Before(*endsAt)) {
+		return nil, ErrCouponNotActive
+	}
+
+	if maxUses.Valid {
+		uses, err := countRedemptions(q, coupon.ID, nil)
+		if err != nil {
+			return nil, err
+		}
+		if uses >= maxUses.Int64 {
+			return nil, ErrCouponUsedUp
+		}
+	}
+	if maxUsesPerCustomer.Valid && customerID != nil {
+		uses, err := countRedemptions(q, coupon.ID, customerID)
+		if err != nil {
+			return nil, err
+		}
+		if uses >= maxUsesPerCustomer.Int64 {
+			return nil, ErrCouponLimitReached
+		}
+	}
+	return &coupon, nil
+}
+
+// countRedemptions counts the orders that weren't cancelled using a coupon, of one customer when customerID is set
+func countRedemptions(q queryer, couponID uuid.UUID, customerID *uuid.UUID) (int64, error) {
+	var uses int64
+	err := q.QueryRow(`SELECT COUNT(*) FROM coupon_redemptions cr JOIN orders o ON cr.order_id = o.id
+		WHERE cr.coupon_id = $1 AND ($2::uuid IS NULL OR cr.customer_id = $2) AND o.status <> 'Cancelled'`,
+		couponID, customerID).Scan(&uses)
+	return uses, err
+}
+
+// activeSales fetches the sales running now on each of a set of items, whether set on the item or its category
+func activeSales(q queryer, itemIDs []uuid.UUID) (map[uuid.UUID][]pricing.Sale, error) {
+	sales := make(map[uuid.UUID][]pricing.Sale)
+	if len(itemIDs) == 0 {
+		return sales, nil
+	}
+	ids := make([]string, len(itemIDs))
+	for i, id := range itemIDs {
+		ids[i] = id.String()
+	}
+
+	rows, err := q.Query(`SELECT i.id, s.id, s.kind, s.value, s.ends_at
+		FROM items i
+		JOIN sales s ON s.business_admin_id = i.business_admin_id
+			AND (s.item_id = i.id OR (s.item_id IS NULL AND s.category = i.category))
+		WHERE i.id = ANY($1::uuid[]) AND s.starts_at <= NOW() AND s.ends_at > NOW()`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var itemID uuid.UUID
+		var sale pricing.Sale
+		if err := rows.Scan(&itemID, &sale.ID, &sale.Kind, &sale.Value, &sale.EndsAt); err != nil {
+			return nil, err
+		}
+		sales[itemID] = append(sales[itemID], sale)
+	}
+	return sales, rows.Err()
+}
+
+// currentPrice returns what an item with a list price sells for now, under the best sale running on it
+func currentPrice(q queryer, itemID uuid.UUID, listPrice money.Money) (money.Money, error) {
+	sales, err := activeSales(q, []uuid.UUID{itemID})
+	if err != nil {
+		return money.Money{}, err
+	}
+	price, _ := pricing.SalePrice(listPrice, sales[itemID])
+	return price, nil
+}
+
+// applySales sets the sale price of listed items that have a sale running
+func applySales(q queryer, items []models.Item) error {
+	ids := make([]uuid.UUID, len(items))
+	for i, item := range items {
+		ids[i] = item.Id
+	}
+	sales, err := activeSales(q, ids)
+	if err != nil {
+		return err
+	}
+	for i := range items {
+		if price, sale := pricing.SalePrice(items[i].Price, sales[items[i].Id]); sale != nil {
+			endsAt := sale.EndsAt
+			items[i].SalePrice = &price
+			items[i].SaleEndsAt = &endsAt
+		}
+	}
+	return nil
+}
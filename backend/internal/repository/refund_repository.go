@@ -0,0 +1,743 @@
+package repository
+
+import (
+	"chainwave/backend/internal/models"
+	"chainwave/backend/internal/orderstate"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrOrderForbidden     = errors.New("order does not belong to this role")
+	ErrOrderShipped       = errors.New("order has already been picked up for delivery")
+	ErrOrderNotPaid       = errors.New("order has no captured payment to refund")
+	ErrOrderItemNotFound  = errors.New("order line not found")
+	ErrRefundExceedsLine  = errors.New("refund quantity exceeds what is left to refund on the order line")
+	ErrRefundExceedsPaid  = errors.New("refund exceeds what is left of the payment")
+	ErrRefundNotFound     = errors.New("refund not found")
+	ErrRefundNotRetryable = errors.New("only failed refunds can be retried")
+)
+
+// Who initiated a refund, besides the customer and business_admin roles
+const RefundBySystem = orderstate.RoleSystem
+
+// CancelledOrder is the result of cancelling an order: the refund of its payment, if it was paid,
+// and the shipments that were called off
+type CancelledOrder struct {
+	OrderID   uuid.UUID
+	Refund    *models.Refund
+	Shipments []models.Shipment
+}
+
+const refundColumns = `id, order_id, payment_id, provider_refund_id, amount, currency, status, reason, initiated_by, created_at, updated_at`
+
+func scanRefund(row rowScanner) (*models.Refund, error) {
+	var r models.Refund
+	err := row.Scan(&r.ID, &r.OrderID, &r.PaymentID, &r.ProviderRefundID, &r.Amount, &r.Currency, &r.Status, &r.Reason,
+		&r.InitiatedBy, &r.CreatedAt, &r.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrRefundNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// lockOrder locks an order for the rest of the transaction
+func lockOrder(tx *sql.Tx, id uuid.UUID) (*models.Order, error) {
+	order, err := scanOrder(tx.QueryRow(`SELECT `+orderColumns+` FROM orders WHERE id = $1 FOR UPDATE`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(`SELECT `+orderItemColumns+` FROM order_items oi WHERE oi.order_id = $1 ORDER BY oi.id FOR UPDATE OF oi`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	order.Items = make([]models.OrderItem, 0)
+	for rows.Next() {
+		item, err := scanOrderItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		order.Items = append(order.Items, item)
+	}
+	return order, rows.Err()
+}
+
+// CancelOrder cancels an order that hasn't been shipped. Every line still reserved is returned to the
+// warehouse it was taken from, shipments not yet picked up are cancelled, freeing their vehicle capacity,
+// and unpaid payment attempts are closed. If the order was paid, a pending refund of whatever hasn't been
+// refunded yet is created, to be sent to the payment provider once the cancellation is committed.
+// before is called with the locked order so callers can check who may cancel it.
+func CancelOrder(db *sql.DB, id uuid.UUID, reason string, actor orderstate.Actor, before func(tx *sql.Tx, order *models.Order) error) (*CancelledOrder, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := lockOrder(tx, id)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := orderstate.Check(order.Status, models.OrderStatusCancelled, actor.Role); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if before != nil {
+		if err := before(tx, order); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	shipments, err := cancelShipments(tx, order.ID, nil)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	restocked := make(map[uuid.UUID]int)
+	for _, item := range order.Items {
+		remaining := item.Quantity - item.CancelledQuantity
+		if err := restockOrderItem(tx, item, remaining); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		restocked[item.ID] = remaining
+	}
+
+	// Close attempts the customer never completed so the order can't be paid after it is cancelled
+	_, err = tx.Exec(`UPDATE payments SET status = $1, failure_reason = 'order cancelled', updated_at = NOW() WHERE order_id = $2 AND status = $3`,
+		models.PaymentStatusFailed, order.ID, models.PaymentStatusCreated)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	sellerOrders, err := lockSellerOrders(tx, order.ID, models.OrderStatusPending, models.OrderStatusProcessing)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	for _, sellerOrder := range sellerOrders {
+		if err := setSellerOrderStatus(tx, sellerOrder, models.OrderStatusCancelled, actor, reason); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	if err := setOrderStatus(tx, order.ID, order.Status, models.OrderStatusCancelled, actor, reason); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	cancelled := &CancelledOrder{OrderID: order.ID, Shipments: shipments}
+
+	cancelled.Refund, err = refundBalance(tx, order, reason, actor.Role, restocked)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return cancelled, tx.Commit()
+}
+
+// RefundCancelledOrder creates a pending refund of everything left of a cancelled order's payment. It is
+// used when a customer completes a payment after the order was cancelled, and returns nil if nothing is left.
+func RefundCancelledOrder(db *sql.DB, orderID uuid.UUID, reason string) (*models.Refund, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := lockOrder(tx, orderID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if order.Status != models.OrderStatusCancelled {
+		tx.Rollback()
+		return nil, nil
+	}
+
+	refund, err := refundBalance(tx, order, reason, RefundBySystem, nil)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return refund, tx.Commit()
+}
+
+// refundBalance creates a pending refund of whatever is left of an order's captured payment, shipping
+// included, itemised by what is left to refund on each line. restocked is how much of each line the
+// caller just returned to stock. It returns nil if the order wasn't paid or is already fully refunded.
+func refundBalance(tx *sql.Tx, order *models.Order, reason, initiatedBy string, restocked map[uuid.UUID]int) (*models.Refund, error) {
+	payment, balance, err := lockCapturedPayment(tx, order.ID)
+	if err == ErrOrderNotPaid {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if balance <= 0 {
+		return nil, nil
+	}
+
+	refund := models.Refund{
+		OrderID:     order.ID,
+		PaymentID:   payment.ID,
+		Amount:      balance,
+		Currency:    payment.Currency,
+		Reason:      reason,
+		InitiatedBy: initiatedBy,
+		Items:       make([]models.RefundItem, 0),
+	}
+	for _, item := range order.Items {
+		quantity := item.Quantity - item.RefundedQuantity
+		if quantity <= 0 {
+			continue
+		}
+		refund.Items = append(refund.Items, models.RefundItem{
+			OrderItemID: item.ID,
+			ItemID:      item.ItemID,
+			Quantity:    quantity,
+			Amount:      lineAmount(item, quantity),
+			Restocked:   minInt(quantity, restocked[item.ID]),
+		})
+	}
+	return insertRefund(tx, refund, "order cancelled")
+}
+
+// CreateRefund creates a pending refund of quantities of an order's lines, to be sent to the payment
+// provider once it is committed. Lines the seller hasn't shipped yet go back into stock, and a seller
+// order left with nothing to ship is cancelled, as is the order once all of them are. before is called
+// with the locked order and the requested lines so callers can check who may refund them.
+func CreateRefund(db *sql.DB, orderID uuid.UUID, lines []models.RefundLineRequest, reason string, actor orderstate.Actor,
+	before func(tx *sql.Tx, order *models.Order, items []models.OrderItem) error) (*models.Refund, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := lockOrder(tx, orderID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Merge repeated lines so their quantities are checked together
+	quantities := make(map[uuid.UUID]int)
+	requested := make([]models.OrderItem, 0)
+	for _, line := range lines {
+		if _, seen := quantities[line.OrderItemID]; !seen {
+			item, ok := findOrderItem(order.Items, line.OrderItemID)
+			if !ok {
+				tx.Rollback()
+				return nil, ErrOrderItemNotFound
+			}
+			requested = append(requested, item)
+		}
+		quantities[line.OrderItemID] += line.Quantity
+	}
+
+	if before != nil {
+		if err := before(tx, order, requested); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	payment, balance, err := lockCapturedPayment(tx, order.ID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	refund := models.Refund{
+		OrderID:     order.ID,
+		PaymentID:   payment.ID,
+		Currency:    payment.Currency,
+		Reason:      reason,
+		InitiatedBy: actor.Role,
+		Items:       make([]models.RefundItem, 0, len(requested)),
+	}
+	for _, item := range requested {
+		quantity := quantities[item.ID]
+		if quantity > item.Quantity-item.RefundedQuantity {
+			tx.Rollback()
+			return nil, ErrRefundExceedsLine
+		}
+
+		restock := 0
+		if order.Status == models.OrderStatusPending || order.Status == models.OrderStatusProcessing {
+			shipped, err := sellerShipped(tx, order.ID, item.ItemID)
+			if err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+			if !shipped {
+				restock = minInt(quantity, item.Quantity-item.CancelledQuantity)
+			}
+		}
+		if err := restockOrderItem(tx, item, restock); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		refundItem := models.RefundItem{
+			OrderItemID: item.ID,
+			ItemID:      item.ItemID,
+			Quantity:    quantity,
+			Amount:      lineAmount(item, quantity),
+			Restocked:   restock,
+		}
+		refund.Items = append(refund.Items, refundItem)
+		refund.Amount += refundItem.Amount
+	}
+
+	// Rounding each line can leave the items a unit over what is left of the payment
+	if refund.Amount > balance {
+		if refund.Amount-balance > int64(len(refund.Items)) {
+			tx.Rollback()
+			return nil, ErrRefundExceedsPaid
+		}
+		refund.Amount = balance
+	}
+	if refund.Amount <= 0 {
+		tx.Rollback()
+		return nil, ErrRefundExceedsPaid
+	}
+
+	// Sellers left with nothing to ship have their part of the order cancelled and their shipping refunded,
+	// and the order is cancelled along with the last of them
+	emptied, err := cancelEmptySellerOrders(tx, order.ID, actor, reason)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	for _, sellerOrder := range emptied {
+		refund.Amount += sellerOrder.ShippingCost.Amount
+	}
+	if refund.Amount > balance {
+		refund.Amount = balance
+	}
+	if err := syncOrderStatus(tx, order.ID, actor, reason); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	created, err := insertRefund(tx, refund, "refund requested")
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return created, tx.Commit()
+}
+
+// RetryRefund moves a failed refund back to pending so it can be sent to the payment provider again,
+// provided its lines and amount are still left to refund
+func RetryRefund(db *sql.DB, orderID, refundID uuid.UUID) (*models.Refund, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := lockOrder(tx, orderID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	refund, err := scanRefund(tx.QueryRow(`SELECT `+refundColumns+` FROM refunds WHERE id = $1 AND order_id = $2 FOR UPDATE`, refundID, orderID))
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if refund.Status != models.RefundStatusFailed {
+		tx.Rollback()
+		return nil, ErrRefundNotRetryable
+	}
+
+	// Failed refunds don't count as refunded, so another refund may have covered the same ground since
+	_, balance, err := lockCapturedPayment(tx, orderID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if refund.Amount > balance {
+		tx.Rollback()
+		return nil, ErrRefundExceedsPaid
+	}
+	items, err := getRefundItems(tx, refund.ID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	for _, refundItem := range items {
+		item, ok := findOrderItem(order.Items, refundItem.OrderItemID)
+		if !ok || refundItem.Quantity > item.Quantity-item.RefundedQuantity {
+			tx.Rollback()
+			return nil, ErrRefundExceedsLine
+		}
+	}
+
+	if err := setRefundStatus(tx, refund.ID, models.RefundStatusPending, nil, "retried"); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	refund.Status = models.RefundStatusPending
+	return refund, tx.Commit()
+}
+
+// ClaimStalledRefunds returns the refunds left pending for longer than age without an answer from the
+// payment provider, as when the server stopped between committing a refund and submitting it or the
+// provider timed out. Claimed refunds have their updated_at bumped, so other replicas running the same job
+// pass over them for another age.
+func ClaimStalledRefunds(db *sql.DB, age time.Duration) ([]models.Refund, error) {
+	rows, err := db.Query(`UPDATE refunds SET updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM refunds
+			WHERE status = $1 AND provider_refund_id IS NULL AND updated_at < NOW() - $2 * INTERVAL '1 second'
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+refundColumns, models.RefundStatusPending, age.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	refunds := make([]models.Refund, 0)
+	for rows.Next() {
+		refund, err := scanRefund(rows)
+		if err != nil {
+			return nil, err
+		}
+		refunds = append(refunds, *refund)
+	}
+	return refunds, rows.Err()
+}
+
+// RecordRefundResult records the provider's answer to a pending refund. A nil providerRefundID
+// leaves the stored one unchanged.
+func RecordRefundResult(db *sql.DB, refundID uuid.UUID, status string, providerRefundID *string, note string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := setRefundStatus(tx, refundID, status, providerRefundID, note); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateRefundByProviderID records a refund status reported by the provider's webhooks. Refunds already in
+// that status are left alone, as are processed ones since the money has gone back and a late or
+// out-of-order failure can't undo that. It returns the refund's ID and whether it changed.
+func UpdateRefundByProviderID(db *sql.DB, providerRefundID, status, note string) (uuid.UUID, bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return uuid.Nil, false, err
+	}
+
+	var id uuid.UUID
+	var current string
+	err = tx.QueryRow(`SELECT id, status FROM refunds WHERE provider_refund_id = $1 FOR UPDATE`, providerRefundID).Scan(&id, &current)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return uuid.Nil, false, ErrRefundNotFound
+	}
+	if err != nil {
+		tx.Rollback()
+		return uuid.Nil, false, err
+	}
+	if current == status || current == models.RefundStatusProcessed {
+		tx.Rollback()
+		return id, false, nil
+	}
+
+	if err := setRefundStatus(tx, id, status, nil, note); err != nil {
+		tx.Rollback()
+		return uuid.Nil, false, err
+	}
+	return id, true, tx.Commit()
+}
+
+// GetRefundByID fetches a refund with its items and status history
+func GetRefundByID(db *sql.DB, id uuid.UUID) (*models.Refund, error) {
+	refund, err := scanRefund(db.QueryRow(`SELECT `+refundColumns+` FROM refunds WHERE id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+	if err := loadRefundDetails(db, refund); err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+// GetRefundsByOrder fetches every refund of an order with its items and status history, newest first
+func GetRefundsByOrder(db *sql.DB, orderID uuid.UUID) ([]models.Refund, error) {
+	rows, err := db.Query(`SELECT `+refundColumns+` FROM refunds WHERE order_id = $1 ORDER BY created_at DESC`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	refunds := make([]models.Refund, 0)
+	for rows.Next() {
+		refund, err := scanRefund(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		refunds = append(refunds, *refund)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range refunds {
+		if err := loadRefundDetails(db, &refunds[i]); err != nil {
+			return nil, err
+		}
+	}
+	return refunds, nil
+}
+
+// GetPaymentByID fetches a payment by its ID
+func GetPaymentByID(db *sql.DB, id uuid.UUID) (*models.Payment, error) {
+	return scanPayment(db.QueryRow(`SELECT `+paymentColumns+` FROM payments WHERE id = $1`, id))
+}
+
+// OrderSoldOnlyBy reports whether every line of an order is sold by the business admin
+func OrderSoldOnlyBy(tx *sql.Tx, orderID, businessAdminID uuid.UUID) (bool, error) {
+	var others bool
+	err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM order_items oi JOIN items i ON oi.item_id = i.id WHERE oi.order_id = $1 AND i.business_admin_id <> $2)`,
+		orderID, businessAdminID).Scan(&others)
+	return !others, err
+}
+
+// ItemsSoldBy reports whether every one of the order lines is sold by the business admin
+func ItemsSoldBy(tx *sql.Tx, items []models.OrderItem, businessAdminID uuid.UUID) (bool, error) {
+	for _, item := range items {
+		var sellerID uuid.UUID
+		if err := tx.QueryRow(`SELECT business_admin_id FROM items WHERE id = $1`, item.ItemID).Scan(&sellerID); err != nil {
+			return false, err
+		}
+		if sellerID != businessAdminID {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// lockCapturedPayment locks an order's captured payment and returns it with how much of it,
+// in minor units, hasn't been refunded by refunds that haven't failed
+func lockCapturedPayment(tx *sql.Tx, orderID uuid.UUID) (*models.Payment, int64, error) {
+	payment, err := scanPayment(tx.QueryRow(`SELECT `+paymentColumns+` FROM payments WHERE order_id = $1 AND status = $2 FOR UPDATE`,
+		orderID, models.PaymentStatusCaptured))
+	if err == ErrPaymentNotFound {
+		return nil, 0, ErrOrderNotPaid
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var refunded int64
+	err = tx.QueryRow(`SELECT COALESCE(SUM(amount), 0) FROM refunds WHERE payment_id = $1 AND status <> $2`,
+		payment.ID, models.RefundStatusFailed).Scan(&refunded)
+	if err != nil {
+		return nil, 0, err
+	}
+	return payment, payment.Amount - refunded, nil
+}
+
+// cancelShipments cancels an order's shipments that haven't been picked up, only the given business admin's
+// when businessAdminID is set, failing with ErrOrderShipped if any already have
+func cancelShipments(tx *sql.Tx, orderID uuid.UUID, businessAdminID *uuid.UUID) ([]models.Shipment, error) {
+	rows, err := tx.Query(`SELECT `+shipmentColumns+` FROM shipments
+		WHERE order_id = $1 AND status <> $2 AND ($3::uuid IS NULL OR business_admin_id = $3)
+		FOR UPDATE`, orderID, models.ShipmentStatusCancelled, businessAdminID)
+	if err != nil {
+		return nil, err
+	}
+	shipments := make([]*models.Shipment, 0)
+	for rows.Next() {
+		shipment, err := scanShipment(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		shipments = append(shipments, shipment)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, shipment := range shipments {
+		if !ShipmentStateMachine.Can(shipment.Status, models.ShipmentStatusCancelled) {
+			return nil, ErrOrderShipped
+		}
+	}
+
+	cancelled := make([]models.Shipment, 0, len(shipments))
+	for _, shipment := range shipments {
+		shipment, err := moveShipment(tx, shipment, models.ShipmentStatusCancelled)
+		if err != nil {
+			return nil, err
+		}
+		cancelled = append(cancelled, *shipment)
+	}
+	return cancelled, nil
+}
+
+// sellerShipped reports whether the seller of an item has had a shipment of the order picked up
+func sellerShipped(tx *sql.Tx, orderID, itemID uuid.UUID) (bool, error) {
+	var shipped bool
+	err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM shipments s JOIN items i ON i.business_admin_id = s.business_admin_id
+		WHERE s.order_id = $1 AND i.id = $2 AND s.status IN ($3, $4))`,
+		orderID, itemID, models.ShipmentStatusPickedUp, models.ShipmentStatusDelivered).Scan(&shipped)
+	return shipped, err
+}
+
+// restockOrderItem returns quantity of an order line to where it was taken from and records it as cancelled
+func restockOrderItem(tx *sql.Tx, item models.OrderItem, quantity int) error {
+	if quantity <= 0 {
+		return nil
+	}
+
+	var err error
+	if item.WarehouseID != nil {
+		_, err = tx.Exec(`INSERT INTO warehouse_stock (warehouse_id, item_id, quantity) VALUES ($1, $2, $3)
+			ON CONFLICT (warehouse_id, item_id) DO UPDATE SET quantity = warehouse_stock.quantity + EXCLUDED.quantity`,
+			*item.WarehouseID, item.ItemID, quantity)
+	} else {
+		_, err = tx.Exec(`UPDATE items SET quantity = quantity + $1 WHERE id = $2`, quantity, item.ItemID)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE order_items SET cancelled_quantity = cancelled_quantity + $1 WHERE id = $2`, quantity, item.ID)
+	return err
+}
+
+// insertRefund saves a pending refund with its items and the first entry of its history
+func insertRefund(tx *sql.Tx, refund models.Refund, note string) (*models.Refund, error) {
+	created, err := scanRefund(tx.QueryRow(`INSERT INTO refunds (id, order_id, payment_id, amount, currency, status, reason, initiated_by)
+		VALUES (uuid_generate_v4(), $1, $2, $3, $4, $5, $6, $7) RETURNING `+refundColumns,
+		refund.OrderID, refund.PaymentID, refund.Amount, refund.Currency, models.RefundStatusPending, refund.Reason, refund.InitiatedBy))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range refund.Items {
+		_, err := tx.Exec(`INSERT INTO refund_items (refund_id, order_item_id, quantity, amount, restocked) VALUES ($1, $2, $3, $4, $5)`,
+			created.ID, item.OrderItemID, item.Quantity, item.Amount, item.Restocked)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	change := models.RefundStatusChange{Status: models.RefundStatusPending, Note: note}
+	err = tx.QueryRow(`INSERT INTO refund_status_history (refund_id, status, note) VALUES ($1, $2, $3) RETURNING created_at`,
+		created.ID, change.Status, change.Note).Scan(&change.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	created.Items = refund.Items
+	created.History = []models.RefundStatusChange{change}
+	return created, nil
+}
+
+// setRefundStatus updates a refund's status and appends it to the refund's history
+func setRefundStatus(tx *sql.Tx, refundID uuid.UUID, status string, providerRefundID *string, note string) error {
+	result, err := tx.Exec(`UPDATE refunds SET status = $1, provider_refund_id = COALESCE($2, provider_refund_id), updated_at = NOW() WHERE id = $3`,
+		status, providerRefundID, refundID)
+	if err != nil {
+		return err
+	}
+	if err := expectUpdated(result); err != nil {
+		return ErrRefundNotFound
+	}
+
+	_, err = tx.Exec(`INSERT INTO refund_status_history (refund_id, status, note) VALUES ($1, $2, $3)`, refundID, status, note)
+	return err
+}
+
+// loadRefundDetails fills in a refund's items and status history
+func loadRefundDetails(db *sql.DB, refund *models.Refund) error {
+	var err error
+	refund.Items, err = getRefundItems(db, refund.ID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := db.Query(`SELECT status, note, created_at FROM refund_status_history WHERE refund_id = $1 ORDER BY created_at, id`, refund.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	refund.History = make([]models.RefundStatusChange, 0)
+	for rows.Next() {
+		var change models.RefundStatusChange
+		if err := rows.Scan(&change.Status, &change.Note, &change.CreatedAt); err != nil {
+			return err
+		}
+		refund.History = append(refund.History, change)
+	}
+	return rows.Err()
+}
+
+// getRefundItems fetches the order lines a refund covers
+func getRefundItems(q queryer, refundID uuid.UUID) ([]models.RefundItem, error) {
+	rows, err := q.Query(`SELECT ri.order_item_id, oi.item_id, ri.quantity, ri.amount, ri.restocked
+		FROM refund_items ri JOIN order_items oi ON ri.order_item_id = oi.id
+		WHERE ri.refund_id = $1`, refundID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]models.RefundItem, 0)
+	for rows.Next() {
+		var item models.RefundItem
+		if err := rows.Scan(&item.OrderItemID, &item.ItemID, &item.Quantity, &item.Amount, &item.Restocked); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func findOrderItem(items []models.OrderItem, id uuid.UUID) (models.OrderItem, bool) {
+	for _, item := range items {
+		if item.ID == id {
+			return item, true
+		}
+	}
+	return models.OrderItem{}, false
+}
+
+// lineAmount is what the customer paid for the next quantity of an order line to be refunded, in minor
+// units, net of the line's coupon discount and with the tax charged on top of its price. Amounts are taken
+// off the running total paid for the line so that refunding it piece by piece adds up to exactly what was paid.
+func lineAmount(item models.OrderItem, quantity int) int64 {
+	paid := func(n int) int64 {
+		return item.Price.Mul(n).Amount + (item.TaxExclusive.Amount-item.Discount.Amount)*int64(n)/int64(item.Quantity)
+	}
+	return paid(item.RefundedQuantity+quantity) - paid(item.RefundedQuantity)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"chainwave/backend/internal/models"
+	"chainwave/backend/internal/money"
+	"fmt"
+	"testing"
+)
+
+func inr(paise int64) money.Money {
+	return money.New(paise, "INR")
+}
+
+func TestLineAmountAddsUpToWhatWasPaid(t *testing.T) {
+	items := []struct {
+		name string
+		item models.OrderItem
+		paid int64
+	}{
+		{"plain", models.OrderItem{Quantity: 4, Price: inr(250)}, 1000},
+		{"coupon", models.OrderItem{Quantity: 3, Price: inr(333), Discount: inr(100)}, 899},
+		{"coupon and exclusive tax", models.OrderItem{Quantity: 3, Price: inr(333), Discount: inr(100), TaxExclusive: inr(50)}, 949},
+		{"uneven tax", models.OrderItem{Quantity: 7, Price: inr(199), TaxExclusive: inr(251)}, 1644},
+	}
+	splits := [][]int{{1}, {2, 1}, {1, 1, 1}, {3}}
+
+	for _, tt := range items {
+		if got := lineAmount(tt.item, tt.item.Quantity); got != tt.paid {
+			t.Errorf("%s: refunding the whole line = %d, want %d", tt.name, got, tt.paid)
+		}
+		for _, split := range splits {
+			t.Run(fmt.Sprintf("%s %v", tt.name, split), func(t *testing.T) {
+				item := tt.item
+				var refunded int64
+				for _, quantity := range split {
+					refunded += lineAmount(item, quantity)
+					item.RefundedQuantity += quantity
+				}
+				rest := item.Quantity - item.RefundedQuantity
+				if rest > 0 {
+					refunded += lineAmount(item, rest)
+				}
+				if refunded != tt.paid {
+					t.Errorf("refunding in parts %v came to %d, want %d", split, refunded, tt.paid)
+				}
+			})
+		}
+	}
+}
+
+func TestLineAmountPieces(t *testing.T) {
+	// 999 of price less 100 of coupon plus 50 of tax is 949, spread as 317, 316 and 316
+	item := models.OrderItem{Quantity: 3, Price: inr(333), Discount: inr(100), TaxExclusive: inr(50)}
+	want := []int64{317, 316, 316}
+	for i, amount := range want {
+		item.RefundedQuantity = i
+		if got := lineAmount(item, 1); got != amount {
+			t.Errorf("refunding unit %d = %d, want %d", i+1, got, amount)
+		}
+	}
+}
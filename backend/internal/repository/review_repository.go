@@ -0,0 +1,318 @@
+package repository
+
+import (
+	"chainwave/backend/internal/models"
+	"database/sql"
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrReviewNotFound       = errors.New("review not found")
+	ErrReviewNotVerified    = errors.New("items can only be reviewed once an order for them has been delivered")
+	ErrAlreadyReviewed      = errors.New("you have already reviewed this item")
+	ErrInvalidRating        = errors.New("rating must be a whole number from 1 to 5")
+	ErrReviewTooLong        = errors.New("review titles can be at most 200 characters and other review text at most 5000")
+	ErrReviewAlreadyFlagged = errors.New("you have already flagged this review")
+	ErrFlagOwnReview        = errors.New("you can't flag your own review")
+	ErrFlagReasonRequired   = errors.New("a reason is required to flag a review")
+	ErrInvalidReviewStatus  = errors.New("review status must be published or hidden")
+)
+
+// ReviewFlagThreshold is how many verified buyers have to flag a review before it is hidden and stops
+// counting towards the item's rating. Only flags raised since a moderator last decided on it count.
+const ReviewFlagThreshold = 3
+
+const (
+	maxReviewTitleLength = 200
+	maxReviewBodyLength  = 5000
+)
+
+// IsReviewError reports whether an error is one of the reasons a review is invalid
+func IsReviewError(err error) bool {
+	switch err {
+	case ErrInvalidRating, ErrReviewTooLong, ErrFlagReasonRequired, ErrInvalidReviewStatus:
+		return true
+	}
+	return false
+}
+
+func validateReview(rating int, title, body string) error {
+	if rating < 1 || rating > 5 {
+		return ErrInvalidRating
+	}
+	if utf8.RuneCountInString(title) > maxReviewTitleLength || utf8.RuneCountInString(body) > maxReviewBodyLength {
+		return ErrReviewTooLong
+	}
+	return nil
+}
+
+// reviewColumns selects a review with its author's name and how many users have flagged it
+const reviewColumns = `rv.id, rv.item_id, rv.customer_id, COALESCE(c.customer_name, ''), rv.order_item_id, rv.rating, rv.title, rv.body,
+	rv.status, (SELECT COUNT(*) FROM review_flags f WHERE f.review_id = rv.id), COALESCE(rv.seller_reply, ''), rv.seller_replied_at,
+	rv.moderated_at, rv.created_at, rv.updated_at`
+
+const reviewTables = `reviews rv LEFT JOIN customers c ON rv.customer_id = c.id`
+
+func scanReview(row rowScanner) (*models.Review, error) {
+	var r models.Review
+	err := row.Scan(&r.ID, &r.ItemID, &r.CustomerID, &r.CustomerName, &r.OrderItemID, &r.Rating, &r.Title, &r.Body,
+		&r.Status, &r.FlagCount, &r.SellerReply, &r.SellerRepliedAt, &r.ModeratedAt, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func queryReviews(q queryer, query string, args ...interface{}) ([]models.Review, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reviews := make([]models.Review, 0)
+	for rows.Next() {
+		r, err := scanReview(rows)
+		if err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, *r)
+	}
+	return reviews, rows.Err()
+}
+
+// CreateReview adds a customer's review of an item. The customer must have had an order line for the item
+// delivered that wasn't cancelled in full, and can review each item once.
+func CreateReview(db *sql.DB, customerID, itemID uuid.UUID, rating int, title, body string) (*models.Review, error) {
+	title, body = strings.TrimSpace(title), strings.TrimSpace(body)
+	if err := validateReview(rating, title, body); err != nil {
+		return nil, err
+	}
+
+	var orderItemID uuid.UUID
+	err := db.QueryRow(`SELECT oi.id FROM order_items oi
+		JOIN orders o ON oi.order_id = o.id
+		JOIN seller_orders so ON oi.seller_order_id = so.id
+		WHERE o.customer_id = $1 AND oi.item_id = $2 AND so.status = $3 AND oi.quantity > oi.cancelled_quantity
+		ORDER BY so.updated_at DESC LIMIT 1`, customerID, itemID, models.OrderStatusDelivered).Scan(&orderItemID)
+	if err == sql.ErrNoRows {
+		return nil, ErrReviewNotVerified
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var id uuid.UUID
+	err = db.QueryRow(`INSERT INTO reviews (item_id, customer_id, order_item_id, rating, title, body) VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (item_id, customer_id) DO NOTHING RETURNING id`,
+		itemID, customerID, orderItemID, rating, title, body).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, ErrAlreadyReviewed
+	}
+	if err != nil {
+		return nil, err
+	}
+	return GetReview(db, id)
+}
+
+// GetReview fetches a review by its ID
+func GetReview(db *sql.DB, id uuid.UUID) (*models.Review, error) {
+	r, err := scanReview(db.QueryRow(`SELECT `+reviewColumns+` FROM `+reviewTables+` WHERE rv.id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrReviewNotFound
+	}
+	return r, err
+}
+
+// GetItemReviews fetches the published reviews of an item, newest first. Flags are left out, as they are
+// only for the seller to see.
+func GetItemReviews(db *sql.DB, itemID uuid.UUID, offset, limit int) ([]models.Review, error) {
+	reviews, err := queryReviews(db, `SELECT `+reviewColumns+` FROM `+reviewTables+`
+		WHERE rv.item_id = $1 AND rv.status = $2
+		ORDER BY rv.created_at DESC OFFSET $3 LIMIT $4`, itemID, models.ReviewStatusPublished, offset, limit)
+	for i := range reviews {
+		reviews[i].FlagCount = 0
+	}
+	return reviews, err
+}
+
+// GetSellerReviews fetches the reviews of a business admin's items, hidden ones included, newest first.
+// With flagged set only reviews users have flagged are returned.
+func GetSellerReviews(db *sql.DB, businessAdminID uuid.UUID, flagged bool, offset, limit int) ([]models.Review, error) {
+	return queryReviews(db, `SELECT `+reviewColumns+` FROM `+reviewTables+`
+		JOIN items i ON rv.item_id = i.id
+		WHERE i.business_admin_id = $1 AND (NOT $2 OR EXISTS (SELECT 1 FROM review_flags f WHERE f.review_id = rv.id))
+		ORDER BY rv.created_at DESC OFFSET $3 LIMIT $4`, businessAdminID, flagged, offset, limit)
+}
+
+// UpdateReview changes the rating and text of a customer's own review
+func UpdateReview(db *sql.DB, customerID, reviewID uuid.UUID, rating int, title, body string) (*models.Review, error) {
+	title, body = strings.TrimSpace(title), strings.TrimSpace(body)
+	if err := validateReview(rating, title, body); err != nil {
+		return nil, err
+	}
+	result, err := db.Exec(`UPDATE reviews SET rating = $1, title = $2, body = $3, updated_at = NOW() WHERE id = $4 AND customer_id = $5`,
+		rating, title, body, reviewID, customerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := expectUpdated(result); err == sql.ErrNoRows {
+		return nil, ErrReviewNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return GetReview(db, reviewID)
+}
+
+// DeleteReview deletes a customer's own review
+func DeleteReview(db *sql.DB, customerID, reviewID uuid.UUID) error {
+	result, err := db.Exec(`DELETE FROM reviews WHERE id = $1 AND customer_id = $2`, reviewID, customerID)
+	if err != nil {
+		return err
+	}
+	if err := expectUpdated(result); err == sql.ErrNoRows {
+		return ErrReviewNotFound
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// ReplyToReview sets the seller's public reply to a review of one of their items. An empty reply removes it.
+func ReplyToReview(db *sql.DB, businessAdminID, reviewID uuid.UUID, reply string) (*models.Review, error) {
+	reply = strings.TrimSpace(reply)
+	if utf8.RuneCountInString(reply) > maxReviewBodyLength {
+		return nil, ErrReviewTooLong
+	}
+	result, err := db.Exec(`UPDATE reviews rv SET seller_reply = NULLIF($1, ''), seller_replied_at = CASE WHEN $1 = '' THEN NULL ELSE NOW() END
+		FROM items i
+		WHERE rv.item_id = i.id AND rv.id = $2 AND i.business_admin_id = $3`, reply, reviewID, businessAdminID)
+	if err != nil {
+		return nil, err
+	}
+	if err := expectUpdated(result); err == sql.ErrNoRows {
+		return nil, ErrReviewNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return GetReview(db, reviewID)
+}
+
+// FlagReview records a user reporting a review, which puts it in the moderation queue. It is hidden until a
+// moderator decides once ReviewFlagThreshold verified buyers have flagged it, so that accounts which never
+// bought the item can't take reviews down. Users can't flag their own reviews or flag a review twice.
+func FlagReview(db *sql.DB, userID, reviewID uuid.UUID, reason string) (*models.ReviewFlag, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, ErrFlagReasonRequired
+	}
+	if utf8.RuneCountInString(reason) > maxReviewBodyLength {
+		return nil, ErrReviewTooLong
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	var authorUserID uuid.NullUUID
+	var itemID uuid.UUID
+	err = tx.QueryRow(`SELECT c.user_id, rv.item_id FROM reviews rv LEFT JOIN customers c ON rv.customer_id = c.id
+		WHERE rv.id = $1 FOR UPDATE OF rv`, reviewID).Scan(&authorUserID, &itemID)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return nil, ErrReviewNotFound
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if authorUserID.Valid && authorUserID.UUID == userID {
+		tx.Rollback()
+		return nil, ErrFlagOwnReview
+	}
+
+	flag := models.ReviewFlag{ReviewID: reviewID, UserID: userID, Reason: reason}
+	err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM order_items oi
+		JOIN orders o ON oi.order_id = o.id
+		JOIN customers c ON o.customer_id = c.id
+		JOIN seller_orders so ON oi.seller_order_id = so.id
+		WHERE c.user_id = $1 AND oi.item_id = $2 AND so.status = $3)`, userID, itemID, models.OrderStatusDelivered).Scan(&flag.VerifiedBuyer)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	err = tx.QueryRow(`INSERT INTO review_flags (review_id, user_id, reason, verified_buyer) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (review_id, user_id) DO NOTHING RETURNING created_at`, reviewID, userID, reason, flag.VerifiedBuyer).Scan(&flag.CreatedAt)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return nil, ErrReviewAlreadyFlagged
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	_, err = tx.Exec(`UPDATE reviews rv SET status = $1
+		WHERE id = $2 AND status = $3 AND (SELECT COUNT(*) FROM review_flags f
+			WHERE f.review_id = rv.id AND f.verified_buyer AND (rv.moderated_at IS NULL OR f.created_at > rv.moderated_at)) >= $4`,
+		models.ReviewStatusHidden, reviewID, models.ReviewStatusPublished, ReviewFlagThreshold)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return &flag, tx.Commit()
+}
+
+// GetModerationQueue fetches the reviews flagged since a moderator last decided on them, hidden ones and
+// the most flagged first
+func GetModerationQueue(db *sql.DB, offset, limit int) ([]models.Review, error) {
+	return queryReviews(db, `SELECT `+reviewColumns+` FROM `+reviewTables+`
+		WHERE EXISTS (SELECT 1 FROM review_flags f WHERE f.review_id = rv.id AND (rv.moderated_at IS NULL OR f.created_at > rv.moderated_at))
+		ORDER BY rv.status = $1 DESC, (SELECT COUNT(*) FROM review_flags f WHERE f.review_id = rv.id) DESC, rv.created_at
+		OFFSET $2 LIMIT $3`, models.ReviewStatusHidden, offset, limit)
+}
+
+// GetReviewFlags fetches the flags raised on a review, oldest first
+func GetReviewFlags(db *sql.DB, reviewID uuid.UUID) ([]models.ReviewFlag, error) {
+	rows, err := db.Query(`SELECT review_id, user_id, reason, verified_buyer, created_at FROM review_flags
+		WHERE review_id = $1 ORDER BY created_at`, reviewID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	flags := make([]models.ReviewFlag, 0)
+	for rows.Next() {
+		var flag models.ReviewFlag
+		if err := rows.Scan(&flag.ReviewID, &flag.UserID, &flag.Reason, &flag.VerifiedBuyer, &flag.CreatedAt); err != nil {
+			return nil, err
+		}
+		flags = append(flags, flag)
+	}
+	return flags, rows.Err()
+}
+
+// ModerateReview records a moderator's decision to publish or hide a review, taking it out of the moderation
+// queue until it is flagged again
+func ModerateReview(db *sql.DB, moderatorID, reviewID uuid.UUID, status string) (*models.Review, error) {
+	if status != models.ReviewStatusPublished && status != models.ReviewStatusHidden {
+		return nil, ErrInvalidReviewStatus
+	}
+	result, err := db.Exec(`UPDATE reviews SET status = $1, moderated_at = NOW(), moderated_by = $2 WHERE id = $3`,
+		status, moderatorID, reviewID)
+	if err != nil {
+		return nil, err
+	}
+	if err := expectUpdated(result); err == sql.ErrNoRows {
+		return nil, ErrReviewNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return GetReview(db, reviewID)
+}
@@ -29,9 +29,8 @@ func AddCustomer(db *sql.DB, userId uuid.UUID, customer models.Customer, locatio
 		return uuid.Nil, uuid.Nil, err
 	}
 
-	var locationID uuid.UUID
-	err = tx.QueryRow(`INSERT INTO locations (id, address, city, state, country, postal_code, latitude, longitude) VALUES (uuid_generate_v4(), $1, $2, $3, $4, $5, $6, $7) RETURNING id`,
-		location.Address, location.City, location.State, location.Country, location.PostalCode, location.Latitude, location.Longitude).Scan(&locationID)
+	// Reuse the location from the user's address book or save a new one
+	locationID, err := saveLocation(tx, userId, location)
 	if err != nil {
 		tx.Rollback()
 		return uuid.Nil, uuid.Nil, err
@@ -55,11 +54,14 @@ func AddCustomer(db *sql.DB, userId uuid.UUID, customer models.Customer, locatio
 	return customerID, locationID, tx.Commit()
 }
 
-// EditCustomer updates an existing customer in the database
-func EditCustomer(db *sql.DB, customer models.Customer) error {
-	_, err := db.Exec(`UPDATE customers SET customer_name = $1, contact_info = $2, location_id = $3 WHERE id = $4`,
-		customer.CustomerName, customer.ContactInfo, customer.LocationId, customer.Id)
-	return err
+// EditCustomer updates one of the user's customers in the database
+func EditCustomer(db *sql.DB, userId uuid.UUID, customer models.Customer) error {
+	result, err := db.Exec(`UPDATE customers SET customer_name = $1, contact_info = $2, location_id = $3 WHERE id = $4 AND user_id = $5`,
+		customer.CustomerName, customer.ContactInfo, customer.LocationId, customer.Id, userId)
+	if err != nil {
+		return err
+	}
+	return expectUpdated(result)
 }
 
 // Check if business admin already exists
@@ -82,17 +84,16 @@ func AddBusinessAdmin(db *sql.DB, userId uuid.UUID, businessAdmin models.Busines
 		return uuid.Nil, uuid.Nil, err
 	}
 
-	var locationID uuid.UUID
-	err = tx.QueryRow(`INSERT INTO locations (id, address, city, state, country, postal_code, latitude, longitude) VALUES (uuid_generate_v4(), $1, $2, $3, $4, $5, $6, $7) RETURNING id`,
-		location.Address, location.City, location.State, location.Country, location.PostalCode, location.Latitude, location.Longitude).Scan(&locationID)
+	// Reuse the location from the user's address book or save a new one
+	locationID, err := saveLocation(tx, userId, location)
 	if err != nil {
 		tx.Rollback()
 		return uuid.Nil, uuid.Nil, err
 	}
 
 	var businessAdminID uuid.UUID
-	err = tx.QueryRow(`INSERT INTO business_admins (id, company_name, contact_info, location_id, user_id) VALUES (uuid_generate_v4(), $1, $2, $3, $4) RETURNING id`,
-		businessAdmin.CompanyName, businessAdmin.ContactInfo, locationID, userId).Scan(&businessAdminID)
+	err = tx.QueryRow(`INSERT INTO business_admins (id, company_name, contact_info, location_id, user_id, currency) VALUES (uuid_generate_v4(), $1, $2, $3, $4, $5) RETURNING id`,
+		businessAdmin.CompanyName, businessAdmin.ContactInfo, locationID, userId, businessAdmin.Currency).Scan(&businessAdminID)
 	if err != nil {
 		tx.Rollback()
 		return uuid.Nil, uuid.Nil, err
@@ -108,11 +109,23 @@ func AddBusinessAdmin(db *sql.DB, userId uuid.UUID, businessAdmin models.Busines
 	return businessAdminID, locationID, tx.Commit()
 }
 
-// EditBusinessAdmin updates an existing business admin in the database
-func EditBusinessAdmin(db *sql.DB, businessAdmin models.BusinessAdmin) error {
-	_, err := db.Exec(`UPDATE business_admins SET company_name = $1, contact_info = $2, location_id = $3 WHERE id = $4`,
-		businessAdmin.CompanyName, businessAdmin.ContactInfo, businessAdmin.LocationId, businessAdmin.Id)
-	return err
+// EditBusinessAdmin updates one of the user's business admins in the database. An empty currency keeps the
+// current one; items already listed stay priced in the currency they were listed in.
+func EditBusinessAdmin(db *sql.DB, userId uuid.UUID, businessAdmin models.BusinessAdmin) error {
+	result, err := db.Exec(`UPDATE business_admins SET company_name = $1, contact_info = $2, location_id = $3, currency = COALESCE(NULLIF($6, ''), currency)
+		WHERE id = $4 AND user_id = $5`,
+		businessAdmin.CompanyName, businessAdmin.ContactInfo, businessAdmin.LocationId, businessAdmin.Id, userId, businessAdmin.Currency)
+	if err != nil {
+		return err
+	}
+	return expectUpdated(result)
+}
+
+// GetBusinessAdminCurrency returns the currency a business admin lists new items in
+func GetBusinessAdminCurrency(db *sql.DB, businessAdminID uuid.UUID) (string, error) {
+	var currency string
+	err := db.QueryRow(`SELECT currency FROM business_admins WHERE id = $1`, businessAdminID).Scan(&currency)
+	return currency, err
 }
 
 // Check if transporter already exists
@@ -135,17 +148,16 @@ func AddTransporter(db *sql.DB, userId uuid.UUID, transporter models.Transporter
 		return uuid.Nil, uuid.Nil, uuid.Nil, err
 	}
 
-	var locationID uuid.UUID
-	err = tx.QueryRow(`INSERT INTO locations (id, address, city, state, country, postal_code, latitude, longitude) VALUES (uuid_generate_v4(), $1, $2, $3, $4, $5, $6, $7) RETURNING id`,
-		location.Address, location.City, location.State, location.Country, location.PostalCode, location.Latitude, location.Longitude).Scan(&locationID)
+	// Reuse the location from the user's address book or save a new one
+	locationID, err := saveLocation(tx, userId, location)
 	if err != nil {
 		tx.Rollback()
 		return uuid.Nil, uuid.Nil, uuid.Nil, err
 	}
 
 	var vehicleID uuid.UUID
-	err = tx.QueryRow(`INSERT INTO vehicles (id, make, model, year, latitude, longitude, max_distance, max_capacity, current_capacity) VALUES (uuid_generate_v4(), $1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
-		vehicle.Make, vehicle.Model, vehicle.Year, vehicle.Latitude, vehicle.Longitude, vehicle.MaxDistance, vehicle.MaxCapacity, vehicle.CurrentCapacity).Scan(&vehicleID)
+	err = tx.QueryRow(`INSERT INTO vehicles (id, make, model, year, latitude, longitude, max_distance, max_capacity, current_capacity, driver_name, driver_contact) VALUES (uuid_generate_v4(), $1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`,
+		vehicle.Make, vehicle.Model, vehicle.Year, vehicle.Latitude, vehicle.Longitude, vehicle.MaxDistance, vehicle.MaxCapacity, vehicle.CurrentCapacity, vehicle.DriverName, vehicle.DriverContact).Scan(&vehicleID)
 	if err != nil {
 		tx.Rollback()
 		return uuid.Nil, uuid.Nil, uuid.Nil, err
@@ -159,6 +171,13 @@ func AddTransporter(db *sql.DB, userId uuid.UUID, transporter models.Transporter
 		return uuid.Nil, uuid.Nil, uuid.Nil, err
 	}
 
+	// Link the vehicle back to its transporter so it shows up in the transporter's fleet
+	_, err = tx.Exec(`UPDATE vehicles SET transporter_id = $1 WHERE id = $2`, transporterID, vehicleID)
+	if err != nil {
+		tx.Rollback()
+		return uuid.Nil, uuid.Nil, uuid.Nil, err
+	}
+
 	_, err = tx.Exec(`SELECT upsert_user_role($1, NULL, NULL, $2, NULL)`,
 		userId, transporterID)
 	if err != nil {
@@ -169,11 +188,14 @@ func AddTransporter(db *sql.DB, userId uuid.UUID, transporter models.Transporter
 	return transporterID, locationID, vehicleID, tx.Commit()
 }
 
-// EditTransporter updates an existing transporter in the database
-func EditTransporter(db *sql.DB, transporter models.Transporter) error {
-	_, err := db.Exec(`UPDATE transporters SET driver_name = $1, vehicle_id = $2, contact_info = $3, location_id = $4 WHERE id = $5`,
-		transporter.DriverName, transporter.VehicleId, transporter.ContactInfo, transporter.LocationId, transporter.Id)
-	return err
+// EditTransporter updates one of the user's transporters in the database
+func EditTransporter(db *sql.DB, userId uuid.UUID, transporter models.Transporter) error {
+	result, err := db.Exec(`UPDATE transporters SET driver_name = $1, vehicle_id = $2, contact_info = $3, location_id = $4 WHERE id = $5 AND user_id = $6`,
+		transporter.DriverName, transporter.VehicleId, transporter.ContactInfo, transporter.LocationId, transporter.Id, userId)
+	if err != nil {
+		return err
+	}
+	return expectUpdated(result)
 }
 
 // Check if supplier already exists
@@ -196,9 +218,8 @@ func AddSupplier(db *sql.DB, userId uuid.UUID, supplier models.Supplier, locatio
 		return uuid.Nil, uuid.Nil, err
 	}
 
-	var locationID uuid.UUID
-	err = tx.QueryRow(`INSERT INTO locations (id, address, city, state, country, postal_code, latitude, longitude) VALUES (uuid_generate_v4(), $1, $2, $3, $4, $5, $6, $7) RETURNING id`,
-		location.Address, location.City, location.State, location.Country, location.PostalCode, location.Latitude, location.Longitude).Scan(&locationID)
+	// Reuse the location from the user's address book or save a new one
+	locationID, err := saveLocation(tx, userId, location)
 	if err != nil {
 		tx.Rollback()
 		return uuid.Nil, uuid.Nil, err
@@ -222,11 +243,14 @@ func AddSupplier(db *sql.DB, userId uuid.UUID, supplier models.Supplier, locatio
 	return supplierID, locationID, tx.Commit()
 }
 
-// EditSupplier updates an existing supplier in the database
-func EditSupplier(db *sql.DB, supplier models.Supplier) error {
-	_, err := db.Exec(`UPDATE suppliers SET supplier_name = $1, contact_info = $2, address = $3, location_id = $4 WHERE id = $5`,
-		supplier.SupplierName, supplier.ContactInfo, supplier.Address, supplier.LocationId, supplier.Id)
-	return err
+// EditSupplier updates one of the user's suppliers in the database
+func EditSupplier(db *sql.DB, userId uuid.UUID, supplier models.Supplier) error {
+	result, err := db.Exec(`UPDATE suppliers SET supplier_name = $1, contact_info = $2, address = $3, location_id = $4 WHERE id = $5 AND user_id = $6`,
+		supplier.SupplierName, supplier.ContactInfo, supplier.Address, supplier.LocationId, supplier.Id, userId)
+	if err != nil {
+		return err
+	}
+	return expectUpdated(result)
 }
 
 // GetRolesByUserId fetches roles for a given user ID
@@ -249,7 +273,14 @@ func GetRolesByUserId(db *sql.DB, userId uuid.UUID) ([]models.Role, error) {
 	return roles, nil
 }
 
-
-
-
-
+// expectUpdated returns sql.ErrNoRows when an update matched no rows
+func expectUpdated(result sql.Result) error {
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+package repository
+
+import (
+	"chainwave/backend/internal/models"
+	"chainwave/backend/internal/orderstate"
+	"chainwave/backend/internal/money"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrSellerOrderNotFound  = errors.New("seller order not found")
+	ErrSellerOrderCancelled = errors.New("seller order has been cancelled")
+)
+
+// sellerOrderColumns selects a seller order along with how much of its lines has been refunded by refunds that haven't failed
+const sellerOrderColumns = `so.id, so.order_id, so.business_admin_id, so.status, so.subtotal, so.tax, so.tax_exclusive, so.shipping_cost,
+	(SELECT o.currency FROM orders o WHERE o.id = so.order_id), COALESCE((SELECT SUM(ri.amount) FROM refund_items ri
+		JOIN refunds r ON ri.refund_id = r.id
+		JOIN order_items oi ON ri.order_item_id = oi.id
+		WHERE oi.seller_order_id = so.id AND r.status <> 'failed'), 0),
+	so.created_at, so.updated_at`
+
+func scanSellerOrder(row rowScanner) (*models.SellerOrder, error) {
+	var so models.SellerOrder
+	var currency string
+	err := row.Scan(&so.ID, &so.OrderID, &so.BusinessAdminID, &so.Status, &so.Subtotal.Amount, &so.Tax.Amount, &so.TaxExclusive.Amount,
+		&so.ShippingCost.Amount, &currency, &so.Refunded.Amount, &so.CreatedAt, &so.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrSellerOrderNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	inCurrency(currency, &so.Subtotal, &so.Tax, &so.TaxExclusive, &so.ShippingCost, &so.Refunded)
+	if so.Payout, err = sellerPayout(&so); err != nil {
+		return nil, err
+	}
+	return &so, nil
+}
+
+// sellerPayout is what a seller is owed for their part of an order: their lines, tax charged on top and
+// shipping, less what has been refunded, and nothing once it is cancelled
+func sellerPayout(so *models.SellerOrder) (money.Money, error) {
+	payout := money.New(0, so.Subtotal.Currency)
+	if so.Status == models.OrderStatusCancelled {
+		return payout, nil
+	}
+	for _, amount := range []money.Money{so.Subtotal, so.TaxExclusive, so.ShippingCost} {
+		sum, err := payout.Add(amount)
+		if err != nil {
+			return money.Money{}, err
+		}
+		payout = sum
+	}
+	payout, err := payout.Sub(so.Refunded)
+	if err != nil || payout.Amount < 0 {
+		return money.New(0, so.Subtotal.Currency), err
+	}
+	return payout, nil
+}
+
+func querySellerOrders(q queryer, query string, args ...interface{}) ([]models.SellerOrder, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sellerOrders := make([]models.SellerOrder, 0)
+	for rows.Next() {
+		so, err := scanSellerOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		sellerOrders = append(sellerOrders, *so)
+	}
+	return sellerOrders, rows.Err()
+}
+
+// getSellerOrders fetches the seller orders an order is split into
+func getSellerOrders(q queryer, orderID uuid.UUID) ([]models.SellerOrder, error) {
+	return querySellerOrders(q, `SELECT `+sellerOrderColumns+` FROM seller_orders so WHERE so.order_id = $1 ORDER BY so.created_at, so.id`, orderID)
+}
+
+// GetSellerOrdersByBusinessAdmin fetches a business admin's seller orders, newest first, optionally only those in a status
+func GetSellerOrdersByBusinessAdmin(db *sql.DB, businessAdminID uuid.UUID, status string) ([]models.SellerOrder, error) {
+	sellerOrders, err := querySellerOrders(db, `SELECT `+sellerOrderColumns+` FROM seller_orders so
+		WHERE so.business_admin_id = $1 AND ($2 = '' OR so.status = $2)
+		ORDER BY so.created_at DESC`, businessAdminID, status)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range sellerOrders {
+		sellerOrders[i].Items, err = getSellerOrderItems(db, sellerOrders[i].ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sellerOrders, nil
+}
+
+// GetSellerOrder fetches one of a business admin's seller orders with its lines and shipments
+func GetSellerOrder(db *sql.DB, id, businessAdminID uuid.UUID) (*models.SellerOrder, error) {
+	so, err := scanSellerOrder(db.QueryRow(`SELECT `+sellerOrderColumns+` FROM seller_orders so WHERE so.id = $1 AND so.business_admin_id = $2`,
+		id, businessAdminID))
+	if err != nil {
+		return nil, err
+	}
+
+	so.Items, err = getSellerOrderItems(db, so.ID)
+	if err != nil {
+		return nil, err
+	}
+	so.Shipments, err = queryShipments(db, `SELECT `+shipmentColumns+` FROM shipments WHERE seller_order_id = $1 ORDER BY created_at`, so.ID)
+	if err != nil {
+		return nil, err
+	}
+	return so, nil
+}
+
+// getSellerOrderItems fetches the lines of a seller order
+func getSellerOrderItems(db *sql.DB, sellerOrderID uuid.UUID) ([]models.OrderItem, error) {
+	rows, err := db.Query(`SELECT `+orderItemColumns+` FROM order_items oi WHERE oi.seller_order_id = $1`, sellerOrderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]models.OrderItem, 0)
+	for rows.Next() {
+		item, err := scanOrderItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, attachOrderItemTaxes(db, items)
+}
+
+// advanceSellerOrder moves the business admin's part of an order towards a status, stepping through the
+// states in between. A seller order that can't reach the status, such as one already cancelled, is left alone.
+func advanceSellerOrder(tx *sql.Tx, orderID, businessAdminID uuid.UUID, to string, actor orderstate.Actor, note string) error {
+	sellerOrder := models.SellerOrder{OrderID: orderID, BusinessAdminID: businessAdminID}
+	err := tx.QueryRow(`SELECT id, status FROM seller_orders WHERE order_id = $1 AND business_admin_id = $2 FOR UPDATE`,
+		orderID, businessAdminID).Scan(&sellerOrder.ID, &sellerOrder.Status)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return setSellerOrderStatus(tx, sellerOrder, to, actor, note)
+}
+
+// cancelEmptySellerOrders cancels the unshipped seller orders of an order whose every line has been
+// cancelled back into stock, along with their shipments, and returns them
+func cancelEmptySellerOrders(tx *sql.Tx, orderID uuid.UUID, actor orderstate.Actor, note string) ([]models.SellerOrder, error) {
+	rows, err := tx.Query(`SELECT id, order_id, business_admin_id, status, shipping_cost,
+		(SELECT o.currency FROM orders o WHERE o.id = so.order_id) FROM seller_orders so
+		WHERE so.order_id = $1 AND so.status IN ($2, $3)
+			AND NOT EXISTS (SELECT 1 FROM order_items oi WHERE oi.seller_order_id = so.id AND oi.cancelled_quantity < oi.quantity)
+		FOR UPDATE`, orderID, models.OrderStatusPending, models.OrderStatusProcessing)
+	if err != nil {
+		return nil, err
+	}
+	emptied := make([]models.SellerOrder, 0)
+	for rows.Next() {
+		var so models.SellerOrder
+		if err := rows.Scan(&so.ID, &so.OrderID, &so.BusinessAdminID, &so.Status, &so.ShippingCost.Amount, &so.ShippingCost.Currency); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		emptied = append(emptied, so)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, so := range emptied {
+		businessAdminID := so.BusinessAdminID
+		if _, err := cancelShipments(tx, orderID, &businessAdminID); err != nil {
+			return nil, err
+		}
+		if err := setSellerOrderStatus(tx, so, models.OrderStatusCancelled, actor, note); err != nil {
+			return nil, err
+		}
+	}
+	return emptied, nil
+}
+
+// syncOrderStatus rolls the statuses of an order's seller orders up into the order: it is cancelled once
+// they all are, shipped once every remaining one has shipped and delivered once they are all delivered.
+// An order that hasn't been paid for stays pending, as it can't be shipped.
+func syncOrderStatus(tx *sql.Tx, orderID uuid.UUID, actor orderstate.Actor, note string) error {
+	var current string
+	if err := tx.QueryRow(`SELECT status FROM orders WHERE id = $1 FOR UPDATE`, orderID).Scan(&current); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(`SELECT status FROM seller_orders WHERE order_id = $1`, orderID)
+	if err != nil {
+		return err
+	}
+	var active, shipped, delivered int
+	for rows.Next() {
+		var status string
+		if err := rows.Scan(&status); err != nil {
+			rows.Close()
+			return err
+		}
+		switch status {
+		case models.OrderStatusCancelled:
+			continue
+		case models.OrderStatusDelivered:
+			delivered++
+			shipped++
+		case models.OrderStatusShipped:
+			shipped++
+		}
+		active++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	target := ""
+	switch {
+	case active == 0:
+		target = models.OrderStatusCancelled
+	case delivered == active:
+		target = models.OrderStatusDelivered
+	case shipped == active:
+		target = models.OrderStatusShipped
+	}
+	if target == "" || target == current {
+		return nil
+	}
+	return setOrderStatus(tx, orderID, current, target, actor, note)
+}
@@ -0,0 +1,367 @@
+package repository
+
+import (
+	"chainwave/backend/internal/geo"
+	"chainwave/backend/internal/matching"
+	"chainwave/backend/internal/models"
+	"chainwave/backend/internal/orderstate"
+	"chainwave/backend/internal/statemachine"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNoItemsForSeller  = errors.New("order has no items from this seller")
+	ErrShipmentForbidden = errors.New("shipment does not belong to this role")
+	ErrVehicleNotOwned   = errors.New("vehicle does not belong to this transporter")
+	ErrShipmentUnlocated = errors.New("shipment's pickup or drop location has no coordinates")
+)
+
+// ShipmentStateMachine lists the status changes a shipment may go through
+var ShipmentStateMachine = statemachine.New("shipment", map[string][]string{
+	models.ShipmentStatusPending:   {models.ShipmentStatusAccepted, models.ShipmentStatusCancelled},
+	models.ShipmentStatusAccepted:  {models.ShipmentStatusPickedUp, models.ShipmentStatusCancelled},
+	models.ShipmentStatusPickedUp:  {models.ShipmentStatusDelivered},
+	models.ShipmentStatusDelivered: {},
+	models.ShipmentStatusCancelled: {},
+})
+
+// shipmentStatusTimestamps maps a status to the column recording when it was reached
+var shipmentStatusTimestamps = map[string]string{
+	models.ShipmentStatusAccepted:  "accepted_at",
+	models.ShipmentStatusPickedUp:  "picked_up_at",
+	models.ShipmentStatusDelivered: "delivered_at",
+	models.ShipmentStatusCancelled: "cancelled_at",
+}
+
+const shipmentColumns = `id, order_id, seller_order_id, business_admin_id, pickup_location_id, drop_location_id, transporter_id, vehicle_id,
+	weight, status, created_at, updated_at, accepted_at, picked_up_at, delivered_at, cancelled_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanShipment(row rowScanner) (*models.Shipment, error) {
+	var s models.Shipment
+	err := row.Scan(&s.ID, &s.OrderID, &s.SellerOrderID, &s.BusinessAdminID, &s.PickupLocationID, &s.DropLocationID, &s.TransporterID, &s.VehicleID,
+		&s.Weight, &s.Status, &s.CreatedAt, &s.UpdatedAt, &s.AcceptedAt, &s.PickedUpAt, &s.DeliveredAt, &s.CancelledAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func queryShipments(db *sql.DB, query string, args ...interface{}) ([]models.Shipment, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shipments := make([]models.Shipment, 0)
+	for rows.Next() {
+		s, err := scanShipment(rows)
+		if err != nil {
+			return nil, err
+		}
+		shipments = append(shipments, *s)
+	}
+	return shipments, rows.Err()
+}
+
+// CreateShipment creates a shipment for the seller's lines of an order.
+// The pickup is the given warehouse, or the seller's own location if warehouseID is nil,
+// and the weight is the total weight of the seller's items in the order.
+func CreateShipment(db *sql.DB, orderID, businessAdminID uuid.UUID, warehouseID *uuid.UUID) (*models.Shipment, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	var dropLocationID uuid.UUID
+	if err := tx.QueryRow(`SELECT location_id FROM orders WHERE id = $1`, orderID).Scan(&dropLocationID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	var lines int
+	var weight float64
+	err = tx.QueryRow(`SELECT COUNT(*), COALESCE(SUM(i.weight * oi.quantity), 0)
+		FROM order_items oi JOIN items i ON oi.item_id = i.id
+		WHERE oi.order_id = $1 AND i.business_admin_id = $2`, orderID, businessAdminID).Scan(&lines, &weight)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if lines == 0 {
+		tx.Rollback()
+		return nil, ErrNoItemsForSeller
+	}
+
+	var sellerOrderID uuid.UUID
+	var sellerOrderStatus string
+	err = tx.QueryRow(`SELECT id, status FROM seller_orders WHERE order_id = $1 AND business_admin_id = $2`,
+		orderID, businessAdminID).Scan(&sellerOrderID, &sellerOrderStatus)
+	if err == sql.ErrNoRows {
+		err = ErrNoItemsForSeller
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if sellerOrderStatus == models.OrderStatusCancelled {
+		tx.Rollback()
+		return nil, ErrSellerOrderCancelled
+	}
+
+	var pickupLocationID uuid.UUID
+	if warehouseID != nil {
+		err = tx.QueryRow(`SELECT location_id FROM warehouses WHERE id = $1 AND business_admin_id = $2`, *warehouseID, businessAdminID).Scan(&pickupLocationID)
+	} else {
+		err = tx.QueryRow(`SELECT location_id FROM business_admins WHERE id = $1`, businessAdminID).Scan(&pickupLocationID)
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	shipment, err := scanShipment(tx.QueryRow(`INSERT INTO shipments (id, order_id, seller_order_id, business_admin_id, pickup_location_id, drop_location_id, weight, status)
+		VALUES (uuid_generate_v4(), $1, $2, $3, $4, $5, $6, $7) RETURNING `+shipmentColumns,
+		orderID, sellerOrderID, businessAdminID, pickupLocationID, dropLocationID, weight, models.ShipmentStatusPending))
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return shipment, tx.Commit()
+}
+
+// GetShipmentByID retrieves a shipment by its ID
+func GetShipmentByID(db *sql.DB, id uuid.UUID) (*models.Shipment, error) {
+	return scanShipment(db.QueryRow(`SELECT `+shipmentColumns+` FROM shipments WHERE id = $1`, id))
+}
+
+// GetShipmentsByBusinessAdmin fetches the shipments created by a business admin, newest first
+func GetShipmentsByBusinessAdmin(db *sql.DB, businessAdminID uuid.UUID) ([]models.Shipment, error) {
+	return queryShipments(db, `SELECT `+shipmentColumns+` FROM shipments WHERE business_admin_id = $1 ORDER BY created_at DESC`, businessAdminID)
+}
+
+// GetShipmentsByTransporter fetches the shipments assigned to a transporter, newest first
+func GetShipmentsByTransporter(db *sql.DB, transporterID uuid.UUID) ([]models.Shipment, error) {
+	return queryShipments(db, `SELECT `+shipmentColumns+` FROM shipments WHERE transporter_id = $1 ORDER BY created_at DESC`, transporterID)
+}
+
+// GetPendingShipments fetches the shipments still waiting for a transporter, oldest first
+func GetPendingShipments(db *sql.DB) ([]models.Shipment, error) {
+	return queryShipments(db, `SELECT `+shipmentColumns+` FROM shipments WHERE status = $1 ORDER BY created_at`, models.ShipmentStatusPending)
+}
+
+// TransporterOwnsVehicle checks whether a vehicle belongs to a transporter
+func TransporterOwnsVehicle(db *sql.DB, transporterID, vehicleID uuid.UUID) (bool, error) {
+	var owns bool
+	err := db.QueryRow(`SELECT EXISTS(
+		SELECT 1 FROM vehicles v LEFT JOIN transporters t ON t.vehicle_id = v.id
+		WHERE v.id = $1 AND (v.transporter_id = $2 OR t.id = $2))`, vehicleID, transporterID).Scan(&owns)
+	return owns, err
+}
+
+// AcceptShipment assigns a pending shipment to a transporter and one of their vehicles,
+// reserving the shipment's weight on the vehicle. The pickup must be within the vehicle's
+// max distance and the weight within its remaining capacity. Shipments whose pickup or drop
+// has no coordinates can't be placed and fail with ErrShipmentUnlocated.
+func AcceptShipment(db *sql.DB, id, transporterID, vehicleID uuid.UUID) (*models.Shipment, error) {
+	owns, err := TransporterOwnsVehicle(db, transporterID, vehicleID)
+	if err != nil {
+		return nil, err
+	}
+	if !owns {
+		return nil, ErrVehicleNotOwned
+	}
+
+	// Accepting a shipment never moves its order along
+	shipment, _, err := TransitionShipment(db, id, models.ShipmentStatusAccepted, func(tx *sql.Tx, s *models.Shipment) error {
+		// Lock the vehicle so its position and capacity can't change between the check and the reservation
+		vehicle, err := scanVehicle(tx.QueryRow(`SELECT `+vehicleColumns+` `+vehicleFrom+` WHERE v.id = $1 FOR UPDATE OF v`, vehicleID))
+		if err != nil {
+			return err
+		}
+		pickup, drop, err := shipmentCoordinates(tx, s.ID)
+		if err != nil {
+			return err
+		}
+		if pickup == nil || drop == nil {
+			return ErrShipmentUnlocated
+		}
+		if err := matching.ValidateAssignment(*vehicle, *pickup, s.Weight); err != nil {
+			return err
+		}
+
+		if err := reserveVehicleCapacity(tx, vehicleID, s.Weight); err != nil {
+			return err
+		}
+		_, err = tx.Exec(`UPDATE shipments SET transporter_id = $1, vehicle_id = $2 WHERE id = $3`, transporterID, vehicleID, s.ID)
+		return err
+	})
+	return shipment, err
+}
+
+// TransitionShipment moves a shipment to a new status, rejecting transitions the state machine doesn't allow.
+// The shipment row is locked for the duration, and before is called with it inside the transaction so callers
+// can check ownership or make related changes; an error from before aborts the transition. When the shipment
+// moves its seller order along, the order is returned as well so its change can be announced. The order is
+// only read once the transition is committed, so failing to read it is logged rather than reported.
+func TransitionShipment(db *sql.DB, id uuid.UUID, to string, before func(tx *sql.Tx, s *models.Shipment) error) (*models.Shipment, *models.Order, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	shipment, err := scanShipment(tx.QueryRow(`SELECT `+shipmentColumns+` FROM shipments WHERE id = $1 FOR UPDATE`, id))
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+
+	if err := ShipmentStateMachine.Transition(shipment.Status, to); err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+
+	if before != nil {
+		if err := before(tx, shipment); err != nil {
+			tx.Rollback()
+			return nil, nil, err
+		}
+	}
+
+	shipment, err = moveShipment(tx, shipment, to)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+
+	moved, err := followShipment(tx, shipment)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	if !moved {
+		return shipment, nil, nil
+	}
+	order, err := GetOrderByID(db, shipment.OrderID)
+	if err != nil {
+		log.Printf("failed to load order %s after shipment %s moved to %s: %v", shipment.OrderID, shipment.ID, to, err)
+		return shipment, nil, nil
+	}
+	return shipment, order, nil
+}
+
+// followShipment moves the seller order a shipment belongs to along with it: shipped once it is picked up and
+// delivered once none of the seller's other shipments are still on their way. The order then follows its seller orders.
+// It reports whether the seller order moved.
+func followShipment(tx *sql.Tx, shipment *models.Shipment) (bool, error) {
+	if shipment.SellerOrderID == nil {
+		return false, nil
+	}
+	actor := orderstate.Actor{Role: orderstate.RoleTransporter}
+	if shipment.TransporterID != nil {
+		actor.ID = *shipment.TransporterID
+	}
+	note := "shipment " + shipment.Status
+
+	switch shipment.Status {
+	case models.ShipmentStatusPickedUp:
+		if err := advanceSellerOrder(tx, shipment.OrderID, shipment.BusinessAdminID, models.OrderStatusShipped, actor, note); err != nil {
+			return false, err
+		}
+	case models.ShipmentStatusDelivered:
+		var open bool
+		err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM shipments WHERE seller_order_id = $1 AND status IN ($2, $3, $4))`,
+			*shipment.SellerOrderID, models.ShipmentStatusPending, models.ShipmentStatusAccepted, models.ShipmentStatusPickedUp).Scan(&open)
+		if err != nil {
+			return false, err
+		}
+		if open {
+			return false, nil
+		}
+		if err := advanceSellerOrder(tx, shipment.OrderID, shipment.BusinessAdminID, models.OrderStatusDelivered, actor, note); err != nil {
+			return false, err
+		}
+	default:
+		return false, nil
+	}
+	return true, syncOrderStatus(tx, shipment.OrderID, actor, note)
+}
+
+// moveShipment sets the status of a locked shipment whose transition has already been checked
+func moveShipment(tx *sql.Tx, shipment *models.Shipment, to string) (*models.Shipment, error) {
+	// A delivered or cancelled shipment no longer takes up room on its vehicle
+	if shipment.VehicleID != nil && (to == models.ShipmentStatusDelivered || to == models.ShipmentStatusCancelled) {
+		if err := releaseVehicleCapacity(tx, *shipment.VehicleID, shipment.Weight); err != nil {
+			return nil, err
+		}
+	}
+
+	query := fmt.Sprintf(`UPDATE shipments SET status = $1, updated_at = NOW(), %s = NOW() WHERE id = $2 RETURNING `+shipmentColumns,
+		shipmentStatusTimestamps[to])
+	return scanShipment(tx.QueryRow(query, to, shipment.ID))
+}
+
+// GetShipmentCoordinates fetches the points of a shipment's pickup and drop locations. Either is nil
+// when its location has no coordinates.
+func GetShipmentCoordinates(db *sql.DB, id uuid.UUID) (pickup, drop *geo.Point, err error) {
+	return shipmentCoordinates(db, id)
+}
+
+func shipmentCoordinates(q queryer, id uuid.UUID) (pickup, drop *geo.Point, err error) {
+	var pickupLatitude, pickupLongitude, dropLatitude, dropLongitude sql.NullFloat64
+	err = q.QueryRow(`SELECT p.latitude, p.longitude, d.latitude, d.longitude
+		FROM shipments s
+		JOIN locations p ON s.pickup_location_id = p.id
+		JOIN locations d ON s.drop_location_id = d.id
+		WHERE s.id = $1`, id).Scan(&pickupLatitude, &pickupLongitude, &dropLatitude, &dropLongitude)
+	if err != nil {
+		return nil, nil, err
+	}
+	return nullablePoint(pickupLatitude, pickupLongitude), nullablePoint(dropLatitude, dropLongitude), nil
+}
+
+// nullablePoint is the point at a location's coordinates, or nil if it has none
+func nullablePoint(latitude, longitude sql.NullFloat64) *geo.Point {
+	if !latitude.Valid || !longitude.Valid {
+		return nil
+	}
+	return &geo.Point{Latitude: latitude.Float64, Longitude: longitude.Float64}
+}
+
+// GetActiveShipmentsForVehicle fetches the shipments a vehicle still has to pick up or drop,
+// along with their pickup and drop locations
+func GetActiveShipmentsForVehicle(db *sql.DB, vehicleID uuid.UUID) ([]models.ShipmentWithLocations, error) {
+	shipments, err := queryShipments(db, `SELECT `+shipmentColumns+` FROM shipments WHERE vehicle_id = $1 AND status IN ($2, $3) ORDER BY created_at`,
+		vehicleID, models.ShipmentStatusAccepted, models.ShipmentStatusPickedUp)
+	if err != nil {
+		return nil, err
+	}
+
+	active := make([]models.ShipmentWithLocations, 0, len(shipments))
+	for _, shipment := range shipments {
+		pickup, err := GetLocationByID(db, shipment.PickupLocationID)
+		if err != nil {
+			return nil, err
+		}
+		drop, err := GetLocationByID(db, shipment.DropLocationID)
+		if err != nil {
+			return nil, err
+		}
+		active = append(active, models.ShipmentWithLocations{Shipment: shipment, Pickup: *pickup, Drop: *drop})
+	}
+	return active, nil
+}
@@ -0,0 +1,225 @@
+package repository
+
+import (
+	"chainwave/backend/internal/geo"
+	"chainwave/backend/internal/models"
+	"chainwave/backend/internal/pricing"
+	"chainwave/backend/internal/shipping"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrTariffNotFound = errors.New("shipping tariff not found")
+
+// queryer is satisfied by both *sql.DB and *sql.Tx so reads can run inside or outside a transaction
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// AddShippingTariff adds a tariff and its distance bands
+func AddShippingTariff(db *sql.DB, tariff models.ShippingTariff) (*models.ShippingTariff, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRow(`INSERT INTO shipping_tariffs (id, transporter_id, name, currency, base_fee, per_kg) VALUES (uuid_generate_v4(), $1, $2, $3, $4, $5) RETURNING id, created_at`,
+		tariff.TransporterID, tariff.Name, tariff.Currency, tariff.BaseFee, tariff.PerKg).Scan(&tariff.ID, &tariff.CreatedAt)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	for _, band := range tariff.Bands {
+		_, err = tx.Exec(`INSERT INTO shipping_tariff_bands (tariff_id, max_distance_km, fee, transit_days) VALUES ($1, $2, $3, $4)`,
+			tariff.ID, band.MaxDistanceKm, band.Fee, band.TransitDays)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	return &tariff, tx.Commit()
+}
+
+// GetShippingTariffsByTransporter fetches a transporter's active tariffs
+func GetShippingTariffsByTransporter(db *sql.DB, transporterID uuid.UUID) ([]models.ShippingTariff, error) {
+	return queryShippingTariffs(db, `WHERE active AND transporter_id = $1`, transporterID)
+}
+
+// DeactivateShippingTariff stops a transporter's tariff from being quoted. Orders already shipped
+// with it keep pointing at it.
+func DeactivateShippingTariff(db *sql.DB, transporterID, id uuid.UUID) error {
+	result, err := db.Exec(`UPDATE shipping_tariffs SET active = FALSE WHERE id = $1 AND transporter_id = $2 AND active`, id, transporterID)
+	if err != nil {
+		return err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return ErrTariffNotFound
+	}
+	return nil
+}
+
+// queryShippingTariffs fetches the tariffs matching a WHERE clause along with their bands
+func queryShippingTariffs(q queryer, where string, args ...interface{}) ([]models.ShippingTariff, error) {
+	rows, err := q.Query(`SELECT id, transporter_id, name, currency, base_fee, per_kg, created_at FROM shipping_tariffs `+where+` ORDER BY created_at`, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	tariffs := make([]models.ShippingTariff, 0)
+	index := make(map[uuid.UUID]int)
+	for rows.Next() {
+		var tariff models.ShippingTariff
+		if err := rows.Scan(&tariff.ID, &tariff.TransporterID, &tariff.Name, &tariff.Currency, &tariff.BaseFee, &tariff.PerKg, &tariff.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tariff.Bands = make([]models.DistanceBand, 0)
+		index[tariff.ID] = len(tariffs)
+		tariffs = append(tariffs, tariff)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = q.Query(`SELECT tariff_id, max_distance_km, fee, transit_days FROM shipping_tariff_bands
+		WHERE tariff_id IN (SELECT id FROM shipping_tariffs `+where+`) ORDER BY max_distance_km`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tariffID uuid.UUID
+		var band models.DistanceBand
+		if err := rows.Scan(&tariffID, &band.MaxDistanceKm, &band.Fee, &band.TransitDays); err != nil {
+			return nil, err
+		}
+		if i, ok := index[tariffID]; ok {
+			tariffs[i].Bands = append(tariffs[i].Bands, band)
+		}
+	}
+	return tariffs, rows.Err()
+}
+
+// GetShippingDestination retrieves the point shipping for a customer is quoted to: a location from their
+// address book when one is given, otherwise their default shipping address or their own location. The point
+// is nil when the address has no coordinates, as orders to it are left for the seller to price.
+func GetShippingDestination(db *sql.DB, customerID uuid.UUID, locationID *uuid.UUID) (*geo.Point, error) {
+	var latitude, longitude sql.NullFloat64
+	var err error
+	if locationID != nil {
+		err = db.QueryRow(`SELECT l.latitude, l.longitude FROM customers c
+			JOIN locations l ON l.user_id = c.user_id WHERE c.id = $1 AND l.id = $2`,
+			customerID, *locationID).Scan(&latitude, &longitude)
+		if err == sql.ErrNoRows {
+			err = ErrLocationNotFound
+		}
+	} else {
+		err = db.QueryRow(`SELECT l.latitude, l.longitude FROM customers c
+			JOIN locations l ON l.id = COALESCE(c.default_shipping_location_id, c.location_id) WHERE c.id = $1`,
+			customerID).Scan(&latitude, &longitude)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nullablePoint(latitude, longitude), nil
+}
+
+// QuoteShipping groups the lines by seller and quotes shipping each group from the seller's location
+// to the destination with every active tariff charging in the items' currency. Lines in different
+// currencies fail with pricing.ErrMixedCurrencies, as they can't be ordered together.
+func QuoteShipping(q queryer, destination geo.Point, lines []models.OrderLineRequest) ([]models.ShippingPackage, error) {
+	packages := make([]models.ShippingPackage, 0)
+	parcels := make([]shipping.Parcel, 0)
+	index := make(map[uuid.UUID]int)
+	var currency string
+
+	for _, line := range lines {
+		var businessAdminID uuid.UUID
+		var itemCurrency string
+		var weight float64
+		var dimensions models.Dimensions
+		var latitude, longitude sql.NullFloat64
+		err := q.QueryRow(`SELECT i.business_admin_id, i.currency, i.weight, `+itemDimensionColumns+`, l.latitude, l.longitude
+			FROM items i
+			JOIN business_admins b ON i.business_admin_id = b.id
+			LEFT JOIN locations l ON b.location_id = l.id
+			WHERE i.id = $1`, line.ItemID).Scan(&businessAdminID, &itemCurrency, &weight, &dimensions.Length, &dimensions.Width, &dimensions.Height, &latitude, &longitude)
+		if err != nil {
+			return nil, err
+		}
+		if !latitude.Valid || !longitude.Valid {
+			return nil, shipping.ErrNotServiceable
+		}
+		if currency != "" && itemCurrency != currency {
+			return nil, pricing.ErrMixedCurrencies
+		}
+		currency = itemCurrency
+
+		i, ok := index[businessAdminID]
+		if !ok {
+			origin := geo.Point{Latitude: latitude.Float64, Longitude: longitude.Float64}
+			i = len(packages)
+			index[businessAdminID] = i
+			packages = append(packages, models.ShippingPackage{BusinessAdminID: businessAdminID, DistanceKm: origin.Distance(destination)})
+			parcels = append(parcels, shipping.Parcel{})
+		}
+
+		// Items without dimensions are charged on their actual weight only
+		parcels[i].Add(weight, dimensions, line.Quantity)
+	}
+
+	tariffs, err := queryShippingTariffs(q, `WHERE active AND currency = $1`, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for i := range packages {
+		quotes, err := shipping.Quote(tariffs, packages[i].DistanceKm, parcels[i], now)
+		if err != nil {
+			return nil, err
+		}
+		for j := range quotes {
+			quotes[j].BusinessAdminID = packages[i].BusinessAdminID
+		}
+		packages[i].Weight = parcels[i].Weight
+		packages[i].VolumetricWeight = parcels[i].VolumetricWeight()
+		packages[i].ChargeableWeight = parcels[i].ChargeableWeight()
+		packages[i].Quotes = quotes
+	}
+	return packages, nil
+}
+
+// getOrderShipping fetches the shipping chosen for each seller of an order
+func getOrderShipping(db *sql.DB, orderID uuid.UUID) ([]models.ShippingQuote, error) {
+	rows, err := db.Query(`SELECT os.business_admin_id, os.tariff_id, t.name, t.transporter_id, os.distance_km, os.chargeable_weight, os.cost, t.currency, os.transit_days, os.estimated_delivery
+		FROM order_shipping os JOIN shipping_tariffs t ON os.tariff_id = t.id
+		WHERE os.order_id = $1`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	quotes := make([]models.ShippingQuote, 0)
+	for rows.Next() {
+		var quote models.ShippingQuote
+		if err := rows.Scan(&quote.BusinessAdminID, &quote.TariffID, &quote.TariffName, &quote.TransporterID, &quote.DistanceKm,
+			&quote.ChargeableWeight, &quote.Cost.Amount, &quote.Cost.Currency, &quote.TransitDays, &quote.EstimatedDelivery); err != nil {
+			return nil, err
+		}
+		quotes = append(quotes, quote)
+	}
+	return quotes, rows.Err()
+}
@@ -0,0 +1,295 @@
+package repository
+
+import (
+	"chainwave/backend/internal/models"
+	"chainwave/backend/internal/money"
+	"chainwave/backend/internal/tax"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+var ErrTaxRuleNotFound = errors.New("tax rule not found")
+
+// IsTaxRuleError reports whether an error is one of the reasons a tax rule is invalid
+func IsTaxRuleError(err error) bool {
+	switch err {
+	case tax.ErrNameRequired, tax.ErrCountryRequired, tax.ErrInvalidRate, tax.ErrInvalidScope:
+		return true
+	}
+	return false
+}
+
+const taxRuleColumns = `id, business_admin_id, name, country, COALESCE(state, ''), COALESCE(category, ''), scope, rate, inclusive, created_at`
+
+func scanTaxRule(row rowScanner) (models.TaxRule, error) {
+	var rule models.TaxRule
+	err := row.Scan(&rule.ID, &rule.BusinessAdminID, &rule.Name, &rule.Country, &rule.State, &rule.Category, &rule.Scope,
+		&rule.Rate, &rule.Inclusive, &rule.CreatedAt)
+	return rule, err
+}
+
+// CreateTaxRule adds a tax rule a business admin charges on their sales, or every seller charges when
+// BusinessAdminID is nil
+func CreateTaxRule(db *sql.DB, rule models.TaxRule) (models.TaxRule, error) {
+	rule.Name, rule.Country, rule.State, rule.Category = strings.TrimSpace(rule.Name), strings.TrimSpace(rule.Country),
+		strings.TrimSpace(rule.State), strings.TrimSpace(rule.Category)
+	if rule.Scope == "" {
+		rule.Scope = tax.ScopeAny
+	}
+	if err := toTaxRule(rule).Validate(); err != nil {
+		return rule, err
+	}
+
+	err := db.QueryRow(`INSERT INTO tax_rules (business_admin_id, name, country, state, category, scope, rate, inclusive)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), $6, $7, $8) RETURNING id, created_at`,
+		rule.BusinessAdminID, rule.Name, rule.Country, rule.State, rule.Category, rule.Scope, rule.Rate, rule.Inclusive).Scan(&rule.ID, &rule.CreatedAt)
+	return rule, err
+}
+
+// GetTaxRules fetches the active tax rules a business admin charges: their own and those every seller charges
+func GetTaxRules(db *sql.DB, businessAdminID uuid.UUID) ([]models.TaxRule, error) {
+	rows, err := db.Query(`SELECT `+taxRuleColumns+` FROM tax_rules
+		WHERE active AND (business_admin_id IS NULL OR business_admin_id = $1)
+		ORDER BY country, state NULLS FIRST, category NULLS FIRST, name`, businessAdminID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := make([]models.TaxRule, 0)
+	for rows.Next() {
+		rule, err := scanTaxRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// DeactivateTaxRule stops one of a business admin's tax rules from being charged. Orders already taxed
+// under it keep their breakdown.
+func DeactivateTaxRule(db *sql.DB, businessAdminID, id uuid.UUID) error {
+	result, err := db.Exec(`UPDATE tax_rules SET active = FALSE WHERE id = $1 AND business_admin_id = $2 AND active`, id, businessAdminID)
+	if err != nil {
+		return err
+	}
+	if err := expectUpdated(result); err == sql.ErrNoRows {
+		return ErrTaxRuleNotFound
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+func toTaxRule(rule models.TaxRule) tax.Rule {
+	return tax.Rule{ID: rule.ID, Name: rule.Name, Country: rule.Country, State: rule.State, Category: rule.Category,
+		Scope: rule.Scope, Rate: rule.Rate, Inclusive: rule.Inclusive}
+}
+
+// taxRules fetches the active rules a business admin charges on sales to buyers in a country
+func taxRules(q queryer, businessAdminID uuid.UUID, country string) ([]tax.Rule, error) {
+	rows, err := q.Query(`SELECT `+taxRuleColumns+` FROM tax_rules
+		WHERE active AND (business_admin_id IS NULL OR business_admin_id = $1) AND LOWER(country) = LOWER(TRIM($2))`,
+		businessAdminID, country)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := make([]tax.Rule, 0)
+	for rows.Next() {
+		rule, err := scanTaxRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, toTaxRule(rule))
+	}
+	return rules, rows.Err()
+}
+
+// shipsFrom returns where an order line ships from: its warehouse, or the business admin's own location for
+// items not stocked in a warehouse
+func shipsFrom(q queryer, businessAdminID uuid.UUID, warehouseID *uuid.UUID) (tax.Place, error) {
+	var place tax.Place
+	err := q.QueryRow(`SELECT COALESCE(l.country, ''), COALESCE(l.state, '') FROM locations l
+		WHERE l.id = COALESCE((SELECT location_id FROM warehouses WHERE id = $2::uuid), (SELECT location_id FROM business_admins WHERE id = $1))`,
+		businessAdminID, warehouseID).Scan(&place.Country, &place.State)
+	if err == sql.ErrNoRows {
+		return place, nil
+	}
+	return place, err
+}
+
+// addOrderItemTaxes records the taxes charged on an order line
+func addOrderItemTaxes(tx *sql.Tx, orderItemID uuid.UUID, line tax.Line) ([]models.OrderItemTax, error) {
+	taxes := make([]models.OrderItemTax, 0, len(line.Charges))
+	for _, charge := range line.Charges {
+		ruleID := charge.Rule.ID
+		t := models.OrderItemTax{
+			TaxRuleID:    &ruleID,
+			Name:         charge.Rule.Name,
+			Jurisdiction: charge.Rule.Jurisdiction(),
+			Rate:         charge.Rule.Rate,
+			Inclusive:    charge.Rule.Inclusive,
+			Taxable:      charge.Taxable,
+			Amount:       charge.Amount,
+		}
+		if _, err := tx.Exec(`INSERT INTO order_item_taxes (order_item_id, tax_rule_id, name, jurisdiction, rate, inclusive, taxable, amount)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+			orderItemID, t.TaxRuleID, t.Name, t.Jurisdiction, t.Rate, t.Inclusive, t.Taxable.Amount, t.Amount.Amount); err != nil {
+			return nil, err
+		}
+		taxes = append(taxes, t)
+	}
+	return taxes, nil
+}
+
+// attachOrderItemTaxes fills in the tax breakdown of order lines
+func attachOrderItemTaxes(q queryer, items []models.OrderItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, len(items))
+	index := make(map[uuid.UUID]int, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+		index[item.ID] = i
+	}
+
+	rows, err := q.Query(`SELECT order_item_id, tax_rule_id, name, jurisdiction, rate, inclusive, taxable, amount
+		FROM order_item_taxes WHERE order_item_id = ANY($1::uuid[]) ORDER BY id`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var orderItemID uuid.UUID
+		var t models.OrderItemTax
+		if err := rows.Scan(&orderItemID, &t.TaxRuleID, &t.Name, &t.Jurisdiction, &t.Rate, &t.Inclusive, &t.Taxable.Amount, &t.Amount.Amount); err != nil {
+			return err
+		}
+		item := &items[index[orderItemID]]
+		inCurrency(item.Price.Currency, &t.Taxable, &t.Amount)
+		item.Taxes = append(item.Taxes, t)
+	}
+	return rows.Err()
+}
+
+// GetTaxSummaries fetches what the invoices for an order show, one for each seller's part of it, or only
+// businessAdminID's part when it is set
+func GetTaxSummaries(db *sql.DB, orderID uuid.UUID, businessAdminID *uuid.UUID) ([]models.TaxSummary, error) {
+	rows, err := db.Query(`SELECT so.id, so.order_id, so.business_admin_id, o.currency, o.created_at, so.shipping_cost,
+			COALESCE(b.company_name, ''), COALESCE(sl.address, ''), COALESCE(sl.city, ''), COALESCE(sl.state, ''),
+			COALESCE(sl.country, ''), COALESCE(sl.postal_code, ''),
+			COALESCE(c.customer_name, ''), COALESCE(bl.address, ''), COALESCE(bl.city, ''), COALESCE(bl.state, ''),
+			COALESCE(bl.country, ''), COALESCE(bl.postal_code, '')
+		FROM seller_orders so
+		JOIN orders o ON so.order_id = o.id
+		JOIN business_admins b ON so.business_admin_id = b.id
+		LEFT JOIN locations sl ON b.location_id = sl.id
+		JOIN customers c ON o.customer_id = c.id
+		LEFT JOIN locations bl ON o.location_id = bl.id
+		WHERE so.order_id = $1 AND ($2::uuid IS NULL OR so.business_admin_id = $2)
+		ORDER BY so.created_at, so.id`, orderID, businessAdminID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	summaries := make([]models.TaxSummary, 0)
+	for rows.Next() {
+		var s models.TaxSummary
+		if err := rows.Scan(&s.SellerOrderID, &s.OrderID, &s.BusinessAdminID, &s.Currency, &s.OrderedAt, &s.ShippingCost.Amount,
+			&s.Seller.Name, &s.Seller.Address, &s.Seller.City, &s.Seller.State, &s.Seller.Country, &s.Seller.PostalCode,
+			&s.Buyer.Name, &s.Buyer.Address, &s.Buyer.City, &s.Buyer.State, &s.Buyer.Country, &s.Buyer.PostalCode); err != nil {
+			return nil, err
+		}
+		s.ShippingCost.Currency = s.Currency
+		summaries = append(summaries, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range summaries {
+		if err := summarizeTax(db, &summaries[i]); err != nil {
+			return nil, err
+		}
+	}
+	return summaries, nil
+}
+
+// summarizeTax fills in a tax summary's lines, the totals of each tax and the summary's totals, worked out
+// in minor units so they add up exactly
+func summarizeTax(db *sql.DB, s *models.TaxSummary) error {
+	rows, err := db.Query(`SELECT oi.id, oi.item_id, COALESCE(i.name, ''), oi.quantity, oi.price, oi.discount, oi.tax, oi.tax_exclusive
+		FROM order_items oi LEFT JOIN items i ON oi.item_id = i.id
+		WHERE oi.seller_order_id = $1 ORDER BY oi.id`, s.SellerOrderID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	subtotal, totalTax := money.New(0, s.Currency), money.New(0, s.Currency)
+	s.Lines = make([]models.TaxSummaryLine, 0)
+	for rows.Next() {
+		var line models.TaxSummaryLine
+		var taxExclusive money.Money
+		if err := rows.Scan(&line.OrderItemID, &line.ItemID, &line.Name, &line.Quantity, &line.UnitPrice.Amount, &line.Discount.Amount,
+			&line.Tax.Amount, &taxExclusive.Amount); err != nil {
+			return err
+		}
+		inCurrency(s.Currency, &line.UnitPrice, &line.Discount, &line.Tax, &taxExclusive)
+
+		// The buyer pays the discounted price and the tax charged on top of it, and the net is that less every tax
+		charged, err := line.UnitPrice.Mul(line.Quantity).Sub(line.Discount)
+		if err != nil {
+			return err
+		}
+		if line.Total, err = charged.Add(taxExclusive); err != nil {
+			return err
+		}
+		if line.Net, err = line.Total.Sub(line.Tax); err != nil {
+			return err
+		}
+		if err := addAmounts(runningTotal{&subtotal, line.Net}, runningTotal{&totalTax, line.Tax}); err != nil {
+			return err
+		}
+		s.Lines = append(s.Lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	taxRows, err := db.Query(`SELECT t.name, t.jurisdiction, t.rate, t.inclusive, SUM(t.taxable), SUM(t.amount)
+		FROM order_item_taxes t JOIN order_items oi ON t.order_item_id = oi.id
+		WHERE oi.seller_order_id = $1
+		GROUP BY t.name, t.jurisdiction, t.rate, t.inclusive
+		ORDER BY t.jurisdiction, t.name, t.rate`, s.SellerOrderID)
+	if err != nil {
+		return err
+	}
+	defer taxRows.Close()
+
+	s.Taxes = make([]models.TaxTotal, 0)
+	for taxRows.Next() {
+		var total models.TaxTotal
+		if err := taxRows.Scan(&total.Name, &total.Jurisdiction, &total.Rate, &total.Inclusive, &total.Taxable.Amount, &total.Amount.Amount); err != nil {
+			return err
+		}
+		inCurrency(s.Currency, &total.Taxable, &total.Amount)
+		s.Taxes = append(s.Taxes, total)
+	}
+	if err := taxRows.Err(); err != nil {
+		return err
+	}
+
+	s.Subtotal, s.Tax, s.Total = subtotal, totalTax, subtotal
+	return addAmounts(runningTotal{&s.Total, totalTax}, runningTotal{&s.Total, s.ShippingCost})
+}
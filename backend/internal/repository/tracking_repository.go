@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"chainwave/backend/internal/models"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// maxTrailLength caps how many pings are returned in a breadcrumb trail
+const maxTrailLength = 500
+
+// RecordVehiclePositions stores a batch of GPS pings for a vehicle and moves the vehicle to the newest one,
+// unless a newer position has already been recorded
+func RecordVehiclePositions(db *sql.DB, vehicleID uuid.UUID, positions []models.VehiclePosition) error {
+	if len(positions) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	latest := positions[0]
+	for _, position := range positions {
+		_, err := tx.Exec(`INSERT INTO vehicle_positions (id, vehicle_id, latitude, longitude, recorded_at) VALUES (uuid_generate_v4(), $1, $2, $3, $4)`,
+			vehicleID, position.Latitude, position.Longitude, position.RecordedAt)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if position.RecordedAt.After(latest.RecordedAt) {
+			latest = position
+		}
+	}
+
+	_, err = tx.Exec(`UPDATE vehicles SET latitude = $1, longitude = $2, position_updated_at = $3
+		WHERE id = $4 AND (position_updated_at IS NULL OR position_updated_at < $3)`,
+		latest.Latitude, latest.Longitude, latest.RecordedAt, vehicleID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetVehicleTrail fetches a vehicle's pings recorded between two times, oldest first.
+// Only the most recent maxTrailLength pings are returned.
+func GetVehicleTrail(db *sql.DB, vehicleID uuid.UUID, since, until time.Time) ([]models.VehiclePosition, error) {
+	rows, err := db.Query(`SELECT id, vehicle_id, latitude, longitude, recorded_at FROM (
+			SELECT id, vehicle_id, latitude, longitude, recorded_at FROM vehicle_positions
+			WHERE vehicle_id = $1 AND recorded_at >= $2 AND recorded_at <= $3
+			ORDER BY recorded_at DESC LIMIT $4
+		) recent ORDER BY recorded_at`, vehicleID, since, until, maxTrailLength)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	trail := make([]models.VehiclePosition, 0)
+	for rows.Next() {
+		var p models.VehiclePosition
+		if err := rows.Scan(&p.ID, &p.VehicleID, &p.Latitude, &p.Longitude, &p.RecordedAt); err != nil {
+			return nil, err
+		}
+		trail = append(trail, p)
+	}
+	return trail, rows.Err()
+}
+
+// GetVehiclePosition fetches the position stored on a vehicle, or nil if it has none. A position set without
+// a ping has no recorded time and is taken to have held since the given time.
+func GetVehiclePosition(db *sql.DB, vehicleID uuid.UUID, since time.Time) (*models.VehiclePosition, error) {
+	position := models.VehiclePosition{VehicleID: vehicleID}
+	err := db.QueryRow(`SELECT latitude, longitude, COALESCE(position_updated_at, $2) FROM vehicles
+		WHERE id = $1 AND latitude IS NOT NULL AND longitude IS NOT NULL`, vehicleID, since).Scan(
+		&position.Latitude, &position.Longitude, &position.RecordedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &position, nil
+}
+
+// GetOrderCustomerID fetches the ID of the customer who placed an order
+func GetOrderCustomerID(db *sql.DB, orderID uuid.UUID) (uuid.UUID, error) {
+	var customerID uuid.UUID
+	err := db.QueryRow(`SELECT customer_id FROM orders WHERE id = $1`, orderID).Scan(&customerID)
+	return customerID, err
+}
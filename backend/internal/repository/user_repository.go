@@ -103,4 +103,30 @@ func UpdatePassword(db *sql.DB, userID uuid.UUID, newPassword string) error {
 	query := `UPDATE users SET password = $1 WHERE id = $2`
 	_, err := db.Exec(query, newPassword, userID)
 	return err
-}
\ No newline at end of file
+}
+
+// Get the unit system a user displays weights and dimensions in
+func GetUnitSystem(db *sql.DB, userID uuid.UUID) (string, error) {
+	var system string
+	err := db.QueryRow(`SELECT unit_system FROM users WHERE id = $1`, userID).Scan(&system)
+	return system, err
+}
+
+// Update the unit system a user displays weights and dimensions in
+func UpdateUnitSystem(db *sql.DB, userID uuid.UUID, system string) error {
+	_, err := db.Exec(`UPDATE users SET unit_system = $1 WHERE id = $2`, system, userID)
+	return err
+}
+
+// Get the currency a user displays prices in, empty when they have not chosen one
+func GetDisplayCurrency(db *sql.DB, userID uuid.UUID) (string, error) {
+	var currency sql.NullString
+	err := db.QueryRow(`SELECT currency FROM users WHERE id = $1`, userID).Scan(&currency)
+	return currency.String, err
+}
+
+// Update the currency a user displays prices in
+func UpdateDisplayCurrency(db *sql.DB, userID uuid.UUID, currency string) error {
+	_, err := db.Exec(`UPDATE users SET currency = $1 WHERE id = $2`, currency, userID)
+	return err
+}
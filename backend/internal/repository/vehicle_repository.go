@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"chainwave/backend/internal/models"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInsufficientCapacity = errors.New("vehicle does not have enough remaining capacity")
+	ErrCapacityBelowLoad    = errors.New("max capacity cannot be lower than the vehicle's current load")
+	ErrVehicleInUse         = errors.New("vehicle still has active shipments")
+)
+
+// vehicleColumns selects a vehicle, resolving its transporter through transporters.vehicle_id
+// for vehicles registered before vehicles.transporter_id was set
+const vehicleColumns = `v.id, COALESCE(v.transporter_id, t.id), COALESCE(v.make, ''), COALESCE(v.model, ''), COALESCE(v.year, 0),
+	COALESCE(v.latitude, 0), COALESCE(v.longitude, 0), COALESCE(v.max_distance, 0), COALESCE(v.max_capacity, 0), COALESCE(v.current_capacity, 0),
+	v.status, COALESCE(v.driver_name, ''), COALESCE(v.driver_contact, ''), v.retired_at`
+
+const vehicleFrom = `FROM vehicles v LEFT JOIN transporters t ON t.vehicle_id = v.id`
+
+func scanVehicle(row rowScanner) (*models.Vehicle, error) {
+	var v models.Vehicle
+	err := row.Scan(&v.ID, &v.TransporterID, &v.Make, &v.Model, &v.Year, &v.Latitude, &v.Longitude, &v.MaxDistance, &v.MaxCapacity, &v.CurrentCapacity,
+		&v.Status, &v.DriverName, &v.DriverContact, &v.RetiredAt)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func queryVehicles(db *sql.DB, query string, args ...interface{}) ([]models.Vehicle, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	vehicles := make([]models.Vehicle, 0)
+	for rows.Next() {
+		v, err := scanVehicle(rows)
+		if err != nil {
+			return nil, err
+		}
+		vehicles = append(vehicles, *v)
+	}
+	return vehicles, rows.Err()
+}
+
+// GetVehiclesWithCapacity fetches the active vehicles belonging to a transporter that can still carry the given weight
+func GetVehiclesWithCapacity(db *sql.DB, weight float64) ([]models.Vehicle, error) {
+	return queryVehicles(db, `SELECT `+vehicleColumns+` `+vehicleFrom+`
+		WHERE COALESCE(v.transporter_id, t.id) IS NOT NULL
+		AND v.status = $1 AND v.retired_at IS NULL
+		AND COALESCE(v.max_capacity, 0) - COALESCE(v.current_capacity, 0) >= $2`, models.VehicleStatusActive, weight)
+}
+
+// AddVehicle adds a new vehicle to a transporter's fleet
+func AddVehicle(db *sql.DB, vehicle models.Vehicle) (uuid.UUID, error) {
+	var id uuid.UUID
+	err := db.QueryRow(`INSERT INTO vehicles (id, transporter_id, make, model, year, latitude, longitude, max_distance, max_capacity, current_capacity, status, driver_name, driver_contact)
+		VALUES (uuid_generate_v4(), $1, $2, $3, $4, $5, $6, $7, $8, 0, $9, $10, $11) RETURNING id`,
+		vehicle.TransporterID, vehicle.Make, vehicle.Model, vehicle.Year, vehicle.Latitude, vehicle.Longitude, vehicle.MaxDistance, vehicle.MaxCapacity,
+		vehicle.Status, vehicle.DriverName, vehicle.DriverContact).Scan(&id)
+	return id, err
+}
+
+// GetVehiclesByTransporter fetches a transporter's fleet, leaving out retired vehicles unless asked for
+func GetVehiclesByTransporter(db *sql.DB, transporterID uuid.UUID, includeRetired bool) ([]models.Vehicle, error) {
+	return queryVehicles(db, `SELECT `+vehicleColumns+` `+vehicleFrom+`
+		WHERE COALESCE(v.transporter_id, t.id) = $1 AND ($2 OR v.retired_at IS NULL)
+		ORDER BY v.make, v.model`, transporterID, includeRetired)
+}
+
+// EditVehicle updates a vehicle's details. The max capacity can't drop below what the vehicle is already carrying.
+func EditVehicle(db *sql.DB, vehicle models.Vehicle) error {
+	result, err := db.Exec(`UPDATE vehicles SET make = $1, model = $2, year = $3, max_distance = $4, max_capacity = $5
+		WHERE id = $6 AND COALESCE(current_capacity, 0) <= $5`,
+		vehicle.Make, vehicle.Model, vehicle.Year, vehicle.MaxDistance, vehicle.MaxCapacity, vehicle.ID)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrCapacityBelowLoad
+	}
+	return nil
+}
+
+// SetVehicleStatus sets a vehicle's availability status
+func SetVehicleStatus(db *sql.DB, id uuid.UUID, status string) error {
+	_, err := db.Exec(`UPDATE vehicles SET status = $1 WHERE id = $2`, status, id)
+	return err
+}
+
+// AssignVehicleDriver sets the driver of a vehicle
+func AssignVehicleDriver(db *sql.DB, id uuid.UUID, driverName, driverContact string) error {
+	_, err := db.Exec(`UPDATE vehicles SET driver_name = $1, driver_contact = $2 WHERE id = $3`, driverName, driverContact, id)
+	return err
+}
+
+// RetireVehicle takes a vehicle out of the fleet for good. Vehicles still carrying shipments can't be retired.
+func RetireVehicle(db *sql.DB, id uuid.UUID) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`SELECT id FROM vehicles WHERE id = $1 FOR UPDATE`, id); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	var active bool
+	err = tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM shipments WHERE vehicle_id = $1 AND status IN ($2, $3))`,
+		id, models.ShipmentStatusAccepted, models.ShipmentStatusPickedUp).Scan(&active)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if active {
+		tx.Rollback()
+		return ErrVehicleInUse
+	}
+
+	_, err = tx.Exec(`UPDATE vehicles SET retired_at = NOW(), status = $1 WHERE id = $2 AND retired_at IS NULL`, models.VehicleStatusOffline, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// reserveVehicleCapacity adds weight to a vehicle's current capacity, failing with ErrInsufficientCapacity
+// if that would exceed its max capacity. The check and update happen in one statement so concurrent
+// reservations can't overbook the vehicle.
+func reserveVehicleCapacity(tx *sql.Tx, vehicleID uuid.UUID, weight float64) error {
+	result, err := tx.Exec(`UPDATE vehicles SET current_capacity = COALESCE(current_capacity, 0) + $1
+		WHERE id = $2 AND COALESCE(max_capacity, 0) - COALESCE(current_capacity, 0) >= $1`, weight, vehicleID)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrInsufficientCapacity
+	}
+	return nil
+}
+
+// releaseVehicleCapacity removes weight from a vehicle's current capacity once a shipment no longer occupies it
+func releaseVehicleCapacity(tx *sql.Tx, vehicleID uuid.UUID, weight float64) error {
+	_, err := tx.Exec(`UPDATE vehicles SET current_capacity = GREATEST(COALESCE(current_capacity, 0) - $1, 0) WHERE id = $2`, weight, vehicleID)
+	return err
+}
+
+// ReconcileVehicleCapacity recomputes every vehicle's current capacity from the weight of its accepted
+// and picked up shipments, correcting any drift. It returns how many vehicles were corrected. The
+// vehicles are locked first, so shipments reserving or releasing capacity wait for the correction, and
+// the weights are summed afterwards so none committed in the meantime are missed.
+func ReconcileVehicleCapacity(db *sql.DB) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`SELECT id FROM vehicles ORDER BY id FOR UPDATE`)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	result, err := tx.Exec(`UPDATE vehicles v SET current_capacity = active.weight
+		FROM (
+			SELECT v2.id, COALESCE(SUM(s.weight), 0) AS weight
+			FROM vehicles v2
+			LEFT JOIN shipments s ON s.vehicle_id = v2.id AND s.status IN ($1, $2)
+			GROUP BY v2.id
+		) active
+		WHERE v.id = active.id AND v.current_capacity IS DISTINCT FROM active.weight`,
+		models.ShipmentStatusAccepted, models.ShipmentStatusPickedUp)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	corrected, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	return corrected, tx.Commit()
+}
@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"chainwave/backend/internal/models"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+)
+
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// AddWarehouse adds a new warehouse and its location to the database
+func AddWarehouse(db *sql.DB, warehouse models.Warehouse, location models.Location) (uuid.UUID, uuid.UUID, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+
+	// Warehouse locations belong to the business admin's user
+	var userID uuid.UUID
+	err = tx.QueryRow(`SELECT user_id FROM business_admins WHERE id = $1`, warehouse.BusinessAdminID).Scan(&userID)
+	if err != nil {
+		tx.Rollback()
+		return uuid.Nil, uuid.Nil, err
+	}
+
+	locationID, err := saveLocation(tx, userID, location)
+	if err != nil {
+		tx.Rollback()
+		return uuid.Nil, uuid.Nil, err
+	}
+
+	var warehouseID uuid.UUID
+	err = tx.QueryRow(`INSERT INTO warehouses (id, business_admin_id, name, location_id) VALUES (uuid_generate_v4(), $1, $2, $3) RETURNING id`,
+		warehouse.BusinessAdminID, warehouse.Name, locationID).Scan(&warehouseID)
+	if err != nil {
+		tx.Rollback()
+		return uuid.Nil, uuid.Nil, err
+	}
+
+	return warehouseID, locationID, tx.Commit()
+}
+
+// GetWarehouseByID retrieves a warehouse by its ID
+func GetWarehouseByID(db *sql.DB, id uuid.UUID) (*models.Warehouse, error) {
+	var warehouse models.Warehouse
+	err := db.QueryRow(`SELECT id, business_admin_id, name, location_id, created_at FROM warehouses WHERE id = $1`, id).Scan(
+		&warehouse.ID, &warehouse.BusinessAdminID, &warehouse.Name, &warehouse.LocationID, &warehouse.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &warehouse, nil
+}
+
+// GetWarehousesByBusinessAdmin fetches all warehouses owned by a business admin
+func GetWarehousesByBusinessAdmin(db *sql.DB, businessAdminID uuid.UUID) ([]models.Warehouse, error) {
+	rows, err := db.Query(`SELECT id, business_admin_id, name, location_id, created_at FROM warehouses WHERE business_admin_id = $1 ORDER BY created_at`, businessAdminID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	warehouses := make([]models.Warehouse, 0)
+	for rows.Next() {
+		var warehouse models.Warehouse
+		if err := rows.Scan(&warehouse.ID, &warehouse.BusinessAdminID, &warehouse.Name, &warehouse.LocationID, &warehouse.CreatedAt); err != nil {
+			return nil, err
+		}
+		warehouses = append(warehouses, warehouse)
+	}
+	return warehouses, rows.Err()
+}
+
+// SetWarehouseStock sets the stock level of an item in a warehouse
+func SetWarehouseStock(db *sql.DB, stock models.WarehouseStock) error {
+	_, err := db.Exec(`INSERT INTO warehouse_stock (warehouse_id, item_id, quantity) VALUES ($1, $2, $3)
+		ON CONFLICT (warehouse_id, item_id) DO UPDATE SET quantity = EXCLUDED.quantity`,
+		stock.WarehouseID, stock.ItemID, stock.Quantity)
+	return err
+}
+
+// GetWarehouseStock fetches the stock levels of every item held in a warehouse
+func GetWarehouseStock(db *sql.DB, warehouseID uuid.UUID) ([]models.WarehouseStock, error) {
+	rows, err := db.Query(`SELECT warehouse_id, item_id, quantity FROM warehouse_stock WHERE warehouse_id = $1`, warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stock := make([]models.WarehouseStock, 0)
+	for rows.Next() {
+		var s models.WarehouseStock
+		if err := rows.Scan(&s.WarehouseID, &s.ItemID, &s.Quantity); err != nil {
+			return nil, err
+		}
+		stock = append(stock, s)
+	}
+	return stock, rows.Err()
+}
+
+// TransferStock moves stock of an item between two warehouses and records the transfer
+func TransferStock(db *sql.DB, transfer models.StockTransfer) (uuid.UUID, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	// Lock the source row so concurrent transfers and orders can't oversell it
+	var available int
+	err = tx.QueryRow(`SELECT quantity FROM warehouse_stock WHERE warehouse_id = $1 AND item_id = $2 FOR UPDATE`,
+		transfer.FromWarehouseID, transfer.ItemID).Scan(&available)
+	if err == sql.ErrNoRows || (err == nil && available < transfer.Quantity) {
+		tx.Rollback()
+		return uuid.Nil, ErrInsufficientStock
+	}
+	if err != nil {
+		tx.Rollback()
+		return uuid.Nil, err
+	}
+
+	_, err = tx.Exec(`UPDATE warehouse_stock SET quantity = quantity - $1 WHERE warehouse_id = $2 AND item_id = $3`,
+		transfer.Quantity, transfer.FromWarehouseID, transfer.ItemID)
+	if err != nil {
+		tx.Rollback()
+		return uuid.Nil, err
+	}
+
+	_, err = tx.Exec(`INSERT INTO warehouse_stock (warehouse_id, item_id, quantity) VALUES ($1, $2, $3)
+		ON CONFLICT (warehouse_id, item_id) DO UPDATE SET quantity = warehouse_stock.quantity + EXCLUDED.quantity`,
+		transfer.ToWarehouseID, transfer.ItemID, transfer.Quantity)
+	if err != nil {
+		tx.Rollback()
+		return uuid.Nil, err
+	}
+
+	var transferID uuid.UUID
+	err = tx.QueryRow(`INSERT INTO stock_transfers (id, item_id, from_warehouse_id, to_warehouse_id, quantity) VALUES (uuid_generate_v4(), $1, $2, $3, $4) RETURNING id`,
+		transfer.ItemID, transfer.FromWarehouseID, transfer.ToWarehouseID, transfer.Quantity).Scan(&transferID)
+	if err != nil {
+		tx.Rollback()
+		return uuid.Nil, err
+	}
+
+	return transferID, tx.Commit()
+}
+
+// GetStockTransfersByWarehouse fetches transfers into or out of a warehouse, newest first
+func GetStockTransfersByWarehouse(db *sql.DB, warehouseID uuid.UUID) ([]models.StockTransfer, error) {
+	rows, err := db.Query(`SELECT id, item_id, from_warehouse_id, to_warehouse_id, quantity, created_at FROM stock_transfers
+		WHERE from_warehouse_id = $1 OR to_warehouse_id = $1 ORDER BY created_at DESC`, warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transfers := make([]models.StockTransfer, 0)
+	for rows.Next() {
+		var t models.StockTransfer
+		if err := rows.Scan(&t.ID, &t.ItemID, &t.FromWarehouseID, &t.ToWarehouseID, &t.Quantity, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, t)
+	}
+	return transfers, rows.Err()
+}
@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"chainwave/backend/internal/models"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNotInWishlist = errors.New("item is not in the wishlist")
+	ErrItemInStock   = errors.New("item is in stock")
+	ErrNotSubscribed = errors.New("not subscribed to this item")
+
+	ErrStockNotificationNotFound = errors.New("notification not found")
+)
+
+// AddWishlistItem saves an item to a customer's wishlist. Saving one already there does nothing.
+func AddWishlistItem(db *sql.DB, customerID, itemID uuid.UUID) error {
+	var exists bool
+	if err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM items WHERE id = $1)`, itemID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrItemNotFound
+	}
+	_, err := db.Exec(`INSERT INTO wishlist_items (customer_id, item_id) VALUES ($1, $2) ON CONFLICT (customer_id, item_id) DO NOTHING`,
+		customerID, itemID)
+	return err
+}
+
+// RemoveWishlistItem takes an item off a customer's wishlist
+func RemoveWishlistItem(db *sql.DB, customerID, itemID uuid.UUID) error {
+	result, err := db.Exec(`DELETE FROM wishlist_items WHERE customer_id = $1 AND item_id = $2`, customerID, itemID)
+	if err != nil {
+		return err
+	}
+	if err := expectUpdated(result); err == sql.ErrNoRows {
+		return ErrNotInWishlist
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetWishlist fetches the items on a customer's wishlist with their current prices and stock, most recently
+// added first
+func GetWishlist(db *sql.DB, customerID uuid.UUID) ([]models.WishlistItem, error) {
+	rows, err := db.Query(`SELECT i.id, i.name, i.description, i.price_minor, i.currency, i.weight, `+itemDimensionColumns+`, i.category, i.quantity, i.image_url, `+itemRatingColumns+`,
+			s.customer_id IS NOT NULL, w.created_at
+		FROM wishlist_items w
+		JOIN items i ON w.item_id = i.id
+		LEFT JOIN item_ratings r ON r.item_id = i.id
+		LEFT JOIN stock_subscriptions s ON s.customer_id = w.customer_id AND s.item_id = w.item_id AND s.notified_at IS NULL
+		WHERE w.customer_id = $1
+		ORDER BY w.created_at DESC`, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	wishlist := make([]models.WishlistItem, 0)
+	for rows.Next() {
+		var entry models.WishlistItem
+		item := &entry.Item
+		if err := rows.Scan(&item.Id, &item.Name, &item.Description, &item.Price.Amount, &item.Price.Currency, &item.Weight,
+			&item.Dimensions.Length, &item.Dimensions.Width, &item.Dimensions.Height, &item.Category, &item.Quantity, &item.ImageURL,
+			&item.Rating, &item.ReviewCount, &entry.Subscribed, &entry.AddedAt); err != nil {
+			return nil, err
+		}
+		storedUnits(&item.WeightUnit, &item.Dimensions)
+		entry.InStock = item.Quantity > 0
+		wishlist = append(wishlist, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	items := make([]models.Item, len(wishlist))
+	for i := range wishlist {
+		items[i] = wishlist[i].Item
+	}
+	if err := applySales(db, items); err != nil {
+		return nil, err
+	}
+	for i := range wishlist {
+		wishlist[i].Item = items[i]
+	}
+	return wishlist, nil
+}
+
+// SubscribeToStock asks for a customer to be notified when an item out of stock comes back. Subscribing
+// again after being notified waits for the next time. The item is locked so a restock can't slip in between
+// checking it is out of stock and subscribing.
+func SubscribeToStock(db *sql.DB, customerID, itemID uuid.UUID) (*models.StockSubscription, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	sub := models.StockSubscription{ItemID: itemID}
+	err = tx.QueryRow(`SELECT name, quantity FROM items WHERE id = $1 FOR SHARE`, itemID).Scan(&sub.Name, &sub.Quantity)
+	if err == sql.ErrNoRows {
+		err = ErrItemNotFound
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if sub.Quantity > 0 {
+		tx.Rollback()
+		return nil, ErrItemInStock
+	}
+
+	err = tx.QueryRow(`INSERT INTO stock_subscriptions (customer_id, item_id) VALUES ($1, $2)
+		ON CONFLICT (customer_id, item_id) DO UPDATE SET created_at = NOW(), notified_at = NULL
+		RETURNING created_at`, customerID, itemID).Scan(&sub.CreatedAt)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return &sub, tx.Commit()
+}
+
+// UnsubscribeFromStock cancels a customer's back-in-stock subscription to an item
+func UnsubscribeFromStock(db *sql.DB, customerID, itemID uuid.UUID) error {
+	result, err := db.Exec(`DELETE FROM stock_subscriptions WHERE customer_id = $1 AND item_id = $2`, customerID, itemID)
+	if err != nil {
+		return err
+	}
+	if err := expectUpdated(result); err == sql.ErrNoRows {
+		return ErrNotSubscribed
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetStockSubscriptions fetches a customer's back-in-stock subscriptions, newest first, including those
+// already notified
+func GetStockSubscriptions(db *sql.DB, customerID uuid.UUID) ([]models.StockSubscription, error) {
+	rows, err := db.Query(`SELECT s.item_id, i.name, i.quantity, s.created_at, s.notified_at
+		FROM stock_subscriptions s
+		JOIN items i ON s.item_id = i.id
+		WHERE s.customer_id = $1
+		ORDER BY s.created_at DESC`, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := make([]models.StockSubscription, 0)
+	for rows.Next() {
+		var sub models.StockSubscription
+		if err := rows.Scan(&sub.ItemID, &sub.Name, &sub.Quantity, &sub.CreatedAt, &sub.NotifiedAt); err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+// GetStockNotifications fetches the back-in-stock notifications stored for a customer, newest first. With
+// unread set it leaves out those already read.
+func GetStockNotifications(db *sql.DB, customerID uuid.UUID, unread bool, limit, offset int) ([]models.StockNotification, error) {
+	rows, err := db.Query(`SELECT id, item_id, name, quantity, created_at, read_at
+		FROM stock_notifications
+		WHERE customer_id = $1 AND (NOT $2 OR read_at IS NULL)
+		ORDER BY created_at DESC
+		LIMIT $3 OFFSET $4`, customerID, unread, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := make([]models.StockNotification, 0)
+	for rows.Next() {
+		var notification models.StockNotification
+		if err := rows.Scan(&notification.ID, &notification.ItemID, &notification.Name, &notification.Quantity,
+			&notification.CreatedAt, &notification.ReadAt); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, notification)
+	}
+	return notifications, rows.Err()
+}
+
+// MarkStockNotificationRead records that a customer has seen one of their notifications. Marking one already
+// read keeps the time it was first read.
+func MarkStockNotificationRead(db *sql.DB, customerID, notificationID uuid.UUID) (*models.StockNotification, error) {
+	notification := models.StockNotification{ID: notificationID}
+	err := db.QueryRow(`UPDATE stock_notifications SET read_at = COALESCE(read_at, NOW())
+		WHERE id = $1 AND customer_id = $2
+		RETURNING item_id, name, quantity, created_at, read_at`, notificationID, customerID).Scan(
+		&notification.ItemID, &notification.Name, &notification.Quantity, &notification.CreatedAt, &notification.ReadAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrStockNotificationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &notification, nil
+}
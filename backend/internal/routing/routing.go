@@ -0,0 +1,205 @@
+package routing
+
+import (
+	"chainwave/backend/internal/geo"
+	"chainwave/backend/internal/models"
+	"errors"
+	"math"
+)
+
+// ErrInfeasible is returned when no order of stops keeps the vehicle within its capacity
+var ErrInfeasible = errors.New("shipments cannot be routed within the vehicle's capacity")
+
+// maxImprovementPasses bounds the 2-opt search on large itineraries
+const maxImprovementPasses = 100
+
+// stop is a pickup or drop of one shipment
+type stop struct {
+	shipment int
+	pickup   bool
+	point    geo.Point
+}
+
+// Plan orders the pickups and drops of a vehicle's shipments starting from its current position.
+// Every pickup comes before its drop and the load never exceeds capacity. Shipments already picked
+// up only need dropping and count towards the starting load. The route is built nearest-neighbour
+// first and then shortened with 2-opt moves that keep it feasible.
+func Plan(start geo.Point, capacity float64, shipments []models.ShipmentWithLocations) ([]models.RouteStop, error) {
+	stops := make([]stop, 0, 2*len(shipments))
+	var startLoad float64
+	for i, s := range shipments {
+		if s.Status == models.ShipmentStatusPickedUp {
+			startLoad += s.Weight
+		} else {
+			stops = append(stops, stop{shipment: i, pickup: true, point: locationPoint(s.Pickup)})
+		}
+		stops = append(stops, stop{shipment: i, point: locationPoint(s.Drop)})
+	}
+	if startLoad > capacity {
+		return nil, ErrInfeasible
+	}
+
+	p := planner{start: start, capacity: capacity, startLoad: startLoad, shipments: shipments}
+	route, err := p.nearestNeighbour(stops)
+	if err != nil {
+		return nil, err
+	}
+	route = p.twoOpt(route)
+	return p.describe(route), nil
+}
+
+type planner struct {
+	start     geo.Point
+	capacity  float64
+	startLoad float64
+	shipments []models.ShipmentWithLocations
+}
+
+// nearestNeighbour builds a route by always going to the closest stop that can be made next
+func (p planner) nearestNeighbour(stops []stop) ([]stop, error) {
+	remaining := append([]stop(nil), stops...)
+	route := make([]stop, 0, len(stops))
+	picked := p.initiallyPicked()
+	load := p.startLoad
+	position := p.start
+
+	for len(remaining) > 0 {
+		best := -1
+		bestDistance := math.Inf(1)
+		for i, s := range remaining {
+			if !p.canVisit(s, picked, load) {
+				continue
+			}
+			if d := position.Distance(s.point); d < bestDistance {
+				best, bestDistance = i, d
+			}
+		}
+		if best < 0 {
+			return nil, ErrInfeasible
+		}
+
+		next := remaining[best]
+		remaining = append(remaining[:best], remaining[best+1:]...)
+		route = append(route, next)
+		load = p.visit(next, picked, load)
+		position = next.point
+	}
+	return route, nil
+}
+
+// twoOpt repeatedly reverses segments of the route while that makes it shorter and keeps it feasible
+func (p planner) twoOpt(route []stop) []stop {
+	best := p.length(route)
+	for pass := 0; pass < maxImprovementPasses; pass++ {
+		improved := false
+		for i := 0; i < len(route)-1; i++ {
+			for j := i + 1; j < len(route); j++ {
+				candidate := reversed(route, i, j)
+				if !p.feasible(candidate) {
+					continue
+				}
+				if length := p.length(candidate); length < best-1e-9 {
+					route, best, improved = candidate, length, true
+				}
+			}
+		}
+		if !improved {
+			break
+		}
+	}
+	return route
+}
+
+func (p planner) initiallyPicked() map[int]bool {
+	picked := make(map[int]bool)
+	for i, s := range p.shipments {
+		if s.Status == models.ShipmentStatusPickedUp {
+			picked[i] = true
+		}
+	}
+	return picked
+}
+
+func (p planner) canVisit(s stop, picked map[int]bool, load float64) bool {
+	if s.pickup {
+		return load+p.shipments[s.shipment].Weight <= p.capacity
+	}
+	return picked[s.shipment]
+}
+
+func (p planner) visit(s stop, picked map[int]bool, load float64) float64 {
+	if s.pickup {
+		picked[s.shipment] = true
+		return load + p.shipments[s.shipment].Weight
+	}
+	return load - p.shipments[s.shipment].Weight
+}
+
+func (p planner) feasible(route []stop) bool {
+	picked := p.initiallyPicked()
+	load := p.startLoad
+	for _, s := range route {
+		if !p.canVisit(s, picked, load) {
+			return false
+		}
+		load = p.visit(s, picked, load)
+	}
+	return true
+}
+
+func (p planner) length(route []stop) float64 {
+	var total float64
+	position := p.start
+	for _, s := range route {
+		total += position.Distance(s.point)
+		position = s.point
+	}
+	return total
+}
+
+// describe turns a route into stops with the leg distance, running distance and load after each stop
+func (p planner) describe(route []stop) []models.RouteStop {
+	stops := make([]models.RouteStop, 0, len(route))
+	picked := p.initiallyPicked()
+	load := p.startLoad
+	position := p.start
+	var cumulative float64
+
+	for i, s := range route {
+		shipment := p.shipments[s.shipment]
+		distance := position.Distance(s.point)
+		cumulative += distance
+		load = p.visit(s, picked, load)
+
+		routeStop := models.RouteStop{
+			Sequence:           i + 1,
+			ShipmentID:         shipment.ID,
+			Type:               models.RouteStopDrop,
+			LocationID:         shipment.Drop.ID,
+			Latitude:           s.point.Latitude,
+			Longitude:          s.point.Longitude,
+			Distance:           distance,
+			CumulativeDistance: cumulative,
+			Load:               load,
+		}
+		if s.pickup {
+			routeStop.Type = models.RouteStopPickup
+			routeStop.LocationID = shipment.Pickup.ID
+		}
+		stops = append(stops, routeStop)
+		position = s.point
+	}
+	return stops
+}
+
+func reversed(route []stop, i, j int) []stop {
+	candidate := append([]stop(nil), route...)
+	for a, b := i, j; a < b; a, b = a+1, b-1 {
+		candidate[a], candidate[b] = candidate[b], candidate[a]
+	}
+	return candidate
+}
+
+func locationPoint(location models.Location) geo.Point {
+	return geo.Point{Latitude: location.Latitude, Longitude: location.Longitude}
+}
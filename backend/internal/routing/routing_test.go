@@ -0,0 +1,179 @@
+package routing
+
+import (
+	"chainwave/backend/internal/geo"
+	"chainwave/backend/internal/models"
+	"math"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// shipmentAlong is a shipment picked up and dropped along the meridian, at degrees of latitude
+func shipmentAlong(status string, weight, pickup, drop float64) models.ShipmentWithLocations {
+	return models.ShipmentWithLocations{
+		Shipment: models.Shipment{ID: uuid.New(), Status: status, Weight: weight},
+		Pickup:   models.Location{ID: uuid.New(), Latitude: pickup},
+		Drop:     models.Location{ID: uuid.New(), Latitude: drop},
+	}
+}
+
+// sequence names the stops of a route as "<shipment>+" for a pickup and "<shipment>-" for a drop
+func sequence(stops []models.RouteStop, shipments []models.ShipmentWithLocations) []string {
+	names := make(map[uuid.UUID]string)
+	for i, s := range shipments {
+		names[s.ID] = string(rune('a' + i))
+	}
+	got := make([]string, len(stops))
+	for i, stop := range stops {
+		got[i] = names[stop.ShipmentID] + "-"
+		if stop.Type == models.RouteStopPickup {
+			got[i] = names[stop.ShipmentID] + "+"
+		}
+	}
+	return got
+}
+
+func TestPlan(t *testing.T) {
+	const accepted, pickedUp = models.ShipmentStatusAccepted, models.ShipmentStatusPickedUp
+	tests := []struct {
+		name      string
+		capacity  float64
+		shipments []models.ShipmentWithLocations
+		want      []string
+		degrees   float64 // length of the route in degrees of latitude
+	}{
+		{
+			name:     "nearest first",
+			capacity: 100,
+			shipments: []models.ShipmentWithLocations{
+				shipmentAlong(accepted, 5, 0.3, 0.4),
+				shipmentAlong(accepted, 5, 0.1, 0.2),
+			},
+			want:    []string{"b+", "b-", "a+", "a-"},
+			degrees: 0.4,
+		},
+		{
+			// Nearest neighbour goes 1, -2, 5 for 11 degrees; reversing the first two legs makes it 9
+			name:     "2-opt shortens nearest neighbour",
+			capacity: 100,
+			shipments: []models.ShipmentWithLocations{
+				shipmentAlong(pickedUp, 5, 0, 1),
+				shipmentAlong(pickedUp, 5, 0, -2),
+				shipmentAlong(pickedUp, 5, 0, 5),
+			},
+			want:    []string{"b-", "a-", "c-"},
+			degrees: 9,
+		},
+		{
+			name:     "pickup before drop",
+			capacity: 100,
+			shipments: []models.ShipmentWithLocations{
+				shipmentAlong(accepted, 5, 1, 0.1),
+			},
+			want:    []string{"a+", "a-"},
+			degrees: 1.9,
+		},
+		{
+			name:     "pickups before drops across shipments",
+			capacity: 100,
+			shipments: []models.ShipmentWithLocations{
+				shipmentAlong(accepted, 5, 0.5, 0.1),
+				shipmentAlong(accepted, 5, 0.4, 0.2),
+			},
+			want:    []string{"b+", "a+", "b-", "a-"},
+			degrees: 0.9,
+		},
+		{
+			name:     "capacity allows one at a time",
+			capacity: 10,
+			shipments: []models.ShipmentWithLocations{
+				shipmentAlong(accepted, 6, 0.1, 0.3),
+				shipmentAlong(accepted, 6, 0.2, 0.4),
+			},
+			want:    []string{"a+", "a-", "b+", "b-"},
+			degrees: 0.6,
+		},
+		{
+			name:     "picked up load counts against capacity",
+			capacity: 10,
+			shipments: []models.ShipmentWithLocations{
+				shipmentAlong(pickedUp, 6, 0, 0.3),
+				shipmentAlong(accepted, 6, 0.1, 0.2),
+			},
+			want:    []string{"a-", "b+", "b-"},
+			degrees: 0.6,
+		},
+		{
+			name:    "no shipments",
+			want:    []string{},
+			degrees: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stops, err := Plan(geo.Point{}, tt.capacity, tt.shipments)
+			if err != nil {
+				t.Fatalf("Plan() error = %v", err)
+			}
+			got := sequence(stops, tt.shipments)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Plan() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("Plan() = %v, want %v", got, tt.want)
+				}
+			}
+
+			picked := make(map[uuid.UUID]bool)
+			for _, s := range tt.shipments {
+				picked[s.ID] = s.Status == pickedUp
+			}
+			var cumulative float64
+			for i, stop := range stops {
+				if stop.Sequence != i+1 {
+					t.Errorf("stop %d has sequence %d", i+1, stop.Sequence)
+				}
+				if stop.Type == models.RouteStopPickup {
+					picked[stop.ShipmentID] = true
+				} else if !picked[stop.ShipmentID] {
+					t.Errorf("stop %d drops a shipment before picking it up", stop.Sequence)
+				}
+				if stop.Load > tt.capacity {
+					t.Errorf("stop %d carries %v, over the capacity of %v", stop.Sequence, stop.Load, tt.capacity)
+				}
+				cumulative += stop.Distance
+				if math.Abs(stop.CumulativeDistance-cumulative) > 1e-9 {
+					t.Errorf("stop %d has cumulative distance %v, want %v", stop.Sequence, stop.CumulativeDistance, cumulative)
+				}
+			}
+			want := geo.Point{}.Distance(geo.Point{Latitude: tt.degrees})
+			if math.Abs(cumulative-want) > 0.01 {
+				t.Errorf("route is %.2f km, want %.2f km", cumulative, want)
+			}
+		})
+	}
+}
+
+func TestPlanInfeasible(t *testing.T) {
+	tests := []struct {
+		name      string
+		shipments []models.ShipmentWithLocations
+	}{
+		{"heavier than the vehicle", []models.ShipmentWithLocations{
+			shipmentAlong(models.ShipmentStatusAccepted, 12, 0.1, 0.2),
+		}},
+		{"picked up load over capacity", []models.ShipmentWithLocations{
+			shipmentAlong(models.ShipmentStatusPickedUp, 6, 0, 0.2),
+			shipmentAlong(models.ShipmentStatusPickedUp, 6, 0, 0.3),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if stops, err := Plan(geo.Point{}, 10, tt.shipments); err != ErrInfeasible {
+				t.Errorf("Plan() = %v, %v, want %v", stops, err, ErrInfeasible)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+// Package shipping prices parcels with distance banded tariffs, charging the greater of the actual
+// and the volumetric weight.
+package shipping
+
+import (
+	"chainwave/backend/internal/models"
+	"chainwave/backend/internal/money"
+	"errors"
+	"math"
+	"sort"
+	"time"
+)
+
+// VolumetricDivisor converts a volume in cubic centimetres to a volumetric weight in kilograms
+const VolumetricDivisor = 5000.0
+
+// ErrNotServiceable is returned when no tariff covers the distance of a parcel
+var ErrNotServiceable = errors.New("no shipping tariff serves this distance")
+
+// Parcel is the goods one seller ships in a single package
+type Parcel struct {
+	Weight float64 // kg
+	Volume float64 // cm³
+}
+
+// Add puts quantity units of an item with the given unit weight in kilograms and dimensions in
+// centimetres into the parcel
+func (p *Parcel) Add(weight float64, dimensions models.Dimensions, quantity int) {
+	p.Weight += weight * float64(quantity)
+	p.Volume += dimensions.Length * dimensions.Width * dimensions.Height * float64(quantity)
+}
+
+// VolumetricWeight returns the weight the parcel is charged at for the space it takes up
+func (p Parcel) VolumetricWeight() float64 {
+	return p.Volume / VolumetricDivisor
+}
+
+// ChargeableWeight returns the greater of the actual and the volumetric weight
+func (p Parcel) ChargeableWeight() float64 {
+	return math.Max(p.Weight, p.VolumetricWeight())
+}
+
+// Price returns the cost and transit time of shipping a parcel over a distance with a tariff, using
+// the narrowest band covering the distance. The cost is in the tariff's currency, rounded to its minor unit.
+func Price(tariff models.ShippingTariff, distanceKm float64, parcel Parcel) (cost money.Money, transitDays int, err error) {
+	var band *models.DistanceBand
+	for i := range tariff.Bands {
+		b := &tariff.Bands[i]
+		if b.MaxDistanceKm >= distanceKm && (band == nil || b.MaxDistanceKm < band.MaxDistanceKm) {
+			band = b
+		}
+	}
+	if band == nil {
+		return money.Money{}, 0, ErrNotServiceable
+	}
+
+	fee := tariff.BaseFee + band.Fee + tariff.PerKg*parcel.ChargeableWeight()
+	return money.FromMajor(fee, tariff.Currency), band.TransitDays, nil
+}
+
+// Quote prices a parcel with every tariff serving the distance, cheapest first and then fastest
+func Quote(tariffs []models.ShippingTariff, distanceKm float64, parcel Parcel, now time.Time) ([]models.ShippingQuote, error) {
+	quotes := make([]models.ShippingQuote, 0, len(tariffs))
+	for _, tariff := range tariffs {
+		cost, transitDays, err := Price(tariff, distanceKm, parcel)
+		if err == ErrNotServiceable {
+			continue
+		}
+		quotes = append(quotes, models.ShippingQuote{
+			TariffID:          tariff.ID,
+			TariffName:        tariff.Name,
+			TransporterID:     tariff.TransporterID,
+			DistanceKm:        distanceKm,
+			ChargeableWeight:  parcel.ChargeableWeight(),
+			Cost:              cost,
+			TransitDays:       transitDays,
+			EstimatedDelivery: now.AddDate(0, 0, transitDays),
+		})
+	}
+	if len(quotes) == 0 {
+		return nil, ErrNotServiceable
+	}
+
+	sort.SliceStable(quotes, func(i, j int) bool {
+		if quotes[i].Cost.Amount != quotes[j].Cost.Amount {
+			return quotes[i].Cost.Amount < quotes[j].Cost.Amount
+		}
+		return quotes[i].TransitDays < quotes[j].TransitDays
+	})
+	return quotes, nil
+}
@@ -0,0 +1,65 @@
+package shipping
+
+import (
+	"chainwave/backend/internal/models"
+	"chainwave/backend/internal/money"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestParcelChargeableWeight(t *testing.T) {
+	var parcel Parcel
+	parcel.Add(0.5, models.Dimensions{Length: 50, Width: 40, Height: 30}, 2)
+	if parcel.Weight != 1 {
+		t.Errorf("Weight = %v, want 1", parcel.Weight)
+	}
+	// 2 × 60000 cm³ / 5000 is 24 kg, which outweighs the actual 1 kg
+	if got := parcel.ChargeableWeight(); got != 24 {
+		t.Errorf("ChargeableWeight() = %v, want 24", got)
+	}
+
+	parcel.Add(30, models.Dimensions{}, 1)
+	if got := parcel.ChargeableWeight(); got != 31 {
+		t.Errorf("ChargeableWeight() = %v, want 31", got)
+	}
+}
+
+func TestQuote(t *testing.T) {
+	standard := models.ShippingTariff{ID: uuid.New(), Name: "Standard", Currency: "INR", BaseFee: 2, PerKg: 0.5, Bands: []models.DistanceBand{
+		{MaxDistanceKm: 1000, Fee: 15, TransitDays: 4},
+		{MaxDistanceKm: 50, Fee: 3, TransitDays: 1},
+	}}
+	express := models.ShippingTariff{ID: uuid.New(), Name: "Express", Currency: "INR", BaseFee: 5, PerKg: 1, Bands: []models.DistanceBand{
+		{MaxDistanceKm: 250, Fee: 5, TransitDays: 1},
+	}}
+	parcel := Parcel{Weight: 4}
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	quotes, err := Quote([]models.ShippingTariff{express, standard}, 30, parcel, now)
+	if err != nil {
+		t.Fatalf("Quote() error = %v", err)
+	}
+	if len(quotes) != 2 || quotes[0].TariffID != standard.ID {
+		t.Fatalf("Quote() = %+v, want standard first", quotes)
+	}
+	// The 50 km band is the narrowest covering 30 km: 2 + 3 + 0.5 × 4
+	if quotes[0].Cost != money.New(700, "INR") || quotes[0].TransitDays != 1 {
+		t.Errorf("standard quote = %+v, want 7 INR in 1 day", quotes[0])
+	}
+	if quotes[1].Cost != money.New(1400, "INR") {
+		t.Errorf("express cost = %v, want 14", quotes[1].Cost)
+	}
+	if !quotes[0].EstimatedDelivery.Equal(now.AddDate(0, 0, 1)) {
+		t.Errorf("EstimatedDelivery = %v", quotes[0].EstimatedDelivery)
+	}
+
+	quotes, err = Quote([]models.ShippingTariff{express, standard}, 600, parcel, now)
+	if err != nil || len(quotes) != 1 || quotes[0].TariffID != standard.ID {
+		t.Errorf("Quote() at 600 km = %+v, %v, want only standard", quotes, err)
+	}
+	if _, err := Quote([]models.ShippingTariff{express, standard}, 2000, parcel, now); err != ErrNotServiceable {
+		t.Errorf("Quote() at 2000 km error = %v, want %v", err, ErrNotServiceable)
+	}
+}
@@ -0,0 +1,50 @@
+package statemachine
+
+import "fmt"
+
+// Machine describes the states an entity may move between
+type Machine struct {
+	name        string
+	transitions map[string][]string
+}
+
+// TransitionError is returned when a transition is not allowed
+type TransitionError struct {
+	Machine string
+	From    string
+	To      string
+	Allowed []string
+}
+
+func (e *TransitionError) Error() string {
+	if len(e.Allowed) == 0 {
+		return fmt.Sprintf("%s cannot move from %q to %q: %q is a final state", e.Machine, e.From, e.To, e.From)
+	}
+	return fmt.Sprintf("%s cannot move from %q to %q, allowed next states are %q", e.Machine, e.From, e.To, e.Allowed)
+}
+
+// New creates a machine from a map of each state to the states it may move to
+func New(name string, transitions map[string][]string) *Machine {
+	return &Machine{name: name, transitions: transitions}
+}
+
+// Can reports whether moving from one state to another is allowed
+func (m *Machine) Can(from, to string) bool {
+	for _, next := range m.transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Transition returns a *TransitionError if moving from one state to another is not allowed
+func (m *Machine) Transition(from, to string) error {
+	if _, ok := m.transitions[from]; !ok {
+		return fmt.Errorf("%s has unknown state %q", m.name, from)
+	}
+	if !m.Can(from, to) {
+		return &TransitionError{Machine: m.name, From: from, To: to, Allowed: m.transitions[from]}
+	}
+	return nil
+}
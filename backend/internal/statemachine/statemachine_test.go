@@ -0,0 +1,87 @@
+package statemachine
+
+import (
+	"errors"
+	"testing"
+)
+
+var door = New("door", map[string][]string{
+	"open":   {"closed"},
+	"closed": {"open", "locked"},
+	"locked": {},
+})
+
+func TestCan(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{"open", "closed", true},
+		{"closed", "open", true},
+		{"closed", "locked", true},
+		{"open", "locked", false},
+		{"open", "open", false},
+		{"locked", "open", false},
+		{"ajar", "closed", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+" to "+tt.to, func(t *testing.T) {
+			if got := door.Can(tt.from, tt.to); got != tt.want {
+				t.Errorf("Can() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransition(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		allowed  []string // nil when the transition is allowed
+		message  string
+	}{
+		{name: "allowed", from: "closed", to: "locked"},
+		{
+			name: "not allowed", from: "open", to: "locked", allowed: []string{"closed"},
+			message: `door cannot move from "open" to "locked", allowed next states are ["closed"]`,
+		},
+		{
+			name: "final state", from: "locked", to: "open", allowed: []string{},
+			message: `door cannot move from "locked" to "open": "locked" is a final state`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := door.Transition(tt.from, tt.to)
+			if tt.allowed == nil {
+				if err != nil {
+					t.Errorf("Transition() error = %v, want nil", err)
+				}
+				return
+			}
+
+			var transitionErr *TransitionError
+			if !errors.As(err, &transitionErr) {
+				t.Fatalf("Transition() error = %v, want a *TransitionError", err)
+			}
+			if transitionErr.Machine != "door" || transitionErr.From != tt.from || transitionErr.To != tt.to ||
+				len(transitionErr.Allowed) != len(tt.allowed) {
+				t.Errorf("Transition() error = %+v", transitionErr)
+			}
+			if err.Error() != tt.message {
+				t.Errorf("Error() = %s, want %s", err, tt.message)
+			}
+		})
+	}
+}
+
+func TestTransitionFromUnknownState(t *testing.T) {
+	err := door.Transition("ajar", "closed")
+	var transitionErr *TransitionError
+	if err == nil || errors.As(err, &transitionErr) {
+		t.Fatalf("Transition() error = %v, want an unknown state error", err)
+	}
+	if want := `door has unknown state "ajar"`; err.Error() != want {
+		t.Errorf("Error() = %s, want %s", err, want)
+	}
+}
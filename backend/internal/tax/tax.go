@@ -0,0 +1,174 @@
+// Package tax works out the taxes charged on an order line from the rules of the buyer's jurisdiction.
+// Rules are chosen by where the buyer is, with a rule's scope able to tell sales within a state from sales
+// across states by where the seller ships from. Amounts are worked out in whole minor units.
+//
+// For a line:
+//   - every rule for the buyer's country, and for their state, applies and the two levels stack
+//   - within one jurisdiction, rules for the item's category replace the rules for all categories
+//   - inclusive rules are already part of the price and are taken out of it; exclusive rules are added
+//     on top of the price without inclusive tax
+package tax
+
+import (
+	"chainwave/backend/internal/money"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Scopes limiting a rule to sales within or across the buyer's state
+const (
+	ScopeAny        = "any"
+	ScopeIntrastate = "intrastate"
+	ScopeInterstate = "interstate"
+)
+
+var (
+	ErrNameRequired    = errors.New("tax rule name is required")
+	ErrCountryRequired = errors.New("tax rule country is required")
+	ErrInvalidRate     = errors.New("tax rate must be a percentage above 0 and at most 100")
+	ErrInvalidScope    = errors.New("tax rule scope must be any, intrastate or interstate")
+)
+
+// Place is the country and state a party is in, as entered on their location
+type Place struct {
+	Country string
+	State   string
+}
+
+// Rule is a tax levied by a jurisdiction. State and Category are empty for rules covering the whole
+// country and every category. Rate is a percentage.
+type Rule struct {
+	ID        uuid.UUID
+	Name      string
+	Country   string
+	State     string
+	Category  string
+	Scope     string
+	Rate      float64
+	Inclusive bool
+}
+
+// Validate checks a rule can be applied
+func (r Rule) Validate() error {
+	switch {
+	case strings.TrimSpace(r.Name) == "":
+		return ErrNameRequired
+	case strings.TrimSpace(r.Country) == "":
+		return ErrCountryRequired
+	case !(r.Rate > 0 && r.Rate <= 100):
+		return ErrInvalidRate
+	case r.Scope != ScopeAny && r.Scope != ScopeIntrastate && r.Scope != ScopeInterstate:
+		return ErrInvalidScope
+	}
+	return nil
+}
+
+// Jurisdiction names where a rule applies, such as "IN" or "IN/Karnataka"
+func (r Rule) Jurisdiction() string {
+	if r.State == "" {
+		return r.Country
+	}
+	return r.Country + "/" + r.State
+}
+
+// Charge is one rule's tax on a line. Taxable is the amount the rate was applied to.
+type Charge struct {
+	Rule    Rule
+	Taxable money.Money
+	Amount  money.Money
+}
+
+// Line is the tax on an order line. Net is the line without tax, Tax every charge on it, Added the
+// exclusive part charged on top of the price and Gross what the customer pays for the line.
+type Line struct {
+	Net     money.Money
+	Charges []Charge
+	Tax     money.Money
+	Added   money.Money
+	Gross   money.Money
+}
+
+// Applicable picks the rules that apply to an item of a category sold from seller to buyer
+func Applicable(rules []Rule, seller, buyer Place, category string) []Rule {
+	candidates := make([]Rule, 0)
+	specific := make(map[string]bool)
+	for _, r := range rules {
+		if !same(r.Country, buyer.Country) || (r.State != "" && !same(r.State, buyer.State)) {
+			continue
+		}
+		if r.Category != "" && !same(r.Category, category) {
+			continue
+		}
+		intrastate := same(seller.Country, buyer.Country) && same(seller.State, buyer.State)
+		interstate := same(seller.Country, buyer.Country) && !same(seller.State, buyer.State)
+		if (r.Scope == ScopeIntrastate && !intrastate) || (r.Scope == ScopeInterstate && !interstate) {
+			continue
+		}
+		candidates = append(candidates, r)
+		if r.Category != "" {
+			specific[jurisdictionKey(r)] = true
+		}
+	}
+
+	applicable := make([]Rule, 0, len(candidates))
+	for _, r := range candidates {
+		if r.Category != "" || !specific[jurisdictionKey(r)] {
+			applicable = append(applicable, r)
+		}
+	}
+	sort.SliceStable(applicable, func(i, j int) bool {
+		if (applicable[i].State == "") != (applicable[j].State == "") {
+			return applicable[i].State == ""
+		}
+		return applicable[i].Name < applicable[j].Name
+	})
+	return applicable
+}
+
+// Calculate works out the tax on a line amount, which is what the line comes to after discounts
+func Calculate(amount money.Money, rules []Rule) Line {
+	var inclusiveRate float64
+	inclusive := 0
+	for _, r := range rules {
+		if r.Inclusive {
+			inclusiveRate += r.Rate
+			inclusive++
+		}
+	}
+
+	// Take the inclusive taxes out of the price, giving the last of them whatever rounding leaves over
+	net := money.New(int64(math.Round(float64(amount.Amount)/(1+inclusiveRate/100))), amount.Currency)
+	included := amount.Amount - net.Amount
+
+	line := Line{Net: net, Charges: make([]Charge, 0, len(rules)), Tax: money.New(0, amount.Currency), Added: money.New(0, amount.Currency)}
+	for _, r := range rules {
+		charge := Charge{Rule: r, Taxable: net, Amount: net.Percent(r.Rate)}
+		if r.Inclusive {
+			inclusive--
+			if inclusive == 0 {
+				charge.Amount = money.New(included, amount.Currency)
+			}
+			included -= charge.Amount.Amount
+		} else {
+			line.Added.Amount += charge.Amount.Amount
+		}
+		line.Tax.Amount += charge.Amount.Amount
+		line.Charges = append(line.Charges, charge)
+	}
+	line.Gross = money.New(amount.Amount+line.Added.Amount, amount.Currency)
+	return line
+}
+
+// jurisdictionKey identifies a rule's jurisdiction regardless of how its names are capitalised
+func jurisdictionKey(r Rule) string {
+	return strings.ToLower(strings.TrimSpace(r.Country)) + "/" + strings.ToLower(strings.TrimSpace(r.State))
+}
+
+// same compares place and category names the way people type them, ignoring case and surrounding spaces
+func same(a, b string) bool {
+	return strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b))
+}
@@ -0,0 +1,104 @@
+package tax
+
+import (
+	"chainwave/backend/internal/money"
+	"testing"
+)
+
+func TestCalculate(t *testing.T) {
+	cgst := Rule{Name: "CGST", Country: "IN", Scope: ScopeIntrastate, Rate: 9, Inclusive: true}
+	sgst := Rule{Name: "SGST", Country: "IN", Scope: ScopeIntrastate, Rate: 9, Inclusive: true}
+	vat := Rule{Name: "VAT", Country: "GB", Scope: ScopeAny, Rate: 10, Inclusive: true}
+	salesTax := Rule{Name: "Sales tax", Country: "US", Scope: ScopeAny, Rate: 18}
+	levy := Rule{Name: "Levy", Country: "GB", Scope: ScopeAny, Rate: 5}
+
+	tests := []struct {
+		name    string
+		amount  int64
+		rules   []Rule
+		net     int64
+		charges []int64
+		added   int64
+	}{
+		{"no rules", 10000, nil, 10000, []int64{}, 0},
+		// 10000 / 1.18 rounds to 8475 and leaves 1525 of tax; 9% of the net is 762.75, so the first charge
+		// rounds up and the last takes the remaining 762
+		{"inclusive remainder", 10000, []Rule{cgst, sgst}, 8475, []int64{763, 762}, 0},
+		{"inclusive exact", 11800, []Rule{cgst, sgst}, 10000, []int64{900, 900}, 0},
+		{"exclusive", 10000, []Rule{salesTax}, 10000, []int64{1800}, 1800},
+		{"inclusive and exclusive", 11000, []Rule{vat, levy}, 10000, []int64{1000, 500}, 500},
+		{"one minor unit", 1, []Rule{cgst, sgst}, 1, []int64{0, 0}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line := Calculate(money.New(tt.amount, "INR"), tt.rules)
+			if line.Net.Amount != tt.net {
+				t.Errorf("Net = %d, want %d", line.Net.Amount, tt.net)
+			}
+			if len(line.Charges) != len(tt.charges) {
+				t.Fatalf("got %d charges, want %d", len(line.Charges), len(tt.charges))
+			}
+			var tax int64
+			for i, charge := range line.Charges {
+				if charge.Amount.Amount != tt.charges[i] {
+					t.Errorf("charge %s = %d, want %d", charge.Rule.Name, charge.Amount.Amount, tt.charges[i])
+				}
+				tax += charge.Amount.Amount
+			}
+			if line.Tax.Amount != tax {
+				t.Errorf("Tax = %d, want the charges' sum %d", line.Tax.Amount, tax)
+			}
+			if line.Added.Amount != tt.added {
+				t.Errorf("Added = %d, want %d", line.Added.Amount, tt.added)
+			}
+			if line.Gross.Amount != tt.amount+tt.added {
+				t.Errorf("Gross = %d, want %d", line.Gross.Amount, tt.amount+tt.added)
+			}
+			if line.Net.Amount+line.Tax.Amount != line.Gross.Amount {
+				t.Errorf("Net %d + Tax %d != Gross %d", line.Net.Amount, line.Tax.Amount, line.Gross.Amount)
+			}
+		})
+	}
+}
+
+func TestApplicable(t *testing.T) {
+	rules := []Rule{
+		{Name: "IGST", Country: "IN", Scope: ScopeInterstate, Rate: 18},
+		{Name: "CGST", Country: "IN", Scope: ScopeIntrastate, Rate: 9},
+		{Name: "SGST", Country: "IN", State: "Karnataka", Scope: ScopeIntrastate, Rate: 9},
+		{Name: "Book GST", Country: "IN", Category: "Books", Scope: ScopeAny, Rate: 5},
+		{Name: "VAT", Country: "GB", Scope: ScopeAny, Rate: 20},
+	}
+	bengaluru := Place{Country: "IN", State: "Karnataka"}
+	mumbai := Place{Country: "IN", State: "Maharashtra"}
+
+	tests := []struct {
+		name     string
+		seller   Place
+		buyer    Place
+		category string
+		want     []string
+	}{
+		{"intrastate", bengaluru, Place{Country: " in ", State: "karnataka"}, "Toys", []string{"CGST", "SGST"}},
+		{"interstate", mumbai, bengaluru, "Toys", []string{"IGST"}},
+		{"category replaces country rules", bengaluru, bengaluru, "books", []string{"Book GST", "SGST"}},
+		{"other country", bengaluru, Place{Country: "US"}, "Toys", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Applicable(rules, tt.seller, tt.buyer, tt.category)
+			names := make([]string, len(got))
+			for i, r := range got {
+				names[i] = r.Name
+			}
+			if len(names) != len(tt.want) {
+				t.Fatalf("Applicable() = %v, want %v", names, tt.want)
+			}
+			for i := range names {
+				if names[i] != tt.want[i] {
+					t.Fatalf("Applicable() = %v, want %v", names, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package tracking
+
+import (
+	"chainwave/backend/internal/geo"
+	"chainwave/backend/internal/models"
+	"time"
+)
+
+// DefaultSpeedKmh is assumed when the trail is too short to measure the vehicle's speed
+const DefaultSpeedKmh = 40.0
+
+// speedWindow is how many of the most recent pings are used to measure speed
+const speedWindow = 10
+
+// AverageSpeed returns the vehicle's average speed in km/h over its most recent pings,
+// or DefaultSpeedKmh if it can't be measured. The trail must be ordered oldest first.
+func AverageSpeed(trail []models.VehiclePosition) float64 {
+	if len(trail) > speedWindow {
+		trail = trail[len(trail)-speedWindow:]
+	}
+	if len(trail) < 2 {
+		return DefaultSpeedKmh
+	}
+
+	var distance float64
+	for i := 1; i < len(trail); i++ {
+		distance += geo.Haversine(trail[i-1].Latitude, trail[i-1].Longitude, trail[i].Latitude, trail[i].Longitude)
+	}
+	hours := trail[len(trail)-1].RecordedAt.Sub(trail[0].RecordedAt).Hours()
+	// A stationary or barely moving vehicle would give a useless ETA, so fall back to the default
+	if hours <= 0 || distance/hours < 1 {
+		return DefaultSpeedKmh
+	}
+	return distance / hours
+}
+
+// RemainingDistance returns how far in km the vehicle still has to travel for a shipment in the given status.
+// An accepted shipment still has to be picked up, a picked up one only has to reach the drop.
+func RemainingDistance(status string, current *models.VehiclePosition, pickup, drop geo.Point) float64 {
+	trip := pickup.Distance(drop)
+	switch status {
+	case models.ShipmentStatusDelivered, models.ShipmentStatusCancelled:
+		return 0
+	case models.ShipmentStatusAccepted:
+		if current == nil {
+			return trip
+		}
+		return geo.Haversine(current.Latitude, current.Longitude, pickup.Latitude, pickup.Longitude) + trip
+	case models.ShipmentStatusPickedUp:
+		if current == nil {
+			return trip
+		}
+		return geo.Haversine(current.Latitude, current.Longitude, drop.Latitude, drop.Longitude)
+	default:
+		return trip
+	}
+}
+
+// Track builds the tracking view of a shipment from its vehicle's trail, ordered oldest first.
+// Until the vehicle pings, a shipment still under way is shown at the position stored on the vehicle, if any.
+// The ETA is only given once a vehicle is moving the shipment and its position is known. Without both
+// pickup and drop points there is no distance to go by, so neither the remaining distance nor the ETA is given.
+func Track(shipment models.Shipment, trail []models.VehiclePosition, stored *models.VehiclePosition, pickup, drop *geo.Point, now time.Time) models.ShipmentTracking {
+	active := shipment.Status == models.ShipmentStatusAccepted || shipment.Status == models.ShipmentStatusPickedUp
+	if len(trail) == 0 && active && stored != nil {
+		trail = []models.VehiclePosition{*stored}
+	}
+
+	view := models.ShipmentTracking{
+		ShipmentID: shipment.ID,
+		Status:     shipment.Status,
+		Trail:      trail,
+	}
+	if len(trail) > 0 {
+		current := trail[len(trail)-1]
+		view.CurrentPosition = &current
+	}
+
+	if pickup == nil || drop == nil {
+		return view
+	}
+	remaining := RemainingDistance(shipment.Status, view.CurrentPosition, *pickup, *drop)
+	view.RemainingDistance = &remaining
+
+	if active && view.CurrentPosition != nil {
+		hours := remaining / AverageSpeed(trail)
+		eta := now.Add(time.Duration(hours * float64(time.Hour)))
+		view.ETA = &eta
+	}
+	return view
+}
@@ -0,0 +1,180 @@
+package tracking
+
+import (
+	"chainwave/backend/internal/geo"
+	"chainwave/backend/internal/models"
+	"math"
+	"testing"
+	"time"
+)
+
+// degree is the length in km of one degree of longitude along the equator
+var degree = geo.Haversine(0, 0, 0, 1)
+
+var start = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// ping is a position on the equator at a longitude, the given number of hours after start
+func ping(longitude, hours float64) models.VehiclePosition {
+	return models.VehiclePosition{Longitude: longitude, RecordedAt: start.Add(time.Duration(hours * float64(time.Hour)))}
+}
+
+func point(longitude float64) *geo.Point {
+	return &geo.Point{Longitude: longitude}
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestAverageSpeed(t *testing.T) {
+	tests := []struct {
+		name  string
+		trail []models.VehiclePosition
+		want  float64
+	}{
+		{"no pings", nil, DefaultSpeedKmh},
+		{"one ping", []models.VehiclePosition{ping(0, 0)}, DefaultSpeedKmh},
+		{"a degree an hour", []models.VehiclePosition{ping(0, 0), ping(0.5, 0.5), ping(1, 1)}, degree},
+		{"stationary", []models.VehiclePosition{ping(1, 0), ping(1, 1)}, DefaultSpeedKmh},
+		{"barely moving", []models.VehiclePosition{ping(0, 0), ping(0.001, 1)}, DefaultSpeedKmh},
+		{"pings at the same time", []models.VehiclePosition{ping(0, 1), ping(1, 1)}, DefaultSpeedKmh},
+		{
+			// Only the last ten pings count, not the jumps before them
+			name: "recent pings only",
+			trail: []models.VehiclePosition{
+				ping(0, 0), ping(20, 1), ping(40, 2), ping(41, 3), ping(42, 4), ping(43, 5),
+				ping(44, 6), ping(45, 7), ping(46, 8), ping(47, 9), ping(48, 10), ping(49, 11),
+			},
+			want: degree,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AverageSpeed(tt.trail); !near(got, tt.want) {
+				t.Errorf("AverageSpeed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemainingDistance(t *testing.T) {
+	// Picked up one degree east of the vehicle and dropped two degrees further on
+	pickup, drop := geo.Point{Longitude: 1}, geo.Point{Longitude: 3}
+	current := ping(0, 0)
+	halfway := ping(2, 0)
+	tests := []struct {
+		name    string
+		status  string
+		current *models.VehiclePosition
+		degrees float64
+	}{
+		{"accepted goes by the pickup", models.ShipmentStatusAccepted, &current, 3},
+		{"accepted without a position", models.ShipmentStatusAccepted, nil, 2},
+		{"picked up heads for the drop", models.ShipmentStatusPickedUp, &halfway, 1},
+		{"picked up without a position", models.ShipmentStatusPickedUp, nil, 2},
+		{"pending is the trip", models.ShipmentStatusPending, &current, 2},
+		{"delivered", models.ShipmentStatusDelivered, &current, 0},
+		{"cancelled", models.ShipmentStatusCancelled, &current, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RemainingDistance(tt.status, tt.current, pickup, drop); !near(got, tt.degrees*degree) {
+				t.Errorf("RemainingDistance() = %v, want %v", got, tt.degrees*degree)
+			}
+		})
+	}
+}
+
+func TestTrack(t *testing.T) {
+	now := start.Add(2 * time.Hour)
+	stored := ping(0, 0)
+	tests := []struct {
+		name     string
+		status   string
+		trail    []models.VehiclePosition
+		stored   *models.VehiclePosition
+		drop     *geo.Point
+		position *float64 // longitude of the current position, nil when unknown
+		degrees  *float64 // remaining distance, nil when unknown
+		eta      *time.Duration
+	}{
+		{
+			name:    "no pings and no stored position",
+			status:  models.ShipmentStatusAccepted,
+			drop:    point(3),
+			degrees: float(2),
+		},
+		{
+			// Without pings the stored position stands in, at the default speed
+			name:     "no pings falls back to the stored position",
+			status:   models.ShipmentStatusAccepted,
+			stored:   &stored,
+			drop:     point(3),
+			position: float(0),
+			degrees:  float(3),
+			eta:      hours(3 * degree / DefaultSpeedKmh),
+		},
+		{
+			name:     "pings win over the stored position",
+			status:   models.ShipmentStatusPickedUp,
+			trail:    []models.VehiclePosition{ping(0, 0), ping(1, 1)},
+			stored:   &stored,
+			drop:     point(3),
+			position: float(1),
+			degrees:  float(2),
+			eta:      hours(2),
+		},
+		{
+			name:    "stored position not shown once delivered",
+			status:  models.ShipmentStatusDelivered,
+			stored:  &stored,
+			drop:    point(3),
+			degrees: float(0),
+		},
+		{
+			name:     "no drop coordinates",
+			status:   models.ShipmentStatusPickedUp,
+			trail:    []models.VehiclePosition{ping(0, 0), ping(1, 1)},
+			position: float(1),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shipment := models.Shipment{Status: tt.status}
+			view := Track(shipment, tt.trail, tt.stored, point(1), tt.drop, now)
+
+			switch {
+			case tt.position == nil && view.CurrentPosition != nil:
+				t.Errorf("CurrentPosition = %+v, want none", view.CurrentPosition)
+			case tt.position != nil && (view.CurrentPosition == nil || view.CurrentPosition.Longitude != *tt.position):
+				t.Errorf("CurrentPosition = %+v, want longitude %v", view.CurrentPosition, *tt.position)
+			}
+			if tt.position != nil && len(view.Trail) == 0 {
+				t.Error("Trail is empty, want the current position on it")
+			}
+
+			switch {
+			case tt.degrees == nil && view.RemainingDistance != nil:
+				t.Errorf("RemainingDistance = %v, want none", *view.RemainingDistance)
+			case tt.degrees != nil && (view.RemainingDistance == nil || !near(*view.RemainingDistance, *tt.degrees*degree)):
+				t.Errorf("RemainingDistance = %v, want %v", view.RemainingDistance, *tt.degrees*degree)
+			}
+
+			switch {
+			case tt.eta == nil && view.ETA != nil:
+				t.Errorf("ETA = %v, want none", view.ETA)
+			case tt.eta != nil && (view.ETA == nil || view.ETA.Sub(now.Add(*tt.eta)).Abs() > time.Second):
+				t.Errorf("ETA = %v, want %v", view.ETA, now.Add(*tt.eta))
+			}
+		})
+	}
+}
+
+func float(f float64) *float64 {
+	return &f
+}
+
+func hours(h float64) *time.Duration {
+	d := time.Duration(h * float64(time.Hour))
+	return &d
+}
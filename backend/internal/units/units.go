@@ -0,0 +1,160 @@
+// Package units parses item dimensions and converts lengths and weights between metric and imperial
+// units. Items are stored in centimetres and kilograms and converted for display.
+package units
+
+import (
+	"chainwave/backend/internal/models"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Length and weight units
+const (
+	Centimetre = "cm"
+	Inch       = "in"
+	Kilogram   = "kg"
+	Pound      = "lb"
+)
+
+// Unit systems a user can display items in
+const (
+	Metric   = "metric"
+	Imperial = "imperial"
+)
+
+const (
+	centimetresPerInch = 2.54
+	kilogramsPerPound  = 0.45359237
+)
+
+var (
+	ErrInvalidDimensions = errors.New("dimensions must be three positive numbers written as LxWxH")
+	ErrUnknownUnit       = errors.New("unknown unit")
+)
+
+// lengthUnits maps the spellings accepted in dimension strings to a length unit
+var lengthUnits = map[string]string{
+	"":       Centimetre,
+	"cm":     Centimetre,
+	"in":     Inch,
+	"inch":   Inch,
+	"inches": Inch,
+	`"`:      Inch,
+}
+
+// ParseDimensions parses a "LxWxH" string such as "30x20x10", "30 × 20 × 10 cm" or "12*8*4 in".
+// Dimensions without a unit are in centimetres.
+func ParseDimensions(s string) (models.Dimensions, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	s = strings.NewReplacer("×", "x", "*", "x").Replace(s)
+
+	// Split off a trailing unit
+	end := len(s)
+	for end > 0 && !(s[end-1] >= '0' && s[end-1] <= '9') && s[end-1] != '.' {
+		end--
+	}
+	unit, ok := lengthUnits[strings.TrimSpace(s[end:])]
+	if !ok {
+		return models.Dimensions{}, ErrUnknownUnit
+	}
+
+	parts := strings.Split(s[:end], "x")
+	if len(parts) != 3 {
+		return models.Dimensions{}, ErrInvalidDimensions
+	}
+	var values [3]float64
+	for i, part := range parts {
+		value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return models.Dimensions{}, ErrInvalidDimensions
+		}
+		values[i] = value
+	}
+
+	d := models.Dimensions{Length: values[0], Width: values[1], Height: values[2], Unit: unit}
+	if err := ValidateDimensions(d); err != nil {
+		return models.Dimensions{}, err
+	}
+	return d, nil
+}
+
+// ValidateDimensions checks that all three sides are positive and the unit is cm or in
+func ValidateDimensions(d models.Dimensions) error {
+	if d.Unit != Centimetre && d.Unit != Inch {
+		return ErrUnknownUnit
+	}
+	if d.Length <= 0 || d.Width <= 0 || d.Height <= 0 ||
+		math.IsInf(d.Length+d.Width+d.Height, 0) || math.IsNaN(d.Length+d.Width+d.Height) {
+		return ErrInvalidDimensions
+	}
+	return nil
+}
+
+// ConvertDimensions returns the dimensions in another length unit. Unknown dimensions, with no unit,
+// are returned unchanged.
+func ConvertDimensions(d models.Dimensions, unit string) (models.Dimensions, error) {
+	if d.Unit == "" || d.Unit == unit {
+		return d, nil
+	}
+	length, err := ConvertLength(d.Length, d.Unit, unit)
+	if err != nil {
+		return d, err
+	}
+	width, _ := ConvertLength(d.Width, d.Unit, unit)
+	height, _ := ConvertLength(d.Height, d.Unit, unit)
+	return models.Dimensions{Length: length, Width: width, Height: height, Unit: unit}, nil
+}
+
+// ConvertLength converts a length between centimetres and inches
+func ConvertLength(value float64, from, to string) (float64, error) {
+	switch {
+	case from == to && (from == Centimetre || from == Inch):
+		return value, nil
+	case from == Centimetre && to == Inch:
+		return round(value / centimetresPerInch), nil
+	case from == Inch && to == Centimetre:
+		return round(value * centimetresPerInch), nil
+	}
+	return 0, ErrUnknownUnit
+}
+
+// ConvertWeight converts a weight between kilograms and pounds
+func ConvertWeight(value float64, from, to string) (float64, error) {
+	switch {
+	case from == to && (from == Kilogram || from == Pound):
+		return value, nil
+	case from == Kilogram && to == Pound:
+		return round(value / kilogramsPerPound), nil
+	case from == Pound && to == Kilogram:
+		return round(value * kilogramsPerPound), nil
+	}
+	return 0, ErrUnknownUnit
+}
+
+// ValidSystem reports whether a unit system is metric or imperial
+func ValidSystem(system string) bool {
+	return system == Metric || system == Imperial
+}
+
+// LengthUnit returns the length unit of a unit system
+func LengthUnit(system string) string {
+	if system == Imperial {
+		return Inch
+	}
+	return Centimetre
+}
+
+// WeightUnit returns the weight unit of a unit system
+func WeightUnit(system string) string {
+	if system == Imperial {
+		return Pound
+	}
+	return Kilogram
+}
+
+// round keeps converted values to 3 decimal places so they don't display floating point noise
+func round(value float64) float64 {
+	return math.Round(value*1000) / 1000
+}
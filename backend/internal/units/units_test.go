@@ -0,0 +1,134 @@
+package units
+
+import (
+	"chainwave/backend/internal/models"
+	"testing"
+)
+
+func TestParseDimensions(t *testing.T) {
+	tests := []struct {
+		input string
+		want  models.Dimensions
+		err   error
+	}{
+		{"30x20x10", models.Dimensions{Length: 30, Width: 20, Height: 10, Unit: Centimetre}, nil},
+		{"30 × 20 × 10 cm", models.Dimensions{Length: 30, Width: 20, Height: 10, Unit: Centimetre}, nil},
+		{" 30 x 20 x 10cm ", models.Dimensions{Length: 30, Width: 20, Height: 10, Unit: Centimetre}, nil},
+		{"12*8*4 in", models.Dimensions{Length: 12, Width: 8, Height: 4, Unit: Inch}, nil},
+		{"12X8X4 Inches", models.Dimensions{Length: 12, Width: 8, Height: 4, Unit: Inch}, nil},
+		{`12x8x4"`, models.Dimensions{Length: 12, Width: 8, Height: 4, Unit: Inch}, nil},
+		{"1.5x2.25x0.5", models.Dimensions{Length: 1.5, Width: 2.25, Height: 0.5, Unit: Centimetre}, nil},
+
+		{"", models.Dimensions{}, ErrInvalidDimensions},
+		{"30x20", models.Dimensions{}, ErrInvalidDimensions},
+		{"30x20x10x5", models.Dimensions{}, ErrInvalidDimensions},
+		{"30xax10", models.Dimensions{}, ErrInvalidDimensions},
+		{"30x0x10", models.Dimensions{}, ErrInvalidDimensions},
+		{"-30x20x10", models.Dimensions{}, ErrInvalidDimensions},
+		{"1e400x20x10", models.Dimensions{}, ErrInvalidDimensions},
+		{"nanx20x10", models.Dimensions{}, ErrInvalidDimensions},
+		{"30x20x10 ft", models.Dimensions{}, ErrUnknownUnit},
+		{"30x20x10 m", models.Dimensions{}, ErrUnknownUnit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseDimensions(tt.input)
+			if err != tt.err {
+				t.Fatalf("ParseDimensions() error = %v, want %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseDimensions() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertLength(t *testing.T) {
+	tests := []struct {
+		value    float64
+		from, to string
+		want     float64
+		err      error
+	}{
+		{2.54, Centimetre, Inch, 1, nil},
+		{10, Centimetre, Inch, 3.937, nil},
+		{10, Inch, Centimetre, 25.4, nil},
+		{7, Centimetre, Centimetre, 7, nil},
+		{7, Inch, Inch, 7, nil},
+		{7, "m", Inch, 0, ErrUnknownUnit},
+		{7, Centimetre, Kilogram, 0, ErrUnknownUnit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+" to "+tt.to, func(t *testing.T) {
+			got, err := ConvertLength(tt.value, tt.from, tt.to)
+			if err != tt.err || got != tt.want {
+				t.Errorf("ConvertLength(%v) = %v, %v, want %v, %v", tt.value, got, err, tt.want, tt.err)
+			}
+		})
+	}
+}
+
+func TestConvertWeight(t *testing.T) {
+	tests := []struct {
+		value    float64
+		from, to string
+		want     float64
+		err      error
+	}{
+		{1, Kilogram, Pound, 2.205, nil},
+		{10, Pound, Kilogram, 4.536, nil},
+		{0.45359237, Kilogram, Pound, 1, nil},
+		{3, Kilogram, Kilogram, 3, nil},
+		{3, Pound, Pound, 3, nil},
+		{3, "oz", Kilogram, 0, ErrUnknownUnit},
+		{3, Pound, Inch, 0, ErrUnknownUnit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+" to "+tt.to, func(t *testing.T) {
+			got, err := ConvertWeight(tt.value, tt.from, tt.to)
+			if err != tt.err || got != tt.want {
+				t.Errorf("ConvertWeight(%v) = %v, %v, want %v, %v", tt.value, got, err, tt.want, tt.err)
+			}
+		})
+	}
+}
+
+func TestConvertDimensions(t *testing.T) {
+	tests := []struct {
+		name string
+		d    models.Dimensions
+		unit string
+		want models.Dimensions
+		err  error
+	}{
+		{"cm to in", models.Dimensions{Length: 25.4, Width: 5.08, Height: 2.54, Unit: Centimetre}, Inch,
+			models.Dimensions{Length: 10, Width: 2, Height: 1, Unit: Inch}, nil},
+		{"in to cm", models.Dimensions{Length: 10, Width: 2, Height: 1, Unit: Inch}, Centimetre,
+			models.Dimensions{Length: 25.4, Width: 5.08, Height: 2.54, Unit: Centimetre}, nil},
+		{"same unit", models.Dimensions{Length: 3, Width: 2, Height: 1, Unit: Inch}, Inch,
+			models.Dimensions{Length: 3, Width: 2, Height: 1, Unit: Inch}, nil},
+		{"unknown dimensions", models.Dimensions{}, Inch, models.Dimensions{}, nil},
+		{"unknown unit", models.Dimensions{Length: 3, Width: 2, Height: 1, Unit: Centimetre}, "ft",
+			models.Dimensions{Length: 3, Width: 2, Height: 1, Unit: Centimetre}, ErrUnknownUnit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ConvertDimensions(tt.d, tt.unit)
+			if err != tt.err || got != tt.want {
+				t.Errorf("ConvertDimensions() = %+v, %v, want %+v, %v", got, err, tt.want, tt.err)
+			}
+		})
+	}
+}
+
+func TestSystemUnits(t *testing.T) {
+	if LengthUnit(Metric) != Centimetre || WeightUnit(Metric) != Kilogram {
+		t.Errorf("metric units = %s, %s", LengthUnit(Metric), WeightUnit(Metric))
+	}
+	if LengthUnit(Imperial) != Inch || WeightUnit(Imperial) != Pound {
+		t.Errorf("imperial units = %s, %s", LengthUnit(Imperial), WeightUnit(Imperial))
+	}
+	if !ValidSystem(Metric) || !ValidSystem(Imperial) || ValidSystem("nautical") {
+		t.Error("ValidSystem() accepts the wrong systems")
+	}
+}